@@ -10,15 +10,12 @@
 package adb
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -26,6 +23,8 @@ import (
 	"syscall"
 	"time"
 
+	"GADS/client/hubclient"
+
 	"github.com/gobwas/ws"
 	"github.com/spf13/pflag"
 )
@@ -37,23 +36,55 @@ func Start(flags *pflag.FlagSet) {
 	udid, _ := flags.GetString("udid")
 	username, _ := flags.GetString("username")
 	password, _ := flags.GetString("password")
-	if password == "" {
-		password = os.Getenv("GADS_PASSWORD")
-	}
-	if password == "" {
-		log.Fatal("password is required: use --password flag or GADS_PASSWORD env var")
-	}
+	clientID, _ := flags.GetString("client-id")
+	clientSecret, _ := flags.GetString("client-secret")
+	tenant, _ := flags.GetString("tenant")
+	leaseTTL, _ := flags.GetInt("lease-ttl")
 	localPort, _ := flags.GetInt("port")
 
+	if clientID != "" {
+		if clientSecret == "" {
+			clientSecret = os.Getenv("GADS_CLIENT_SECRET")
+		}
+		if clientSecret == "" {
+			log.Fatal("client secret is required: use --client-secret flag or GADS_CLIENT_SECRET env var")
+		}
+	} else {
+		if username == "" {
+			log.Fatal("either --username and --password or --client-id and --client-secret are required")
+		}
+		if password == "" {
+			password = os.Getenv("GADS_PASSWORD")
+		}
+		if password == "" {
+			log.Fatal("password is required: use --password flag or GADS_PASSWORD env var")
+		}
+	}
+
 	hub = strings.TrimRight(hub, "/")
 
 	// Authenticate
-	token, err := authenticate(hub, username, password)
+	session, err := hubclient.NewSession(hub, hubclient.Credentials{
+		Username:     username,
+		Password:     password,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Tenant:       tenant,
+	})
 	if err != nil {
 		log.Fatalf("Authentication failed: %v", err)
 	}
 	log.Println("Authenticated successfully")
 
+	// Acquire the API lease so the tunnel does not need a remote control session
+	if leaseTTL > 0 {
+		expiresAt, err := session.LockDevice(udid, leaseTTL)
+		if err != nil {
+			log.Fatalf("Failed to acquire device lease: %v", err)
+		}
+		log.Printf("Device lease acquired until %s", expiresAt.Format(time.TimeOnly))
+	}
+
 	// Open local TCP listener
 	listenAddr := fmt.Sprintf("localhost:%d", localPort)
 	ln, err := net.Listen("tcp", listenAddr)
@@ -68,27 +99,31 @@ func Start(flags *pflag.FlagSet) {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	// Build WebSocket URL
-	wsScheme := "ws"
-	if strings.HasPrefix(hub, "https") {
-		wsScheme = "wss"
+	// Keep the token and the lease valid for long-running jobs
+	go session.KeepAlive(ctx)
+	if leaseTTL > 0 {
+		leaseLost := session.KeepLease(ctx, udid, leaseTTL)
+		go func() {
+			select {
+			case <-leaseLost:
+				log.Println("Device lease lost - the device is locked by another user")
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 	}
-	parsedHub, err := url.Parse(hub)
+
+	// Build WebSocket URL
+	wsURL, err := hubclient.WebSocketURL(hub, fmt.Sprintf("/devices/control/%s/adb-tunnel", udid))
 	if err != nil {
 		log.Fatalf("Invalid hub URL: %v", err)
 	}
 
-	wsURL := url.URL{
-		Scheme: wsScheme,
-		Host:   parsedHub.Host,
-		Path:   fmt.Sprintf("/devices/control/%s/adb-tunnel", udid),
-	}
-
 	log.Printf("ADB tunnel listening on %s", adbAddr)
 
 	// Verify the tunnel works before connecting ADB
 	log.Println("Verifying ADB tunnel connectivity...")
-	if err := verifyTunnel(ctx, wsURL.String(), token); err != nil {
+	if err := verifyTunnel(ctx, wsURL.String(), session.Token()); err != nil {
 		log.Fatalf("ADB tunnel not available: %v", err)
 	}
 	log.Println("ADB tunnel verified, connecting adb...")
@@ -130,7 +165,7 @@ func Start(flags *pflag.FlagSet) {
 				continue
 			}
 		}
-		go handleConnection(ctx, cancel, tcpConn, wsURL.String(), token)
+		go handleConnection(ctx, cancel, tcpConn, wsURL.String(), session.Token())
 	}
 }
 
@@ -162,7 +197,7 @@ func handleConnection(ctx context.Context, shutdown context.CancelFunc, tcpConn
 	if err != nil {
 		// If the tunnel is rejected (session ended, auth failed, etc.), shut down the client
 		friendlyErr := wsErrorToMessage(err)
-		log.Printf("Remote control session lost: %v", friendlyErr)
+		log.Printf("Device lock lost: %v", friendlyErr)
 		shutdown()
 		return
 	}
@@ -190,7 +225,7 @@ func wsErrorToMessage(err error) error {
 	errMsg := err.Error()
 	switch {
 	case strings.Contains(errMsg, "409"):
-		return fmt.Errorf("device requires an active remote control session - open the device in the web UI first")
+		return fmt.Errorf("device lock required - remote control the device in the web UI, acquire an API lease or use --lease-ttl")
 	case strings.Contains(errMsg, "401"):
 		return fmt.Errorf("authentication failed - check your credentials")
 	case strings.Contains(errMsg, "400"):
@@ -203,34 +238,3 @@ func wsErrorToMessage(err error) error {
 		return err
 	}
 }
-
-func authenticate(hub, username, password string) (string, error) {
-	payload, err := json.Marshal(map[string]string{"username": username, "password": password})
-	if err != nil {
-		return "", fmt.Errorf("failed to encode credentials: %w", err)
-	}
-	resp, err := http.Post(hub+"/authenticate", "application/json", bytes.NewReader(payload))
-	if err != nil {
-		return "", fmt.Errorf("Failed to post to authenticate endpoint - %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("auth returned %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result struct {
-		Success bool `json:"success"`
-		Result  struct {
-			AccessToken string `json:"access_token"`
-		} `json:"result"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode auth response: %v", err)
-	}
-	if result.Result.AccessToken == "" {
-		return "", fmt.Errorf("no access_token in auth response")
-	}
-	return result.Result.AccessToken, nil
-}
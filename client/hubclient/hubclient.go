@@ -0,0 +1,76 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package hubclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Authenticate logs in to the hub and returns the access token
+func Authenticate(hub, username, password string) (string, error) {
+	token, _, err := passwordToken(hub, username, password)
+	return token, err
+}
+
+// passwordToken logs in to the hub with username and password, returns the access token and its lifetime in seconds
+func passwordToken(hub, username, password string) (string, int, error) {
+	payload, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	resp, err := http.Post(hub+"/authenticate", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to post to authenticate endpoint - %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", 0, fmt.Errorf("auth returned %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+		Result  struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("failed to decode auth response: %v", err)
+	}
+	if result.Result.AccessToken == "" {
+		return "", 0, fmt.Errorf("no access_token in auth response")
+	}
+	return result.Result.AccessToken, result.Result.ExpiresIn, nil
+}
+
+// WebSocketURL returns the WebSocket URL of the path on the hub
+func WebSocketURL(hub, path string) (url.URL, error) {
+	parsedHub, err := url.Parse(hub)
+	if err != nil {
+		return url.URL{}, err
+	}
+	wsScheme := "ws"
+	if strings.HasPrefix(hub, "https") {
+		wsScheme = "wss"
+	}
+	return url.URL{
+		Scheme: wsScheme,
+		Host:   parsedHub.Host,
+		Path:   path,
+	}, nil
+}
@@ -0,0 +1,201 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package hubclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeviceLockedByOther is returned when the device lease cannot be acquired because another user holds the device
+var ErrDeviceLockedByOther = errors.New("device is locked by another user")
+
+// Credentials used to log in to the hub - either a username and password or client credentials
+type Credentials struct {
+	Username     string
+	Password     string
+	ClientID     string
+	ClientSecret string
+	Tenant       string
+}
+
+// Session keeps a valid hub access token for long-running clients
+type Session struct {
+	hub         string
+	credentials Credentials
+
+	mu        sync.RWMutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewSession logs in to the hub with the credentials
+func NewSession(hub string, credentials Credentials) (*Session, error) {
+	if credentials.ClientID == "" && credentials.Username == "" {
+		return nil, fmt.Errorf("either username and password or client id and client secret are required")
+	}
+	s := &Session{hub: hub, credentials: credentials}
+	if err := s.login(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token returns the current access token
+func (s *Session) Token() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token
+}
+
+func (s *Session) login() error {
+	var (
+		token     string
+		expiresIn int
+		err       error
+	)
+	if s.credentials.ClientID != "" {
+		token, expiresIn, err = clientCredentialsToken(s.hub, s.credentials.ClientID, s.credentials.ClientSecret, s.credentials.Tenant)
+	} else {
+		token, expiresIn, err = passwordToken(s.hub, s.credentials.Username, s.credentials.Password)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.token = token
+	s.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	s.mu.Unlock()
+	return nil
+}
+
+// KeepAlive renews the access token before it expires until the context is done
+func (s *Session) KeepAlive(ctx context.Context) {
+	for {
+		s.mu.RLock()
+		// Renew once 80% of the token lifetime has passed
+		wait := time.Until(s.expiresAt) * 4 / 5
+		s.mu.RUnlock()
+		if wait < 10*time.Second {
+			wait = 10 * time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if err := s.login(); err != nil {
+			log.Printf("Warning: failed to renew access token: %v", err)
+			continue
+		}
+		log.Println("Access token renewed")
+	}
+}
+
+// LockDevice acquires or extends the API lease of the device
+func (s *Session) LockDevice(udid string, ttlMinutes int) (time.Time, error) {
+	lockURL := fmt.Sprintf("%s/devices/control/%s/lock?ttl_minutes=%d", s.hub, url.PathEscape(udid), ttlMinutes)
+	req, err := http.NewRequest(http.MethodPost, lockURL, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to post to lock endpoint - %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return time.Time{}, ErrDeviceLockedByOther
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return time.Time{}, fmt.Errorf("lock returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		ExpiresAtMS int64 `json:"expires_at_ms"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode lock response: %v", err)
+	}
+	return time.UnixMilli(result.ExpiresAtMS), nil
+}
+
+// KeepLease extends the API lease of the device at half of its TTL until the context is done.
+// The returned channel is closed when the lease could not be extended because the device is locked by someone else.
+func (s *Session) KeepLease(ctx context.Context, udid string, ttlMinutes int) <-chan struct{} {
+	lost := make(chan struct{})
+	go func() {
+		interval := time.Duration(ttlMinutes) * time.Minute / 2
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+
+			expiresAt, err := s.LockDevice(udid, ttlMinutes)
+			if err != nil {
+				if errors.Is(err, ErrDeviceLockedByOther) {
+					close(lost)
+					return
+				}
+				log.Printf("Warning: failed to renew device lease: %v", err)
+				continue
+			}
+			log.Printf("Device lease renewed until %s", expiresAt.Format(time.TimeOnly))
+		}
+	}()
+	return lost
+}
+
+func clientCredentialsToken(hub, clientID, clientSecret, tenant string) (string, int, error) {
+	form := url.Values{"client_id": {clientID}, "client_secret": {clientSecret}}
+	if tenant != "" {
+		form.Set("tenant", tenant)
+	}
+	resp, err := http.PostForm(hub+"/oauth/token", form)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to post to oauth token endpoint - %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", 0, fmt.Errorf("oauth token returned %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("failed to decode oauth token response: %v", err)
+	}
+	if result.AccessToken == "" {
+		return "", 0, fmt.Errorf("no access_token in oauth token response")
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
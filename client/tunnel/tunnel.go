@@ -0,0 +1,169 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"GADS/client/hubclient"
+
+	"github.com/gobwas/ws"
+	"github.com/spf13/pflag"
+)
+
+func Start(flags *pflag.FlagSet) {
+	log.SetFlags(log.Ldate | log.Ltime)
+
+	hub, _ := flags.GetString("hub")
+	udid, _ := flags.GetString("udid")
+	username, _ := flags.GetString("username")
+	password, _ := flags.GetString("password")
+	if password == "" {
+		password = os.Getenv("GADS_PASSWORD")
+	}
+	if password == "" {
+		log.Fatal("password is required: use --password flag or GADS_PASSWORD env var")
+	}
+	target, _ := flags.GetString("target")
+	if target != "device" && target != "provider" {
+		log.Fatal("target must be `device` or `provider`")
+	}
+	remotePort, _ := flags.GetInt("remote-port")
+	if remotePort < 1 || remotePort > 65535 {
+		log.Fatal("remote-port must be between 1 and 65535")
+	}
+	localPort, _ := flags.GetInt("port")
+
+	hub = strings.TrimRight(hub, "/")
+
+	token, err := hubclient.Authenticate(hub, username, password)
+	if err != nil {
+		log.Fatalf("Authentication failed: %v", err)
+	}
+	log.Println("Authenticated successfully")
+
+	wsURL, err := hubclient.WebSocketURL(hub, fmt.Sprintf("/devices/control/%s/tunnel", udid))
+	if err != nil {
+		log.Fatalf("Invalid hub URL: %v", err)
+	}
+	wsURL.RawQuery = url.Values{"target": {target}, "port": {strconv.Itoa(remotePort)}}.Encode()
+
+	listenAddr := fmt.Sprintf("localhost:%d", localPort)
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", listenAddr, err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Verify the tunnel works before accepting connections
+	if err := verifyTunnel(ctx, wsURL.String(), token); err != nil {
+		log.Fatalf("Tunnel not available: %v", err)
+	}
+	log.Printf("Tunnel to %s port %d of device %s listening on %s", target, remotePort, udid, ln.Addr())
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		ln.Close()
+	}()
+
+	for {
+		tcpConn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				log.Printf("Accept error: %v", err)
+				continue
+			}
+		}
+		go handleConnection(ctx, cancel, tcpConn, wsURL.String(), token)
+	}
+}
+
+func dial(ctx context.Context, wsURL string, token string) (net.Conn, error) {
+	dialer := ws.Dialer{
+		Header: ws.HandshakeHeaderHTTP(http.Header{
+			"Authorization": []string{"Bearer " + token},
+		}),
+	}
+	conn, _, _, err := dialer.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, wsErrorToMessage(err)
+	}
+	return conn, nil
+}
+
+func verifyTunnel(ctx context.Context, wsURL string, token string) error {
+	conn, err := dial(ctx, wsURL, token)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+func handleConnection(ctx context.Context, shutdown context.CancelFunc, tcpConn net.Conn, wsURL string, token string) {
+	defer tcpConn.Close()
+
+	wsConn, err := dial(ctx, wsURL, token)
+	if err != nil {
+		// The hub rejects new tunnels once the device lock ends, nothing more can be relayed
+		log.Printf("Tunnel lost: %v", err)
+		shutdown()
+		return
+	}
+	defer wsConn.Close()
+
+	log.Printf("Connection from %s established", tcpConn.RemoteAddr())
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(wsConn, tcpConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(tcpConn, wsConn)
+		done <- struct{}{}
+	}()
+
+	<-done
+	log.Printf("Connection from %s closed", tcpConn.RemoteAddr())
+}
+
+func wsErrorToMessage(err error) error {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "409"):
+		return fmt.Errorf("device lock required - remote control the device or acquire an API lease first")
+	case strings.Contains(errMsg, "401"):
+		return fmt.Errorf("authentication failed - check your credentials")
+	case strings.Contains(errMsg, "400"):
+		return fmt.Errorf("bad request - device not found or invalid target port")
+	case strings.Contains(errMsg, "502"):
+		return fmt.Errorf("hub could not open the tunnel - check the provider is running and the port is reachable")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package usbmux
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"GADS/client/hubclient"
+
+	"github.com/gobwas/ws"
+	"github.com/spf13/pflag"
+	"howett.net/plist"
+)
+
+func Start(flags *pflag.FlagSet) {
+	log.SetFlags(log.Ldate | log.Ltime)
+
+	hub, _ := flags.GetString("hub")
+	udid, _ := flags.GetString("udid")
+	username, _ := flags.GetString("username")
+	password, _ := flags.GetString("password")
+	if password == "" {
+		password = os.Getenv("GADS_PASSWORD")
+	}
+	if password == "" {
+		log.Fatal("password is required: use --password flag or GADS_PASSWORD env var")
+	}
+	socket, _ := flags.GetString("socket")
+	if socket == "" {
+		socket = defaultSocket(udid)
+	}
+
+	hub = strings.TrimRight(hub, "/")
+
+	token, err := hubclient.Authenticate(hub, username, password)
+	if err != nil {
+		log.Fatalf("Authentication failed: %v", err)
+	}
+	log.Println("Authenticated successfully")
+
+	wsURL, err := hubclient.WebSocketURL(hub, fmt.Sprintf("/devices/control/%s/usbmux-tunnel", udid))
+	if err != nil {
+		log.Fatalf("Invalid hub URL: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	log.Println("Verifying usbmux tunnel connectivity...")
+	if err := verifyTunnel(ctx, wsURL.String(), token, udid); err != nil {
+		log.Fatalf("usbmux tunnel not available: %v", err)
+	}
+
+	// Same convention as USBMUXD_SOCKET_ADDRESS - `host:port` is a TCP socket, anything else a unix socket path
+	network := "unix"
+	if strings.Contains(socket, ":") {
+		network = "tcp"
+	} else {
+		os.Remove(socket)
+	}
+	ln, err := net.Listen(network, socket)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", socket, err)
+	}
+
+	log.Printf("usbmux tunnel for device %s listening on %s", udid, socket)
+	log.Printf("Point go-ios and libimobiledevice tools to it with: export USBMUXD_SOCKET_ADDRESS=%s", socket)
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				log.Printf("Accept error: %v", err)
+				continue
+			}
+		}
+		go handleConnection(ctx, cancel, conn, wsURL.String(), token)
+	}
+}
+
+func defaultSocket(udid string) string {
+	if runtime.GOOS == "windows" {
+		return "127.0.0.1:27015"
+	}
+	return fmt.Sprintf("%s/gads-usbmuxd-%s", os.TempDir(), udid)
+}
+
+func dial(ctx context.Context, wsURL string, token string) (net.Conn, error) {
+	dialer := ws.Dialer{
+		Header: ws.HandshakeHeaderHTTP(http.Header{
+			"Authorization": []string{"Bearer " + token},
+		}),
+	}
+	conn, _, _, err := dialer.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, wsErrorToMessage(err)
+	}
+	return conn, nil
+}
+
+// verifyTunnel lists the devices through the tunnel and checks the device is attached to its provider
+func verifyTunnel(ctx context.Context, wsURL string, token string, udid string) error {
+	conn, err := dial(ctx, wsURL, token)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request, err := plist.Marshal(map[string]any{
+		"MessageType":         "ListDevices",
+		"ProgName":            "gads-usbmux-tunnel",
+		"ClientVersionString": "gads",
+		"kLibUSBMuxVersion":   3,
+	}, plist.XMLFormat)
+	if err != nil {
+		return err
+	}
+	header := []uint32{uint32(16 + len(request)), 1, 8, 1}
+	if err := binary.Write(conn, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	if err := binary.Read(conn, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("failed to read device list: %w", err)
+	}
+	payload := make([]byte, header[0]-16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("failed to read device list: %w", err)
+	}
+	var response struct {
+		DeviceList []struct {
+			Properties struct {
+				SerialNumber string
+			}
+		}
+	}
+	if _, err := plist.Unmarshal(payload, &response); err != nil {
+		return fmt.Errorf("failed to decode device list: %w", err)
+	}
+	for _, device := range response.DeviceList {
+		if device.Properties.SerialNumber == udid {
+			return nil
+		}
+	}
+	return fmt.Errorf("device is not attached to its provider")
+}
+
+func handleConnection(ctx context.Context, shutdown context.CancelFunc, conn net.Conn, wsURL string, token string) {
+	defer conn.Close()
+
+	wsConn, err := dial(ctx, wsURL, token)
+	if err != nil {
+		// The hub rejects new tunnels once the device lock ends, nothing more can be relayed
+		log.Printf("usbmux tunnel lost: %v", err)
+		shutdown()
+		return
+	}
+	defer wsConn.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(wsConn, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, wsConn)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func wsErrorToMessage(err error) error {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "409"):
+		return fmt.Errorf("device lock required - remote control the device or acquire an API lease first")
+	case strings.Contains(errMsg, "401"):
+		return fmt.Errorf("authentication failed - check your credentials")
+	case strings.Contains(errMsg, "400"):
+		return fmt.Errorf("bad request - device not found or not an iOS device")
+	case strings.Contains(errMsg, "502"):
+		return fmt.Errorf("hub could not connect to provider - check if the provider is running")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProviderTokenPrefix is the prefix of the provider tokens generated by the hub
+const ProviderTokenPrefix = "gads_pt_"
+
+const (
+	ProviderTimestampHeader     = "X-GADS-Timestamp"
+	ProviderNonceHeader         = "X-GADS-Nonce"
+	ProviderContentSHA256Header = "X-GADS-Content-SHA256"
+	ProviderSignatureHeader     = "X-GADS-Signature"
+)
+
+// ProviderSignatureMaxSkew is how old a signed hub request can be before the provider rejects it
+const ProviderSignatureMaxSkew = 5 * time.Minute
+
+// signedBodyMemoryLimit is how much of a signed body is kept in memory, larger bodies such as app uploads are kept in a temporary file
+const signedBodyMemoryLimit = 8 << 20
+
+var (
+	ErrMissingProviderSignature  = errors.New("missing hub request signature")
+	ErrInvalidProviderSignature  = errors.New("invalid hub request signature")
+	ErrExpiredProviderSignature  = errors.New("expired hub request signature")
+	ErrReplayedProviderSignature = errors.New("replayed hub request signature")
+)
+
+// HashProviderToken returns the hash the hub stores the provider token by
+func HashProviderToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// ProviderSigningKey derives the key the hub signs its requests to the provider with from the provider token.
+// The hub only gets it when the provider authenticates and never stores it, so a leaked token hash cannot be used to forge hub requests.
+func ProviderSigningKey(token string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("gads-request-signing"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignProviderRequest signs a hub request to a provider with the provider signing key.
+// The signature covers the method, the request URI, the timestamp, a single-use nonce and the SHA-256 of the body,
+// so a captured request cannot be replayed or sent with another body. The body is buffered to hash it.
+func SignProviderRequest(req *http.Request, key string) error {
+	bodyHash, err := hashRequestBody(req)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(ProviderTimestampHeader, timestamp)
+	req.Header.Set(ProviderNonceHeader, hex.EncodeToString(nonce))
+	req.Header.Set(ProviderContentSHA256Header, bodyHash)
+	req.Header.Set(ProviderSignatureHeader, providerRequestSignature(key, req.Method, req.URL.RequestURI(), timestamp, req.Header.Get(ProviderNonceHeader), bodyHash))
+	return nil
+}
+
+// VerifyProviderRequest checks that a request received by a provider was signed by the hub with the provider signing key
+// and that its nonce was not used before
+func VerifyProviderRequest(req *http.Request, key string) error {
+	timestamp := req.Header.Get(ProviderTimestampHeader)
+	nonce := req.Header.Get(ProviderNonceHeader)
+	signature := req.Header.Get(ProviderSignatureHeader)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrMissingProviderSignature
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidProviderSignature
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > ProviderSignatureMaxSkew || skew < -ProviderSignatureMaxSkew {
+		return ErrExpiredProviderSignature
+	}
+
+	bodyHash, err := hashRequestBody(req)
+	if err != nil {
+		return err
+	}
+	expected := providerRequestSignature(key, req.Method, req.URL.RequestURI(), timestamp, nonce, bodyHash)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidProviderSignature
+	}
+	if !usedNonces.add(nonce, time.Unix(signedAt, 0)) {
+		return ErrReplayedProviderSignature
+	}
+	return nil
+}
+
+func providerRequestSignature(key, method, requestURI, timestamp, nonce, bodyHash string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + nonce + "\n" + bodyHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashRequestBody returns the hex SHA-256 of the request body and replaces the body so it can still be read
+func hashRequestBody(req *http.Request) (string, error) {
+	hash := sha256.New()
+	if req.Body == nil || req.Body == http.NoBody {
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	defer req.Body.Close()
+
+	var memory bytes.Buffer
+	size, err := io.CopyN(io.MultiWriter(&memory, hash), req.Body, signedBodyMemoryLimit+1)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if size <= signedBodyMemoryLimit {
+		data := memory.Bytes()
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	file, err := os.CreateTemp("", "gads-signed-body-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, &memory); err == nil {
+		_, err = io.Copy(io.MultiWriter(file, hash), req.Body)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	req.Body = &tempFileBody{file}
+	req.GetBody = nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// tempFileBody is a buffered request body that removes its temporary file when closed
+type tempFileBody struct {
+	*os.File
+}
+
+func (b *tempFileBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// nonceCache holds the nonces of the verified requests until they expire
+type nonceCache struct {
+	mu       sync.Mutex
+	nonces   map[string]time.Time
+	prunedAt time.Time
+}
+
+var usedNonces = &nonceCache{nonces: make(map[string]time.Time)}
+
+// add records the nonce, false if it was already used
+func (c *nonceCache) add(nonce string, signedAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.prunedAt) > time.Minute {
+		for usedNonce, expiresAt := range c.nonces {
+			if now.After(expiresAt) {
+				delete(c.nonces, usedNonce)
+			}
+		}
+		c.prunedAt = now
+	}
+	if _, used := c.nonces[nonce]; used {
+		return false
+	}
+	// Requests are accepted up to the max skew on each side of their timestamp
+	c.nonces[nonce] = signedAt.Add(ProviderSignatureMaxSkew)
+	return true
+}
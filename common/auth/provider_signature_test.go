@@ -0,0 +1,115 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashProviderToken(t *testing.T) {
+	hash := HashProviderToken(ProviderTokenPrefix + "token")
+	if len(hash) != 64 {
+		t.Errorf("Expected a hex SHA256 hash, got: %s", hash)
+	}
+	if hash != HashProviderToken(ProviderTokenPrefix+"token") || hash == HashProviderToken(ProviderTokenPrefix+"other") {
+		t.Errorf("Expected the hash to be stable and unique per token")
+	}
+}
+
+func TestProviderSigningKey(t *testing.T) {
+	token := ProviderTokenPrefix + "token"
+	key := ProviderSigningKey(token)
+	if key != ProviderSigningKey(token) || key == ProviderSigningKey(ProviderTokenPrefix+"other") {
+		t.Errorf("Expected the signing key to be stable and unique per token")
+	}
+	if key == HashProviderToken(token) {
+		t.Errorf("Expected the signing key to differ from the stored token hash")
+	}
+}
+
+func TestVerifyProviderRequest(t *testing.T) {
+	key := ProviderSigningKey(ProviderTokenPrefix + "secret")
+
+	req := httptest.NewRequest("POST", "http://provider:10001/device/udid/tap?x=1", nil)
+	if err := SignProviderRequest(req, key); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	if err := VerifyProviderRequest(req, ProviderSigningKey(ProviderTokenPrefix+"other")); !errors.Is(err, ErrInvalidProviderSignature) {
+		t.Errorf("Expected invalid signature for a different key, got: %v", err)
+	}
+	if err := VerifyProviderRequest(req, key); err != nil {
+		t.Fatalf("Expected signed request to verify, got: %v", err)
+	}
+
+	// The nonce can only be used once
+	if err := VerifyProviderRequest(req, key); !errors.Is(err, ErrReplayedProviderSignature) {
+		t.Errorf("Expected replayed signature, got: %v", err)
+	}
+
+	// The signature is bound to the method and the request URI
+	replayed := httptest.NewRequest("POST", "http://provider:10001/device/udid/reboot", nil)
+	replayed.Header = req.Header.Clone()
+	if err := VerifyProviderRequest(replayed, key); !errors.Is(err, ErrInvalidProviderSignature) {
+		t.Errorf("Expected invalid signature for a different path, got: %v", err)
+	}
+}
+
+func TestVerifyProviderRequest_MissingOrExpired(t *testing.T) {
+	key := ProviderSigningKey(ProviderTokenPrefix + "secret")
+
+	req := httptest.NewRequest("GET", "http://provider:10001/info", nil)
+	if err := VerifyProviderRequest(req, key); !errors.Is(err, ErrMissingProviderSignature) {
+		t.Errorf("Expected missing signature, got: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Add(-2*ProviderSignatureMaxSkew).Unix(), 10)
+	req.Header.Set(ProviderTimestampHeader, timestamp)
+	req.Header.Set(ProviderNonceHeader, "nonce")
+	req.Header.Set(ProviderSignatureHeader, providerRequestSignature(key, req.Method, req.URL.RequestURI(), timestamp, "nonce", ""))
+	if err := VerifyProviderRequest(req, key); !errors.Is(err, ErrExpiredProviderSignature) {
+		t.Errorf("Expected expired signature, got: %v", err)
+	}
+}
+
+func TestVerifyProviderRequest_Body(t *testing.T) {
+	key := ProviderSigningKey(ProviderTokenPrefix + "secret")
+
+	for _, size := range []int{10, signedBodyMemoryLimit + 10} {
+		body := bytes.Repeat([]byte("a"), size)
+		req := httptest.NewRequest("POST", "http://provider:10001/uploadFile", bytes.NewReader(body))
+		if err := SignProviderRequest(req, key); err != nil {
+			t.Fatalf("Failed to sign request: %v", err)
+		}
+
+		// The signature is bound to the body
+		tampered := httptest.NewRequest("POST", "http://provider:10001/uploadFile", strings.NewReader("other"))
+		tampered.Header = req.Header.Clone()
+		if err := VerifyProviderRequest(tampered, key); !errors.Is(err, ErrInvalidProviderSignature) {
+			t.Errorf("Expected invalid signature for a different body, got: %v", err)
+		}
+
+		// The body can still be read after it was hashed by the signer and the verifier
+		if err := VerifyProviderRequest(req, key); err != nil {
+			t.Fatalf("Expected signed request with a %d bytes body to verify, got: %v", size, err)
+		}
+		received, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil || !bytes.Equal(received, body) {
+			t.Errorf("Expected the %d bytes body to be preserved, got %d bytes - %v", size, len(received), err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package db
+
+import (
+	"GADS/common/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const discoveryRulesCollection = "discovery_rules"
+
+func (m *MongoStore) GetDiscoveryRules() ([]models.DiscoveryRule, error) {
+	coll := m.GetCollection(discoveryRulesCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return GetDocuments[models.DiscoveryRule](m.Ctx, coll, bson.M{}, opts)
+}
+
+func (m *MongoStore) AddDiscoveryRule(rule models.DiscoveryRule) error {
+	coll := m.GetCollection(discoveryRulesCollection)
+	return InsertDocument(m.Ctx, coll, rule)
+}
+
+func (m *MongoStore) DeleteDiscoveryRule(id string) error {
+	coll := m.GetCollection(discoveryRulesCollection)
+	result, err := coll.DeleteOne(m.Ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
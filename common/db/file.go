@@ -216,3 +216,21 @@ func (m *MongoStore) DownloadFile(fileName, downloadPath string) error {
 
 	return nil
 }
+
+// OpenFileDownloadStream opens a GridFS file by name, or by its hex ObjectID if fileID is provided.
+// Used by the hub to serve the provider files to providers that do not connect to MongoDB.
+func (m *MongoStore) OpenFileDownloadStream(fileName, fileID string) (*gridfs.DownloadStream, error) {
+	bucket, err := gridfs.NewBucket(m.GetDefaultDatabase(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileID != "" {
+		id, err := primitive.ObjectIDFromHex(fileID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse file id `%s` - %s", fileID, err)
+		}
+		return bucket.OpenDownloadStream(id)
+	}
+	return bucket.OpenDownloadStreamByName(fileName)
+}
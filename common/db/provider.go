@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func (m *MongoStore) GetProvider(providerNickname string) (models.Provider, error) {
@@ -48,6 +49,26 @@ func (m *MongoStore) UpdateProviderTimestamp(nickname string) error {
 	return PartialDocumentUpdate(m.Ctx, coll, filter, updates)
 }
 
+// SetProviderTokenHash stores the hash of a new provider token, replacing the previous token
+func (m *MongoStore) SetProviderTokenHash(nickname, tokenHash string) error {
+	coll := m.GetCollection("providers")
+	filter := bson.M{"nickname": nickname}
+	result, err := coll.UpdateOne(m.Ctx, filter, bson.M{"$set": bson.M{"token_hash": tokenHash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (m *MongoStore) GetProviderByTokenHash(tokenHash string) (models.Provider, error) {
+	coll := m.GetCollection("providers")
+	filter := bson.D{{Key: "token_hash", Value: tokenHash}}
+	return GetDocument[models.Provider](m.Ctx, coll, filter)
+}
+
 // This is a temporary function that will update all current provider configurations that do not have the new `setup_appium_servers` property.
 // It will set it to true by default so we do not break the setup for people that already have it from a previous version
 func (m *MongoStore) InitializeProviderSetupAppiumServers() (int64, error) {
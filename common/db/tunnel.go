@@ -0,0 +1,39 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package db
+
+import (
+	"GADS/common/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const tunnelAuditCollection = "tunnel_audit"
+
+// UpsertTunnelAuditLog stores the tunnel audit entry when the tunnel opens and updates it once it closes
+func (m *MongoStore) UpsertTunnelAuditLog(log models.TunnelAuditLog) error {
+	coll := m.GetCollection(tunnelAuditCollection)
+	return UpsertDocument(m.Ctx, coll, bson.M{"_id": log.ID}, log)
+}
+
+// GetTunnelAuditLogs returns the latest tunnel audit entries, optionally only for a single device
+func (m *MongoStore) GetTunnelAuditLogs(udid string, limit int) ([]models.TunnelAuditLog, error) {
+	coll := m.GetCollection(tunnelAuditCollection)
+	filter := bson.M{}
+	if udid != "" {
+		filter["udid"] = udid
+	}
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "opened_at", Value: -1}})
+	findOptions.SetLimit(int64(limit))
+
+	return GetDocuments[models.TunnelAuditLog](m.Ctx, coll, filter, findOptions)
+}
@@ -43,9 +43,12 @@ func (m *MongoStore) UpdateWorkspace(workspace *models.Workspace) error {
 	}
 	filter := bson.M{"_id": objectID}
 	update := bson.M{
-		"name":        workspace.Name,
-		"description": workspace.Description,
-		"tenant":      workspace.Tenant,
+		"name":                 workspace.Name,
+		"description":          workspace.Description,
+		"tenant":               workspace.Tenant,
+		"har_redacted_headers": workspace.HARRedactedHeaders,
+		"reboot_schedule":      workspace.RebootSchedule,
+		"appium_versions":      workspace.AppiumVersions,
 	}
 	return PartialDocumentUpdate(m.Ctx, coll, filter, update)
 }
@@ -0,0 +1,198 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mockdevice
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// AppiumServer is a stub Appium server. It creates and deletes W3C sessions, serves screenshots
+// of the mock screen and accepts every other session command without doing anything.
+type AppiumServer struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]any
+	// defaultCaps are merged into the capabilities of every session like Appium `--default-capabilities`
+	defaultCaps map[string]any
+	screen      *Screen
+	mux         *http.ServeMux
+
+	// OnSessionCreated and OnSessionDeleted are called like the GADS Appium plugin notifies the provider
+	OnSessionCreated func(sessionID string)
+	OnSessionDeleted func(sessionID string)
+}
+
+func NewAppiumServer(defaultCaps map[string]any, screen *Screen) *AppiumServer {
+	s := &AppiumServer{
+		sessions:    make(map[string]map[string]any),
+		defaultCaps: defaultCaps,
+		screen:      screen,
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /status", s.status)
+	s.mux.HandleFunc("POST /session", s.createSession)
+	s.mux.HandleFunc("GET /session/{id}", s.getSession)
+	s.mux.HandleFunc("DELETE /session/{id}", s.deleteSession)
+	s.mux.HandleFunc("GET /session/{id}/screenshot", s.screenshot)
+	s.mux.HandleFunc("/session/{id}/{command...}", s.command)
+	return s
+}
+
+func (s *AppiumServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// SessionIDs returns the IDs of the active sessions
+func (s *AppiumServer) SessionIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *AppiumServer) status(w http.ResponseWriter, r *http.Request) {
+	writeValue(w, http.StatusOK, map[string]any{
+		"ready":   true,
+		"message": "The GADS mock Appium server is ready to accept new sessions",
+		"build":   map[string]any{"version": "mock"},
+	})
+}
+
+// sessionRequest are the capability objects of a new session request, like in models.AppiumSession
+type sessionRequest struct {
+	Capabilities struct {
+		AlwaysMatch map[string]any   `json:"alwaysMatch"`
+		FirstMatch  []map[string]any `json:"firstMatch"`
+	} `json:"capabilities"`
+	DesiredCapabilities map[string]any `json:"desiredCapabilities"`
+}
+
+func (s *AppiumServer) createSession(w http.ResponseWriter, r *http.Request) {
+	var request sessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid argument", fmt.Sprintf("Failed to parse the session request - %s", err))
+		return
+	}
+
+	caps := maps.Clone(s.defaultCaps)
+	if caps == nil {
+		caps = make(map[string]any)
+	}
+	maps.Copy(caps, request.DesiredCapabilities)
+	maps.Copy(caps, request.Capabilities.AlwaysMatch)
+	if len(request.Capabilities.FirstMatch) > 0 {
+		maps.Copy(caps, request.Capabilities.FirstMatch[0])
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "session not created", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions[sessionID] = caps
+	s.mu.Unlock()
+	if s.OnSessionCreated != nil {
+		s.OnSessionCreated(sessionID)
+	}
+
+	writeValue(w, http.StatusOK, map[string]any{
+		"sessionId":    sessionID,
+		"capabilities": caps,
+	})
+}
+
+func (s *AppiumServer) getSession(w http.ResponseWriter, r *http.Request) {
+	caps, ok := s.session(r.PathValue("id"))
+	if !ok {
+		writeInvalidSession(w, r.PathValue("id"))
+		return
+	}
+	writeValue(w, http.StatusOK, caps)
+}
+
+func (s *AppiumServer) deleteSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	s.mu.Lock()
+	_, ok := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	if !ok {
+		writeInvalidSession(w, sessionID)
+		return
+	}
+	if s.OnSessionDeleted != nil {
+		s.OnSessionDeleted(sessionID)
+	}
+	writeValue(w, http.StatusOK, nil)
+}
+
+func (s *AppiumServer) screenshot(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.session(r.PathValue("id")); !ok {
+		writeInvalidSession(w, r.PathValue("id"))
+		return
+	}
+	frame, err := s.screen.Frame(0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unknown error", err.Error())
+		return
+	}
+	writeValue(w, http.StatusOK, base64.StdEncoding.EncodeToString(frame))
+}
+
+func (s *AppiumServer) command(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.session(r.PathValue("id")); !ok {
+		writeInvalidSession(w, r.PathValue("id"))
+		return
+	}
+	writeValue(w, http.StatusOK, nil)
+}
+
+func (s *AppiumServer) session(sessionID string) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	caps, ok := s.sessions[sessionID]
+	return caps, ok
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID - %w", err)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func writeValue(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"value": value})
+}
+
+func writeError(w http.ResponseWriter, status int, errorCode, message string) {
+	writeValue(w, status, map[string]any{
+		"error":      errorCode,
+		"message":    message,
+		"stacktrace": "",
+	})
+}
+
+func writeInvalidSession(w http.ResponseWriter, sessionID string) {
+	writeError(w, http.StatusNotFound, "invalid session id", fmt.Sprintf("A session with ID `%s` is either terminated or not started", sessionID))
+}
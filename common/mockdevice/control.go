@@ -0,0 +1,168 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mockdevice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Command is a remote control command received by a mock device
+type Command struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// Controller handles the commands of the GADS Android remote server on a mock screen,
+// so the provider controls mock devices with the same requests as Android devices
+type Controller struct {
+	screen    *Screen
+	mu        sync.Mutex
+	clipboard string
+	mux       *http.ServeMux
+
+	// OnCommand is called with every received command, e.g. to log it
+	OnCommand func(command Command)
+}
+
+func NewController(screen *Screen) *Controller {
+	c := &Controller{
+		screen: screen,
+		mux:    http.NewServeMux(),
+	}
+	c.mux.HandleFunc("POST /tap", c.touchCommand("tap", "x", "y"))
+	c.mux.HandleFunc("POST /doubleTap", c.touchCommand("doubleTap", "x", "y"))
+	c.mux.HandleFunc("POST /touchAndHold", c.touchCommand("touchAndHold", "x", "y"))
+	c.mux.HandleFunc("POST /swipe", c.touchCommand("swipe", "x2", "y2"))
+	c.mux.HandleFunc("POST /pinch", c.touchCommand("pinch", "centerX", "centerY"))
+	c.mux.HandleFunc("POST /home", c.command("home"))
+	c.mux.HandleFunc("POST /lock", c.lock(true))
+	c.mux.HandleFunc("POST /unlock", c.lock(false))
+	c.mux.HandleFunc("POST /type", c.typeText)
+	c.mux.HandleFunc("POST /clipboard", c.getClipboard)
+	return c
+}
+
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+func (c *Controller) handle(command Command) {
+	if c.OnCommand != nil {
+		c.OnCommand(command)
+	}
+}
+
+// touchCommand handles a gesture, the screen is marked at the point of the given parameters
+func (c *Controller) touchCommand(name, xParam, yParam string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := readParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		x, xOK := params[xParam].(float64)
+		y, yOK := params[yParam].(float64)
+		if !xOK || !yOK {
+			http.Error(w, fmt.Sprintf("`%s` and `%s` are required", xParam, yParam), http.StatusBadRequest)
+			return
+		}
+		c.screen.Touch(int(x), int(y))
+		c.handle(Command{Name: name, Params: params})
+		w.Write([]byte("OK"))
+	}
+}
+
+func (c *Controller) command(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.handle(Command{Name: name})
+		w.Write([]byte("OK"))
+	}
+}
+
+func (c *Controller) lock(locked bool) http.HandlerFunc {
+	name := "unlock"
+	if locked {
+		name = "lock"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.screen.SetLocked(locked)
+		c.handle(Command{Name: name})
+		w.Write([]byte("OK"))
+	}
+}
+
+func (c *Controller) typeText(w http.ResponseWriter, r *http.Request) {
+	params, err := readParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text, _ := params["text"].(string)
+	// There is no text field on the mock screen so the typed text ends up in the clipboard to be checked
+	c.mu.Lock()
+	c.clipboard = text
+	c.mu.Unlock()
+	c.handle(Command{Name: "type", Params: params})
+	w.Write([]byte("OK"))
+}
+
+func (c *Controller) getClipboard(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	clipboard := c.clipboard
+	c.mu.Unlock()
+	c.handle(Command{Name: "clipboard"})
+	w.Write([]byte(clipboard))
+}
+
+func readParams(r *http.Request) (map[string]any, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body - %w", err)
+	}
+	params := make(map[string]any)
+	if len(body) == 0 {
+		return params, nil
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse request body - %w", err)
+	}
+	return params, nil
+}
+
+// StreamHandler streams the screen as JPEG frames over a websocket like the GADS Android stream,
+// fps and quality are read for every frame so stream settings apply right away
+func StreamHandler(screen *Screen, fps func() int, quality func() int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			frame, err := screen.Frame(quality())
+			if err != nil {
+				return
+			}
+			if err := wsutil.WriteServerBinary(conn, frame); err != nil {
+				return
+			}
+			time.Sleep(time.Second / time.Duration(max(fps(), 1)))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mockdevice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppiumServerSessionLifecycle(t *testing.T) {
+	appium := NewAppiumServer(map[string]any{"appium:udid": "mock-1"}, NewScreen(320, 640))
+	var created, deleted string
+	appium.OnSessionCreated = func(sessionID string) { created = sessionID }
+	appium.OnSessionDeleted = func(sessionID string) { deleted = sessionID }
+	server := httptest.NewServer(appium)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/session", "application/json", strings.NewReader(`{"capabilities":{"alwaysMatch":{"platformName":"mock"}}}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var session struct {
+		Value struct {
+			SessionID    string         `json:"sessionId"`
+			Capabilities map[string]any `json:"capabilities"`
+		} `json:"value"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	sessionID := session.Value.SessionID
+	assert.NotEmpty(t, sessionID)
+	assert.Equal(t, sessionID, created)
+	assert.Equal(t, "mock", session.Value.Capabilities["platformName"])
+	assert.Equal(t, "mock-1", session.Value.Capabilities["appium:udid"])
+
+	commandResp, err := http.Post(server.URL+"/session/"+sessionID+"/element", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	commandResp.Body.Close()
+	assert.Equal(t, http.StatusOK, commandResp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/session/"+sessionID, nil)
+	deleteResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+	assert.Equal(t, http.StatusOK, deleteResp.StatusCode)
+	assert.Equal(t, sessionID, deleted)
+	assert.Empty(t, appium.SessionIDs())
+
+	staleResp, err := http.Get(server.URL + "/session/" + sessionID + "/url")
+	require.NoError(t, err)
+	staleResp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, staleResp.StatusCode)
+}
+
+func TestControllerCommands(t *testing.T) {
+	screen := NewScreen(320, 640)
+	controller := NewController(screen)
+	var commands []Command
+	controller.OnCommand = func(command Command) { commands = append(commands, command) }
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		controller.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post("/tap", `{"x":10,"y":20}`).Code)
+	touch, ok := screen.LastTouch()
+	assert.True(t, ok)
+	assert.Equal(t, 10, touch.X)
+	assert.Equal(t, 20, touch.Y)
+
+	assert.Equal(t, http.StatusOK, post("/swipe", `{"x1":10,"y1":20,"x2":100,"y2":200,"duration":500}`).Code)
+	touch, _ = screen.LastTouch()
+	assert.Equal(t, 100, touch.X)
+
+	assert.Equal(t, http.StatusBadRequest, post("/tap", `{"x":10}`).Code)
+
+	assert.Equal(t, http.StatusOK, post("/type", `{"text":"hello"}`).Code)
+	assert.Equal(t, "hello", post("/clipboard", "").Body.String())
+
+	var names []string
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	assert.Equal(t, []string{"tap", "swipe", "type", "clipboard"}, names)
+}
+
+func TestStreamHandler(t *testing.T) {
+	screen := NewScreen(320, 640)
+	server := httptest.NewServer(StreamHandler(screen, func() int { return 30 }, func() int { return 50 }))
+	defer server.Close()
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for range 2 {
+		frame, err := wsutil.ReadServerBinary(conn)
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		require.NoError(t, err)
+		assert.Equal(t, 320, img.Bounds().Dx())
+		assert.Equal(t, 640, img.Bounds().Dy())
+	}
+}
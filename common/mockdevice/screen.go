@@ -0,0 +1,113 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+// Package mockdevice fakes a remote controllable device without hardware or vendor tools.
+// It is the backend of the provider `mock` OS and of the hub end-to-end tests - a synthetic screen
+// that is streamed as JPEG frames, a control server that accepts the Android remote server commands
+// and a stub Appium server that creates sessions.
+package mockdevice
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"sync"
+)
+
+// touchMarkerSize is the size of the square drawn where the screen was last touched
+const touchMarkerSize = 24
+
+// Screen is the synthetic display of a mock device.
+// Every frame moves a bar down the screen and marks the last touch so a stream shows both
+// that it is live and that the commands reach the device.
+type Screen struct {
+	mu        sync.Mutex
+	width     int
+	height    int
+	frame     int
+	lastTouch *image.Point
+	locked    bool
+}
+
+func NewScreen(width, height int) *Screen {
+	return &Screen{width: width, height: height}
+}
+
+// Size returns the screen width and height in pixels
+func (s *Screen) Size() (int, int) {
+	return s.width, s.height
+}
+
+// Touch marks the point where the screen was touched last
+func (s *Screen) Touch(x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTouch = &image.Point{X: x, Y: y}
+}
+
+// LastTouch returns the point where the screen was touched last
+func (s *Screen) LastTouch() (image.Point, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastTouch == nil {
+		return image.Point{}, false
+	}
+	return *s.lastTouch, true
+}
+
+// SetLocked blanks the screen while the device is locked
+func (s *Screen) SetLocked(locked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locked = locked
+}
+
+// Frame renders the next frame as a JPEG
+func (s *Screen) Frame(quality int) ([]byte, error) {
+	s.mu.Lock()
+	s.frame++
+	frame := s.frame
+	lastTouch := s.lastTouch
+	locked := s.locked
+	s.mu.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	if !locked {
+		background := color.RGBA{R: 32, G: 48, B: uint8(64 + frame%64), A: 255}
+		fillRect(img, img.Bounds(), background)
+
+		barHeight := max(s.height/40, 4)
+		barY := (frame * barHeight) % s.height
+		fillRect(img, image.Rect(0, barY, s.width, barY+barHeight), color.RGBA{R: 120, G: 200, B: 255, A: 255})
+
+		if lastTouch != nil {
+			marker := image.Rect(lastTouch.X-touchMarkerSize/2, lastTouch.Y-touchMarkerSize/2, lastTouch.X+touchMarkerSize/2, lastTouch.Y+touchMarkerSize/2)
+			fillRect(img, marker, color.RGBA{R: 255, G: 80, B: 80, A: 255})
+		}
+	}
+
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
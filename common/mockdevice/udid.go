@@ -0,0 +1,38 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mockdevice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CountHeader is the header the provider reports its `--mock-devices` count to the hub provider API with,
+// the hub only registers new mock devices for providers that send it
+const CountHeader = "X-GADS-Mock-Devices"
+
+// UDID returns the UDID of the n-th mock device of a provider, starting from 1
+func UDID(providerNickname string, n int) string {
+	return fmt.Sprintf("mock-%s-%d", providerNickname, n)
+}
+
+// IsProviderUDID reports whether the UDID belongs to one of the first `count` mock devices of the provider
+func IsProviderUDID(udid, providerNickname string, count int) bool {
+	index, found := strings.CutPrefix(udid, fmt.Sprintf("mock-%s-", providerNickname))
+	if !found {
+		return false
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil {
+		return false
+	}
+	return n >= 1 && n <= count && UDID(providerNickname, n) == udid
+}
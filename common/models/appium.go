@@ -58,6 +58,8 @@ type AppiumTypeText struct {
 
 type AndroidKeycodePayload struct {
 	Keycode int `json:"keycode"`
+	// Key is a platform independent key name like back, volume_up or enter, used by the provider key endpoint instead of Keycode
+	Key string `json:"key,omitempty"`
 }
 
 type AppiumServerCapabilities struct {
@@ -77,6 +79,35 @@ type AppiumServerCapabilities struct {
 	DeviceAddress          string `json:"appium:deviceAddress,omitempty"`
 	DeviceHost             string `json:"appium:deviceHost,omitempty"`
 	ChromeDriverExecutable string `json:"appium:chromedriverExecutable,omitempty"`
+	RokuHost               string `json:"appium:rokuHost,omitempty"`
+	RokuEcpPort            int    `json:"appium:rokuEcpPort,omitempty"`
+	RokuWebPort            int    `json:"appium:rokuWebPort,omitempty"`
+	RokuUser               string `json:"appium:rokuUser,omitempty"`
+	RokuPass               string `json:"appium:rokuPass,omitempty"`
+}
+
+// AppiumVersions pins the Appium server and driver versions the provider installs for its devices
+// in an isolated APPIUM_HOME, devices without pinned versions use the Appium installed on the provider host
+type AppiumVersions struct {
+	// Appium is the Appium server version, empty uses the Appium installed on the provider host with the pinned drivers
+	Appium string `json:"appium,omitempty" bson:"appium,omitempty" example:"2.11.3"`
+	// Drivers are the driver versions by driver name, e.g. `uiautomator2`, or by npm package for third-party drivers
+	Drivers map[string]string `json:"drivers,omitempty" bson:"drivers,omitempty"`
+}
+
+// IsEmpty reports whether no versions are pinned
+func (v *AppiumVersions) IsEmpty() bool {
+	return v == nil || (v.Appium == "" && len(v.Drivers) == 0)
+}
+
+// AppiumInstallation is an Appium installation used by the provider devices and the versions installed in it
+type AppiumInstallation struct {
+	// Home is the isolated APPIUM_HOME of pinned versions, empty for the Appium installed on the provider host
+	Home    string            `json:"home,omitempty"`
+	Appium  string            `json:"appium"`
+	Drivers map[string]string `json:"drivers"`
+	// Devices are the UDIDs of the devices whose Appium server runs from the installation
+	Devices []string `json:"devices"`
 }
 
 type AppiumTomlNode struct {
@@ -137,20 +168,25 @@ type AppiumSession struct {
 
 // ExtractClientSecretFromSession extracts client secret from Appium session request
 func ExtractClientSecretFromSession(sessionReq map[string]interface{}, prefix string) string {
+	return ExtractStringCapabilityFromSession(sessionReq, prefix+":clientSecret")
+}
+
+// ExtractStringCapabilityFromSession extracts a string capability from Appium session request
+func ExtractStringCapabilityFromSession(sessionReq map[string]interface{}, capability string) string {
 	// Check capabilities (W3C format)
 	if caps, ok := sessionReq["capabilities"].(map[string]interface{}); ok {
 		// Check capabilities.alwaysMatch (W3C format)
 		if alwaysMatch, ok := caps["alwaysMatch"].(map[string]interface{}); ok {
-			if secret, ok := alwaysMatch[prefix+":clientSecret"].(string); ok {
-				return secret
+			if value, ok := alwaysMatch[capability].(string); ok {
+				return value
 			}
 		}
 		// Check capabilities.firstMatch (W3C format)
 		if firstMatch, ok := caps["firstMatch"].([]interface{}); ok {
 			for _, item := range firstMatch {
 				if firstCaps, ok := item.(map[string]interface{}); ok {
-					if secret, ok := firstCaps[prefix+":clientSecret"].(string); ok {
-						return secret
+					if value, ok := firstCaps[capability].(string); ok {
+						return value
 					}
 				}
 			}
@@ -159,8 +195,8 @@ func ExtractClientSecretFromSession(sessionReq map[string]interface{}, prefix st
 
 	// Also check desiredCapabilities for backward compatibility
 	if desired, ok := sessionReq["desiredCapabilities"].(map[string]interface{}); ok {
-		if secret, ok := desired[prefix+":clientSecret"].(string); ok {
-			return secret
+		if value, ok := desired[capability].(string); ok {
+			return value
 		}
 	}
 
@@ -18,6 +18,7 @@ type Provider struct {
 	ProvideIOS           bool   `json:"provide_ios" bson:"provide_ios"`
 	ProvideTizen         bool   `json:"provide_tizen" bson:"provide_tizen"`
 	ProvideWebOS         bool   `json:"provide_webos" bson:"provide_webos"`
+	ProvideRoku          bool   `json:"provide_roku" bson:"provide_roku"`
 	WdaBundleID          string `json:"wda_bundle_id" bson:"wda_bundle_id"`
 	WebDriverAgentIPA    string `json:"web_driver_agent_ipa" bson:"web_driver_agent_ipa"`
 	BroadcastIPA         string `json:"broadcast_ipa" bson:"broadcast_ipa"`
@@ -26,9 +27,26 @@ type Provider struct {
 	LastUpdatedTimestamp int64  `json:"last_updated" bson:"last_updated"`
 	UseGadsIosStream     bool   `json:"use_gads_ios_stream" bson:"use_gads_ios_stream"`
 	HubAddress           string `json:"hub_address" bson:"-"`
-	SetupAppiumServers   bool   `json:"setup_appium_servers" bson:"setup_appium_servers"`
-	TURNUsernameSuffix   string `json:"-" bson:"-"`
-	UseIOSPairCache      bool   `json:"-" bson:"-"`
+	// HubAddresses are all the hub addresses the provider fails over between, HubAddress is the first of them
+	HubAddresses       []string `json:"-" bson:"-"`
+	SetupAppiumServers bool     `json:"setup_appium_servers" bson:"setup_appium_servers"`
+	TURNUsernameSuffix string   `json:"-" bson:"-"`
+	UseIOSPairCache    bool     `json:"-" bson:"-"`
+	// RebootSchedule is the daily `HH:MM` time with an optional IANA time zone, UTC by default, when idle provider devices are rebooted, empty disables it
+	RebootSchedule string `json:"reboot_schedule,omitempty" bson:"reboot_schedule,omitempty"`
+	// DeviceDiscovery reports connected devices that are not registered to the hub for approval
+	DeviceDiscovery bool `json:"device_discovery" bson:"device_discovery"`
+	// AppiumVersions are the Appium and driver versions pinned for the provider devices, workspace versions override them
+	AppiumVersions *AppiumVersions `json:"appium_versions,omitempty" bson:"appium_versions,omitempty"`
+	// TokenHash is the SHA256 hash of the provider token used to access the hub provider API
+	TokenHash string `json:"-" bson:"token_hash,omitempty"`
+	// SigningKey is derived from the provider token when the provider authenticates, it is never stored
+	SigningKey string `json:"-" bson:"-"`
+}
+
+// ProviderTokenResponse holds a newly generated provider token, it is only returned once
+type ProviderTokenResponse struct {
+	Token string `json:"token"`
 }
 
 // ProviderDeviceSync is the lightweight struct sent from provider to hub each second
@@ -38,11 +56,41 @@ type ProviderDeviceSync struct {
 	Host          string `json:"host"`
 	Connected     bool   `json:"connected"`
 	ProviderState string `json:"provider_state"`
+	// AppiumSessionID is only sent on full syncs so the hub can restore running sessions after it was unreachable
+	AppiumSessionID string `json:"appium_session_id,omitempty"`
+}
+
+// ProviderStateDraining is reported for the live devices of a draining provider, they keep serving
+// their active sessions but the hub does not start new ones on them
+const ProviderStateDraining = "draining"
+
+// ProviderDrainStatus is the drain state of a provider and the sessions that are still active on it
+type ProviderDrainStatus struct {
+	Draining       bool `json:"draining"`
+	AppiumSessions int  `json:"appium_sessions"`
+	ActiveStreams  int  `json:"active_streams"`
+}
+
+// ProviderDrainRequest toggles the drain mode of a provider
+type ProviderDrainRequest struct {
+	Draining bool `json:"draining"`
 }
 
 type ProviderData struct {
 	ProviderData Provider             `json:"provider"`
 	DeviceData   []ProviderDeviceSync `json:"device_data"`
+	// DiscoveredDevices are the connected devices that are not registered, only sent with device discovery enabled
+	DiscoveredDevices []DiscoveredDevice `json:"discovered_devices,omitempty"`
+	// FullSync is set on the first update after the provider starts or reconnects to the hub
+	FullSync bool `json:"full_sync,omitempty"`
+	// AppiumInstallations are the Appium installations used by the provider devices, only reported by the provider `/info`
+	AppiumInstallations []AppiumInstallation `json:"appium_installations,omitempty"`
+}
+
+// ProviderUpdateResult is the hub response to a provider update
+type ProviderUpdateResult struct {
+	// ResyncRequired asks the provider for a full sync, e.g. after the hub restarted
+	ResyncRequired bool `json:"resync_required"`
 }
 
 type HubConfig struct {
@@ -54,6 +102,7 @@ type HubConfig struct {
 	OS                   string `json:"os"`
 	AuthEnabled          bool   `json:"auth_enabled"`
 	TURNUsernameSuffix   string `json:"-"`
+	RequireProviderToken bool   `json:"require_provider_token"`
 }
 
 type MinioConfig struct {
@@ -73,10 +122,11 @@ type TURNConfig struct {
 }
 
 // RegularizeProviderState applies business rules to ensure provider configuration is consistent
-// If SetupAppiumServers is false, ProvideTizen and ProvideWebOS must also be false since they require Appium servers
+// If SetupAppiumServers is false, ProvideTizen, ProvideWebOS and ProvideRoku must also be false since they require Appium servers
 func (p *Provider) RegularizeProviderState() {
 	if !p.SetupAppiumServers {
 		p.ProvideTizen = false
 		p.ProvideWebOS = false
+		p.ProvideRoku = false
 	}
 }
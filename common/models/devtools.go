@@ -0,0 +1,30 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// DevToolsSocket is a Chrome DevTools protocol endpoint on the device - Chrome or a debuggable WebView
+type DevToolsSocket struct {
+	Socket      string           `json:"socket"`
+	PackageName string           `json:"package_name,omitempty"`
+	Browser     string           `json:"browser,omitempty"`
+	Targets     []DevToolsTarget `json:"targets"`
+}
+
+// DevToolsTarget is a debuggable page as returned by the DevTools `/json/list` endpoint
+type DevToolsTarget struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	Title                string `json:"title"`
+	URL                  string `json:"url"`
+	Description          string `json:"description,omitempty"`
+	FaviconURL           string `json:"faviconUrl,omitempty"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl,omitempty"`
+	DevtoolsFrontendURL  string `json:"devtoolsFrontendUrl,omitempty"`
+}
@@ -0,0 +1,57 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+const (
+	DiagnosticEventState = "state"
+	DiagnosticEventStep  = "step"
+)
+
+// DeviceDiagnosticEvent is a provider state transition or a setup step of a device
+type DeviceDiagnosticEvent struct {
+	Type string `json:"type"`
+	// Timestamp is when the state changed or the step started, in Unix milliseconds
+	Timestamp int64  `json:"timestamp"`
+	FromState string `json:"from_state,omitempty"`
+	ToState   string `json:"to_state,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Step      string `json:"step,omitempty"`
+	// DurationMs is how long the step took
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DeviceSetupStep is the setup step a device is currently running
+type DeviceSetupStep struct {
+	Step      string `json:"step"`
+	StartedAt int64  `json:"started_at"`
+}
+
+// TizenRetryDiagnostics is the automatic connection retry state of a Tizen TV
+type TizenRetryDiagnostics struct {
+	RetryCount  int   `json:"retry_count"`
+	LastAttempt int64 `json:"last_attempt,omitempty"`
+	Paused      bool  `json:"paused"`
+	PauseUntil  int64 `json:"pause_until,omitempty"`
+}
+
+// DeviceDiagnostics explain why a device is not live - its recent state transitions and setup steps,
+// the step it is running and when it will be set up again after a failed setup
+type DeviceDiagnostics struct {
+	UDID          string           `json:"udid"`
+	ProviderState string           `json:"provider_state"`
+	Connected     bool             `json:"connected"`
+	CurrentStep   *DeviceSetupStep `json:"current_step,omitempty"`
+	// SetupBackoffUntil is when the next setup attempt is allowed after a failed setup, in Unix milliseconds
+	SetupBackoffUntil int64                   `json:"setup_backoff_until,omitempty"`
+	SetupBackoffMs    int64                   `json:"setup_backoff_ms,omitempty"`
+	TizenRetry        *TizenRetryDiagnostics  `json:"tizen_retry,omitempty"`
+	Events            []DeviceDiagnosticEvent `json:"events"`
+}
@@ -0,0 +1,46 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// DiscoveredDevice is a device connected to a provider with discovery enabled that is not registered in the DB yet
+type DiscoveredDevice struct {
+	UDID         string `json:"udid"`
+	OS           string `json:"os"`
+	Name         string `json:"name"`
+	Model        string `json:"model"`
+	OSVersion    string `json:"os_version"`
+	ScreenWidth  string `json:"screen_width"`
+	ScreenHeight string `json:"screen_height"`
+	DeviceType   string `json:"device_type"`
+	Provider     string `json:"provider"`
+	DiscoveredAt int64  `json:"discovered_at"`
+}
+
+// DiscoveryRule auto-approves discovered devices that match all of its non-empty criteria
+type DiscoveryRule struct {
+	ID       string `json:"id" bson:"_id"`
+	Provider string `json:"provider" bson:"provider"`
+	OS       string `json:"os" bson:"os"`
+	// ModelPattern is a regular expression matched against the device model
+	ModelPattern string `json:"model_pattern" bson:"model_pattern"`
+	WorkspaceID  string `json:"workspace_id" bson:"workspace_id"`
+	Usage        string `json:"usage" bson:"usage"`
+	CreatedBy    string `json:"created_by" bson:"created_by"`
+	CreatedAt    int64  `json:"created_at" bson:"created_at"`
+}
+
+// ApproveDeviceRequest registers a discovered device, empty fields fall back to the detected values and the default workspace
+type ApproveDeviceRequest struct {
+	WorkspaceID string `json:"workspace_id"`
+	Name        string `json:"name"`
+	Usage       string `json:"usage"`
+	// Provider selects the provider of the device when more than one provider reports the same UDID
+	Provider string `json:"provider,omitempty"`
+}
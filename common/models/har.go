@@ -0,0 +1,113 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// Network capture states
+const (
+	NetworkCaptureStatusCapturing = "capturing"
+	NetworkCaptureStatusCompleted = "completed"
+	NetworkCaptureStatusFailed    = "failed"
+)
+
+type StartNetworkCaptureRequest struct {
+	// InterceptTLS decrypts HTTPS traffic with the GADS CA, the CA has to be trusted by the device
+	// Without it only the CONNECT tunnels of HTTPS traffic are recorded
+	InterceptTLS bool `json:"intercept_tls"`
+}
+
+type NetworkCapture struct {
+	ID           string `json:"id"`
+	UDID         string `json:"udid"`
+	SessionID    string `json:"session_id,omitempty"`
+	InterceptTLS bool   `json:"intercept_tls"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	StartedAt    int64  `json:"started_at"`
+	StoppedAt    int64  `json:"stopped_at,omitempty"`
+	FileName     string `json:"file_name,omitempty"`
+	SizeBytes    int64  `json:"size_bytes,omitempty"`
+	EntryCount   int    `json:"entry_count"`
+	// DroppedEntries is how many of the oldest entries were dropped because the capture reached its entry limit
+	DroppedEntries int `json:"dropped_entries,omitempty"`
+}
+
+// HAR 1.2 format - http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -0,0 +1,37 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+type LocationPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type SetLocationRequest struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type LocationRouteRequest struct {
+	// Route is the raw GPX or KML document
+	Route string `json:"route"`
+	// SpeedKmh is the speed the route is replayed with, defaults to 50 km/h
+	SpeedKmh float64 `json:"speed_kmh"`
+	// Loop restarts the route from the first point when the last one is reached
+	Loop bool `json:"loop"`
+}
+
+type LocationSimulationStatus struct {
+	Active   bool           `json:"active"`
+	Route    bool           `json:"route"`
+	Current  *LocationPoint `json:"current,omitempty"`
+	Points   int            `json:"points,omitempty"`
+	SpeedKmh float64        `json:"speed_kmh,omitempty"`
+}
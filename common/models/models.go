@@ -220,15 +220,24 @@ type Workspace struct {
 	Description string `json:"description" bson:"description" example:"Workspace for development team testing"`
 	IsDefault   bool   `json:"is_default" bson:"is_default" example:"false"`
 	Tenant      string `json:"tenant" bson:"tenant,omitempty" example:"acme-corp"`
+	// HARRedactedHeaders are redacted from network captures of the workspace devices in addition to the default sensitive headers
+	HARRedactedHeaders []string `json:"har_redacted_headers,omitempty" bson:"har_redacted_headers,omitempty" example:"X-Session-Token"`
+	// RebootSchedule is the daily `HH:MM` time with an optional IANA time zone, UTC by default, when idle workspace devices are rebooted, overrides the provider schedule
+	RebootSchedule string `json:"reboot_schedule,omitempty" bson:"reboot_schedule,omitempty" example:"03:00 Europe/Berlin"`
+	// AppiumVersions are the Appium and driver versions pinned for the workspace devices, overrides the provider versions
+	AppiumVersions *AppiumVersions `json:"appium_versions,omitempty" bson:"appium_versions,omitempty"`
 }
 
 type WorkspaceWithDeviceCount struct {
-	ID          string `json:"id" bson:"_id,omitempty" example:"workspace_123"`
-	Name        string `json:"name" bson:"name" example:"Development Team"`
-	Description string `json:"description" bson:"description" example:"Workspace for development team testing"`
-	IsDefault   bool   `json:"is_default" bson:"is_default" example:"false"`
-	Tenant      string `json:"tenant" bson:"tenant,omitempty" example:"acme-corp"`
-	DeviceCount int    `json:"device_count" bson:"device_count" example:"5"`
+	ID                 string          `json:"id" bson:"_id,omitempty" example:"workspace_123"`
+	Name               string          `json:"name" bson:"name" example:"Development Team"`
+	Description        string          `json:"description" bson:"description" example:"Workspace for development team testing"`
+	IsDefault          bool            `json:"is_default" bson:"is_default" example:"false"`
+	Tenant             string          `json:"tenant" bson:"tenant,omitempty" example:"acme-corp"`
+	DeviceCount        int             `json:"device_count" bson:"device_count" example:"5"`
+	HARRedactedHeaders []string        `json:"har_redacted_headers,omitempty" bson:"har_redacted_headers,omitempty" example:"X-Session-Token"`
+	RebootSchedule     string          `json:"reboot_schedule,omitempty" bson:"reboot_schedule,omitempty" example:"03:00 Europe/Berlin"`
+	AppiumVersions     *AppiumVersions `json:"appium_versions,omitempty" bson:"appium_versions,omitempty"`
 }
 
 type ProviderLog struct {
@@ -294,17 +303,10 @@ func ValidateDeviceUsageForOS(os, usage string) error {
 	normalizedOS := strings.ToLower(strings.TrimSpace(os))
 	normalizedUsage := strings.ToLower(strings.TrimSpace(usage))
 
-	// Validate Tizen devices can only be used for automation
+	// Validate Tizen devices can only be used for automation, they cannot capture their screen for remote control
 	if normalizedOS == "tizen" {
 		if normalizedUsage != "automation" {
-			return fmt.Errorf("tizen devices only support 'automation' usage. Current usage '%s' is not supported. Tizen devices can only be used for Appium testing and automation", usage)
-		}
-	}
-
-	// Validate WebOS devices can only be used for automation
-	if normalizedOS == "webos" {
-		if normalizedUsage != "automation" {
-			return fmt.Errorf("webos devices only support 'automation' usage. Current usage '%s' is not supported. WebOS devices can only be used for Appium testing and automation", usage)
+			return fmt.Errorf("tizen devices only support 'automation' usage. Current usage '%s' is not supported. Tizen devices cannot capture their screen so they can only be used for Appium testing and automation", usage)
 		}
 	}
 
@@ -317,7 +319,6 @@ func ValidateDevice(device *DBDevice) error {
 		return errors.New("device cannot be nil")
 	}
 
-	// Validate OS and Usage combination
 	if err := ValidateDeviceUsageForOS(device.OS, device.Usage); err != nil {
 		return err
 	}
@@ -334,4 +335,13 @@ type DeviceApp struct {
 	AppName          string `json:"app_name" bson:"-"`
 	BundleIdentifier string `json:"bundle_identifier" bson:"-"`
 	CanUninstall     bool   `json:"can_uninstall" bson:"-"`
+	Version          string `json:"version,omitempty" bson:"-"`
+	InstallTime      int64  `json:"install_time,omitempty" bson:"-"` // Unix ms of the first install, 0 if the platform does not report it
+}
+
+type AppPermissionRequest struct {
+	App string `json:"app"`
+	// Permissions are Android runtime permissions (android.permission.CAMERA) or app ops (SYSTEM_ALERT_WINDOW),
+	// or iOS privacy resources (camera, photos, location...) when resetting
+	Permissions []string `json:"permissions"`
 }
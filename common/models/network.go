@@ -0,0 +1,35 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+type NetworkProfile struct {
+	Name string `json:"name"`
+	// LatencyMs is added to every new connection and to each chunk of data in both directions
+	LatencyMs int `json:"latency_ms"`
+	// DownloadKbps and UploadKbps limit the throughput of each connection, 0 means unlimited
+	DownloadKbps int `json:"download_kbps"`
+	UploadKbps   int `json:"upload_kbps"`
+	// PacketLoss is the percentage (0-100) of data chunks that are delayed as if they were retransmitted
+	PacketLoss float64 `json:"packet_loss"`
+	// Offline rejects all connections
+	Offline bool `json:"offline"`
+}
+
+type SetNetworkProfileRequest struct {
+	// Profile is the name of a predefined profile or `custom` to apply Custom
+	Profile string          `json:"profile"`
+	Custom  *NetworkProfile `json:"custom,omitempty"`
+}
+
+type NetworkShapingStatus struct {
+	Active    bool            `json:"active"`
+	ProxyPort string          `json:"proxy_port,omitempty"`
+	Profile   *NetworkProfile `json:"profile,omitempty"`
+}
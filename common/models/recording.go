@@ -0,0 +1,47 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// Screen recording sources
+const (
+	// RecordingSourceScreenrecord uses the Android `screenrecord` binary on the device
+	RecordingSourceScreenrecord = "screenrecord"
+	// RecordingSourceStream encodes the JPEG frames of the device MJPEG stream with ffmpeg
+	RecordingSourceStream = "stream"
+)
+
+// Screen recording states
+const (
+	RecordingStatusRecording  = "recording"
+	RecordingStatusProcessing = "processing"
+	RecordingStatusCompleted  = "completed"
+	RecordingStatusFailed     = "failed"
+)
+
+type StartRecordingRequest struct {
+	// Source is optional, Android defaults to `screenrecord`, iOS always uses `stream`
+	Source string `json:"source"`
+	// Overlay draws markers for taps and gestures performed through the provider while recording
+	Overlay bool `json:"overlay"`
+}
+
+type DeviceRecording struct {
+	ID         string `json:"id"`
+	UDID       string `json:"udid"`
+	Source     string `json:"source"`
+	Overlay    bool   `json:"overlay"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+	StoppedAt  int64  `json:"stopped_at,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+	SizeBytes  int64  `json:"size_bytes,omitempty"`
+	EventCount int    `json:"event_count"`
+}
@@ -0,0 +1,36 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// Tunnel targets
+const (
+	TunnelTargetDevice   = "device"
+	TunnelTargetProvider = "provider"
+	// TunnelTargetADB is the adb server connection of an Android device
+	TunnelTargetADB = "adb"
+	// TunnelTargetUsbmux is a usbmuxd connection restricted to an iOS device
+	TunnelTargetUsbmux = "usbmux"
+)
+
+// TunnelAuditLog records a single TCP connection relayed through the hub to a device or provider port
+type TunnelAuditLog struct {
+	ID            string `json:"id" bson:"_id"`
+	UDID          string `json:"udid" bson:"udid"`
+	User          string `json:"user" bson:"user"`
+	Tenant        string `json:"tenant" bson:"tenant"`
+	Target        string `json:"target" bson:"target"`
+	Port          int    `json:"port" bson:"port"`
+	ClientAddress string `json:"client_address" bson:"client_address"`
+	OpenedAt      int64  `json:"opened_at" bson:"opened_at"`
+	ClosedAt      int64  `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+	BytesSent     int64  `json:"bytes_sent" bson:"bytes_sent"`
+	BytesReceived int64  `json:"bytes_received" bson:"bytes_received"`
+	CloseReason   string `json:"close_reason,omitempty" bson:"close_reason,omitempty"`
+}
@@ -0,0 +1,25 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package models
+
+// WebInspectorPage is an inspectable Safari tab, WKWebView or JSContext on an iOS device
+type WebInspectorPage struct {
+	AppID    string `json:"app_id"`
+	AppName  string `json:"app_name"`
+	BundleID string `json:"bundle_id"`
+	PageID   int    `json:"page_id"`
+	// Type is the WebKit target type e.g. `WIRTypeWebPage`, `WIRTypeWeb` or `WIRTypeJavaScript`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// InUse is true when another inspector, e.g. Safari on a Mac, is already attached to the page
+	InUse                bool   `json:"in_use"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl,omitempty"`
+}
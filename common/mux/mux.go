@@ -0,0 +1,499 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+// Package mux multiplexes byte streams and control messages over a single connection.
+// It carries the provider control channel - the provider dials the hub once and the hub
+// opens streams back to the provider HTTP server over that connection.
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	frameOpen    byte = 1
+	frameData    byte = 2
+	frameClose   byte = 3
+	frameWindow  byte = 4
+	frameMessage byte = 5
+	framePing    byte = 6
+)
+
+const (
+	headerSize = 9
+	// maxFrameSize limits data frames so one stream cannot hold the connection for long
+	maxFrameSize = 32 * 1024
+	// maxMessageSize limits control messages
+	maxMessageSize = 16 * 1024 * 1024
+	// streamWindow is how much unread data a stream buffers before the sender has to wait
+	streamWindow = 256 * 1024
+	writeTimeout = 30 * time.Second
+)
+
+var (
+	ErrSessionClosed = errors.New("mux session closed")
+	ErrStreamClosed  = errors.New("mux stream closed")
+	errTimeout       = &timeoutError{}
+)
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// Session multiplexes streams and messages over a connection.
+// Both sides can open streams, the side that dialed the connection is the client.
+type Session struct {
+	conn      net.Conn
+	keepalive time.Duration
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+	err     error
+
+	accept   chan *Stream
+	messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewSession starts multiplexing over the connection. With a keepalive both sides ping each other
+// at that interval and the session is closed when nothing was received for three intervals.
+func NewSession(conn net.Conn, client bool, keepalive time.Duration) *Session {
+	s := &Session{
+		conn:      conn,
+		keepalive: keepalive,
+		streams:   make(map[uint32]*Stream),
+		nextID:    2,
+		accept:    make(chan *Stream, 64),
+		messages:  make(chan []byte, 16),
+		closed:    make(chan struct{}),
+	}
+	if client {
+		s.nextID = 1
+	}
+	go s.recvLoop()
+	if keepalive > 0 {
+		go s.pingLoop()
+	}
+	return s
+}
+
+// Open opens a new stream to the other side
+func (s *Session) Open() (*Stream, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	id := s.nextID
+	s.nextID += 2
+	stream := newStream(s, id)
+	s.streams[id] = stream
+	s.mu.Unlock()
+
+	if err := s.writeFrame(frameOpen, id, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Accept waits for a stream opened by the other side
+func (s *Session) Accept() (*Stream, error) {
+	select {
+	case stream := <-s.accept:
+		return stream, nil
+	case <-s.closed:
+		return nil, s.Err()
+	}
+}
+
+// SendMessage sends a control message to the other side
+func (s *Session) SendMessage(message []byte) error {
+	if len(message) > maxMessageSize {
+		return fmt.Errorf("mux message of %d bytes exceeds the limit of %d bytes", len(message), maxMessageSize)
+	}
+	return s.writeFrame(frameMessage, 0, message)
+}
+
+// ReadMessage waits for a control message from the other side
+func (s *Session) ReadMessage() ([]byte, error) {
+	select {
+	case message := <-s.messages:
+		return message, nil
+	case <-s.closed:
+		return nil, s.Err()
+	}
+}
+
+// Done is closed when the session is closed
+func (s *Session) Done() <-chan struct{} {
+	return s.closed
+}
+
+// Err returns why the session was closed
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the connection and all streams
+func (s *Session) Close() error {
+	s.closeWithError(ErrSessionClosed)
+	return nil
+}
+
+func (s *Session) closeWithError(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		streams := s.streams
+		s.streams = make(map[uint32]*Stream)
+		s.mu.Unlock()
+
+		close(s.closed)
+		s.conn.Close()
+		for _, stream := range streams {
+			stream.sessionClosed()
+		}
+	})
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.mu.Lock()
+	delete(s.streams, id)
+	s.mu.Unlock()
+}
+
+func (s *Session) getStream(id uint32) *Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+func (s *Session) writeFrame(frameType byte, id uint32, payload []byte) error {
+	var header [headerSize]byte
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:5], id)
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	select {
+	case <-s.closed:
+		return s.Err()
+	default:
+	}
+
+	// The frame is written at once so it is carried in a single WebSocket frame
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := s.conn.Write(append(header[:], payload...)); err != nil {
+		s.closeWithError(err)
+		return err
+	}
+	return nil
+}
+
+func (s *Session) pingLoop() {
+	ticker := time.NewTicker(s.keepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.writeFrame(framePing, 0, nil); err != nil {
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *Session) recvLoop() {
+	var header [headerSize]byte
+	for {
+		if s.keepalive > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(3 * s.keepalive))
+		}
+		if _, err := io.ReadFull(s.conn, header[:]); err != nil {
+			s.closeWithError(err)
+			return
+		}
+		frameType := header[0]
+		id := binary.BigEndian.Uint32(header[1:5])
+		length := binary.BigEndian.Uint32(header[5:9])
+		if length > maxMessageSize || (frameType == frameData && length > maxFrameSize) {
+			s.closeWithError(fmt.Errorf("mux frame of %d bytes is too large", length))
+			return
+		}
+
+		var payload []byte
+		if length > 0 {
+			payload = make([]byte, length)
+			if _, err := io.ReadFull(s.conn, payload); err != nil {
+				s.closeWithError(err)
+				return
+			}
+		}
+
+		switch frameType {
+		case frameOpen:
+			stream := newStream(s, id)
+			s.mu.Lock()
+			s.streams[id] = stream
+			s.mu.Unlock()
+			select {
+			case s.accept <- stream:
+			default:
+				// Nobody is accepting streams fast enough
+				stream.Close()
+			}
+		case frameData:
+			if stream := s.getStream(id); stream != nil && !stream.receive(payload) {
+				s.closeWithError(fmt.Errorf("mux stream %d received more data than its window", id))
+				return
+			}
+		case frameClose:
+			if stream := s.getStream(id); stream != nil {
+				stream.remoteClosed()
+			}
+		case frameWindow:
+			if stream := s.getStream(id); stream != nil && len(payload) == 4 {
+				stream.addSendWindow(binary.BigEndian.Uint32(payload))
+			}
+		case frameMessage:
+			select {
+			case s.messages <- payload:
+			case <-s.closed:
+				return
+			}
+		case framePing:
+		default:
+			s.closeWithError(fmt.Errorf("unknown mux frame type %d", frameType))
+			return
+		}
+	}
+}
+
+// Stream is a bidirectional byte stream within a session, it implements net.Conn
+type Stream struct {
+	session *Session
+	id      uint32
+
+	mu            sync.Mutex
+	cond          *sync.Cond
+	buffer        []byte
+	unacked       int
+	sendWindow    int
+	localClosed   bool
+	remoteDone    bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+	readTimer     *time.Timer
+	writeTimer    *time.Timer
+}
+
+func newStream(session *Session, id uint32) *Stream {
+	stream := &Stream{
+		session:    session,
+		id:         id,
+		sendWindow: streamWindow,
+	}
+	stream.cond = sync.NewCond(&stream.mu)
+	return stream
+}
+
+func (st *Stream) Read(p []byte) (int, error) {
+	st.mu.Lock()
+	for len(st.buffer) == 0 {
+		switch {
+		case st.localClosed:
+			st.mu.Unlock()
+			return 0, ErrStreamClosed
+		case st.remoteDone:
+			st.mu.Unlock()
+			return 0, io.EOF
+		case !st.readDeadline.IsZero() && !time.Now().Before(st.readDeadline):
+			st.mu.Unlock()
+			return 0, errTimeout
+		}
+		st.cond.Wait()
+	}
+
+	n := copy(p, st.buffer)
+	st.buffer = st.buffer[n:]
+	st.unacked += n
+	var credit int
+	// Give the sender its window back in batches
+	if st.unacked >= streamWindow/2 || len(st.buffer) == 0 {
+		credit = st.unacked
+		st.unacked = 0
+	}
+	st.mu.Unlock()
+
+	if credit > 0 {
+		var payload [4]byte
+		binary.BigEndian.PutUint32(payload[:], uint32(credit))
+		st.session.writeFrame(frameWindow, st.id, payload[:])
+	}
+	return n, nil
+}
+
+func (st *Stream) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		st.mu.Lock()
+		for st.sendWindow == 0 {
+			switch {
+			case st.localClosed:
+				st.mu.Unlock()
+				return written, ErrStreamClosed
+			case st.remoteDone:
+				st.mu.Unlock()
+				return written, io.ErrClosedPipe
+			case !st.writeDeadline.IsZero() && !time.Now().Before(st.writeDeadline):
+				st.mu.Unlock()
+				return written, errTimeout
+			}
+			st.cond.Wait()
+		}
+		if st.localClosed {
+			st.mu.Unlock()
+			return written, ErrStreamClosed
+		}
+		if st.remoteDone {
+			st.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		chunk := min(len(p)-written, st.sendWindow, maxFrameSize)
+		st.sendWindow -= chunk
+		st.mu.Unlock()
+
+		if err := st.session.writeFrame(frameData, st.id, p[written:written+chunk]); err != nil {
+			return written, err
+		}
+		written += chunk
+	}
+	return written, nil
+}
+
+// Close closes the stream on both sides
+func (st *Stream) Close() error {
+	st.mu.Lock()
+	if st.localClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	st.stopTimers()
+	st.cond.Broadcast()
+	st.mu.Unlock()
+
+	st.session.removeStream(st.id)
+	return st.session.writeFrame(frameClose, st.id, nil)
+}
+
+// receive buffers the data sent by the other side, it reports false when the sender did not respect the stream window
+func (st *Stream) receive(data []byte) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	// The unread and read but not yet credited data is what the sender used of its window
+	if len(st.buffer)+st.unacked+len(data) > streamWindow {
+		return false
+	}
+	if !st.localClosed {
+		st.buffer = append(st.buffer, data...)
+		st.cond.Broadcast()
+	}
+	return true
+}
+
+func (st *Stream) remoteClosed() {
+	st.mu.Lock()
+	st.remoteDone = true
+	st.cond.Broadcast()
+	st.mu.Unlock()
+}
+
+func (st *Stream) sessionClosed() {
+	st.mu.Lock()
+	st.remoteDone = true
+	st.localClosed = true
+	st.stopTimers()
+	st.cond.Broadcast()
+	st.mu.Unlock()
+}
+
+func (st *Stream) addSendWindow(credit uint32) {
+	st.mu.Lock()
+	st.sendWindow += int(credit)
+	st.cond.Broadcast()
+	st.mu.Unlock()
+}
+
+func (st *Stream) stopTimers() {
+	if st.readTimer != nil {
+		st.readTimer.Stop()
+	}
+	if st.writeTimer != nil {
+		st.writeTimer.Stop()
+	}
+}
+
+func (st *Stream) LocalAddr() net.Addr  { return st.session.conn.LocalAddr() }
+func (st *Stream) RemoteAddr() net.Addr { return st.session.conn.RemoteAddr() }
+
+func (st *Stream) SetDeadline(t time.Time) error {
+	st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+func (st *Stream) SetReadDeadline(t time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.readDeadline = t
+	st.readTimer = st.resetTimer(st.readTimer, t)
+	return nil
+}
+
+func (st *Stream) SetWriteDeadline(t time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.writeDeadline = t
+	st.writeTimer = st.resetTimer(st.writeTimer, t)
+	return nil
+}
+
+// resetTimer wakes up the waiting reads or writes when the deadline passes. Caller holds st.mu.
+func (st *Stream) resetTimer(timer *time.Timer, deadline time.Time) *time.Timer {
+	if timer != nil {
+		timer.Stop()
+	}
+	st.cond.Broadcast()
+	if deadline.IsZero() {
+		return nil
+	}
+	return time.AfterFunc(time.Until(deadline), func() {
+		st.mu.Lock()
+		st.cond.Broadcast()
+		st.mu.Unlock()
+	})
+}
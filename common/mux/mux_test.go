@@ -0,0 +1,232 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mux
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionPair(t *testing.T) (*Session, *Session) {
+	clientConn, serverConn := net.Pipe()
+	client := NewSession(clientConn, true, 0)
+	server := NewSession(serverConn, false, 0)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func TestStreamTransfersDataBothWays(t *testing.T) {
+	client, server := newSessionPair(t)
+
+	// More than the stream window so the flow control has to hand out credit
+	payload := bytes.Repeat([]byte("0123456789abcdef"), streamWindow/4)
+
+	go func() {
+		stream, err := client.Accept()
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		io.Copy(stream, stream)
+	}()
+
+	stream, err := server.Open()
+	require.NoError(t, err)
+
+	go func() {
+		stream.Write(payload)
+	}()
+
+	received := make([]byte, len(payload))
+	_, err = io.ReadFull(stream, received)
+	require.NoError(t, err)
+	assert.Equal(t, payload, received)
+	stream.Close()
+}
+
+func TestStreamCloseReturnsEOF(t *testing.T) {
+	client, server := newSessionPair(t)
+
+	stream, err := server.Open()
+	require.NoError(t, err)
+	accepted, err := client.Accept()
+	require.NoError(t, err)
+
+	_, err = stream.Write([]byte("bye"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	data, err := io.ReadAll(accepted)
+	require.NoError(t, err)
+	assert.Equal(t, "bye", string(data))
+}
+
+func TestMessages(t *testing.T) {
+	client, server := newSessionPair(t)
+
+	require.NoError(t, client.SendMessage([]byte(`{"full_sync":true}`)))
+	message, err := server.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, `{"full_sync":true}`, string(message))
+}
+
+func TestReadDeadline(t *testing.T) {
+	client, server := newSessionPair(t)
+
+	stream, err := server.Open()
+	require.NoError(t, err)
+	_, err = client.Accept()
+	require.NoError(t, err)
+
+	stream.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = stream.Read(make([]byte, 1))
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestSessionCloseClosesStreams(t *testing.T) {
+	client, server := newSessionPair(t)
+
+	stream, err := server.Open()
+	require.NoError(t, err)
+	_, err = client.Accept()
+	require.NoError(t, err)
+
+	client.Close()
+	<-server.Done()
+
+	_, err = stream.Read(make([]byte, 1))
+	assert.Error(t, err)
+	_, err = server.Open()
+	assert.Error(t, err)
+}
+
+func TestSessionOverWebSocket(t *testing.T) {
+	serverSessions := make(chan *Session, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		serverSessions <- NewSession(NewWebSocketConn(conn, rw.Reader, false), false, 0)
+	}))
+	defer server.Close()
+
+	conn, br, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"))
+	require.NoError(t, err)
+	client := NewSession(NewWebSocketConn(conn, br, true), true, 0)
+	defer client.Close()
+	serverSession := <-serverSessions
+	defer serverSession.Close()
+
+	require.NoError(t, client.SendMessage([]byte(`{"full_sync":true}`)))
+	message, err := serverSession.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, `{"full_sync":true}`, string(message))
+
+	go func() {
+		stream, err := client.Accept()
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		io.Copy(stream, stream)
+	}()
+
+	stream, err := serverSession.Open()
+	require.NoError(t, err)
+	defer stream.Close()
+	payload := bytes.Repeat([]byte("0123456789abcdef"), streamWindow/4)
+	go stream.Write(payload)
+
+	received := make([]byte, len(payload))
+	_, err = io.ReadFull(stream, received)
+	require.NoError(t, err)
+	assert.Equal(t, payload, received)
+}
+
+func TestWebSocketConnFrames(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	conn := NewWebSocketConn(clientConn, nil, true)
+	defer conn.Close()
+
+	go conn.Write([]byte("mux frame"))
+	frame, err := ws.ReadFrame(serverConn)
+	require.NoError(t, err)
+	assert.Equal(t, ws.OpBinary, frame.Header.OpCode)
+	assert.True(t, frame.Header.Masked, "client frames must be masked")
+	assert.Equal(t, "mux frame", string(ws.UnmaskFrame(frame).Payload))
+
+	// Pings are answered and do not end up in the session data
+	go func() {
+		wsutil.WriteServerMessage(serverConn, ws.OpPing, []byte("ping"))
+		wsutil.WriteServerBinary(serverConn, []byte("data"))
+	}()
+	received := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 16)
+		n, _ := conn.Read(buf)
+		received <- string(buf[:n])
+	}()
+	frame, err = ws.ReadFrame(serverConn)
+	require.NoError(t, err)
+	assert.Equal(t, ws.OpPong, frame.Header.OpCode)
+	assert.Equal(t, "ping", string(ws.UnmaskFrame(frame).Payload))
+	assert.Equal(t, "data", <-received)
+}
+
+func TestStreamWindowIsEnforced(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	server := NewSession(serverConn, false, 0)
+	defer server.Close()
+
+	writeFrame := func(frameType byte, payload []byte) error {
+		header := make([]byte, headerSize)
+		header[0] = frameType
+		binary.BigEndian.PutUint32(header[1:5], 1)
+		binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+		_, err := clientConn.Write(append(header, payload...))
+		return err
+	}
+
+	require.NoError(t, writeFrame(frameOpen, nil))
+	// The stream is never read so everything beyond the window must be refused
+	for sent := 0; sent <= streamWindow; sent += maxFrameSize {
+		if err := writeFrame(frameData, make([]byte, maxFrameSize)); err != nil {
+			break
+		}
+	}
+
+	select {
+	case <-server.Done():
+		assert.ErrorContains(t, server.Err(), "window")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session was not closed after the stream window was exceeded")
+	}
+}
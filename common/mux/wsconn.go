@@ -0,0 +1,110 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package mux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// wsConn carries the session bytes in binary WebSocket frames so the session works through
+// WebSocket aware reverse proxies and load balancers between the provider and the hub
+type wsConn struct {
+	net.Conn
+	state  ws.State
+	reader *wsutil.Reader
+
+	writeMu sync.Mutex
+}
+
+// NewWebSocketConn wraps an upgraded WebSocket connection, the client is the side that dialed it.
+// The reader holds the data received along with the handshake, it can be nil.
+func NewWebSocketConn(conn net.Conn, reader *bufio.Reader, client bool) net.Conn {
+	state := ws.StateServerSide
+	if client {
+		state = ws.StateClientSide
+	}
+	var source io.Reader = conn
+	if reader != nil && reader.Buffered() > 0 {
+		source = io.MultiReader(io.LimitReader(reader, int64(reader.Buffered())), conn)
+	}
+	return &wsConn{
+		Conn:   conn,
+		state:  state,
+		reader: wsutil.NewReader(source, state),
+	}
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		n, err := c.reader.Read(p)
+		switch {
+		case err == wsutil.ErrNoFrameAdvance:
+			header, err := c.reader.NextFrame()
+			if err != nil {
+				return 0, err
+			}
+			if header.OpCode.IsControl() {
+				if err := c.handleControl(header); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if header.OpCode != ws.OpBinary {
+				if err := c.reader.Discard(); err != nil {
+					return 0, err
+				}
+			}
+		case err == io.EOF:
+			// End of the frame, the session stream continues in the next one
+			if n > 0 {
+				return n, nil
+			}
+		default:
+			if n > 0 || err != nil {
+				return n, err
+			}
+		}
+	}
+}
+
+// handleControl answers pings and ends the connection on a close frame
+func (c *wsConn) handleControl(header ws.Header) error {
+	payload, err := io.ReadAll(c.reader)
+	if err != nil {
+		return err
+	}
+	switch header.OpCode {
+	case ws.OpPing:
+		return c.writeMessage(ws.OpPong, payload)
+	case ws.OpClose:
+		c.writeMessage(ws.OpClose, nil)
+		return io.EOF
+	}
+	return nil
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeMessage(ws.OpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) writeMessage(op ws.OpCode, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return wsutil.WriteMessage(c.Conn, c.state, op, payload)
+}
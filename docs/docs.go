@@ -2887,6 +2887,16 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Workspace for development team testing"
                 },
+                "har_redacted_headers": {
+                    "description": "HARRedactedHeaders are redacted from network captures of the workspace devices in addition to the default sensitive headers",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "X-Session-Token"
+                    ]
+                },
                 "id": {
                     "type": "string",
                     "example": "workspace_123"
@@ -2916,6 +2926,15 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 5
                 },
+                "har_redacted_headers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "X-Session-Token"
+                    ]
+                },
                 "id": {
                     "type": "string",
                     "example": "workspace_123"
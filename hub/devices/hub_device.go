@@ -121,6 +121,16 @@ func (d *LocalHubDevice) HasActiveLease() bool {
 	return d.LockSource == LockSourceAPI && d.LeaseExpiresAt > time.Now().UnixMilli()
 }
 
+// IsHeldBy reports whether the user holds the device lock through a UI session or an active API lease.
+func (d *LocalHubDevice) IsHeldBy(user, tenant string) bool {
+	return d.InUseBy == user && d.InUseByTenant == tenant && (d.HasUISession() || d.HasActiveLease())
+}
+
+// IsDraining reports whether the provider of the device is draining - running sessions go on but no new ones are started.
+func (d *LocalHubDevice) IsDraining() bool {
+	return d.ProviderState == models.ProviderStateDraining
+}
+
 // RefreshLock updates InUseTS to now, keeping the lock alive.
 func (d *LocalHubDevice) RefreshLock() {
 	d.InUseTS = time.Now().UnixMilli()
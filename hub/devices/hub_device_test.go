@@ -10,6 +10,7 @@
 package devices
 
 import (
+	"GADS/common/models"
 	"net"
 	"testing"
 	"time"
@@ -315,6 +316,48 @@ func TestRefreshLock_UpdatesTimestamp(t *testing.T) {
 	}
 }
 
+// --- IsHeldBy ---
+
+func TestIsHeldBy_UISession(t *testing.T) {
+	d := &LocalHubDevice{}
+	d.AcquireLock("alice", "t1", LockSourceUI)
+	d.SetWSConnection(&fakeConn{})
+
+	if !d.IsHeldBy("alice", "t1") {
+		t.Error("should be held by the UI session user")
+	}
+	if d.IsHeldBy("bob", "t1") {
+		t.Error("should not be held by another user")
+	}
+	if d.IsHeldBy("alice", "t2") {
+		t.Error("should not be held by the same user in another tenant")
+	}
+}
+
+func TestIsHeldBy_APILease(t *testing.T) {
+	d := &LocalHubDevice{}
+	d.AcquireLock("ci", "t1", LockSourceAPI)
+	d.LeaseExpiresAt = time.Now().Add(5 * time.Minute).UnixMilli()
+
+	if !d.IsHeldBy("ci", "t1") {
+		t.Error("should be held by the lease holder")
+	}
+
+	d.LeaseExpiresAt = time.Now().Add(-time.Minute).UnixMilli()
+	if d.IsHeldBy("ci", "t1") {
+		t.Error("should not be held once the lease expired")
+	}
+}
+
+func TestIsHeldBy_NoSessionOrLease(t *testing.T) {
+	d := &LocalHubDevice{}
+	d.AcquireLock("alice", "t1", LockSourceUI)
+
+	if d.IsHeldBy("alice", "t1") {
+		t.Error("should not be held without a UI session or an API lease")
+	}
+}
+
 // --- SetWSConnection / ClearWSConnection ---
 
 func TestSetAndClearWSConnection(t *testing.T) {
@@ -340,3 +383,17 @@ func TestSetAndClearWSConnection(t *testing.T) {
 		t.Error("ClearWSConnection should not close the connection")
 	}
 }
+
+// --- IsDraining ---
+
+func TestIsDraining(t *testing.T) {
+	d := &LocalHubDevice{ProviderState: "live"}
+	if d.IsDraining() {
+		t.Error("live device should not be draining")
+	}
+
+	d.ProviderState = models.ProviderStateDraining
+	if !d.IsDraining() {
+		t.Error("device in draining provider state should be draining")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"sync"
+	"time"
+)
+
+// LockRelease describes a device lock that ended.
+type LockRelease struct {
+	UDID   string
+	OS     string
+	Host   string
+	User   string
+	Tenant string
+}
+
+// LockReleaseHook is called once for every device lock that ended, no matter if it was
+// released explicitly, its API lease expired, the UI session was closed or the automation session finished.
+type LockReleaseHook func(release LockRelease)
+
+type lockHolder struct {
+	user   string
+	tenant string
+}
+
+var (
+	lockReleaseHooks   []LockReleaseHook
+	lockReleaseHooksMu sync.RWMutex
+	lastLockHolders    = make(map[string]lockHolder)
+)
+
+// OnLockReleased registers a hook that runs when a device lock ends.
+func OnLockReleased(hook LockReleaseHook) {
+	lockReleaseHooksMu.Lock()
+	defer lockReleaseHooksMu.Unlock()
+	lockReleaseHooks = append(lockReleaseHooks, hook)
+}
+
+// WatchLockReleases checks the devices each second and runs the registered hooks for every lock that ended.
+// Polling is used because locks can also end implicitly when a lease or the InUseTS window expires.
+func WatchLockReleases() {
+	for {
+		for _, release := range detectLockReleases() {
+			runLockReleaseHooks(release)
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// currentLockHolder returns the user holding the device, the caller must hold device.Mu
+func (d *LocalHubDevice) currentLockHolder() (lockHolder, bool) {
+	if d.InUseBy == "" {
+		return lockHolder{}, false
+	}
+	if !d.IsLocked() && !d.IsRunningAutomation {
+		return lockHolder{}, false
+	}
+	return lockHolder{user: d.InUseBy, tenant: d.InUseByTenant}, true
+}
+
+// detectLockReleases compares the current lock holders with the ones from the previous check.
+// A lock is considered released when the device is no longer locked or is locked by someone else.
+func detectLockReleases() []LockRelease {
+	var releases []LockRelease
+	seen := make(map[string]bool)
+
+	for _, device := range HubDeviceStore.All() {
+		device.Mu.RLock()
+		udid := device.Device.UDID
+		deviceOS := device.Device.OS
+		host := device.Host
+		holder, locked := device.currentLockHolder()
+		device.Mu.RUnlock()
+
+		seen[udid] = true
+		previous, wasLocked := lastLockHolders[udid]
+		if wasLocked && (!locked || previous != holder) {
+			releases = append(releases, LockRelease{UDID: udid, OS: deviceOS, Host: host, User: previous.user, Tenant: previous.tenant})
+		}
+		if locked {
+			lastLockHolders[udid] = holder
+		} else {
+			delete(lastLockHolders, udid)
+		}
+	}
+
+	// Devices removed from the store cannot be released anymore
+	for udid := range lastLockHolders {
+		if !seen[udid] {
+			delete(lastLockHolders, udid)
+		}
+	}
+	return releases
+}
+
+func runLockReleaseHooks(release LockRelease) {
+	lockReleaseHooksMu.RLock()
+	hooks := append([]LockReleaseHook(nil), lockReleaseHooks...)
+	lockReleaseHooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		go hook(release)
+	}
+}
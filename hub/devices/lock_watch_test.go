@@ -0,0 +1,105 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"testing"
+	"time"
+
+	"GADS/common/models"
+)
+
+func setupLockWatchStore(t *testing.T) *LocalHubDevice {
+	t.Helper()
+	originalStore := HubDeviceStore
+	HubDeviceStore = NewDeviceStore()
+	lastLockHolders = make(map[string]lockHolder)
+	t.Cleanup(func() {
+		HubDeviceStore = originalStore
+		lastLockHolders = make(map[string]lockHolder)
+	})
+
+	d := &LocalHubDevice{Device: models.DBDevice{UDID: "udid1", OS: "android"}, Host: "provider:10001"}
+	HubDeviceStore.Set("udid1", d)
+	return d
+}
+
+func TestDetectLockReleases_ExplicitRelease(t *testing.T) {
+	d := setupLockWatchStore(t)
+	d.SetWSConnection(&fakeConn{})
+	d.AcquireLock("alice", "tenantA", LockSourceUI)
+
+	if releases := detectLockReleases(); len(releases) != 0 {
+		t.Fatalf("expected no releases while locked, got %d", len(releases))
+	}
+
+	d.ReleaseLock()
+	releases := detectLockReleases()
+	if len(releases) != 1 {
+		t.Fatalf("expected 1 release, got %d", len(releases))
+	}
+	if releases[0].UDID != "udid1" || releases[0].User != "alice" || releases[0].Tenant != "tenantA" || releases[0].Host != "provider:10001" {
+		t.Errorf("unexpected release %+v", releases[0])
+	}
+
+	if releases := detectLockReleases(); len(releases) != 0 {
+		t.Errorf("expected release to be reported once, got %d", len(releases))
+	}
+}
+
+func TestDetectLockReleases_LeaseExpired(t *testing.T) {
+	d := setupLockWatchStore(t)
+	d.AcquireLock("alice", "tenantA", LockSourceAPI)
+	d.LeaseExpiresAt = time.Now().Add(time.Minute).UnixMilli()
+	detectLockReleases()
+
+	// Expire both the lease and the InUseTS grace window without calling ReleaseLock
+	d.LeaseExpiresAt = time.Now().Add(-time.Minute).UnixMilli()
+	d.InUseTS = time.Now().Add(-time.Minute).UnixMilli()
+
+	releases := detectLockReleases()
+	if len(releases) != 1 {
+		t.Fatalf("expected 1 release after lease expiry, got %d", len(releases))
+	}
+}
+
+func TestDetectLockReleases_HolderChanged(t *testing.T) {
+	d := setupLockWatchStore(t)
+	d.SetWSConnection(&fakeConn{})
+	d.AcquireLock("alice", "tenantA", LockSourceUI)
+	detectLockReleases()
+
+	// Admin takeover
+	d.ReleaseLock()
+	d.SetWSConnection(&fakeConn{})
+	d.AcquireLock("bob", "tenantA", LockSourceUI)
+
+	releases := detectLockReleases()
+	if len(releases) != 1 || releases[0].User != "alice" {
+		t.Fatalf("expected release for previous holder alice, got %+v", releases)
+	}
+}
+
+func TestDetectLockReleases_AutomationKeepsLock(t *testing.T) {
+	d := setupLockWatchStore(t)
+	d.AcquireLock("alice", "tenantA", LockSourceUI)
+	d.IsRunningAutomation = true
+	detectLockReleases()
+
+	d.InUseTS = time.Now().Add(-time.Minute).UnixMilli()
+	if releases := detectLockReleases(); len(releases) != 0 {
+		t.Fatalf("expected no release while automation is running, got %d", len(releases))
+	}
+
+	d.IsRunningAutomation = false
+	if releases := detectLockReleases(); len(releases) != 1 {
+		t.Fatalf("expected 1 release after automation finished, got %d", len(releases))
+	}
+}
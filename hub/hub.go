@@ -55,6 +55,9 @@ func StartHub(flags *pflag.FlagSet, appVersion string, uiFiles fs.FS, resourceFi
 	turnUsernameSuffix, _ := flags.GetString("turn-username-suffix")
 	fmt.Printf("TURN username suffix: %s. You can change it with the --turn-username-suffix flag\n", turnUsernameSuffix)
 
+	requireProviderToken, _ := flags.GetBool("require-provider-token")
+	fmt.Printf("Require provider token: %v. You can reject providers without a provider token with the --require-provider-token flag\n", requireProviderToken)
+
 	fmt.Println("Default admin username is `admin`")
 	fmt.Println("Default admin password is `password` unless you've changed it")
 
@@ -77,14 +80,15 @@ func StartHub(flags *pflag.FlagSet, appVersion string, uiFiles fs.FS, resourceFi
 	}
 
 	hubConfig := models.HubConfig{
-		HostAddress:        hostAddress,
-		Port:               port,
-		MongoDB:            mongoDB,
-		OSTempDir:          osTempDir,
-		FilesTempDir:       filesTempDir,
-		OS:                 runtime.GOOS,
-		AuthEnabled:        authEnabled,
-		TURNUsernameSuffix: turnUsernameSuffix,
+		HostAddress:          hostAddress,
+		Port:                 port,
+		MongoDB:              mongoDB,
+		OSTempDir:            osTempDir,
+		FilesTempDir:         filesTempDir,
+		OS:                   runtime.GOOS,
+		AuthEnabled:          authEnabled,
+		TURNUsernameSuffix:   turnUsernameSuffix,
+		RequireProviderToken: requireProviderToken,
 	}
 
 	// Set the global config for other hub packages to use
@@ -111,6 +115,10 @@ func StartHub(flags *pflag.FlagSet, appVersion string, uiFiles fs.FS, resourceFi
 	go devices.GetLatestDBDevices()
 	// Start a goroutine to clean hanging grid sessions
 	go router.UpdateExpiredGridSessions()
+	// Start a goroutine that runs the cleanup hooks when device locks end
+	router.RegisterLockReleaseHooks()
+	go devices.WatchLockReleases()
+	go router.ScheduleDeviceReboots()
 
 	err = db.GlobalMongoStore.AddAdminUserIfMissing()
 	if err != nil {
@@ -10,6 +10,7 @@
 package router
 
 import (
+	"GADS/common/models"
 	"GADS/hub/auth"
 	"GADS/hub/devices"
 	"context"
@@ -23,49 +24,93 @@ import (
 	"github.com/gobwas/ws"
 )
 
+// ADBTunnelHandler relays the ADB connection of the lock holder to the provider.
+// The device can be held from remote control or with an API lease, the tunnel is closed when the lock ends.
 func ADBTunnelHandler(c *gin.Context) {
 	udid := c.Param("udid")
+	device, claims, ok := getLockedDevice(c, "ADB tunnel")
+	if !ok {
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	deviceOS := device.Device.OS
+	device.Mu.RUnlock()
+	if deviceOS != "android" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ADB tunnel is only available for Android devices"})
+		return
+	}
+
+	// Connect to provider's ADB tunnel WebSocket
+	providerURL := url.URL{
+		Scheme: "ws",
+		Host:   host,
+		Path:   fmt.Sprintf("/device/%s/adb-tunnel", udid),
+	}
+	relayTunnel(c, claims, udid, models.TunnelTargetADB, 0, providerURL.String())
+}
 
+// getUISessionDevice returns the device if it runs the required OS and the user making the request is actively using it from remote control
+func getUISessionDevice(c *gin.Context, deviceOS, feature string) (*devices.LocalHubDevice, *auth.JWTClaims, bool) {
 	claims, err := auth.GetClaimsFromRequest(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
-		return
+		return nil, nil, false
 	}
-	username := claims.Username
+	return uiSessionDevice(c, claims, deviceOS, feature)
+}
+
+// getTicketUISessionDevice is getUISessionDevice for WebSocket connections authorized by the tunnel ticket in the `ticket` query parameter
+func getTicketUISessionDevice(c *gin.Context, deviceOS, feature string) (*devices.LocalHubDevice, *auth.JWTClaims, bool) {
+	claims, ok := redeemTunnelTicket(c.Query("ticket"), c.Param("udid"))
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired ticket"})
+		return nil, nil, false
+	}
+	return uiSessionDevice(c, claims, deviceOS, feature)
+}
+
+func uiSessionDevice(c *gin.Context, claims *auth.JWTClaims, deviceOS, feature string) (*devices.LocalHubDevice, *auth.JWTClaims, bool) {
+	udid := c.Param("udid")
 
 	device, ok := devices.HubDeviceStore.Get(udid)
 	if !ok || device == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Device with UDID `%s` not found", udid)})
-		return
+		return nil, nil, false
 	}
 
 	device.Mu.RLock()
+	defer device.Mu.RUnlock()
 
-	if device.Device.OS != "android" {
-		device.Mu.RUnlock()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ADB tunnel is only available for Android devices"})
-		return
+	if device.Device.OS != deviceOS {
+		osName := "Android"
+		if deviceOS == "ios" {
+			osName = "iOS"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is only available for %s devices", feature, osName)})
+		return nil, nil, false
 	}
 
-	// ADB tunnel is only allowed when the device is actively used from remote control by this user
-	if !device.HasUISession() || device.InUseBy != username {
-		device.Mu.RUnlock()
-		c.JSON(http.StatusConflict, gin.H{"error": "ADB tunnel requires an active remote control session on this device"})
-		return
+	// Only allowed when the device is actively used from remote control by this user
+	if !isInUISessionOf(device, claims) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s requires an active remote control session on this device", feature)})
+		return nil, nil, false
 	}
-	host := device.Host
-	device.Mu.RUnlock()
+	return device, claims, true
+}
 
-	// Connect to provider's ADB tunnel WebSocket
-	providerURL := url.URL{
-		Scheme: "ws",
-		Host:   host,
-		Path:   fmt.Sprintf("/device/%s/adb-tunnel", udid),
-	}
+// isInUISessionOf reports whether the user of the claims, in their tenant, remote controls the device, device.Mu must be held
+func isInUISessionOf(device *devices.LocalHubDevice, claims *auth.JWTClaims) bool {
+	return device.HasUISession() && device.IsHeldBy(claims.Username, claims.Tenant)
+}
 
-	providerConn, _, _, err := ws.DefaultDialer.Dial(context.Background(), providerURL.String())
+// relayWebSocketWhileInUse relays the client WebSocket to the provider WebSocket until either side closes
+// or the remote control session of the user on the device ends
+func relayWebSocketWhileInUse(c *gin.Context, device *devices.LocalHubDevice, claims *auth.JWTClaims, providerURL, feature string) {
+	providerConn, _, _, err := dialProviderWS(context.Background(), providerURL)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to connect to provider ADB tunnel - %s", err)})
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to connect to provider %s - %s", feature, err)})
 		return
 	}
 
@@ -81,7 +126,7 @@ func ADBTunnelHandler(c *gin.Context) {
 		providerConn.Close()
 	}()
 
-	// Monitor: close the tunnel if the remote control session ends
+	// Monitor: close the relay if the remote control session ends
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -94,10 +139,10 @@ func ADBTunnelHandler(c *gin.Context) {
 				return
 			case <-ticker.C:
 				device.Mu.RLock()
-				active := device.HasUISession() && device.InUseBy == username
+				active := isInUISessionOf(device, claims)
 				device.Mu.RUnlock()
 				if !active {
-					// Remote control session ended, close the tunnel
+					// Remote control session ended, close the relay
 					clientConn.Close()
 					providerConn.Close()
 					return
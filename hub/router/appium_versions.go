@@ -0,0 +1,42 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// pinnedVersionRegex matches exact versions, ranges and tags like `latest` would not keep the installation pinned
+	pinnedVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+	// appiumDriverNameRegex matches Appium driver names and npm package names of third-party drivers
+	appiumDriverNameRegex = regexp.MustCompile(`^(@[a-z0-9~][a-z0-9._~-]*/)?[a-z0-9~][a-z0-9._~-]*$`)
+)
+
+// validateAppiumVersions checks the pinned Appium and driver versions of a provider or workspace
+func validateAppiumVersions(versions *models.AppiumVersions) error {
+	if versions.IsEmpty() {
+		return nil
+	}
+	if versions.Appium != "" && !pinnedVersionRegex.MatchString(versions.Appium) {
+		return fmt.Errorf("Appium version `%s` must be an exact version, e.g. `2.11.3`", versions.Appium)
+	}
+	for driver, version := range versions.Drivers {
+		if !appiumDriverNameRegex.MatchString(driver) {
+			return fmt.Errorf("invalid Appium driver name `%s`", driver)
+		}
+		if !pinnedVersionRegex.MatchString(version) {
+			return fmt.Errorf("version `%s` of Appium driver `%s` must be an exact version, e.g. `3.8.0`", version, driver)
+		}
+	}
+	return nil
+}
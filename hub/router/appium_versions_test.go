@@ -0,0 +1,43 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"testing"
+
+	"GADS/common/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAppiumVersions(t *testing.T) {
+	valid := []*models.AppiumVersions{
+		nil,
+		{},
+		{Appium: "2.11.3"},
+		{Drivers: map[string]string{"uiautomator2": "3.8.0"}},
+		{Appium: "3.0.0-beta.1", Drivers: map[string]string{"xcuitest": "7.24.0", "@dlenroc/appium-roku-driver": "0.12.1"}},
+	}
+	for _, versions := range valid {
+		assert.NoError(t, validateAppiumVersions(versions), versions)
+	}
+
+	invalid := []*models.AppiumVersions{
+		{Appium: "latest"},
+		{Appium: "^2.11.0"},
+		{Appium: "2.11"},
+		{Drivers: map[string]string{"uiautomator2": "latest"}},
+		{Drivers: map[string]string{"--source=local": "1.0.0"}},
+		{Drivers: map[string]string{"UiAutomator2": "3.8.0"}},
+	}
+	for _, versions := range invalid {
+		assert.Error(t, validateAppiumVersions(versions), versions)
+	}
+}
@@ -57,9 +57,10 @@ func UpdateExpiredGridSessions() {
 			// Reset device if its not connected
 			// Or it hasn't received any Appium requests in the command timeout and is running automation
 			// Or if its provider state is not "live" - device was re-provisioned for example
+			// Draining devices keep their running sessions until they finish
 			if !hubDevice.Connected ||
 				(hubDevice.LastAutomationActionTS <= (time.Now().UnixMilli()-hubDevice.AppiumNewCommandTimeout) && hubDevice.IsRunningAutomation) ||
-				hubDevice.ProviderState != "live" {
+				(hubDevice.ProviderState != "live" && !hubDevice.IsDraining()) {
 				hubDevice.IsRunningAutomation = false
 				hubDevice.IsAvailableForAutomation = true
 				hubDevice.SessionID = ""
@@ -226,8 +227,27 @@ func AppiumGridMiddleware() gin.HandlerFunc {
 			foundDevice.Mu.RLock()
 			deviceHost := foundDevice.Host
 			deviceUDID := foundDevice.Device.UDID
+			deviceOS := foundDevice.Device.OS
 			foundDevice.Mu.RUnlock()
 
+			// Apply the requested network profile before the session starts so the app under test launches with it
+			sessionCreated := false
+			if networkProfile := models.ExtractStringCapabilityFromSession(sessionReq, capabilityPrefix+":networkProfile"); networkProfile != "" {
+				if err := setDeviceNetworkProfile(deviceHost, deviceUDID, networkProfile); err != nil {
+					foundDevice.Mu.Lock()
+					foundDevice.IsAvailableForAutomation = true
+					foundDevice.IsRunningAutomation = false
+					foundDevice.Mu.Unlock()
+					c.JSON(http.StatusBadRequest, createErrorResponse(fmt.Sprintf("GADS failed to apply network profile `%s`", networkProfile), "session not created", err.Error()))
+					return
+				}
+				defer func() {
+					if !sessionCreated {
+						go resetDeviceNetworkProfile(devices.LockRelease{UDID: deviceUDID, OS: deviceOS, Host: deviceHost})
+					}
+				}()
+			}
+
 			proxyReq, err := http.NewRequest(c.Request.Method, fmt.Sprintf("http://%s/device/%s/appium%s", deviceHost, deviceUDID, strings.Replace(c.Request.URL.Path, "/grid", "", -1)), bytes.NewBuffer(updatedSessionBody))
 			if err != nil {
 				foundDevice.Mu.Lock()
@@ -244,7 +264,7 @@ func AppiumGridMiddleware() gin.HandlerFunc {
 			}
 
 			// Send the request
-			client := &http.Client{}
+			client := &http.Client{Transport: proxyTransport}
 			resp, err := client.Do(proxyReq)
 			if err != nil {
 				foundDevice.Mu.Lock()
@@ -317,6 +337,7 @@ func AppiumGridMiddleware() gin.HandlerFunc {
 			foundDevice.Mu.Lock()
 			foundDevice.SessionID = proxySessionResponse.Value.SessionID
 			foundDevice.Mu.Unlock()
+			sessionCreated = true
 
 			// Copy the response back to the original client
 			for k, v := range resp.Header {
@@ -420,7 +441,7 @@ func AppiumGridMiddleware() gin.HandlerFunc {
 			}
 
 			// Send the request
-			client := &http.Client{}
+			client := &http.Client{Transport: proxyTransport}
 			resp, err := client.Do(proxyReq)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, createErrorResponse("GADS failed to failed to execute the proxy request to the device respective provider Appium endpoint", "", err.Error()))
@@ -484,6 +505,25 @@ func AppiumGridMiddleware() gin.HandlerFunc {
 	}
 }
 
+// setDeviceNetworkProfile applies a predefined network profile to the device through its provider
+func setDeviceNetworkProfile(host, udid, profile string) error {
+	body, err := json.Marshal(models.SetNetworkProfileRequest{Profile: profile})
+	if err != nil {
+		return err
+	}
+	resp, err := lockReleaseClient.Post(fmt.Sprintf("http://%s/device/%s/network", host, udid), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := readBody(resp.Body)
+		return fmt.Errorf("provider returned %d - %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
 func readBody(r io.Reader) ([]byte, error) {
 	body, err := io.ReadAll(r)
 	if err != nil {
@@ -540,6 +580,16 @@ func getTargetOSFromCaps(caps models.CommonCapabilities) string {
 		return "webos"
 	}
 
+	if strings.EqualFold(caps.PlatformName, "Roku") ||
+		strings.EqualFold(caps.AutomationName, "Roku") {
+		return "roku"
+	}
+
+	if strings.EqualFold(caps.PlatformName, "mock") ||
+		strings.EqualFold(caps.AutomationName, "mock") {
+		return "mock"
+	}
+
 	return ""
 }
 
@@ -578,6 +628,10 @@ func findAvailableDevice(caps models.CommonCapabilities, allowedWorkspaceIDs []s
 		}
 
 		d.Mu.Lock()
+		if d.IsDraining() {
+			d.Mu.Unlock()
+			return nil, fmt.Errorf("Device provider is draining and does not accept new sessions")
+		}
 		if d.IsAvailableForAutomation {
 			d.IsAvailableForAutomation = false
 			d.Mu.Unlock()
@@ -603,6 +657,7 @@ func findAvailableDevice(caps models.CommonCapabilities, allowedWorkspaceIDs []s
 			isLockedByOther := localDevice.IsLockedByOther(userID, userTenant)
 			localDevice.Mu.RUnlock()
 
+			// Devices of draining providers are reported in the `draining` state and are skipped here
 			if !strings.EqualFold(os, targetOS) ||
 				!connected ||
 				state != "live" ||
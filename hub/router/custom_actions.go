@@ -22,6 +22,10 @@ var validActionTypes = map[string]bool{
 	"type_text":      true,
 	"pinch_in":       true,
 	"pinch_out":      true,
+	"set_location":   true,
+	"location_route": true,
+	"reset_location": true,
+	"key":            true,
 }
 
 func validateCustomAction(action *models.CustomAction) error {
@@ -111,9 +115,47 @@ func validateParameters(actionType string, params map[string]any) error {
 			return fmt.Errorf("scale must be between 0.1 and 10")
 		}
 
-	case "pinch_in", "pinch_out":
+	case "pinch_in", "pinch_out", "reset_location":
 		// No required parameters
 
+	case "set_location":
+		latitude, ok := params["latitude"].(float64)
+		if !ok {
+			return fmt.Errorf("parameter 'latitude' is required for set_location")
+		}
+		longitude, ok := params["longitude"].(float64)
+		if !ok {
+			return fmt.Errorf("parameter 'longitude' is required for set_location")
+		}
+		if latitude < -90 || latitude > 90 {
+			return fmt.Errorf("latitude must be between -90 and 90")
+		}
+		if longitude < -180 || longitude > 180 {
+			return fmt.Errorf("longitude must be between -180 and 180")
+		}
+
+	case "location_route":
+		route, ok := params["route"].(string)
+		if !ok || route == "" {
+			return fmt.Errorf("parameter 'route' with GPX or KML content is required for location_route")
+		}
+		if len(route) > 1<<20 {
+			return fmt.Errorf("route cannot exceed 1MB")
+		}
+		if speed, ok := params["speed_kmh"].(float64); ok && (speed <= 0 || speed > 1000) {
+			return fmt.Errorf("speed_kmh must be between 0 and 1000")
+		}
+
+	case "key":
+		key, hasKey := params["key"].(string)
+		keycode, hasKeycode := params["keycode"].(float64)
+		if (!hasKey || key == "") && !hasKeycode {
+			return fmt.Errorf("parameter 'key' or 'keycode' is required for key")
+		}
+		if hasKeycode && (keycode <= 0 || keycode != float64(int(keycode))) {
+			return fmt.Errorf("keycode must be a positive integer")
+		}
+
 	default:
 		return fmt.Errorf("unsupported action type: %s", actionType)
 	}
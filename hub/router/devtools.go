@@ -0,0 +1,235 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DevToolsHandler exposes the Chrome DevTools sockets of an Android device to the user that is remote controlling it.
+// `/devtools/` lists the debuggable Chrome tabs and WebViews, `/devtools/{socket}/...` proxies the DevTools JSON endpoints
+// and the page WebSocket connections of a socket.
+func DevToolsHandler(c *gin.Context) {
+	udid := c.Param("udid")
+	device, claims, ok := getUISessionDevice(c, "android", "DevTools debugging")
+	if !ok {
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	path := c.Param("path")
+	socket, socketPath, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	socketPath = "/" + socketPath
+	newTicket := func() string { return issueTunnelTicket(udid, claims) }
+
+	if socket == "" {
+		devToolsTargets(c, host, udid, newTicket)
+		return
+	}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		providerURL := url.URL{
+			Scheme: "ws",
+			Host:   host,
+			Path:   fmt.Sprintf("/device/%s/devtools/%s%s", udid, socket, socketPath),
+		}
+		relayWebSocketWhileInUse(c, device, claims, providerURL.String(), "DevTools")
+		return
+	}
+
+	wsBase := devToolsWebSocketBase(c, udid, socket)
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = host
+			req.URL.Path = fmt.Sprintf("/device/%s/devtools/%s%s", udid, socket, socketPath)
+			query := req.URL.Query()
+			query.Del("token")
+			req.URL.RawQuery = query.Encode()
+			req.Header.Del("Authorization")
+		},
+		Transport: proxyTransport,
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del("Access-Control-Allow-Origin")
+			if resp.StatusCode != http.StatusOK || !strings.HasPrefix(socketPath, "/json") {
+				return nil
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			body = rewriteDevToolsJSON(body, wsBase, newTicket)
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.ContentLength = int64(len(body))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			return nil
+		},
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// DevToolsWebSocketHandler relays the DevTools page WebSocket of a socket for the WebSocket URLs rewritten by DevToolsHandler.
+// The connection is authorized by the single-use tunnel ticket of the URL because DevTools cannot send an Authorization header.
+func DevToolsWebSocketHandler(c *gin.Context) {
+	udid := c.Param("udid")
+	device, claims, ok := getTicketUISessionDevice(c, "android", "DevTools debugging")
+	if !ok {
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	providerURL := url.URL{
+		Scheme: "ws",
+		Host:   host,
+		Path:   fmt.Sprintf("/device/%s/devtools/%s%s", udid, c.Param("socket"), c.Param("path")),
+	}
+	relayWebSocketWhileInUse(c, device, claims, providerURL.String(), "DevTools")
+}
+
+func devToolsTargets(c *gin.Context, host, udid string, newTicket func() string) {
+	client := &http.Client{Transport: proxyTransport}
+	resp, err := client.Get(fmt.Sprintf("http://%s/device/%s/devtools", host, udid))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to get DevTools targets from provider - %s", err)})
+		return
+	}
+	defer resp.Body.Close()
+
+	var providerResp models.APIResponse[[]models.DevToolsSocket]
+	if err := json.NewDecoder(resp.Body).Decode(&providerResp); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to decode provider response - %s", err)})
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.JSON(resp.StatusCode, providerResp)
+		return
+	}
+
+	for i, socket := range providerResp.Result {
+		wsBase := devToolsWebSocketBase(c, udid, socket.Socket)
+		for j, target := range socket.Targets {
+			providerResp.Result[i].Targets[j].WebSocketDebuggerURL, providerResp.Result[i].Targets[j].DevtoolsFrontendURL =
+				rewriteDevToolsURLs(target.WebSocketDebuggerURL, target.DevtoolsFrontendURL, wsBase, newTicket)
+		}
+	}
+	c.JSON(http.StatusOK, providerResp)
+}
+
+// hubWebSocketURL returns the WebSocket URL of the path on the hub as reached by the client
+func hubWebSocketURL(c *gin.Context, path string) string {
+	scheme := "ws"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, path)
+}
+
+// devToolsWebSocketBase returns the hub URL that DevTools WebSocket paths of the socket are relative to
+func devToolsWebSocketBase(c *gin.Context, udid, socket string) string {
+	return hubWebSocketURL(c, fmt.Sprintf("/devices/control/%s/devtools-ws/%s", udid, socket))
+}
+
+// rewriteDevToolsJSON rewrites the URLs in the response of the DevTools `/json/list` and `/json/version` endpoints
+func rewriteDevToolsJSON(body []byte, wsBase string, newTicket func() string) []byte {
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+
+	rewrite := func(v any) {
+		target, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		wsURL, _ := target["webSocketDebuggerUrl"].(string)
+		frontendURL, _ := target["devtoolsFrontendUrl"].(string)
+		wsURL, frontendURL = rewriteDevToolsURLs(wsURL, frontendURL, wsBase, newTicket)
+		if wsURL != "" {
+			target["webSocketDebuggerUrl"] = wsURL
+		}
+		if frontendURL != "" {
+			target["devtoolsFrontendUrl"] = frontendURL
+		}
+	}
+	if targets, ok := payload.([]any); ok {
+		for _, target := range targets {
+			rewrite(target)
+		}
+	} else {
+		rewrite(payload)
+	}
+
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// rewriteDevToolsURLs points the WebSocket debugger URL and the `ws` parameter of the DevTools frontend URL
+// from the forwarded port on the provider to the hub. Each URL gets its own ticket from newTicket as tickets are single-use.
+func rewriteDevToolsURLs(wsURL, frontendURL, wsBase string, newTicket func() string) (string, string) {
+	hubURL := func(path string) string {
+		if newTicket == nil {
+			return wsBase + path
+		}
+		return wsBase + path + "?ticket=" + url.QueryEscape(newTicket())
+	}
+
+	if u, err := url.Parse(wsURL); err == nil && wsURL != "" {
+		wsURL = hubURL(u.Path)
+	}
+
+	if frontendURL == "" {
+		return wsURL, frontendURL
+	}
+	u, err := url.Parse(frontendURL)
+	if err != nil {
+		return wsURL, frontendURL
+	}
+	query := u.Query()
+	pagePath := query.Get("ws")
+	if pagePath == "" {
+		pagePath = query.Get("wss")
+	}
+	if _, path, found := strings.Cut(pagePath, "/"); found {
+		query.Del("ws")
+		query.Del("wss")
+		scheme, hostPath, _ := strings.Cut(hubURL("/"+path), "://")
+		query.Set(scheme, hostPath)
+	}
+	u.RawQuery = query.Encode()
+	// Relative frontend URLs are served by the DevTools socket itself, use the frontend bundled with Chrome instead
+	if u.Host == "" {
+		u.Scheme = "devtools"
+		u.Host = "devtools"
+		u.Path = "/bundled/" + strings.TrimPrefix(u.Path, "/devtools/")
+	}
+	return wsURL, u.String()
+}
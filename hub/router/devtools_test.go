@@ -0,0 +1,67 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteDevToolsURLs(t *testing.T) {
+	wsBase := "wss://hub.example.com/devices/control/udid1/devtools-ws/chrome_devtools_remote"
+
+	t.Run("Remote Frontend - Should Point To Hub", func(t *testing.T) {
+		wsURL, frontendURL := rewriteDevToolsURLs(
+			"ws://localhost:9222/devtools/page/ABC",
+			"https://chrome-devtools-frontend.appspot.com/serve_rev/@123/inspector.html?ws=localhost:9222/devtools/page/ABC",
+			wsBase, nil)
+
+		assert.Equal(t, wsBase+"/devtools/page/ABC", wsURL)
+		assert.Equal(t, "https://chrome-devtools-frontend.appspot.com/serve_rev/@123/inspector.html?wss=hub.example.com%2Fdevices%2Fcontrol%2Fudid1%2Fdevtools-ws%2Fchrome_devtools_remote%2Fdevtools%2Fpage%2FABC", frontendURL)
+	})
+
+	t.Run("Relative Frontend - Should Use Bundled DevTools", func(t *testing.T) {
+		_, frontendURL := rewriteDevToolsURLs("", "/devtools/inspector.html?ws=localhost:9222/devtools/page/ABC", wsBase, nil)
+
+		assert.Equal(t, "devtools://devtools/bundled/inspector.html?wss=hub.example.com%2Fdevices%2Fcontrol%2Fudid1%2Fdevtools-ws%2Fchrome_devtools_remote%2Fdevtools%2Fpage%2FABC", frontendURL)
+	})
+
+	t.Run("Ticket - Should Be Added To Each WebSocket URL", func(t *testing.T) {
+		issued := 0
+		newTicket := func() string {
+			issued++
+			return fmt.Sprintf("ticket %d", issued)
+		}
+		wsURL, frontendURL := rewriteDevToolsURLs(
+			"ws://localhost:9222/devtools/page/ABC",
+			"/devtools/inspector.html?ws=localhost:9222/devtools/page/ABC",
+			wsBase, newTicket)
+
+		assert.Equal(t, wsBase+"/devtools/page/ABC?ticket=ticket+1", wsURL)
+		assert.Equal(t, "devtools://devtools/bundled/inspector.html?wss=hub.example.com%2Fdevices%2Fcontrol%2Fudid1%2Fdevtools-ws%2Fchrome_devtools_remote%2Fdevtools%2Fpage%2FABC%3Fticket%3Dticket%2B2", frontendURL)
+		assert.NotContains(t, wsURL+frontendURL, "token")
+	})
+}
+
+func TestRewriteDevToolsJSON(t *testing.T) {
+	wsBase := "ws://hub:10000/devices/control/udid1/devtools-ws/webview_devtools_remote_123"
+	body := []byte(`[{"id":"1","parentId":"0","webSocketDebuggerUrl":"ws://localhost:9222/devtools/page/1"},{"id":"2"}]`)
+
+	var targets []map[string]any
+	assert.NoError(t, json.Unmarshal(rewriteDevToolsJSON(body, wsBase, nil), &targets))
+
+	assert.Len(t, targets, 2)
+	assert.Equal(t, wsBase+"/devtools/page/1", targets[0]["webSocketDebuggerUrl"])
+	assert.Equal(t, "0", targets[0]["parentId"])
+	assert.NotContains(t, targets[1], "webSocketDebuggerUrl")
+}
@@ -0,0 +1,70 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/hub/devices"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var diagnosticsClient = &http.Client{
+	Transport: proxyTransport,
+	Timeout:   10 * time.Second,
+}
+
+// GetDeviceDiagnostics godoc
+// @Summary      Get device diagnostics
+// @Description  Get the recent provider state transitions and setup steps of a device, the step it is running,
+// @Description  its setup backoff and for Tizen TVs the connection retry state - to find out why a device is not live
+// @Tags         Hub - Admin - Devices
+// @Produce      json
+// @Param        udid  path      string  true  "Device UDID"
+// @Success      200   {object}  models.APIResponse[models.DeviceDiagnostics]
+// @Failure      404   {object}  models.ErrorResponse
+// @Failure      502   {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/device/{udid}/diagnostics [get]
+func GetDeviceDiagnostics(c *gin.Context) {
+	udid := c.Param("udid")
+
+	device, ok := devices.HubDeviceStore.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with udid `%s` not found", udid))
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+	if host == "" {
+		api.NotFound(c, fmt.Sprintf("Device `%s` was not reported by its provider yet", udid))
+		return
+	}
+
+	resp, err := diagnosticsClient.Get(fmt.Sprintf("http://%s/device/%s/diagnostics", host, udid))
+	if err != nil {
+		api.ErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("Failed to get device diagnostics from provider - %s", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		api.ErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("Failed to read device diagnostics from provider - %s", err))
+		return
+	}
+	c.Data(resp.StatusCode, "application/json", body)
+}
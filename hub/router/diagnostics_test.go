@@ -0,0 +1,60 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"GADS/hub/devices"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDeviceDiagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/admin/device/:udid/diagnostics", GetDeviceDiagnostics)
+
+	t.Run("Known Device - Should Relay Provider Diagnostics", func(t *testing.T) {
+		udid := "test-device-diagnostics"
+		provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/device/"+udid+"/diagnostics", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"success":true,"result":{"udid":"` + udid + `","provider_state":"init","events":[]}}`))
+		}))
+		defer provider.Close()
+
+		devices.HubDeviceStore.Set(udid, &devices.LocalHubDevice{
+			Device: models.DBDevice{UDID: udid},
+			Host:   strings.TrimPrefix(provider.URL, "http://"),
+		})
+		defer devices.HubDeviceStore.Delete(udid)
+
+		req, _ := http.NewRequest(http.MethodGet, "/admin/device/"+udid+"/diagnostics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"provider_state":"init"`)
+	})
+
+	t.Run("Unknown Device - Should Return 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/admin/device/unknown-device/diagnostics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
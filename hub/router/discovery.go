@@ -0,0 +1,385 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/db"
+	"GADS/common/models"
+	"GADS/hub/devices"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// pendingDeviceTimeout is how long a pending device is listed after its provider last reported it
+const pendingDeviceTimeout = 30 * time.Second
+
+// autoApproveRetryInterval is how often a pending device is matched against the discovery rules again,
+// so devices whose auto-approval failed or that match a rule added later do not stay pending
+const autoApproveRetryInterval = 10 * time.Second
+
+type pendingDevice struct {
+	device          models.DiscoveredDevice
+	lastReported    time.Time
+	lastAutoApprove time.Time
+	approving       bool // an auto-approval attempt for the device is running
+}
+
+var (
+	// pendingDevices holds the unregistered devices reported by providers with discovery enabled, by provider and UDID
+	pendingDevices   = make(map[string]*pendingDevice)
+	pendingDevicesMu sync.Mutex
+)
+
+var validDeviceUsages = []string{"enabled", "automation", "control", "disabled"}
+
+func pendingDeviceKey(provider, udid string) string {
+	return provider + "/" + udid
+}
+
+// updatePendingDevices replaces the pending devices of the provider with its latest report
+// and auto-approves the ones that match a discovery rule, retrying the devices that are still pending
+func updatePendingDevices(provider string, discovered []models.DiscoveredDevice) {
+	now := time.Now()
+	reported := make(map[string]bool)
+	var toApprove []models.DiscoveredDevice
+
+	pendingDevicesMu.Lock()
+	for _, device := range discovered {
+		// Approved devices are reported until the provider picks them up from the DB
+		if _, registered := devices.HubDeviceStore.Get(device.UDID); registered {
+			continue
+		}
+		device.Provider = provider
+		key := pendingDeviceKey(provider, device.UDID)
+		reported[key] = true
+		pending, ok := pendingDevices[key]
+		if !ok {
+			pending = &pendingDevice{}
+			pendingDevices[key] = pending
+		}
+		pending.device = device
+		pending.lastReported = now
+		if !pending.approving && now.Sub(pending.lastAutoApprove) >= autoApproveRetryInterval {
+			pending.approving = true
+			pending.lastAutoApprove = now
+			toApprove = append(toApprove, device)
+		}
+	}
+	for key, pending := range pendingDevices {
+		if pending.device.Provider == provider && !reported[key] {
+			delete(pendingDevices, key)
+		}
+	}
+	pendingDevicesMu.Unlock()
+
+	if len(toApprove) > 0 {
+		go runAutoApprove(toApprove)
+	}
+}
+
+// runAutoApprove matches the pending devices against the discovery rules, replaced in tests
+var runAutoApprove = autoApproveDevices
+
+func autoApproveDevices(discovered []models.DiscoveredDevice) {
+	defer func() {
+		pendingDevicesMu.Lock()
+		for _, device := range discovered {
+			if pending, ok := pendingDevices[pendingDeviceKey(device.Provider, device.UDID)]; ok {
+				pending.approving = false
+			}
+		}
+		pendingDevicesMu.Unlock()
+	}()
+
+	rules, err := db.GlobalMongoStore.GetDiscoveryRules()
+	if err != nil {
+		log.Warnf("Device discovery - failed to get discovery rules - %s", err)
+		return
+	}
+
+	for _, device := range discovered {
+		for _, rule := range rules {
+			if !matchDiscoveryRule(rule, device) {
+				continue
+			}
+			if err := registerDiscoveredDevice(device, rule.WorkspaceID, "", rule.Usage); err != nil {
+				log.Warnf("Device discovery - failed to auto-approve device `%s` of provider `%s` with rule `%s`, retrying in %v - %s", device.UDID, device.Provider, rule.ID, autoApproveRetryInterval, err)
+			} else {
+				log.Infof("Device discovery - auto-approved device `%s` of provider `%s` with rule `%s`", device.UDID, device.Provider, rule.ID)
+			}
+			break
+		}
+	}
+}
+
+// matchDiscoveryRule reports whether the device matches all the criteria set on the rule
+func matchDiscoveryRule(rule models.DiscoveryRule, device models.DiscoveredDevice) bool {
+	if rule.Provider != "" && rule.Provider != device.Provider {
+		return false
+	}
+	if rule.OS != "" && !strings.EqualFold(rule.OS, device.OS) {
+		return false
+	}
+	if rule.ModelPattern != "" {
+		matched, err := regexp.MatchString(rule.ModelPattern, device.Model)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// registerDiscoveredDevice adds the discovered device to the DB, the provider sets it up once it syncs its devices
+func registerDiscoveredDevice(device models.DiscoveredDevice, workspaceID, name, usage string) error {
+	if workspaceID == "" {
+		workspace, err := db.GlobalMongoStore.GetDefaultWorkspace()
+		if err != nil {
+			return fmt.Errorf("failed to get default workspace - %w", err)
+		}
+		workspaceID = workspace.ID
+	} else if _, err := db.GlobalMongoStore.GetWorkspaceByID(workspaceID); err != nil {
+		return fmt.Errorf("workspace `%s` not found", workspaceID)
+	}
+	if name == "" {
+		name = device.Name
+	}
+	if usage == "" {
+		usage = "enabled"
+		if strings.EqualFold(device.OS, "tizen") {
+			usage = "automation"
+		}
+	}
+
+	dbDevice := models.DBDevice{
+		UDID:         device.UDID,
+		OS:           device.OS,
+		Name:         name,
+		OSVersion:    device.OSVersion,
+		Provider:     device.Provider,
+		Usage:        usage,
+		ScreenWidth:  device.ScreenWidth,
+		ScreenHeight: device.ScreenHeight,
+		DeviceType:   device.DeviceType,
+		WorkspaceID:  workspaceID,
+		StreamType:   models.MJPEGStreamType.ID,
+	}
+	if err := models.ValidateDevice(&dbDevice); err != nil {
+		return err
+	}
+	return db.GlobalMongoStore.AddOrUpdateDevice(&dbDevice)
+}
+
+// GetPendingDevices godoc
+// @Summary      Get pending devices
+// @Description  List the connected devices that providers with discovery enabled report as not registered
+// @Tags         Hub - Admin - Devices
+// @Produce      json
+// @Success      200  {object}  models.SuccessResponse
+// @Security     BearerAuth
+// @Router       /admin/devices/pending [get]
+func GetPendingDevices(c *gin.Context) {
+	pendingDevicesMu.Lock()
+	pending := []models.DiscoveredDevice{}
+	for _, device := range pendingDevices {
+		if time.Since(device.lastReported) < pendingDeviceTimeout {
+			pending = append(pending, device.device)
+		}
+	}
+	pendingDevicesMu.Unlock()
+
+	api.OK(c, "", pending)
+}
+
+// ApprovePendingDevice godoc
+// @Summary      Approve a pending device
+// @Description  Register a discovered device with its detected info, in the default workspace unless another one is provided
+// @Tags         Hub - Admin - Devices
+// @Accept       json
+// @Produce      json
+// @Param        udid     path      string                       true   "Device UDID"
+// @Param        request  body      models.ApproveDeviceRequest  false  "Approval options"
+// @Success      200      {object}  models.SuccessResponse
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Failure      409      {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/devices/pending/{udid}/approve [post]
+func ApprovePendingDevice(c *gin.Context) {
+	udid := c.Param("udid")
+
+	var request models.ApproveDeviceRequest
+	if c.Request.ContentLength > 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+			api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+			return
+		}
+	}
+	if request.Usage != "" && !slices.Contains(validDeviceUsages, request.Usage) {
+		api.BadRequest(c, fmt.Sprintf("usage must be one of %s", strings.Join(validDeviceUsages, ", ")))
+		return
+	}
+
+	pending, err := findPendingDevice(udid, request.Provider)
+	if err != nil {
+		if errors.Is(err, errPendingDeviceAmbiguous) {
+			api.Conflict(c, err.Error())
+			return
+		}
+		api.NotFound(c, err.Error())
+		return
+	}
+
+	if err := registerDiscoveredDevice(pending, request.WorkspaceID, request.Name, request.Usage); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Failed to approve device - %s", err))
+		return
+	}
+	api.OKMessage(c, fmt.Sprintf("Device `%s` of provider `%s` approved", udid, pending.Provider))
+}
+
+var errPendingDeviceAmbiguous = errors.New("pending device is reported by multiple providers")
+
+// findPendingDevice returns the pending device with the UDID, the provider is only required
+// when more than one provider reports the same UDID
+func findPendingDevice(udid, provider string) (models.DiscoveredDevice, error) {
+	pendingDevicesMu.Lock()
+	defer pendingDevicesMu.Unlock()
+
+	if provider != "" {
+		if pending, ok := pendingDevices[pendingDeviceKey(provider, udid)]; ok {
+			return pending.device, nil
+		}
+		return models.DiscoveredDevice{}, fmt.Errorf("No pending device with udid `%s` on provider `%s`", udid, provider)
+	}
+
+	var matches []models.DiscoveredDevice
+	for _, pending := range pendingDevices {
+		if pending.device.UDID == udid {
+			matches = append(matches, pending.device)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return models.DiscoveredDevice{}, fmt.Errorf("No pending device with udid `%s`", udid)
+	case 1:
+		return matches[0], nil
+	}
+	providers := make([]string, 0, len(matches))
+	for _, match := range matches {
+		providers = append(providers, match.Provider)
+	}
+	slices.Sort(providers)
+	return models.DiscoveredDevice{}, fmt.Errorf("%w - `%s` is reported by `%s`, set the provider in the request", errPendingDeviceAmbiguous, udid, strings.Join(providers, "`, `"))
+}
+
+// GetDiscoveryRules godoc
+// @Summary      Get discovery rules
+// @Description  List the rules that auto-approve discovered devices
+// @Tags         Hub - Admin - Devices
+// @Produce      json
+// @Success      200  {object}  models.SuccessResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/discovery-rules [get]
+func GetDiscoveryRules(c *gin.Context) {
+	rules, err := db.GlobalMongoStore.GetDiscoveryRules()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to get discovery rules - %s", err))
+		return
+	}
+	if rules == nil {
+		rules = []models.DiscoveryRule{}
+	}
+	api.OK(c, "", rules)
+}
+
+// AddDiscoveryRule godoc
+// @Summary      Add a discovery rule
+// @Description  Auto-approve discovered devices matching the provider, OS and model pattern into a workspace
+// @Tags         Hub - Admin - Devices
+// @Accept       json
+// @Produce      json
+// @Param        rule  body      models.DiscoveryRule  true  "Discovery rule"
+// @Success      200   {object}  models.SuccessResponse
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      500   {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/discovery-rules [post]
+func AddDiscoveryRule(c *gin.Context) {
+	var rule models.DiscoveryRule
+	if err := json.NewDecoder(c.Request.Body).Decode(&rule); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	if rule.Provider == "" && rule.OS == "" && rule.ModelPattern == "" {
+		api.BadRequest(c, "At least one of provider, os or model_pattern is required")
+		return
+	}
+	if rule.ModelPattern != "" {
+		if _, err := regexp.Compile(rule.ModelPattern); err != nil {
+			api.BadRequest(c, fmt.Sprintf("Invalid model_pattern - %s", err))
+			return
+		}
+	}
+	if rule.Usage != "" && !slices.Contains(validDeviceUsages, rule.Usage) {
+		api.BadRequest(c, fmt.Sprintf("usage must be one of %s", strings.Join(validDeviceUsages, ", ")))
+		return
+	}
+	if rule.WorkspaceID != "" {
+		if _, err := db.GlobalMongoStore.GetWorkspaceByID(rule.WorkspaceID); err != nil {
+			api.BadRequest(c, fmt.Sprintf("Workspace `%s` not found", rule.WorkspaceID))
+			return
+		}
+	}
+
+	rule.ID = uuid.NewString()
+	rule.CreatedBy = c.GetString("username")
+	rule.CreatedAt = time.Now().UnixMilli()
+	if err := db.GlobalMongoStore.AddDiscoveryRule(rule); err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to add discovery rule - %s", err))
+		return
+	}
+	api.OK(c, "Discovery rule added", rule)
+}
+
+// DeleteDiscoveryRule godoc
+// @Summary      Delete a discovery rule
+// @Tags         Hub - Admin - Devices
+// @Produce      json
+// @Param        id   path      string  true  "Rule ID"
+// @Success      200  {object}  models.SuccessResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/discovery-rules/{id} [delete]
+func DeleteDiscoveryRule(c *gin.Context) {
+	id := c.Param("id")
+	if err := db.GlobalMongoStore.DeleteDiscoveryRule(id); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			api.NotFound(c, fmt.Sprintf("Discovery rule `%s` not found", id))
+			return
+		}
+		api.InternalError(c, fmt.Sprintf("Failed to delete discovery rule - %s", err))
+		return
+	}
+	api.OKMessage(c, "Discovery rule deleted")
+}
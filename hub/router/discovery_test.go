@@ -0,0 +1,153 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchDiscoveryRule(t *testing.T) {
+	device := models.DiscoveredDevice{
+		UDID:     "R58M123",
+		OS:       "android",
+		Model:    "samsung SM-G991B",
+		Provider: "provider-1",
+	}
+
+	t.Run("All Criteria Match - Should Match", func(t *testing.T) {
+		rule := models.DiscoveryRule{Provider: "provider-1", OS: "Android", ModelPattern: "^samsung SM-G"}
+		assert.True(t, matchDiscoveryRule(rule, device))
+	})
+
+	t.Run("Only OS Set - Should Match Any Provider And Model", func(t *testing.T) {
+		assert.True(t, matchDiscoveryRule(models.DiscoveryRule{OS: "android"}, device))
+	})
+
+	t.Run("Different Provider - Should Not Match", func(t *testing.T) {
+		assert.False(t, matchDiscoveryRule(models.DiscoveryRule{Provider: "provider-2", OS: "android"}, device))
+	})
+
+	t.Run("Different OS - Should Not Match", func(t *testing.T) {
+		assert.False(t, matchDiscoveryRule(models.DiscoveryRule{OS: "ios"}, device))
+	})
+
+	t.Run("Model Pattern Mismatch - Should Not Match", func(t *testing.T) {
+		assert.False(t, matchDiscoveryRule(models.DiscoveryRule{ModelPattern: "Pixel"}, device))
+	})
+
+	t.Run("Invalid Model Pattern - Should Not Match", func(t *testing.T) {
+		assert.False(t, matchDiscoveryRule(models.DiscoveryRule{ModelPattern: "("}, device))
+	})
+}
+
+// usePendingDevices isolates the pending devices and records the devices passed to auto-approval
+func usePendingDevices(t *testing.T) chan []models.DiscoveredDevice {
+	originalPending, originalAutoApprove := pendingDevices, runAutoApprove
+	pendingDevices = make(map[string]*pendingDevice)
+	approvals := make(chan []models.DiscoveredDevice, 10)
+	runAutoApprove = func(discovered []models.DiscoveredDevice) {
+		approvals <- discovered
+	}
+	t.Cleanup(func() {
+		pendingDevicesMu.Lock()
+		pendingDevices = originalPending
+		pendingDevicesMu.Unlock()
+		runAutoApprove = originalAutoApprove
+	})
+	return approvals
+}
+
+func finishAutoApprove(devices ...models.DiscoveredDevice) {
+	pendingDevicesMu.Lock()
+	defer pendingDevicesMu.Unlock()
+	for _, device := range devices {
+		if pending, ok := pendingDevices[pendingDeviceKey(device.Provider, device.UDID)]; ok {
+			pending.approving = false
+		}
+	}
+}
+
+func TestUpdatePendingDevices(t *testing.T) {
+	t.Run("Same UDID On Two Providers - Should Keep Both", func(t *testing.T) {
+		approvals := usePendingDevices(t)
+		updatePendingDevices("provider-1", []models.DiscoveredDevice{{UDID: "R58M123", OS: "android"}})
+		updatePendingDevices("provider-2", []models.DiscoveredDevice{{UDID: "R58M123", OS: "android"}})
+		<-approvals
+		<-approvals
+
+		pendingDevicesMu.Lock()
+		assert.Len(t, pendingDevices, 2)
+		assert.Contains(t, pendingDevices, pendingDeviceKey("provider-1", "R58M123"))
+		assert.Contains(t, pendingDevices, pendingDeviceKey("provider-2", "R58M123"))
+		pendingDevicesMu.Unlock()
+
+		// A provider report only replaces its own devices
+		updatePendingDevices("provider-1", nil)
+		pendingDevicesMu.Lock()
+		assert.Len(t, pendingDevices, 1)
+		assert.Contains(t, pendingDevices, pendingDeviceKey("provider-2", "R58M123"))
+		pendingDevicesMu.Unlock()
+	})
+
+	t.Run("Still Pending - Should Retry Auto-Approval", func(t *testing.T) {
+		approvals := usePendingDevices(t)
+		device := models.DiscoveredDevice{UDID: "R58M123", OS: "android"}
+		updatePendingDevices("provider-1", []models.DiscoveredDevice{device})
+		approved := <-approvals
+		assert.Equal(t, "provider-1", approved[0].Provider)
+
+		// Not retried while the attempt runs or before the retry interval
+		updatePendingDevices("provider-1", []models.DiscoveredDevice{device})
+		finishAutoApprove(approved...)
+		updatePendingDevices("provider-1", []models.DiscoveredDevice{device})
+		assert.Empty(t, approvals)
+
+		pendingDevicesMu.Lock()
+		pendingDevices[pendingDeviceKey("provider-1", "R58M123")].lastAutoApprove = time.Now().Add(-autoApproveRetryInterval)
+		pendingDevicesMu.Unlock()
+		updatePendingDevices("provider-1", []models.DiscoveredDevice{device})
+		retried := <-approvals
+		assert.Equal(t, "R58M123", retried[0].UDID)
+	})
+}
+
+func TestFindPendingDevice(t *testing.T) {
+	approvals := usePendingDevices(t)
+	updatePendingDevices("provider-1", []models.DiscoveredDevice{{UDID: "shared", Name: "first"}, {UDID: "single"}})
+	updatePendingDevices("provider-2", []models.DiscoveredDevice{{UDID: "shared", Name: "second"}})
+	<-approvals
+	<-approvals
+
+	t.Run("Single Provider - Should Not Require The Provider", func(t *testing.T) {
+		device, err := findPendingDevice("single", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "provider-1", device.Provider)
+	})
+
+	t.Run("Multiple Providers - Should Require The Provider", func(t *testing.T) {
+		_, err := findPendingDevice("shared", "")
+		assert.ErrorIs(t, err, errPendingDeviceAmbiguous)
+
+		device, err := findPendingDevice("shared", "provider-2")
+		assert.NoError(t, err)
+		assert.Equal(t, "second", device.Name)
+	})
+
+	t.Run("Unknown Device - Should Not Be Found", func(t *testing.T) {
+		_, err := findPendingDevice("missing", "")
+		assert.Error(t, err)
+		_, err = findPendingDevice("single", "provider-2")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,98 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/db"
+	"GADS/common/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var drainClient = &http.Client{
+	Transport: proxyTransport,
+	Timeout:   10 * time.Second,
+}
+
+// GetProviderDrain godoc
+// @Summary      Get provider drain status
+// @Description  Get if the provider is draining and how many Appium sessions and streams are still active on it
+// @Tags         Hub - Admin - Providers
+// @Produce      json
+// @Param        nickname  path      string  true  "Provider nickname"
+// @Success      200       {object}  models.SuccessResponse
+// @Failure      404       {object}  models.ErrorResponse
+// @Failure      502       {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/providers/{nickname}/drain [get]
+func GetProviderDrain(c *gin.Context) {
+	proxyProviderDrain(c, http.MethodGet, nil)
+}
+
+// SetProviderDrain godoc
+// @Summary      Toggle provider drain mode
+// @Description  A draining provider serves its running sessions but the hub does not start new Appium sessions, remote control or leases on its devices
+// @Tags         Hub - Admin - Providers
+// @Accept       json
+// @Produce      json
+// @Param        nickname  path      string                       true  "Provider nickname"
+// @Param        request   body      models.ProviderDrainRequest  true  "Drain mode"
+// @Success      200       {object}  models.SuccessResponse
+// @Failure      400       {object}  models.ErrorResponse
+// @Failure      404       {object}  models.ErrorResponse
+// @Failure      502       {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/providers/{nickname}/drain [post]
+func SetProviderDrain(c *gin.Context) {
+	var request models.ProviderDrainRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+	body, _ := json.Marshal(request)
+	proxyProviderDrain(c, http.MethodPost, body)
+}
+
+func proxyProviderDrain(c *gin.Context, method string, body []byte) {
+	nickname := c.Param("nickname")
+	provider, err := db.GlobalMongoStore.GetProvider(nickname)
+	if err != nil {
+		api.NotFound(c, fmt.Sprintf("Provider `%s` not found", nickname))
+		return
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%v/drain", provider.HostAddress, provider.Port), bytes.NewReader(body))
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to create provider request - %s", err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := drainClient.Do(req)
+	if err != nil {
+		api.ErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("Failed to reach provider `%s` - %s", nickname, err))
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		api.ErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("Failed to read provider `%s` response - %s", nickname, err))
+		return
+	}
+	c.Data(resp.StatusCode, "application/json", respBody)
+}
@@ -91,6 +91,10 @@ func HandleRequests(uiFiles fs.FS) *gin.Engine {
 	authGroup.POST("/devices/control/:udid/lock", LockDevice)
 	authGroup.POST("/devices/control/:udid/unlock", UnlockDevice)
 	authGroup.POST("/provider-update", ProviderUpdate)
+	authGroup.GET("/provider-channel", ProviderChannel)
+	// DevTools and Web Inspector WebSockets are authorized by the single-use tunnel ticket in their URL
+	authGroup.GET("/devices/control/:udid/devtools-ws/:socket/*path", DevToolsWebSocketHandler)
+	authGroup.GET("/devices/control/:udid/webinspector-ws/:app/:page", WebInspectorWebSocketHandler)
 	// OAuth2 endpoints (unauthenticated)
 	authGroup.POST("/oauth/token", OAuth2TokenEndpoint)
 	// Enable authentication on the endpoints below
@@ -102,18 +106,34 @@ func HandleRequests(uiFiles fs.FS) *gin.Engine {
 	authGroup.GET("/health", HealthCheck)
 	authGroup.POST("/logout", auth.LogoutHandler)
 	authGroup.GET("/devices/control/:udid/adb-tunnel", ADBTunnelHandler)
+	authGroup.Any("/devices/control/:udid/devtools/*path", DevToolsHandler)
+	authGroup.GET("/devices/control/:udid/webinspector/*path", WebInspectorHandler)
+	authGroup.GET("/devices/control/:udid/tunnel", TunnelHandler)
+	authGroup.GET("/devices/control/:udid/usbmux-tunnel", UsbmuxTunnelHandler)
 	authGroup.Any("/device/:udid/*path", DeviceProxyHandler)
 	authGroup.Any("/provider/:name/*path", ProviderProxyHandler)
 	authGroup.GET("/admin/providers", GetProviders)
 	authGroup.POST("/admin/providers/add", AddProvider)
 	authGroup.POST("/admin/providers/update", UpdateProvider)
 	authGroup.DELETE("/admin/providers/:nickname", DeleteProvider)
+	authGroup.GET("/admin/providers/:nickname/drain", GetProviderDrain)
+	authGroup.POST("/admin/providers/:nickname/drain", SetProviderDrain)
+	authGroup.POST("/admin/providers/:nickname/token", GenerateProviderToken)
 	authGroup.GET("/admin/providers/logs", GetProviderLogs)
 	authGroup.POST("/admin/device", AddDevice)
 	authGroup.PUT("/admin/device", UpdateDevice)
 	authGroup.DELETE("/admin/device/:udid", DeleteDevice)
 	authGroup.POST("/admin/device/:udid/release", ReleaseUsedDevice)
+	authGroup.POST("/admin/device/:udid/reboot", RebootDeviceAdmin)
+	authGroup.GET("/admin/device/:udid/diagnostics", GetDeviceDiagnostics)
 	authGroup.GET("/admin/devices", GetDevices)
+	authGroup.GET("/admin/devices/pending", GetPendingDevices)
+	authGroup.POST("/admin/devices/pending/:udid/approve", ApprovePendingDevice)
+	authGroup.GET("/admin/discovery-rules", GetDiscoveryRules)
+	authGroup.POST("/admin/discovery-rules", AddDiscoveryRule)
+	authGroup.DELETE("/admin/discovery-rules/:id", DeleteDiscoveryRule)
+	authGroup.GET("/admin/tunnels", GetActiveTunnels)
+	authGroup.GET("/admin/tunnels/audit", GetTunnelAuditLogs)
 	authGroup.POST("/admin/user", AddUser)
 	authGroup.GET("/admin/users", GetUsers)
 	authGroup.GET("/admin/files", GetFiles)
@@ -162,6 +182,21 @@ func HandleRequests(uiFiles fs.FS) *gin.Engine {
 	authGroup.POST("/custom-actions/favorites/:id", AddUserFavorite)
 	authGroup.DELETE("/custom-actions/favorites/:id", RemoveUserFavorite)
 
+	// Provider API for providers that do not connect to MongoDB, authenticated with provider tokens
+	providerAPIGroup := r.Group("/provider-api")
+	providerAPIGroup.Use(ProviderTokenMiddleware())
+	providerAPIGroup.GET("/config", ProviderAPIGetConfig)
+	providerAPIGroup.GET("/devices", ProviderAPIGetDevices)
+	providerAPIGroup.PUT("/devices", ProviderAPIUpdateDevice)
+	providerAPIGroup.GET("/devices/:udid/stream-settings", ProviderAPIGetDeviceStreamSettings)
+	providerAPIGroup.PUT("/devices/:udid/stream-settings", ProviderAPIUpdateDeviceStreamSettings)
+	providerAPIGroup.POST("/devices/:udid/appium-logs", ProviderAPIAddAppiumLog)
+	providerAPIGroup.GET("/workspaces/default", ProviderAPIGetDefaultWorkspace)
+	providerAPIGroup.GET("/workspaces/:id", ProviderAPIGetWorkspace)
+	providerAPIGroup.GET("/turn-config", ProviderAPIGetTURNConfig)
+	providerAPIGroup.GET("/stream-settings", ProviderAPIGetStreamSettings)
+	providerAPIGroup.GET("/files", ProviderAPIDownloadFile)
+
 	appiumGroup := r.Group("/grid")
 	appiumGroup.Use(AppiumGridMiddleware())
 	appiumGroup.Any("/*path")
@@ -0,0 +1,74 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/hub/devices"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var lockReleaseClient = &http.Client{
+	Transport: proxyTransport,
+	Timeout:   30 * time.Second,
+}
+
+// RegisterLockReleaseHooks registers the device cleanups that should run on the provider once a device lock ends
+func RegisterLockReleaseHooks() {
+	devices.OnLockReleased(resetDeviceLocation)
+	devices.OnLockReleased(resetDeviceNetworkProfile)
+	devices.OnLockReleased(closeDeviceTunnels)
+	devices.OnLockReleased(stopDeviceRecording)
+}
+
+// resetDeviceLocation restores the real location of the device so a spoofed location does not leak to the next user
+func resetDeviceLocation(release devices.LockRelease) {
+	resetOnProvider(release, "location")
+}
+
+// resetDeviceNetworkProfile removes the network shaping so the next user gets the real device network
+func resetDeviceNetworkProfile(release devices.LockRelease) {
+	resetOnProvider(release, "network")
+}
+
+// stopDeviceRecording stops a screen recording the lock holder left running so its processes do not outlive the session
+func stopDeviceRecording(release devices.LockRelease) {
+	callOnProvider(release, http.MethodPost, "recording/stop")
+}
+
+// resetOnProvider calls DELETE on the provider device endpoint for Android and iOS devices
+func resetOnProvider(release devices.LockRelease, endpoint string) {
+	callOnProvider(release, http.MethodDelete, endpoint)
+}
+
+// callOnProvider calls the provider device endpoint for Android and iOS devices, a missing resource is not an error
+func callOnProvider(release devices.LockRelease, method, endpoint string) {
+	if release.Host == "" || (release.OS != "android" && release.OS != "ios") {
+		return
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/device/%s/%s", release.Host, release.UDID, endpoint), nil)
+	if err != nil {
+		return
+	}
+	resp, err := lockReleaseClient.Do(req)
+	if err != nil {
+		log.Warnf("Failed to reset %s of device `%s` after lock release - %s", endpoint, release.UDID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		log.Warnf("Failed to reset %s of device `%s` after lock release - provider returned %d", endpoint, release.UDID, resp.StatusCode)
+	}
+}
@@ -0,0 +1,313 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"GADS/common/mockdevice"
+	"GADS/common/models"
+	"GADS/hub/devices"
+	providerconfig "GADS/provider/config"
+	providerdevices "GADS/provider/devices"
+	"GADS/provider/logger"
+	providerrouter "GADS/provider/router"
+	"GADS/provider/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The mock device tests run the hub against a provider started with `--mock-devices`,
+// the hub requests go through the provider router to the services of the mock devices
+
+const (
+	mockTestProvider  = "unit"
+	mockTestTenant    = "mock-tenant"
+	mockTestWorkspace = "mock-workspace"
+)
+
+// mockStreamStore is the provider store of the mock devices, it only serves the stream settings
+type mockStreamStore struct {
+	store.Store
+}
+
+func (s *mockStreamStore) GetDeviceStreamSettings(udid string) (models.DeviceStreamSettings, error) {
+	return models.DeviceStreamSettings{UDID: udid, StreamTargetFPS: 30, StreamJpegQuality: 50, StreamScalingFactor: 100}, nil
+}
+
+// mockProvider is a provider with mock devices served by its router
+type mockProvider struct {
+	server  *httptest.Server
+	udids   []string
+	devices map[string]*providerdevices.MockDevice
+	// logs are the logs of the mock devices, e.g. the received remote control commands
+	logs *logtest.Hook
+}
+
+// setupMockDevices starts the `--mock-devices` devices of a provider, serves them with the provider router
+// and adds them to the hub device store like the provider reports them
+func setupMockDevices(t *testing.T, deviceCount int) *mockProvider {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if logger.ProviderLogger == nil {
+		discard := logrus.New()
+		discard.SetOutput(io.Discard)
+		logger.ProviderLogger = &logger.CustomLogger{Logger: discard}
+	}
+
+	previousConfig, previousLocal, previousStore := providerconfig.ProviderConfig, providerconfig.Local, store.GlobalStore
+	t.Cleanup(func() {
+		providerconfig.ProviderConfig, providerconfig.Local, store.GlobalStore = previousConfig, previousLocal, previousStore
+	})
+	providerconfig.ProviderConfig = &models.Provider{Nickname: mockTestProvider, ProviderFolder: t.TempDir()}
+	providerconfig.Local = providerconfig.DefaultLocalConfig()
+	providerconfig.Local.MockDevices = deviceCount
+	store.GlobalStore = &mockStreamStore{}
+
+	deviceLogger, logs := logtest.NewNullLogger()
+	provider := &mockProvider{
+		server:  httptest.NewServer(providerrouter.HandleRequests()),
+		devices: make(map[string]*providerdevices.MockDevice),
+		logs:    logs,
+	}
+	t.Cleanup(provider.server.Close)
+
+	for i := 1; i <= deviceCount; i++ {
+		udid := mockdevice.UDID(mockTestProvider, i)
+		dev := &providerdevices.MockDevice{}
+		dev.DBDevice = models.DBDevice{
+			UDID:         udid,
+			OS:           "mock",
+			Name:         fmt.Sprintf("Mock %d", i),
+			OSVersion:    "1.0.0",
+			Provider:     mockTestProvider,
+			Usage:        "enabled",
+			ScreenWidth:  "360",
+			ScreenHeight: "640",
+			WorkspaceID:  mockTestWorkspace,
+		}
+		dev.Logger = &logger.CustomLogger{Logger: deviceLogger}
+		ctx, cancel := context.WithCancel(context.Background())
+		dev.SetNewContext(ctx, cancel)
+		require.NoError(t, dev.Setup())
+		require.Equal(t, "live", dev.GetProviderState())
+		providerdevices.DevManager.Set(udid, dev)
+		t.Cleanup(func() {
+			dev.Reset("Mock device test finished")
+			providerdevices.DevManager.Delete(udid)
+		})
+
+		devices.HubDeviceStore.Set(udid, &devices.LocalHubDevice{
+			Device:                   dev.DBDevice,
+			Host:                     strings.TrimPrefix(provider.server.URL, "http://"),
+			Connected:                true,
+			ProviderState:            dev.GetProviderState(),
+			LastUpdatedTimestamp:     time.Now().UnixMilli(),
+			IsAvailableForAutomation: true,
+			Available:                true,
+		})
+		t.Cleanup(func() { devices.HubDeviceStore.Delete(udid) })
+
+		provider.udids = append(provider.udids, udid)
+		provider.devices[udid] = dev
+	}
+	return provider
+}
+
+// newMockHub serves the hub device proxy
+func newMockHub(t *testing.T) *httptest.Server {
+	t.Helper()
+	r := gin.New()
+	r.Any("/device/:udid/*path", DeviceProxyHandler)
+	hub := httptest.NewServer(r)
+	t.Cleanup(hub.Close)
+	return hub
+}
+
+func lockMockDevice(t *testing.T, udid string) {
+	t.Helper()
+	device, _ := devices.HubDeviceStore.Get(udid)
+	device.Mu.Lock()
+	defer device.Mu.Unlock()
+	require.NoError(t, device.AcquireLock("someone-else", mockTestTenant, devices.LockSourceAPI))
+	device.LeaseExpiresAt = time.Now().Add(time.Minute).UnixMilli()
+}
+
+func TestMockDevices_GridSkipsDevicesLockedByOtherUsers(t *testing.T) {
+	udids := setupMockDevices(t, 2).udids
+	lockMockDevice(t, udids[0])
+
+	caps := models.CommonCapabilities{PlatformName: "mock", AutomationName: "mock"}
+	found, err := findAvailableDevice(caps, []string{mockTestWorkspace}, "user", mockTestTenant)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, udids[1], found.Device.UDID)
+
+	// The found device is reserved for the session so no other mock device is left
+	found, _ = findAvailableDevice(caps, []string{mockTestWorkspace}, "user", mockTestTenant)
+	assert.Nil(t, found)
+
+	found, err = findAvailableDevice(caps, []string{"other-workspace"}, "user", mockTestTenant)
+	assert.Error(t, err)
+	assert.Nil(t, found)
+}
+
+func TestMockDevices_StreamProxy(t *testing.T) {
+	udids := setupMockDevices(t, 2).udids
+	lockMockDevice(t, udids[0])
+	hub := newMockHub(t)
+
+	t.Run("Device stream is proxied by the hub", func(t *testing.T) {
+		wsURL := "ws" + strings.TrimPrefix(hub.URL, "http") + "/device/" + udids[1] + "/android-stream"
+		conn, br, _, err := ws.Dial(context.Background(), wsURL)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// The first frame can arrive with the handshake response and be buffered in br
+		var reader io.Reader = conn
+		if br != nil {
+			reader = br
+		}
+		frame, err := wsutil.ReadServerBinary(struct {
+			io.Reader
+			io.Writer
+		}{reader, conn})
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		require.NoError(t, err)
+		assert.Equal(t, 360, img.Bounds().Dx())
+		assert.Equal(t, 640, img.Bounds().Dy())
+	})
+
+	t.Run("Device stream is refused for devices locked by other users", func(t *testing.T) {
+		resp, err := http.Get(hub.URL + "/device/" + udids[0] + "/android-stream")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+}
+
+func TestMockDevices_RemoteControl(t *testing.T) {
+	provider := setupMockDevices(t, 1)
+	udid := provider.udids[0]
+	hub := newMockHub(t)
+
+	t.Run("Tap reaches the mock device control server", func(t *testing.T) {
+		resp, err := http.Post(hub.URL+"/device/"+udid+"/tap", "application/json", strings.NewReader(`{"x":120,"y":240}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var received bool
+		for _, entry := range provider.logs.AllEntries() {
+			if strings.Contains(entry.Message, "Received `tap` command") {
+				received = true
+			}
+		}
+		assert.True(t, received, "mock device did not log the tap command")
+	})
+
+	t.Run("Screenshot is the frame of the mock screen", func(t *testing.T) {
+		resp, err := http.Post(hub.URL+"/device/"+udid+"/screenshot", "application/json", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var screenshot models.APIResponse[any]
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&screenshot))
+		frame, err := base64.StdEncoding.DecodeString(screenshot.Message)
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		require.NoError(t, err)
+		assert.Equal(t, 360, img.Bounds().Dx())
+	})
+
+	t.Run("Appium sessions are served by the mock device", func(t *testing.T) {
+		appiumURL := provider.server.URL + "/device/" + udid + "/appium"
+		body := `{"capabilities":{"alwaysMatch":{"platformName":"mock","appium:automationName":"mock"},"firstMatch":[{}]}}`
+		resp, err := http.Post(appiumURL+"/session", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var session struct {
+			Value struct {
+				SessionID    string         `json:"sessionId"`
+				Capabilities map[string]any `json:"capabilities"`
+			} `json:"value"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+		require.NotEmpty(t, session.Value.SessionID)
+		assert.Equal(t, udid, session.Value.Capabilities["appium:udid"])
+
+		dev := provider.devices[udid]
+		assert.True(t, dev.GetHasAppiumSession())
+		assert.Equal(t, session.Value.SessionID, dev.GetAppiumSessionID())
+
+		req, _ := http.NewRequest(http.MethodDelete, appiumURL+"/session/"+session.Value.SessionID, nil)
+		deleteResp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		deleteResp.Body.Close()
+		assert.Equal(t, http.StatusOK, deleteResp.StatusCode)
+		assert.False(t, dev.GetHasAppiumSession())
+	})
+}
+
+func TestIsNewMockDevice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registered := mockdevice.UDID(mockTestProvider, 1)
+	devices.HubDeviceStore.Set(registered, &devices.LocalHubDevice{Device: models.DBDevice{UDID: registered, OS: "mock", Provider: "unit"}})
+	t.Cleanup(func() { devices.HubDeviceStore.Delete(registered) })
+
+	tests := []struct {
+		name        string
+		mockDevices string
+		device      models.DBDevice
+		want        bool
+	}{
+		{"new mock device", "2", models.DBDevice{UDID: "mock-unit-2", OS: "mock"}, true},
+		{"provider without mock devices", "", models.DBDevice{UDID: "mock-unit-2", OS: "mock"}, false},
+		{"index above the mock devices count", "2", models.DBDevice{UDID: "mock-unit-3", OS: "mock"}, false},
+		{"mock device of another provider", "2", models.DBDevice{UDID: "mock-other-2", OS: "mock"}, false},
+		{"arbitrary UDID", "2", models.DBDevice{UDID: "00008110-001234", OS: "mock"}, false},
+		{"not the mock OS", "2", models.DBDevice{UDID: "mock-unit-2", OS: "android"}, false},
+		{"already registered", "2", models.DBDevice{UDID: registered, OS: "mock"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPut, "/provider-api/devices", nil)
+			if tt.mockDevices != "" {
+				c.Request.Header.Set(mockdevice.CountHeader, tt.mockDevices)
+			}
+			c.Set("provider", models.Provider{Nickname: "unit"})
+
+			assert.Equal(t, tt.want, isNewMockDevice(c, tt.device))
+		})
+	}
+}
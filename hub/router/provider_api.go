@@ -0,0 +1,300 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/auth"
+	"GADS/common/constants"
+	"GADS/common/db"
+	"GADS/common/mockdevice"
+	"GADS/common/models"
+	"GADS/hub/devices"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The provider API serves the configuration, settings and files to the providers that do not connect to MongoDB.
+// Providers authenticate with the provider token generated for them by an admin.
+
+// ProviderTokenMiddleware authenticates the provider API requests by the provider token
+func ProviderTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || !strings.HasPrefix(token, auth.ProviderTokenPrefix) {
+			api.ErrorResponse(c, http.StatusUnauthorized, "Missing or invalid provider token")
+			c.Abort()
+			return
+		}
+
+		provider, err := db.GlobalMongoStore.GetProviderByTokenHash(auth.HashProviderToken(token))
+		if err != nil {
+			api.ErrorResponse(c, http.StatusUnauthorized, "Missing or invalid provider token")
+			c.Abort()
+			return
+		}
+		c.Set("provider", provider)
+		c.Next()
+	}
+}
+
+func tokenProvider(c *gin.Context) models.Provider {
+	return c.MustGet("provider").(models.Provider)
+}
+
+// GenerateProviderToken godoc
+// @Summary      Generate a provider token
+// @Description  Generate a token the provider uses to get its configuration and data from the hub instead of MongoDB. It replaces the previous token of the provider and is only returned once.
+// @Tags         Hub - Admin - Providers
+// @Produce      json
+// @Param        nickname  path      string  true  "Provider nickname"
+// @Success      200       {object}  models.APIResponse[models.ProviderTokenResponse]
+// @Failure      404       {object}  models.ErrorResponse
+// @Failure      500       {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/providers/{nickname}/token [post]
+func GenerateProviderToken(c *gin.Context) {
+	nickname := c.Param("nickname")
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to generate provider token - %s", err))
+		return
+	}
+	token := auth.ProviderTokenPrefix + hex.EncodeToString(secret)
+
+	if err := db.GlobalMongoStore.SetProviderTokenHash(nickname, auth.HashProviderToken(token)); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			api.NotFound(c, fmt.Sprintf("Provider `%s` not found", nickname))
+			return
+		}
+		api.InternalError(c, fmt.Sprintf("Failed to store provider token - %s", err))
+		return
+	}
+	api.OK(c, "Provider token generated, it will not be shown again", models.ProviderTokenResponse{Token: token})
+}
+
+// ProviderAPIGetConfig returns the configuration of the token provider
+func ProviderAPIGetConfig(c *gin.Context) {
+	api.OK(c, "Provider configuration", tokenProvider(c))
+}
+
+// ProviderAPIGetDevices returns the devices registered to the token provider
+func ProviderAPIGetDevices(c *gin.Context) {
+	providerDevices, err := db.GlobalMongoStore.GetProviderDevices(tokenProvider(c).Nickname)
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to get provider devices - %s", err))
+		return
+	}
+	api.OK(c, "Provider devices", providerDevices)
+}
+
+// ProviderAPIUpdateDevice updates a device registered to the token provider
+func ProviderAPIUpdateDevice(c *gin.Context) {
+	var device models.DBDevice
+	if err := c.ShouldBindJSON(&device); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+	if device.Provider != tokenProvider(c).Nickname || !(providerOwnsDevice(c, device.UDID) || isNewMockDevice(c, device)) {
+		api.NotFound(c, fmt.Sprintf("Device `%s` is not registered to this provider", device.UDID))
+		return
+	}
+
+	if err := db.GlobalMongoStore.AddOrUpdateDevice(&device); err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to update device - %s", err))
+		return
+	}
+	api.OKMessage(c, "Device updated")
+}
+
+// providerOwnsDevice reports whether the device is registered to the token provider
+func providerOwnsDevice(c *gin.Context, udid string) bool {
+	hubDevice, ok := devices.HubDeviceStore.Get(udid)
+	if !ok {
+		return false
+	}
+	hubDevice.Mu.RLock()
+	defer hubDevice.Mu.RUnlock()
+	return hubDevice.Device.Provider == tokenProvider(c).Nickname
+}
+
+// isNewMockDevice reports whether the device is a `--mock-devices` device that the provider registers itself,
+// other devices are added to providers on the hub. Only providers reporting their mock devices count can register them
+// and only with the mock device UDIDs of the provider.
+func isNewMockDevice(c *gin.Context, device models.DBDevice) bool {
+	mockDevices, err := strconv.Atoi(c.GetHeader(mockdevice.CountHeader))
+	if err != nil || device.OS != "mock" || !mockdevice.IsProviderUDID(device.UDID, tokenProvider(c).Nickname, mockDevices) {
+		return false
+	}
+	_, ok := devices.HubDeviceStore.Get(device.UDID)
+	return !ok
+}
+
+// ProviderAPIGetDefaultWorkspace returns the default workspace
+func ProviderAPIGetDefaultWorkspace(c *gin.Context) {
+	workspace, err := db.GlobalMongoStore.GetDefaultWorkspace()
+	if err != nil {
+		api.NotFound(c, "Default workspace not found")
+		return
+	}
+	api.OK(c, "Default workspace", workspace)
+}
+
+// ProviderAPIGetWorkspace returns a workspace by its ID
+func ProviderAPIGetWorkspace(c *gin.Context) {
+	workspace, err := db.GlobalMongoStore.GetWorkspaceByID(c.Param("id"))
+	if err != nil {
+		api.NotFound(c, fmt.Sprintf("Workspace `%s` not found", c.Param("id")))
+		return
+	}
+	api.OK(c, "Workspace", workspace)
+}
+
+// ProviderAPIGetTURNConfig returns the TURN configuration for the device WebRTC streams
+func ProviderAPIGetTURNConfig(c *gin.Context) {
+	turnConfig, err := db.GlobalMongoStore.GetTURNConfig()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to get TURN config - %s", err))
+		return
+	}
+	api.OK(c, "TURN config", turnConfig)
+}
+
+// ProviderAPIGetStreamSettings returns the global stream settings
+func ProviderAPIGetStreamSettings(c *gin.Context) {
+	settings, err := db.GlobalMongoStore.GetGlobalStreamSettings()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to get global stream settings - %s", err))
+		return
+	}
+	api.OK(c, "Global stream settings", settings)
+}
+
+// ProviderAPIGetDeviceStreamSettings returns the stream settings of a provider device
+func ProviderAPIGetDeviceStreamSettings(c *gin.Context) {
+	udid := c.Param("udid")
+	if !providerOwnsDevice(c, udid) {
+		api.NotFound(c, fmt.Sprintf("Device `%s` is not registered to this provider", udid))
+		return
+	}
+
+	settings, err := db.GlobalMongoStore.GetDeviceStreamSettings(udid)
+	if err != nil {
+		api.NotFound(c, fmt.Sprintf("No stream settings for device `%s`", udid))
+		return
+	}
+	api.OK(c, "Device stream settings", settings)
+}
+
+// ProviderAPIUpdateDeviceStreamSettings stores the stream settings of a provider device
+func ProviderAPIUpdateDeviceStreamSettings(c *gin.Context) {
+	udid := c.Param("udid")
+	if !providerOwnsDevice(c, udid) {
+		api.NotFound(c, fmt.Sprintf("Device `%s` is not registered to this provider", udid))
+		return
+	}
+
+	var settings models.DeviceStreamSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+	settings.UDID = udid
+
+	if err := db.GlobalMongoStore.UpdateDeviceStreamSettings(udid, settings); err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to update device stream settings - %s", err))
+		return
+	}
+	api.OKMessage(c, "Device stream settings updated")
+}
+
+// ProviderAPIDownloadFile serves a file uploaded to the hub, e.g. the WebDriverAgent IPA, by its name or ID
+func ProviderAPIDownloadFile(c *gin.Context) {
+	fileName := c.Query("name")
+	fileID := c.Query("id")
+	if fileName == "" && fileID == "" {
+		api.BadRequest(c, "Provide the file `name` or `id`")
+		return
+	}
+
+	downloadStream, err := db.GlobalMongoStore.OpenFileDownloadStream(fileName, fileID)
+	if err != nil {
+		api.NotFound(c, fmt.Sprintf("File not found - %s", err))
+		return
+	}
+	defer downloadStream.Close()
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, downloadStream)
+}
+
+// appiumLogCollections holds the devices whose Appium logs collection was already set up
+var appiumLogCollections sync.Map
+
+// ProviderAPIAddAppiumLog stores an Appium log of a provider device
+func ProviderAPIAddAppiumLog(c *gin.Context) {
+	udid := c.Param("udid")
+	if !providerOwnsDevice(c, udid) {
+		api.NotFound(c, fmt.Sprintf("Device `%s` is not registered to this provider", udid))
+		return
+	}
+
+	var appiumLog models.AppiumPluginLog
+	if err := c.ShouldBindJSON(&appiumLog); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	if _, ok := appiumLogCollections.Load(udid); !ok {
+		if err := setupAppiumLogCollection(udid); err != nil {
+			api.InternalError(c, fmt.Sprintf("Failed to set up the Appium logs collection - %s", err))
+			return
+		}
+		appiumLogCollections.Store(udid, true)
+	}
+
+	if err := db.GlobalMongoStore.AddAppiumLog(udid, appiumLog); err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to store Appium log - %s", err))
+		return
+	}
+	api.OKMessage(c, "Logged successfully")
+}
+
+// setupAppiumLogCollection creates the capped Appium logs collection of a device like the providers with MongoDB access do
+func setupAppiumLogCollection(udid string) error {
+	exists, _ := db.GlobalMongoStore.CheckCollectionExistsWithDB("appium_logs_new", udid)
+	if exists {
+		return nil
+	}
+	if err := db.GlobalMongoStore.CreateCappedCollectionWithDB("appium_logs_new", udid, 30000, 30); err != nil {
+		return err
+	}
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "timestamp", Value: constants.SortAscending},
+			{Key: "session_id", Value: constants.SortAscending},
+			{Key: "sequenceNumber", Value: constants.SortAscending},
+		},
+	}
+	return db.GlobalMongoStore.AddCollectionIndexWithDB("appium_logs_new", udid, indexModel)
+}
@@ -0,0 +1,37 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderTokenMiddleware_RejectsMissingOrForeignToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ProviderTokenMiddleware())
+	router.GET("/config", ProviderAPIGetConfig)
+
+	for _, header := range []string{"", "Bearer some-user-jwt", "gads_pt_without_bearer"} {
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/config", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "header %q", header)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/auth"
+	"GADS/common/db"
+	"GADS/common/models"
+	"GADS/hub/config"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/ws"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errInvalidProviderToken  = errors.New("invalid provider token")
+	errProviderTokenRequired = errors.New("provider token required")
+)
+
+// providerSigningKeys holds the signing key of each enrolled provider by its `host:port` address.
+// A key is registered when the provider authenticates with its token, the hub requests to that address are then signed with it.
+var providerSigningKeys sync.Map
+
+// providerFromToken resolves the provider by the token sent with a provider update or channel request, nil when no token is sent
+func providerFromToken(req *http.Request) (*models.Provider, error) {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return nil, nil
+	}
+	if !strings.HasPrefix(token, auth.ProviderTokenPrefix) {
+		return nil, errInvalidProviderToken
+	}
+
+	provider, err := db.GlobalMongoStore.GetProviderByTokenHash(auth.HashProviderToken(token))
+	if err != nil {
+		return nil, errInvalidProviderToken
+	}
+	provider.SigningKey = auth.ProviderSigningKey(token)
+	return &provider, nil
+}
+
+// authorizeProvider checks that the provider with the nickname can push its data with the token provider.
+// Enrolled providers must send their token, the others are accepted unless the hub requires provider tokens.
+func authorizeProvider(tokenProvider *models.Provider, nickname string) error {
+	if tokenProvider != nil {
+		if tokenProvider.Nickname != nickname {
+			return errInvalidProviderToken
+		}
+		providerSigningKeys.Store(fmt.Sprintf("%s:%v", tokenProvider.HostAddress, tokenProvider.Port), tokenProvider.SigningKey)
+		// Enrolled providers do not connect to MongoDB and rely on the hub to keep their timestamp fresh
+		if err := db.GlobalMongoStore.UpdateProviderTimestamp(nickname); err != nil {
+			log.Debugf("Failed to update provider `%s` timestamp - %s", nickname, err)
+		}
+		return nil
+	}
+
+	if config.GlobalHubConfig.RequireProviderToken {
+		return errProviderTokenRequired
+	}
+	provider, err := db.GlobalMongoStore.GetProvider(nickname)
+	if err != nil {
+		return fmt.Errorf("provider `%s` is not registered", nickname)
+	}
+	if provider.TokenHash != "" {
+		return errProviderTokenRequired
+	}
+	return nil
+}
+
+// providerSigningTransport signs the hub requests to the enrolled providers
+type providerSigningTransport struct {
+	base http.RoundTripper
+}
+
+func (t *providerSigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if key, ok := providerSigningKeys.Load(req.URL.Host); ok {
+		// RoundTrip must not modify the request
+		req = req.Clone(req.Context())
+		if err := auth.SignProviderRequest(req, key.(string)); err != nil {
+			return nil, fmt.Errorf("failed to sign the provider request - %w", err)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// dialProviderWS opens a WebSocket to a provider, signed if the provider is enrolled
+func dialProviderWS(ctx context.Context, providerURL string) (net.Conn, *bufio.Reader, ws.Handshake, error) {
+	req, err := http.NewRequest(http.MethodGet, providerURL, nil)
+	if err != nil {
+		return nil, nil, ws.Handshake{}, err
+	}
+
+	dialer := providerWSDialer
+	if key, ok := providerSigningKeys.Load(req.URL.Host); ok {
+		if err := auth.SignProviderRequest(req, key.(string)); err != nil {
+			return nil, nil, ws.Handshake{}, err
+		}
+		dialer.Header = ws.HandshakeHeaderHTTP(req.Header)
+	}
+	return dialer.Dial(ctx, providerURL)
+}
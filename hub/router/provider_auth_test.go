@@ -0,0 +1,49 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderSigningTransport(t *testing.T) {
+	key := auth.ProviderSigningKey(auth.ProviderTokenPrefix + "secret")
+
+	var verifyErr error
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyErr = auth.VerifyProviderRequest(r, key)
+	}))
+	defer provider.Close()
+
+	providerURL, _ := url.Parse(provider.URL)
+	client := &http.Client{Transport: proxyTransport}
+
+	// Requests to providers that are not enrolled are sent unsigned
+	resp, err := client.Get(provider.URL + "/device/udid/info")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.ErrorIs(t, verifyErr, auth.ErrMissingProviderSignature)
+
+	providerSigningKeys.Store(providerURL.Host, key)
+	defer providerSigningKeys.Delete(providerURL.Host)
+
+	req, _ := http.NewRequest(http.MethodPost, provider.URL+"/device/udid/tap?x=1", nil)
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, verifyErr)
+	assert.Empty(t, req.Header.Get(auth.ProviderSignatureHeader), "the original request must not be modified")
+}
@@ -0,0 +1,143 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/db"
+	"GADS/common/models"
+	"GADS/common/mux"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	log "github.com/sirupsen/logrus"
+)
+
+// providerChannelKeepalive matches the ping interval of the providers
+const providerChannelKeepalive = 10 * time.Second
+
+var (
+	// providerChannels holds the channel of each connected provider by its nickname
+	providerChannels sync.Map
+	// providerChannelNicknames maps the registered `host:port` address of each connected provider to its nickname,
+	// the requests to that address go over the provider channel instead of a direct connection
+	providerChannelNicknames sync.Map
+)
+
+var providerDialer = &net.Dialer{
+	Timeout:   10 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// providerWSDialer dials the provider WebSockets through dialProvider
+var providerWSDialer = ws.Dialer{
+	NetDial: dialProvider,
+}
+
+// dialProvider connects to a provider over its channel if it has one, otherwise directly.
+// All hub requests to providers go through it, so providers behind NAT or a firewall work the same as local ones.
+func dialProvider(ctx context.Context, network, addr string) (net.Conn, error) {
+	if nickname, ok := providerChannelNicknames.Load(addr); ok {
+		if value, ok := providerChannels.Load(nickname); ok {
+			return value.(*mux.Session).Open()
+		}
+	}
+	return providerDialer.DialContext(ctx, network, addr)
+}
+
+// ProviderChannel godoc
+// @Summary      Provider channel
+// @Description  Persistent WebSocket opened by a provider. The provider pushes its device state changes over it
+// @Description  and the hub sends the requests to the provider devices back over the same connection.
+// @Description  Only providers enrolled with a provider token can open a channel.
+// @Tags         Hub - Admin - Providers
+// @Param        Authorization  header  string  true  "Bearer provider token"
+// @Success      101  {string}  string  "Switching Protocols"
+// @Failure      401  {object}  models.ErrorResponse
+// @Router       /provider-channel [get]
+func ProviderChannel(c *gin.Context) {
+	tokenProvider, err := providerFromToken(c.Request)
+	if err == nil && tokenProvider == nil {
+		err = errProviderTokenRequired
+	}
+	if err != nil {
+		api.ErrorResponse(c, http.StatusUnauthorized, fmt.Sprintf("Provider channel rejected - %s", err))
+		return
+	}
+	// The channel belongs to the provider the token was issued for, not to the nickname or address the provider reports
+	nickname := tokenProvider.Nickname
+	address := fmt.Sprintf("%s:%v", tokenProvider.HostAddress, tokenProvider.Port)
+
+	conn, rw, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	if err != nil {
+		log.Errorf("Failed upgrading provider channel connection - %s", err)
+		return
+	}
+
+	session := mux.NewSession(mux.NewWebSocketConn(conn, rw.Reader, false), false, providerChannelKeepalive)
+	defer session.Close()
+
+	connected := false
+	defer func() {
+		if connected && providerChannels.CompareAndDelete(nickname, session) {
+			providerChannelNicknames.CompareAndDelete(address, nickname)
+			log.Infof("Provider `%s` channel at `%s` closed - %v", nickname, address, session.Err())
+		}
+	}()
+
+	for {
+		message, err := session.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var providerDeviceData models.ProviderData
+		if err := json.Unmarshal(message, &providerDeviceData); err != nil {
+			log.Warnf("Invalid provider channel message from `%s` - %s", c.Request.RemoteAddr, err)
+			continue
+		}
+
+		if !connected {
+			if err := authorizeProvider(tokenProvider, providerDeviceData.ProviderData.Nickname); err != nil {
+				log.Warnf("Provider channel from `%s` for `%s` rejected - %s", c.Request.RemoteAddr, providerDeviceData.ProviderData.Nickname, err)
+				return
+			}
+			if previous, loaded := providerChannels.Swap(nickname, session); loaded {
+				previous.(*mux.Session).Close()
+			}
+			providerChannelNicknames.Store(address, nickname)
+			connected = true
+			log.Infof("Provider `%s` connected over the provider channel from `%s`", nickname, c.Request.RemoteAddr)
+		} else if providerDeviceData.ProviderData.Nickname != nickname {
+			log.Warnf("Provider channel of `%s` sent data for `%s`, closing it", nickname, providerDeviceData.ProviderData.Nickname)
+			return
+		} else {
+			// Enrolled providers do not connect to MongoDB and rely on the hub to keep their timestamp fresh
+			if err := db.GlobalMongoStore.UpdateProviderTimestamp(nickname); err != nil {
+				log.Debugf("Failed to update provider `%s` timestamp - %s", nickname, err)
+			}
+		}
+
+		result := applyProviderData(&providerDeviceData, true)
+		if result.ResyncRequired {
+			resultJSON, _ := json.Marshal(result)
+			if err := session.SendMessage(resultJSON); err != nil {
+				return
+			}
+		}
+	}
+}
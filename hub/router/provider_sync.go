@@ -0,0 +1,113 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"GADS/hub/devices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// syncedProviders holds the nicknames of the providers that did a full sync since the hub started
+var syncedProviders sync.Map
+
+func markProviderSynced(nickname string) {
+	if _, loaded := syncedProviders.Swap(nickname, true); !loaded {
+		log.Infof("Provider `%s` completed a full sync", nickname)
+	}
+}
+
+// isProviderSynced reports whether the provider did a full sync since the hub started,
+// providers that did not are asked for one in the update response
+func isProviderSynced(nickname string) bool {
+	_, ok := syncedProviders.Load(nickname)
+	return ok
+}
+
+// restoreAutomationSession restores the Appium session the provider reports on a full sync,
+// e.g. when the hub restarted while the session was running. Caller holds device.Mu.
+func restoreAutomationSession(target *devices.LocalHubDevice, source *models.ProviderDeviceSync) {
+	if source.AppiumSessionID == "" || target.SessionID != "" {
+		return
+	}
+	target.SessionID = source.AppiumSessionID
+	target.IsRunningAutomation = true
+	target.IsAvailableForAutomation = false
+	target.LastAutomationActionTS = time.Now().UnixMilli()
+	if target.AppiumNewCommandTimeout == 0 {
+		target.AppiumNewCommandTimeout = 60000
+	}
+	log.Infof("Restored Appium session `%s` of device `%s` from provider sync", source.AppiumSessionID, target.Device.UDID)
+}
+
+// applyProviderData applies the device state sent by a provider over HTTP or the provider channel.
+// Providers on the channel only send the devices that changed, so the message itself
+// refreshes all their connected devices.
+func applyProviderData(providerDeviceData *models.ProviderData, incremental bool) models.ProviderUpdateResult {
+	nickname := providerDeviceData.ProviderData.Nickname
+	// A full sync is only complete if the hub already knows all the provider devices - it loads them from the DB on start
+	fullSynced := providerDeviceData.FullSync
+	for i := range providerDeviceData.DeviceData {
+		providerDevice := &providerDeviceData.DeviceData[i]
+		hubDevice, ok := devices.HubDeviceStore.Get(providerDevice.UDID)
+		if !ok {
+			fullSynced = false
+			continue
+		}
+		hubDevice.Mu.Lock()
+		// A provider only updates its own devices, otherwise it could point other devices at its host and take their traffic
+		if hubDevice.Device.Provider != nickname {
+			owner := hubDevice.Device.Provider
+			hubDevice.Mu.Unlock()
+			log.Warnf("Provider `%s` sent an update for device `%s` of provider `%s`, ignoring it", nickname, providerDevice.UDID, owner)
+			continue
+		}
+		// If device is not connected reset all fields that might allow it to get stuck in Running automation state
+		if !providerDevice.Connected {
+			hubDevice.Connected = false
+			hubDevice.ProviderState = providerDevice.ProviderState
+			hubDevice.Host = providerDevice.Host
+			hubDevice.IsAvailableForAutomation = false
+			hubDevice.IsRunningAutomation = false
+			hubDevice.ReleaseLockIfNotHeld()
+			hubDevice.SessionID = ""
+			hubDevice.Mu.Unlock()
+			continue
+		}
+		// Stamp when we last heard from the provider about this device
+		hubDevice.LastUpdatedTimestamp = time.Now().UnixMilli()
+
+		syncDeviceFields(hubDevice, providerDevice)
+		if providerDeviceData.FullSync {
+			restoreAutomationSession(hubDevice, providerDevice)
+		}
+		hubDevice.Mu.Unlock()
+	}
+	if incremental {
+		now := time.Now().UnixMilli()
+		for _, hubDevice := range devices.HubDeviceStore.All() {
+			hubDevice.Mu.Lock()
+			if hubDevice.Device.Provider == nickname && hubDevice.Connected {
+				hubDevice.LastUpdatedTimestamp = now
+			}
+			hubDevice.Mu.Unlock()
+		}
+	}
+	updatePendingDevices(nickname, providerDeviceData.DiscoveredDevices)
+
+	if fullSynced {
+		markProviderSynced(nickname)
+	}
+
+	return models.ProviderUpdateResult{ResyncRequired: !isProviderSynced(nickname)}
+}
@@ -0,0 +1,96 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"GADS/hub/devices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreAutomationSession(t *testing.T) {
+	t.Run("Session Reported After Hub Restart - Should Be Restored", func(t *testing.T) {
+		device := &devices.LocalHubDevice{IsAvailableForAutomation: true}
+		restoreAutomationSession(device, &models.ProviderDeviceSync{AppiumSessionID: "session-1"})
+
+		assert.Equal(t, "session-1", device.SessionID)
+		assert.True(t, device.IsRunningAutomation)
+		assert.False(t, device.IsAvailableForAutomation)
+		assert.Equal(t, int64(60000), device.AppiumNewCommandTimeout)
+		assert.NotZero(t, device.LastAutomationActionTS)
+	})
+
+	t.Run("Hub Already Tracks A Session - Should Keep It", func(t *testing.T) {
+		device := &devices.LocalHubDevice{SessionID: "session-1", AppiumNewCommandTimeout: 120000}
+		restoreAutomationSession(device, &models.ProviderDeviceSync{AppiumSessionID: "session-2"})
+
+		assert.Equal(t, "session-1", device.SessionID)
+		assert.Equal(t, int64(120000), device.AppiumNewCommandTimeout)
+	})
+
+	t.Run("No Session Reported - Should Not Change Device", func(t *testing.T) {
+		device := &devices.LocalHubDevice{IsAvailableForAutomation: true}
+		restoreAutomationSession(device, &models.ProviderDeviceSync{})
+
+		assert.Empty(t, device.SessionID)
+		assert.False(t, device.IsRunningAutomation)
+		assert.True(t, device.IsAvailableForAutomation)
+	})
+}
+
+func TestProviderSynced(t *testing.T) {
+	assert.False(t, isProviderSynced("sync-test-provider"))
+	markProviderSynced("sync-test-provider")
+	assert.True(t, isProviderSynced("sync-test-provider"))
+}
+
+func TestApplyProviderData_IgnoresDevicesOfOtherProviders(t *testing.T) {
+	udid := "provider-sync-test-device"
+	devices.HubDeviceStore.Set(udid, &devices.LocalHubDevice{
+		Device:                   models.DBDevice{UDID: udid, Provider: "provider-b"},
+		Host:                     "provider-b:10001",
+		Connected:                true,
+		ProviderState:            "live",
+		InUseBy:                  "user",
+		IsAvailableForAutomation: true,
+	})
+	defer devices.HubDeviceStore.Delete(udid)
+
+	applyProviderData(&models.ProviderData{
+		ProviderData: models.Provider{Nickname: "provider-a"},
+		DeviceData: []models.ProviderDeviceSync{
+			{UDID: udid, Host: "provider-a:10001", Connected: true, ProviderState: "live"},
+		},
+	}, false)
+	applyProviderData(&models.ProviderData{
+		ProviderData: models.Provider{Nickname: "provider-a"},
+		DeviceData:   []models.ProviderDeviceSync{{UDID: udid, Host: "provider-a:10001"}},
+	}, false)
+
+	device, _ := devices.HubDeviceStore.Get(udid)
+	device.Mu.RLock()
+	assert.Equal(t, "provider-b:10001", device.Host)
+	assert.True(t, device.Connected)
+	assert.Equal(t, "live", device.ProviderState)
+	assert.Equal(t, "user", device.InUseBy)
+	assert.True(t, device.IsAvailableForAutomation)
+	device.Mu.RUnlock()
+
+	// The owning provider still updates the device
+	applyProviderData(&models.ProviderData{
+		ProviderData: models.Provider{Nickname: "provider-b"},
+		DeviceData:   []models.ProviderDeviceSync{{UDID: udid, Host: "provider-b:10002", Connected: true, ProviderState: "live"}},
+	}, false)
+	device.Mu.RLock()
+	assert.Equal(t, "provider-b:10002", device.Host)
+	device.Mu.RUnlock()
+}
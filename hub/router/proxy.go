@@ -23,10 +23,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var proxyTransport = &http.Transport{
-	MaxIdleConnsPerHost: 10,
-	DisableCompression:  true,
-	IdleConnTimeout:     60 * time.Second,
+var proxyTransport = &providerSigningTransport{
+	base: &http.Transport{
+		DialContext:         dialProvider,
+		MaxIdleConnsPerHost: 10,
+		DisableCompression:  true,
+		IdleConnTimeout:     60 * time.Second,
+	},
 }
 
 // Get capability prefix from environment variable, default to "gads"
@@ -62,6 +65,12 @@ func DeviceProxyHandler(c *gin.Context) {
 		return
 	}
 
+	// DevTools and Web Inspector are limited to the user remote controlling the device
+	if strings.HasPrefix(path, "/devtools") || strings.HasPrefix(path, "/webinspector") {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Use /devices/control/%s/%s/ for web debugging", udid, strings.SplitN(path, "/", 3)[1])})
+		return
+	}
+
 	var username string
 	var tenant string
 
@@ -0,0 +1,188 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/db"
+	"GADS/hub/devices"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	// The zone database is embedded so reboot schedule time zones work on hosts without one, e.g. Windows
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// rebootWindow is how long after the scheduled time a busy device can still be rebooted once it becomes idle
+const rebootWindow = time.Hour
+
+var rebootClient = &http.Client{
+	Transport: proxyTransport,
+	Timeout:   60 * time.Second,
+}
+
+// RebootDeviceAdmin godoc
+// @Summary      Reboot a device
+// @Description  Reboot a device no matter if it is in use, it is re-provisioned automatically once it reconnects
+// @Tags         Hub - Admin - Devices
+// @Produce      json
+// @Param        udid  path      string  true  "Device UDID"
+// @Success      200   {object}  models.SuccessResponse
+// @Failure      404   {object}  models.ErrorResponse
+// @Failure      409   {object}  models.ErrorResponse
+// @Failure      501   {object}  models.ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/device/{udid}/reboot [post]
+func RebootDeviceAdmin(c *gin.Context) {
+	udid := c.Param("udid")
+
+	device, ok := devices.HubDeviceStore.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with udid `%s` not found", udid))
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	status, body, err := rebootOnProvider(host, udid)
+	if err != nil {
+		api.ErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("Failed to reboot device on provider - %s", err))
+		return
+	}
+	c.Data(status, "application/json", body)
+}
+
+// rebootOnProvider asks the provider of the device to reboot it
+func rebootOnProvider(host, udid string) (int, []byte, error) {
+	if host == "" {
+		return 0, nil, fmt.Errorf("device has no provider host")
+	}
+	resp, err := rebootClient.Post(fmt.Sprintf("http://%s/device/%s/reboot", host, udid), "application/json", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// parseRebootSchedule parses a daily `HH:MM` reboot time with an optional IANA time zone, e.g. `03:00 Europe/Berlin`.
+// Schedules without a time zone are in UTC so they do not depend on the time zone of the hub host.
+func parseRebootSchedule(schedule string) (hour, minute int, location *time.Location, err error) {
+	clock, zone, _ := strings.Cut(strings.TrimSpace(schedule), " ")
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("reboot schedule must be a daily time in `HH:MM` format with an optional time zone, e.g. `03:00 Europe/Berlin`")
+	}
+	location = time.UTC
+	if zone = strings.TrimSpace(zone); zone != "" {
+		// time.LoadLocation treats `Local` as the hub time zone, which is what the explicit zone avoids
+		if zone == "Local" {
+			return 0, 0, nil, fmt.Errorf("reboot schedule time zone must be an IANA time zone like `Europe/Berlin` or `UTC`")
+		}
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("reboot schedule has an unknown time zone `%s`", zone)
+		}
+	}
+	return t.Hour(), t.Minute(), location, nil
+}
+
+// dueRebootSlot returns the scheduled reboot time that is due at `now`, if any.
+// A slot stays due for rebootWindow so devices that are busy at the scheduled time are rebooted once idle.
+func dueRebootSlot(schedule string, now time.Time) (time.Time, bool) {
+	hour, minute, location, err := parseRebootSchedule(schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	now = now.In(location)
+	slot := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, location)
+	// The window of yesterday's slot can reach past midnight
+	if now.Before(slot) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if now.Sub(slot) >= rebootWindow {
+		return time.Time{}, false
+	}
+	return slot, true
+}
+
+// ScheduleDeviceReboots reboots idle devices at the daily time set on their workspace or provider.
+// Workspace schedules override provider schedules.
+func ScheduleDeviceReboots() {
+	lastReboots := make(map[string]time.Time)
+	for {
+		time.Sleep(30 * time.Second)
+		runScheduledReboots(time.Now(), lastReboots)
+	}
+}
+
+func runScheduledReboots(now time.Time, lastReboots map[string]time.Time) {
+	providerSchedules := make(map[string]string)
+	providers, err := db.GlobalMongoStore.GetAllProviders()
+	if err != nil {
+		log.Warnf("Scheduled reboots - failed to get providers - %s", err)
+		return
+	}
+	for _, provider := range providers {
+		providerSchedules[provider.Nickname] = provider.RebootSchedule
+	}
+
+	workspaceSchedules := make(map[string]string)
+	workspaces, err := db.GlobalMongoStore.GetWorkspaces()
+	if err != nil {
+		log.Warnf("Scheduled reboots - failed to get workspaces - %s", err)
+		return
+	}
+	for _, workspace := range workspaces {
+		workspaceSchedules[workspace.ID] = workspace.RebootSchedule
+	}
+
+	for _, device := range devices.HubDeviceStore.All() {
+		device.Mu.RLock()
+		udid := device.Device.UDID
+		schedule := workspaceSchedules[device.Device.WorkspaceID]
+		if schedule == "" {
+			schedule = providerSchedules[device.Device.Provider]
+		}
+		host := device.Host
+		idle := device.Connected && device.ProviderState == "live" && device.Device.Usage != "disabled" &&
+			!device.IsLocked() && !device.IsRunningAutomation
+		device.Mu.RUnlock()
+
+		slot, due := dueRebootSlot(schedule, now)
+		if !due || !lastReboots[udid].Before(slot) || !idle {
+			continue
+		}
+
+		lastReboots[udid] = slot
+		status, body, err := rebootOnProvider(host, udid)
+		if err != nil {
+			log.Warnf("Scheduled reboot of device `%s` failed - %s", udid, err)
+			continue
+		}
+		if status != http.StatusOK {
+			log.Warnf("Scheduled reboot of device `%s` failed - provider returned %d: %s", udid, status, body)
+			continue
+		}
+		log.Infof("Rebooted device `%s` as scheduled at %s", udid, schedule)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRebootSchedule(t *testing.T) {
+	hour, minute, location, err := parseRebootSchedule("03:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, hour)
+	assert.Equal(t, 30, minute)
+	assert.Equal(t, time.UTC, location)
+
+	hour, minute, location, err = parseRebootSchedule("23:15 Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, 23, hour)
+	assert.Equal(t, 15, minute)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	for _, invalid := range []string{"3am", "25:00", "03:60", "0330", "", "03:00 Mars/Olympus", "03:00 Local"} {
+		_, _, _, err := parseRebootSchedule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDueRebootSlot(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("Before Scheduled Time - Should Not Be Due", func(t *testing.T) {
+		_, due := dueRebootSlot("03:00", at(10, 2, 59))
+		assert.False(t, due)
+	})
+
+	t.Run("Within Window - Should Be Due", func(t *testing.T) {
+		slot, due := dueRebootSlot("03:00", at(10, 3, 45))
+		assert.True(t, due)
+		assert.Equal(t, at(10, 3, 0), slot)
+	})
+
+	t.Run("After Window - Should Not Be Due", func(t *testing.T) {
+		_, due := dueRebootSlot("03:00", at(10, 4, 0))
+		assert.False(t, due)
+	})
+
+	t.Run("Window Past Midnight - Should Be Due For Previous Day", func(t *testing.T) {
+		slot, due := dueRebootSlot("23:30", at(11, 0, 15))
+		assert.True(t, due)
+		assert.Equal(t, at(10, 23, 30), slot)
+	})
+
+	t.Run("Empty Schedule - Should Not Be Due", func(t *testing.T) {
+		_, due := dueRebootSlot("", at(10, 3, 0))
+		assert.False(t, due)
+	})
+	t.Run("Schedule Time Zone - Should Not Depend On The Hub Time Zone", func(t *testing.T) {
+		berlin, err := time.LoadLocation("Europe/Berlin")
+		assert.NoError(t, err)
+		// 02:30 UTC is 03:30 in Berlin in winter
+		slot, due := dueRebootSlot("03:00 Europe/Berlin", at(10, 2, 30).In(time.FixedZone("UTC-7", -7*3600)))
+		assert.True(t, due)
+		assert.True(t, slot.Equal(time.Date(2025, 3, 10, 3, 0, 0, 0, berlin)))
+
+		_, due = dueRebootSlot("03:00", at(10, 2, 30).In(berlin))
+		assert.False(t, due)
+	})
+}
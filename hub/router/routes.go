@@ -354,6 +354,18 @@ func AddProvider(c *gin.Context) {
 		return
 	}
 
+	if provider.RebootSchedule != "" {
+		if _, _, _, err := parseRebootSchedule(provider.RebootSchedule); err != nil {
+			api.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	if err := validateAppiumVersions(provider.AppiumVersions); err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
 	provider.RegularizeProviderState()
 
 	err = db.GlobalMongoStore.AddOrUpdateProvider(provider)
@@ -410,6 +422,18 @@ func UpdateProvider(c *gin.Context) {
 		return
 	}
 
+	if provider.RebootSchedule != "" {
+		if _, _, _, err := parseRebootSchedule(provider.RebootSchedule); err != nil {
+			api.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	if err := validateAppiumVersions(provider.AppiumVersions); err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
 	provider.RegularizeProviderState()
 
 	err = db.GlobalMongoStore.AddOrUpdateProvider(provider)
@@ -511,6 +535,13 @@ func DeviceInUseWS(c *gin.Context) {
 		return
 	}
 
+	// Draining providers do not accept new remote control sessions
+	if device.IsDraining() && !device.IsHeldBy(username, userTenant) {
+		device.Mu.Unlock()
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
 	// If the device is already held via an API lease by this user, preserve the API lock.
 	// Calling AcquireLock here would overwrite LockSource to "ui", which would cause
 	// HasActiveLease() to return false and the API lock to be released on WS disconnect.
@@ -1246,6 +1277,7 @@ type lockDeviceResponse struct {
 // @Failure      401   {object}  models.ErrorResponse
 // @Failure      404   {object}  models.ErrorResponse
 // @Failure      409   {object}  models.ErrorResponse
+// @Failure      503   {object}  models.ErrorResponse
 // @Security     BearerAuth
 // @Router       /devices/control/{udid}/lock [post]
 func LockDevice(c *gin.Context) {
@@ -1269,6 +1301,12 @@ func LockDevice(c *gin.Context) {
 	device.Mu.Lock()
 	defer device.Mu.Unlock()
 
+	// Leases can be renewed but no new ones are given out while the provider is draining
+	if device.IsDraining() && !device.IsHeldBy(claims.Username, claims.Tenant) {
+		api.ErrorResponse(c, http.StatusServiceUnavailable, fmt.Sprintf("Provider of device `%s` is draining", udid))
+		return
+	}
+
 	if device.IsLockedByOther(claims.Username, claims.Tenant) {
 		if claims.Role != "admin" {
 			api.Conflict(c, fmt.Sprintf("Device `%s` is already locked by another user", udid))
@@ -1380,33 +1418,16 @@ func ProviderUpdate(c *gin.Context) {
 		// handle error if needed
 	}
 
-	for i := range providerDeviceData.DeviceData {
-		providerDevice := &providerDeviceData.DeviceData[i]
-		hubDevice, ok := devices.HubDeviceStore.Get(providerDevice.UDID)
-		if !ok {
-			continue
-		}
-		hubDevice.Mu.Lock()
-		// If device is not connected reset all fields that might allow it to get stuck in Running automation state
-		if !providerDevice.Connected {
-			hubDevice.Connected = false
-			hubDevice.ProviderState = providerDevice.ProviderState
-			hubDevice.Host = providerDevice.Host
-			hubDevice.IsAvailableForAutomation = false
-			hubDevice.IsRunningAutomation = false
-			hubDevice.ReleaseLockIfNotHeld()
-			hubDevice.SessionID = ""
-			hubDevice.Mu.Unlock()
-			continue
-		}
-		// Stamp when we last heard from the provider about this device
-		hubDevice.LastUpdatedTimestamp = time.Now().UnixMilli()
-
-		syncDeviceFields(hubDevice, providerDevice)
-		hubDevice.Mu.Unlock()
+	tokenProvider, err := providerFromToken(c.Request)
+	if err == nil {
+		err = authorizeProvider(tokenProvider, providerDeviceData.ProviderData.Nickname)
+	}
+	if err != nil {
+		api.ErrorResponse(c, http.StatusUnauthorized, fmt.Sprintf("Provider update rejected - %s", err))
+		return
 	}
 
-	api.OKMessage(c, "Provider data updated in hub")
+	api.OK(c, "Provider data updated in hub", applyProviderData(&providerDeviceData, false))
 }
 
 // GetUsers godoc
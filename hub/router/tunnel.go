@@ -0,0 +1,256 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/db"
+	"GADS/common/models"
+	"GADS/hub/auth"
+	"GADS/hub/devices"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type activeTunnel struct {
+	mu           sync.Mutex
+	audit        models.TunnelAuditLog
+	clientConn   net.Conn
+	providerConn net.Conn
+}
+
+var (
+	activeTunnels   = make(map[string]*activeTunnel)
+	activeTunnelsMu sync.Mutex
+)
+
+// TunnelHandler relays a TCP connection of the lock holder to a device port or to a provider port of the device.
+// After the WebSocket upgrade the connection carries the raw TCP stream.
+func TunnelHandler(c *gin.Context) {
+	target := c.Query("target")
+	if target != models.TunnelTargetDevice && target != models.TunnelTargetProvider {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target must be `device` or `provider`"})
+		return
+	}
+	port, err := strconv.Atoi(c.Query("port"))
+	if err != nil || port < 1 || port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "port must be between 1 and 65535"})
+		return
+	}
+
+	udid := c.Param("udid")
+	device, claims, ok := getLockedDevice(c, "Tunnel")
+	if !ok {
+		return
+	}
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	providerURL := url.URL{
+		Scheme:   "ws",
+		Host:     host,
+		Path:     fmt.Sprintf("/device/%s/tunnel", udid),
+		RawQuery: url.Values{"target": {target}, "port": {strconv.Itoa(port)}}.Encode(),
+	}
+	relayTunnel(c, claims, udid, target, port, providerURL.String())
+}
+
+// UsbmuxTunnelHandler relays a usbmuxd connection of the lock holder to the provider, restricted to the iOS device.
+// After the WebSocket upgrade the connection carries the raw usbmuxd protocol stream.
+func UsbmuxTunnelHandler(c *gin.Context) {
+	udid := c.Param("udid")
+	device, claims, ok := getLockedDevice(c, "usbmux tunnel")
+	if !ok {
+		return
+	}
+	device.Mu.RLock()
+	host := device.Host
+	deviceOS := device.Device.OS
+	device.Mu.RUnlock()
+	if deviceOS != "ios" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "usbmux tunnel is only available for iOS devices"})
+		return
+	}
+
+	providerURL := url.URL{
+		Scheme: "ws",
+		Host:   host,
+		Path:   fmt.Sprintf("/device/%s/usbmux", udid),
+	}
+	relayTunnel(c, claims, udid, models.TunnelTargetUsbmux, 0, providerURL.String())
+}
+
+// getLockedDevice returns the device of the request if the user holds its lock - from remote control or an API lease
+func getLockedDevice(c *gin.Context, feature string) (*devices.LocalHubDevice, *auth.JWTClaims, bool) {
+	udid := c.Param("udid")
+
+	claims, err := auth.GetClaimsFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, nil, false
+	}
+
+	device, ok := devices.HubDeviceStore.Get(udid)
+	if !ok || device == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Device with UDID `%s` not found", udid)})
+		return nil, nil, false
+	}
+
+	device.Mu.RLock()
+	defer device.Mu.RUnlock()
+	if !device.IsHeldBy(claims.Username, claims.Tenant) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s requires holding the device lock - remote control it or acquire an API lease", feature)})
+		return nil, nil, false
+	}
+	return device, claims, true
+}
+
+// relayTunnel dials the provider WebSocket, upgrades the client and relays the raw stream between them.
+// The tunnel is audited and closed when the device lock of the user ends.
+func relayTunnel(c *gin.Context, claims *auth.JWTClaims, udid, target string, port int, providerURL string) {
+	providerConn, _, _, err := dialProviderWS(context.Background(), providerURL)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to connect to provider tunnel - %s", err)})
+		return
+	}
+
+	clientConn, _, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	if err != nil {
+		providerConn.Close()
+		return
+	}
+
+	tunnel := &activeTunnel{
+		audit: models.TunnelAuditLog{
+			ID:            uuid.NewString(),
+			UDID:          udid,
+			User:          claims.Username,
+			Tenant:        claims.Tenant,
+			Target:        target,
+			Port:          port,
+			ClientAddress: c.ClientIP(),
+			OpenedAt:      time.Now().UnixMilli(),
+		},
+		clientConn:   clientConn,
+		providerConn: providerConn,
+	}
+	activeTunnelsMu.Lock()
+	activeTunnels[tunnel.audit.ID] = tunnel
+	activeTunnelsMu.Unlock()
+	saveTunnelAudit(tunnel.audit)
+	log.Infof("User `%s` opened tunnel `%s` to %s port %d of device `%s`", claims.Username, tunnel.audit.ID, target, port, udid)
+
+	var sent, received int64
+	done := make(chan struct{}, 2)
+	go func() {
+		sent, _ = io.Copy(providerConn, clientConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		received, _ = io.Copy(clientConn, providerConn)
+		done <- struct{}{}
+	}()
+
+	<-done
+	tunnel.close("")
+	<-done
+
+	activeTunnelsMu.Lock()
+	delete(activeTunnels, tunnel.audit.ID)
+	activeTunnelsMu.Unlock()
+
+	tunnel.mu.Lock()
+	tunnel.audit.ClosedAt = time.Now().UnixMilli()
+	tunnel.audit.BytesSent = sent
+	tunnel.audit.BytesReceived = received
+	audit := tunnel.audit
+	tunnel.mu.Unlock()
+	saveTunnelAudit(audit)
+	log.Infof("Tunnel `%s` to %s port %d of device `%s` closed - %s", audit.ID, audit.Target, audit.Port, audit.UDID, audit.CloseReason)
+}
+
+// close closes both sides of the tunnel, the first reason is kept
+func (t *activeTunnel) close(reason string) {
+	t.mu.Lock()
+	if t.audit.CloseReason == "" {
+		if reason == "" {
+			reason = "connection closed"
+		}
+		t.audit.CloseReason = reason
+	}
+	t.mu.Unlock()
+	t.clientConn.Close()
+	t.providerConn.Close()
+}
+
+func saveTunnelAudit(audit models.TunnelAuditLog) {
+	if err := db.GlobalMongoStore.UpsertTunnelAuditLog(audit); err != nil {
+		log.Warnf("Failed to store audit log of tunnel `%s` - %s", audit.ID, err)
+	}
+}
+
+// closeDeviceTunnels closes the tunnels of the previous lock holder when the device lock ends
+func closeDeviceTunnels(release devices.LockRelease) {
+	activeTunnelsMu.Lock()
+	var tunnels []*activeTunnel
+	for _, tunnel := range activeTunnels {
+		if tunnel.audit.UDID == release.UDID && tunnel.audit.User == release.User && tunnel.audit.Tenant == release.Tenant {
+			tunnels = append(tunnels, tunnel)
+		}
+	}
+	activeTunnelsMu.Unlock()
+
+	for _, tunnel := range tunnels {
+		tunnel.close("device lock released")
+	}
+}
+
+// GetActiveTunnels lists the tunnels that are currently open
+func GetActiveTunnels(c *gin.Context) {
+	activeTunnelsMu.Lock()
+	tunnels := []models.TunnelAuditLog{}
+	for _, tunnel := range activeTunnels {
+		tunnel.mu.Lock()
+		tunnels = append(tunnels, tunnel.audit)
+		tunnel.mu.Unlock()
+	}
+	activeTunnelsMu.Unlock()
+
+	api.OK(c, "", tunnels)
+}
+
+// GetTunnelAuditLogs returns the tunnel history, optionally filtered by device with `udid`
+func GetTunnelAuditLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		api.BadRequest(c, "limit must be a positive number")
+		return
+	}
+
+	logs, err := db.GlobalMongoStore.GetTunnelAuditLogs(c.Query("udid"), limit)
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to get tunnel audit logs - %s", err))
+		return
+	}
+	api.OK(c, "", logs)
+}
@@ -0,0 +1,103 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"net"
+	"testing"
+
+	"GADS/common/models"
+	"GADS/hub/auth"
+	"GADS/hub/devices"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseDeviceTunnels(t *testing.T) {
+	newTunnel := func(id, udid, user string) (*activeTunnel, net.Conn) {
+		clientConn, clientPeer := net.Pipe()
+		providerConn, _ := net.Pipe()
+		tunnel := &activeTunnel{
+			audit:        models.TunnelAuditLog{ID: id, UDID: udid, User: user, Tenant: "default"},
+			clientConn:   clientConn,
+			providerConn: providerConn,
+		}
+		activeTunnelsMu.Lock()
+		activeTunnels[id] = tunnel
+		activeTunnelsMu.Unlock()
+		return tunnel, clientPeer
+	}
+	defer func() {
+		activeTunnelsMu.Lock()
+		activeTunnels = make(map[string]*activeTunnel)
+		activeTunnelsMu.Unlock()
+	}()
+
+	released, releasedPeer := newTunnel("t1", "udid1", "user1")
+	otherUser, otherUserPeer := newTunnel("t2", "udid1", "user2")
+	otherDevice, otherDevicePeer := newTunnel("t3", "udid2", "user1")
+
+	closeDeviceTunnels(devices.LockRelease{UDID: "udid1", User: "user1", Tenant: "default"})
+
+	_, err := releasedPeer.Write([]byte("x"))
+	assert.Error(t, err, "tunnel of the released lock should be closed")
+	assert.Equal(t, "device lock released", released.audit.CloseReason)
+
+	for _, peer := range []net.Conn{otherUserPeer, otherDevicePeer} {
+		go peer.Write([]byte("x"))
+	}
+	buf := make([]byte, 1)
+	_, err = otherUser.clientConn.Read(buf)
+	assert.NoError(t, err, "tunnel of another user should stay open")
+	_, err = otherDevice.clientConn.Read(buf)
+	assert.NoError(t, err, "tunnel to another device should stay open")
+	assert.Empty(t, otherUser.audit.CloseReason)
+	assert.Empty(t, otherDevice.audit.CloseReason)
+}
+
+func TestIsInUISessionOf(t *testing.T) {
+	uiConn, _ := net.Pipe()
+	defer uiConn.Close()
+
+	tests := []struct {
+		name   string
+		device *devices.LocalHubDevice
+		claims auth.JWTClaims
+		want   bool
+	}{
+		{
+			name:   "user remote controlling the device",
+			device: &devices.LocalHubDevice{InUseBy: "user1", InUseByTenant: "tenant1", InUseWSConnection: uiConn},
+			claims: auth.JWTClaims{Username: "user1", Tenant: "tenant1"},
+			want:   true,
+		},
+		{
+			name:   "same username in another tenant",
+			device: &devices.LocalHubDevice{InUseBy: "user1", InUseByTenant: "tenant1", InUseWSConnection: uiConn},
+			claims: auth.JWTClaims{Username: "user1", Tenant: "tenant2"},
+		},
+		{
+			name:   "another user",
+			device: &devices.LocalHubDevice{InUseBy: "user1", InUseByTenant: "tenant1", InUseWSConnection: uiConn},
+			claims: auth.JWTClaims{Username: "user2", Tenant: "tenant1"},
+		},
+		{
+			name:   "lock without a remote control session",
+			device: &devices.LocalHubDevice{InUseBy: "user1", InUseByTenant: "tenant1"},
+			claims: auth.JWTClaims{Username: "user1", Tenant: "tenant1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isInUISessionOf(tt.device, &tt.claims))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/hub/auth"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// tunnelTicketTTL is how long an issued tunnel ticket can be used to connect
+var tunnelTicketTTL = 2 * time.Minute
+
+// tunnelTicket authorizes a single WebSocket connection to a device for the user it was issued to.
+// DevTools and Web Inspector clients cannot send an Authorization header, the ticket goes in the URL instead of the user token.
+type tunnelTicket struct {
+	udid      string
+	username  string
+	tenant    string
+	expiresAt time.Time
+}
+
+var (
+	tunnelTickets   = make(map[string]tunnelTicket)
+	tunnelTicketsMu sync.Mutex
+)
+
+// issueTunnelTicket returns a new ticket for the user of the claims to connect to the device, empty if it could not be generated
+func issueTunnelTicket(udid string, claims *auth.JWTClaims) string {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return ""
+	}
+	ticket := hex.EncodeToString(secret)
+
+	tunnelTicketsMu.Lock()
+	defer tunnelTicketsMu.Unlock()
+	now := time.Now()
+	for id, existing := range tunnelTickets {
+		if now.After(existing.expiresAt) {
+			delete(tunnelTickets, id)
+		}
+	}
+	tunnelTickets[ticket] = tunnelTicket{
+		udid:      udid,
+		username:  claims.Username,
+		tenant:    claims.Tenant,
+		expiresAt: now.Add(tunnelTicketTTL),
+	}
+	return ticket
+}
+
+// redeemTunnelTicket consumes the ticket and returns the user it was issued to if it is valid for the device
+func redeemTunnelTicket(ticket, udid string) (*auth.JWTClaims, bool) {
+	tunnelTicketsMu.Lock()
+	defer tunnelTicketsMu.Unlock()
+
+	issued, ok := tunnelTickets[ticket]
+	if !ok {
+		return nil, false
+	}
+	delete(tunnelTickets, ticket)
+	if issued.udid != udid || time.Now().After(issued.expiresAt) {
+		return nil, false
+	}
+	return &auth.JWTClaims{Username: issued.username, Tenant: issued.tenant}, true
+}
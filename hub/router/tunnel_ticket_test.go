@@ -0,0 +1,109 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"GADS/common/models"
+	"GADS/hub/auth"
+	"GADS/hub/devices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedeemTunnelTicket(t *testing.T) {
+	claims := &auth.JWTClaims{Username: "user1", Tenant: "tenant1"}
+
+	t.Run("Ticket is valid once for its device", func(t *testing.T) {
+		ticket := issueTunnelTicket("udid1", claims)
+		require.NotEmpty(t, ticket)
+
+		redeemed, ok := redeemTunnelTicket(ticket, "udid1")
+		require.True(t, ok)
+		assert.Equal(t, "user1", redeemed.Username)
+		assert.Equal(t, "tenant1", redeemed.Tenant)
+
+		_, ok = redeemTunnelTicket(ticket, "udid1")
+		assert.False(t, ok, "ticket should be single-use")
+	})
+
+	t.Run("Ticket of another device is rejected and consumed", func(t *testing.T) {
+		ticket := issueTunnelTicket("udid1", claims)
+
+		_, ok := redeemTunnelTicket(ticket, "udid2")
+		assert.False(t, ok)
+		_, ok = redeemTunnelTicket(ticket, "udid1")
+		assert.False(t, ok)
+	})
+
+	t.Run("Expired ticket is rejected", func(t *testing.T) {
+		previousTTL := tunnelTicketTTL
+		tunnelTicketTTL = -time.Second
+		defer func() { tunnelTicketTTL = previousTTL }()
+
+		ticket := issueTunnelTicket("udid1", claims)
+		_, ok := redeemTunnelTicket(ticket, "udid1")
+		assert.False(t, ok)
+	})
+
+	t.Run("Unknown ticket is rejected", func(t *testing.T) {
+		_, ok := redeemTunnelTicket("", "udid1")
+		assert.False(t, ok)
+		_, ok = redeemTunnelTicket("not-issued", "udid1")
+		assert.False(t, ok)
+	})
+
+	t.Run("Expired tickets are pruned", func(t *testing.T) {
+		tunnelTicketsMu.Lock()
+		tunnelTickets["stale"] = tunnelTicket{udid: "udid1", expiresAt: time.Now().Add(-time.Minute)}
+		tunnelTicketsMu.Unlock()
+
+		issueTunnelTicket("udid1", claims)
+		tunnelTicketsMu.Lock()
+		_, stale := tunnelTickets["stale"]
+		tunnelTicketsMu.Unlock()
+		assert.False(t, stale)
+	})
+}
+
+func TestDevToolsWebSocketHandler_Ticket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	uiConn, _ := net.Pipe()
+	defer uiConn.Close()
+
+	udid := "devtools-ticket-udid"
+	devices.HubDeviceStore.Set(udid, &devices.LocalHubDevice{
+		Device:            models.DBDevice{UDID: udid, OS: "android"},
+		InUseBy:           "user1",
+		InUseByTenant:     "tenant1",
+		InUseWSConnection: uiConn,
+	})
+	defer devices.HubDeviceStore.Delete(udid)
+
+	r := gin.New()
+	r.GET("/devices/control/:udid/devtools-ws/:socket/*path", DevToolsWebSocketHandler)
+	connect := func(ticket string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices/control/"+udid+"/devtools-ws/chrome_devtools_remote/devtools/page/1?ticket="+ticket, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, connect(""))
+	assert.Equal(t, http.StatusUnauthorized, connect(issueTunnelTicket("other-udid", &auth.JWTClaims{Username: "user1", Tenant: "tenant1"})))
+	assert.Equal(t, http.StatusConflict, connect(issueTunnelTicket(udid, &auth.JWTClaims{Username: "user1", Tenant: "tenant2"})),
+		"ticket of a user that does not remote control the device")
+}
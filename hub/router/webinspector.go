@@ -0,0 +1,107 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/models"
+	"GADS/hub/auth"
+	"GADS/hub/devices"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebInspectorHandler exposes the Web Inspector of an iOS device to the user that is remote controlling it.
+// `/webinspector/` lists the inspectable pages, `/webinspector/{app}/{page}` is a WebSocket speaking the WebKit Inspector protocol.
+func WebInspectorHandler(c *gin.Context) {
+	udid := c.Param("udid")
+	device, claims, ok := getUISessionDevice(c, "ios", "Web Inspector")
+	if !ok {
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	path := strings.Trim(c.Param("path"), "/")
+	if path == "" {
+		webInspectorPages(c, host, udid, claims)
+		return
+	}
+
+	appID, pageID, found := strings.Cut(path, "/")
+	if _, err := strconv.Atoi(pageID); !found || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected /webinspector/{app}/{page}"})
+		return
+	}
+	relayWebInspectorPage(c, device, claims, host, udid, appID, pageID)
+}
+
+// WebInspectorWebSocketHandler relays the page WebSocket for the URLs listed by WebInspectorHandler.
+// The connection is authorized by the single-use tunnel ticket of the URL because inspector clients cannot send an Authorization header.
+func WebInspectorWebSocketHandler(c *gin.Context) {
+	udid := c.Param("udid")
+	device, claims, ok := getTicketUISessionDevice(c, "ios", "Web Inspector")
+	if !ok {
+		return
+	}
+
+	device.Mu.RLock()
+	host := device.Host
+	device.Mu.RUnlock()
+
+	pageID := c.Param("page")
+	if _, err := strconv.Atoi(pageID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected /webinspector-ws/{app}/{page}"})
+		return
+	}
+	relayWebInspectorPage(c, device, claims, host, udid, c.Param("app"), pageID)
+}
+
+func relayWebInspectorPage(c *gin.Context, device *devices.LocalHubDevice, claims *auth.JWTClaims, host, udid, appID, pageID string) {
+	providerURL := url.URL{
+		Scheme: "ws",
+		Host:   host,
+		Path:   fmt.Sprintf("/device/%s/webinspector/%s/%s", udid, appID, pageID),
+	}
+	relayWebSocketWhileInUse(c, device, claims, providerURL.String(), "Web Inspector")
+}
+
+func webInspectorPages(c *gin.Context, host, udid string, claims *auth.JWTClaims) {
+	client := &http.Client{Transport: proxyTransport}
+	resp, err := client.Get(fmt.Sprintf("http://%s/device/%s/webinspector", host, udid))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to get inspectable pages from provider - %s", err)})
+		return
+	}
+	defer resp.Body.Close()
+
+	var providerResp models.APIResponse[[]models.WebInspectorPage]
+	if err := json.NewDecoder(resp.Body).Decode(&providerResp); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to decode provider response - %s", err)})
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.JSON(resp.StatusCode, providerResp)
+		return
+	}
+
+	for i, page := range providerResp.Result {
+		wsURL := hubWebSocketURL(c, fmt.Sprintf("/devices/control/%s/webinspector-ws/%s/%d", udid, url.PathEscape(page.AppID), page.PageID))
+		providerResp.Result[i].WebSocketDebuggerURL = wsURL + "?ticket=" + url.QueryEscape(issueTunnelTicket(udid, claims))
+	}
+	c.JSON(http.StatusOK, providerResp)
+}
@@ -58,6 +58,18 @@ func CreateWorkspace(c *gin.Context) {
 		return
 	}
 
+	if workspace.RebootSchedule != "" {
+		if _, _, _, err := parseRebootSchedule(workspace.RebootSchedule); err != nil {
+			api.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	if err := validateAppiumVersions(workspace.AppiumVersions); err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
 	// Validate unique name
 	existingWorkspaces, _ := db.GlobalMongoStore.GetWorkspaces()
 	for _, ws := range existingWorkspaces {
@@ -100,6 +112,18 @@ func UpdateWorkspace(c *gin.Context) {
 		return
 	}
 
+	if workspace.RebootSchedule != "" {
+		if _, _, _, err := parseRebootSchedule(workspace.RebootSchedule); err != nil {
+			api.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	if err := validateAppiumVersions(workspace.AppiumVersions); err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
 	// Validate unique workspace name
 	existingWorkspaces, _ := db.GlobalMongoStore.GetWorkspaces()
 	for _, ws := range existingWorkspaces {
@@ -11,11 +11,14 @@ package main
 
 import (
 	"GADS/client/adb"
+	"GADS/client/tunnel"
+	"GADS/client/usbmux"
 	"GADS/hub"
 	"GADS/provider"
 	"embed"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -44,6 +47,7 @@ func main() {
 		"\nBy default app will try to use a temp dir on the host, use this flag only if you encounter issues with the temp folder."+
 		"\nAlso you need to have created the folder in advance!")
 	hubCmd.Flags().String("turn-username-suffix", "gads", "Suffix to append to TURN usernames (format: timestamp:suffix)")
+	hubCmd.Flags().Bool("require-provider-token", false, "Reject the updates of providers that were not enrolled with a provider token")
 	rootCmd.AddCommand(hubCmd)
 
 	// Provider Command
@@ -60,6 +64,10 @@ func main() {
 	providerCmd.Flags().String("hub", "", "The address of the GADS hub instance")
 	providerCmd.Flags().String("turn-username-suffix", "gads", "Suffix to append to TURN usernames (format: timestamp:suffix)")
 	providerCmd.Flags().Bool("use-ios-pair-cache", false, "Cache iOS pair records on disk to skip Trust dialog on reconnect (for unsupervised devices)")
+	providerCmd.Flags().String("config", "", "Path to a YAML or TOML provider configuration file")
+	providerCmd.Flags().String("provider-token", "", "Provider token generated in the hub, the provider then gets its data from the hub instead of MongoDB")
+	providerCmd.Flags().Int("mock-devices", 0, "Number of fake `mock` devices to serve, for local development and end-to-end tests without real devices")
+	providerCmd.Flags().Duration("drain-timeout", 10*time.Minute, "How long to wait for active sessions to finish on SIGTERM before shutting down")
 	rootCmd.AddCommand(providerCmd)
 
 	// ADB Tunnel Command
@@ -74,13 +82,54 @@ func main() {
 	adbTunnelCmd.Flags().String("udid", "", "Device UDID to tunnel")
 	adbTunnelCmd.Flags().String("username", "", "GADS username")
 	adbTunnelCmd.Flags().String("password", "", "GADS password")
+	adbTunnelCmd.Flags().String("client-id", "", "Client credentials ID, used instead of username and password")
+	adbTunnelCmd.Flags().String("client-secret", "", "Client credentials secret")
+	adbTunnelCmd.Flags().String("tenant", "", "Tenant of the client credentials")
+	adbTunnelCmd.Flags().Int("lease-ttl", 0, "Acquire an API lease on the device with this TTL in minutes and keep renewing it (0 = use the existing lock)")
 	adbTunnelCmd.Flags().Int("port", 0, "Local port to listen on (0 = auto)")
 	adbTunnelCmd.MarkFlagRequired("hub")
 	adbTunnelCmd.MarkFlagRequired("udid")
-	adbTunnelCmd.MarkFlagRequired("username")
-	adbTunnelCmd.MarkFlagRequired("password")
 	rootCmd.AddCommand(adbTunnelCmd)
 
+	// Generic TCP Tunnel Command
+	var tunnelCmd = &cobra.Command{
+		Use:   "tunnel",
+		Short: "Forward a local port to a device port or a provider port of the device through the hub",
+		Run: func(cmd *cobra.Command, args []string) {
+			tunnel.Start(cmd.Flags())
+		},
+	}
+	tunnelCmd.Flags().String("hub", "", "Hub URL (e.g. http://localhost:10000)")
+	tunnelCmd.Flags().String("udid", "", "Device UDID to tunnel")
+	tunnelCmd.Flags().String("username", "", "GADS username")
+	tunnelCmd.Flags().String("password", "", "GADS password")
+	tunnelCmd.Flags().String("target", "device", "Where the remote port is - `device` or `provider`")
+	tunnelCmd.Flags().Int("remote-port", 0, "Port on the device or on the provider to tunnel to")
+	tunnelCmd.Flags().Int("port", 0, "Local port to listen on (0 = auto)")
+	tunnelCmd.MarkFlagRequired("hub")
+	tunnelCmd.MarkFlagRequired("udid")
+	tunnelCmd.MarkFlagRequired("username")
+	tunnelCmd.MarkFlagRequired("remote-port")
+	rootCmd.AddCommand(tunnelCmd)
+
+	// usbmux Tunnel Command
+	var usbmuxTunnelCmd = &cobra.Command{
+		Use:   "usbmux-tunnel",
+		Short: "Expose a remote iOS device on a local usbmuxd socket through the hub",
+		Run: func(cmd *cobra.Command, args []string) {
+			usbmux.Start(cmd.Flags())
+		},
+	}
+	usbmuxTunnelCmd.Flags().String("hub", "", "Hub URL (e.g. http://localhost:10000)")
+	usbmuxTunnelCmd.Flags().String("udid", "", "Device UDID to tunnel")
+	usbmuxTunnelCmd.Flags().String("username", "", "GADS username")
+	usbmuxTunnelCmd.Flags().String("password", "", "GADS password")
+	usbmuxTunnelCmd.Flags().String("socket", "", "Local usbmuxd socket - a unix socket path or `host:port` (default a unix socket in the temp dir, 127.0.0.1:27015 on Windows)")
+	usbmuxTunnelCmd.MarkFlagRequired("hub")
+	usbmuxTunnelCmd.MarkFlagRequired("udid")
+	usbmuxTunnelCmd.MarkFlagRequired("username")
+	rootCmd.AddCommand(usbmuxTunnelCmd)
+
 	var versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print the application version",
@@ -10,8 +10,8 @@
 package config
 
 import (
-	"GADS/common/db"
 	"GADS/common/models"
+	"GADS/provider/store"
 	"fmt"
 	"log"
 	"strings"
@@ -20,15 +20,18 @@ import (
 var ProviderConfig = &models.Provider{}
 
 func SetupConfig(nickname, folder, hubAddress string) {
-	provider, err := db.GlobalMongoStore.GetProvider(nickname)
+	provider, err := store.GlobalStore.GetProvider(nickname)
 	if err != nil {
-		log.Fatalf("Failed to get provider data from DB - %s", err)
+		log.Fatalf("Failed to get provider data - %s", err)
 	}
 	if provider.Nickname == "" {
 		log.Fatal("Provider with this nickname is not registered in the DB")
 	}
 	provider.ProviderFolder = folder
-	provider.HubAddress = strings.TrimSuffix(hubAddress, "/")
+	provider.HubAddresses = ParseHubAddresses(hubAddress)
+	if len(provider.HubAddresses) > 0 {
+		provider.HubAddress = provider.HubAddresses[0]
+	}
 	if !strings.HasSuffix(provider.WdaBundleID, ".xctrunner") {
 		provider.WdaBundleID = fmt.Sprintf("%s.xctrunner", provider.WdaBundleID)
 	}
@@ -36,8 +39,19 @@ func SetupConfig(nickname, folder, hubAddress string) {
 	ProviderConfig = &provider
 }
 
+// ParseHubAddresses splits the hub address, multiple comma separated hub addresses can be provided for failover
+func ParseHubAddresses(hubAddress string) []string {
+	var addresses []string
+	for _, address := range strings.Split(hubAddress, ",") {
+		if address = strings.TrimSuffix(strings.TrimSpace(address), "/"); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
 func SetupIOSSupervisionProfileFile() error {
-	return db.GlobalMongoStore.DownloadFile("supervision.p12", ProviderConfig.ProviderFolder)
+	return store.GlobalStore.DownloadFile("supervision.p12", ProviderConfig.ProviderFolder)
 }
 
 func SetupWebDriverAgentFile() error {
@@ -46,9 +60,9 @@ func SetupWebDriverAgentFile() error {
 	// name the install step expects. Fall back to the legacy fixed-filename
 	// lookup for providers configured before the per-provider selection existed.
 	if ProviderConfig.WebDriverAgentIPA != "" {
-		return db.GlobalMongoStore.DownloadFileByID(ProviderConfig.WebDriverAgentIPA, ProviderConfig.ProviderFolder, "WebDriverAgent.ipa")
+		return store.GlobalStore.DownloadFileByID(ProviderConfig.WebDriverAgentIPA, ProviderConfig.ProviderFolder, "WebDriverAgent.ipa")
 	}
-	return db.GlobalMongoStore.DownloadFile("WebDriverAgent.ipa", ProviderConfig.ProviderFolder)
+	return store.GlobalStore.DownloadFile("WebDriverAgent.ipa", ProviderConfig.ProviderFolder)
 }
 
 func SetupBroadcastFile() error {
@@ -58,5 +72,5 @@ func SetupBroadcastFile() error {
 	if ProviderConfig.BroadcastIPA == "" {
 		return nil
 	}
-	return db.GlobalMongoStore.DownloadFileByID(ProviderConfig.BroadcastIPA, ProviderConfig.ProviderFolder, "Broadcast.ipa")
+	return store.GlobalStore.DownloadFileByID(ProviderConfig.BroadcastIPA, ProviderConfig.ProviderFolder, "Broadcast.ipa")
 }
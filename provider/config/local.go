@@ -0,0 +1,350 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"GADS/common/models"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// LocalConfig is the host specific provider configuration.
+// It is read from the optional `--config` YAML or TOML file, then overridden by the GADS_PROVIDER_* environment
+// variables and lastly by the explicitly provided CLI flags.
+type LocalConfig struct {
+	Nickname       string `yaml:"nickname" toml:"nickname"`
+	Hub            string `yaml:"hub" toml:"hub"`
+	ProviderFolder string `yaml:"provider_folder" toml:"provider_folder"`
+	MongoDB        string `yaml:"mongo_db" toml:"mongo_db"`
+	// ProviderToken makes the provider get its configuration and data from the hub instead of MongoDB
+	ProviderToken      string         `yaml:"provider_token" toml:"provider_token"`
+	TURNUsernameSuffix string         `yaml:"turn_username_suffix" toml:"turn_username_suffix"`
+	UseIOSPairCache    bool           `yaml:"use_ios_pair_cache" toml:"use_ios_pair_cache"`
+	MockDevices        int            `yaml:"mock_devices" toml:"mock_devices"`           // fake `mock` OS devices to register and serve
+	RokuDevPassword    string         `yaml:"roku_dev_password" toml:"roku_dev_password"` // password of the Roku developer web installer
+	Ports              PortRanges     `yaml:"ports" toml:"ports"`
+	Tools              ToolPaths      `yaml:"tools" toml:"tools"`
+	Platforms          PlatformToggle `yaml:"platforms" toml:"platforms"`
+	Logging            LogSinks       `yaml:"logging" toml:"logging"`
+}
+
+// PortRange limits the ports allocated for device services, zero values allow any free port
+type PortRange struct {
+	Min int `yaml:"min" toml:"min" json:"min"`
+	Max int `yaml:"max" toml:"max" json:"max"`
+}
+
+func (r PortRange) validate(name string) error {
+	if r.Min < 0 || r.Max < r.Min || r.Max > 65535 {
+		return fmt.Errorf("invalid %s port range %d-%d", name, r.Min, r.Max)
+	}
+	return nil
+}
+
+// The purposes the provider allocates host ports for
+const (
+	PortAppium       = "appium"
+	PortStream       = "stream"
+	PortWDA          = "wda"
+	PortIME          = "ime"
+	PortADB          = "adb"
+	PortRemoteServer = "remote_server"
+	// The purposes below always use the default range
+	PortTunnel       = "tunnel"
+	PortDevTools     = "devtools"
+	PortNetworkProxy = "network_proxy"
+)
+
+// PortRanges are the default port range and the ranges of the purposes that override it
+type PortRanges struct {
+	Min          int       `yaml:"min" toml:"min"`
+	Max          int       `yaml:"max" toml:"max"`
+	Appium       PortRange `yaml:"appium" toml:"appium"`
+	Stream       PortRange `yaml:"stream" toml:"stream"`
+	WDA          PortRange `yaml:"wda" toml:"wda"`
+	IME          PortRange `yaml:"ime" toml:"ime"`
+	ADB          PortRange `yaml:"adb" toml:"adb"`
+	RemoteServer PortRange `yaml:"remote_server" toml:"remote_server"`
+}
+
+// Purposes returns the ranges of the purposes that can be configured by their purpose
+func (p *PortRanges) Purposes() map[string]*PortRange {
+	return map[string]*PortRange{
+		PortAppium:       &p.Appium,
+		PortStream:       &p.Stream,
+		PortWDA:          &p.WDA,
+		PortIME:          &p.IME,
+		PortADB:          &p.ADB,
+		PortRemoteServer: &p.RemoteServer,
+	}
+}
+
+// Range returns the port range for the purpose, the default range if the purpose has none configured
+func (p PortRanges) Range(purpose string) PortRange {
+	if purposeRange, ok := p.Purposes()[purpose]; ok && purposeRange.Max > 0 {
+		return *purposeRange
+	}
+	return PortRange{Min: p.Min, Max: p.Max}
+}
+
+// ToolPaths are the executables the provider runs, by default they are looked up on PATH
+type ToolPaths struct {
+	ADB    string `yaml:"adb" toml:"adb"`
+	FFmpeg string `yaml:"ffmpeg" toml:"ffmpeg"`
+	SDB    string `yaml:"sdb" toml:"sdb"`
+	// Ares is the folder of the WebOS ares CLI tools
+	Ares   string `yaml:"ares" toml:"ares"`
+	Appium string `yaml:"appium" toml:"appium"`
+}
+
+// AresCommand returns the path of an ares CLI tool, e.g. `ares-install`
+func (t ToolPaths) AresCommand(name string) string {
+	if t.Ares == "" {
+		return name
+	}
+	return filepath.Join(t.Ares, name)
+}
+
+// PlatformToggle overrides the platforms enabled for the provider in the hub, unset values keep the hub setting
+type PlatformToggle struct {
+	Android *bool `yaml:"android" toml:"android"`
+	IOS     *bool `yaml:"ios" toml:"ios"`
+	Tizen   *bool `yaml:"tizen" toml:"tizen"`
+	WebOS   *bool `yaml:"webos" toml:"webos"`
+	Roku    *bool `yaml:"roku" toml:"roku"`
+}
+
+// LogSinks are where the provider and device logs are written
+type LogSinks struct {
+	Level  string `yaml:"level" toml:"level"`
+	File   bool   `yaml:"file" toml:"file"`
+	Stdout bool   `yaml:"stdout" toml:"stdout"`
+	// MongoDB is always disabled for providers that use a provider token
+	MongoDB bool `yaml:"mongo_db" toml:"mongo_db"`
+}
+
+// Local is the loaded host specific configuration
+var Local = DefaultLocalConfig()
+
+func DefaultLocalConfig() *LocalConfig {
+	return &LocalConfig{
+		ProviderFolder:     ".",
+		MongoDB:            "localhost:27017",
+		TURNUsernameSuffix: "gads",
+		Tools: ToolPaths{
+			ADB:    "adb",
+			FFmpeg: "ffmpeg",
+			SDB:    "sdb",
+			Appium: "appium",
+		},
+		Logging: LogSinks{
+			Level:   "info",
+			File:    true,
+			Stdout:  true,
+			MongoDB: true,
+		},
+	}
+}
+
+// HubManaged reports whether the provider gets its configuration and data from the hub instead of MongoDB
+func (c *LocalConfig) HubManaged() bool {
+	return c.ProviderToken != ""
+}
+
+// LoadLocalConfig builds the host configuration from the config file, the environment and the CLI flags
+func LoadLocalConfig(flags *pflag.FlagSet) (*LocalConfig, error) {
+	cfg := DefaultLocalConfig()
+
+	configPath, _ := flags.GetString("config")
+	if !flags.Changed("config") {
+		if envPath := os.Getenv("GADS_PROVIDER_CONFIG"); envPath != "" {
+			configPath = envPath
+		}
+	}
+	if configPath != "" {
+		if err := readConfigFile(configPath, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, err
+	}
+	applyFlagOverrides(flags, cfg)
+
+	if err := (PortRange{Min: cfg.Ports.Min, Max: cfg.Ports.Max}).validate("default"); err != nil {
+		return nil, err
+	}
+	for purpose, purposeRange := range cfg.Ports.Purposes() {
+		if err := purposeRange.validate(purpose); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.HubManaged() {
+		cfg.Logging.MongoDB = false
+	}
+	return cfg, nil
+}
+
+func readConfigFile(path string, cfg *LocalConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file `%s` - %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file `%s`, use a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file `%s` - %w", path, err)
+	}
+	return nil
+}
+
+func applyEnvOverrides(cfg *LocalConfig) error {
+	stringVars := map[string]*string{
+		"GADS_PROVIDER_NICKNAME":             &cfg.Nickname,
+		"GADS_PROVIDER_HUB":                  &cfg.Hub,
+		"GADS_PROVIDER_FOLDER":               &cfg.ProviderFolder,
+		"GADS_PROVIDER_MONGO_DB":             &cfg.MongoDB,
+		"GADS_PROVIDER_TOKEN":                &cfg.ProviderToken,
+		"GADS_PROVIDER_TURN_USERNAME_SUFFIX": &cfg.TURNUsernameSuffix,
+		"GADS_PROVIDER_LOG_LEVEL":            &cfg.Logging.Level,
+		"GADS_PROVIDER_ADB_PATH":             &cfg.Tools.ADB,
+		"GADS_PROVIDER_FFMPEG_PATH":          &cfg.Tools.FFmpeg,
+		"GADS_PROVIDER_SDB_PATH":             &cfg.Tools.SDB,
+		"GADS_PROVIDER_ARES_PATH":            &cfg.Tools.Ares,
+		"GADS_PROVIDER_APPIUM_PATH":          &cfg.Tools.Appium,
+		"GADS_PROVIDER_ROKU_DEV_PASSWORD":    &cfg.RokuDevPassword,
+	}
+	for name, target := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+
+	ints := map[string]*int{
+		"GADS_PROVIDER_PORT_MIN":     &cfg.Ports.Min,
+		"GADS_PROVIDER_PORT_MAX":     &cfg.Ports.Max,
+		"GADS_PROVIDER_MOCK_DEVICES": &cfg.MockDevices,
+	}
+	for name, target := range ints {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value `%s` - %w", name, value, err)
+			}
+			*target = parsed
+		}
+	}
+
+	// Purpose ranges are provided as `min-max`, e.g. GADS_PROVIDER_APPIUM_PORTS=21000-21099
+	for purpose, target := range cfg.Ports.Purposes() {
+		name := fmt.Sprintf("GADS_PROVIDER_%s_PORTS", strings.ToUpper(purpose))
+		if value, ok := os.LookupEnv(name); ok {
+			lower, upper, found := strings.Cut(value, "-")
+			parsedMin, minErr := strconv.Atoi(strings.TrimSpace(lower))
+			parsedMax, maxErr := strconv.Atoi(strings.TrimSpace(upper))
+			if !found || minErr != nil || maxErr != nil {
+				return fmt.Errorf("invalid %s value `%s`, expected `min-max`", name, value)
+			}
+			*target = PortRange{Min: parsedMin, Max: parsedMax}
+		}
+	}
+
+	bools := map[string]*bool{
+		"GADS_PROVIDER_USE_IOS_PAIR_CACHE": &cfg.UseIOSPairCache,
+		"GADS_PROVIDER_LOG_FILE":           &cfg.Logging.File,
+		"GADS_PROVIDER_LOG_STDOUT":         &cfg.Logging.Stdout,
+		"GADS_PROVIDER_LOG_MONGO_DB":       &cfg.Logging.MongoDB,
+	}
+	for name, target := range bools {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value `%s` - %w", name, value, err)
+			}
+			*target = parsed
+		}
+	}
+
+	platforms := map[string]**bool{
+		"GADS_PROVIDER_ANDROID": &cfg.Platforms.Android,
+		"GADS_PROVIDER_IOS":     &cfg.Platforms.IOS,
+		"GADS_PROVIDER_TIZEN":   &cfg.Platforms.Tizen,
+		"GADS_PROVIDER_WEBOS":   &cfg.Platforms.WebOS,
+		"GADS_PROVIDER_ROKU":    &cfg.Platforms.Roku,
+	}
+	for name, target := range platforms {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value `%s` - %w", name, value, err)
+			}
+			*target = &parsed
+		}
+	}
+	return nil
+}
+
+func applyFlagOverrides(flags *pflag.FlagSet, cfg *LocalConfig) {
+	stringFlags := map[string]*string{
+		"nickname":             &cfg.Nickname,
+		"hub":                  &cfg.Hub,
+		"provider-folder":      &cfg.ProviderFolder,
+		"mongo-db":             &cfg.MongoDB,
+		"provider-token":       &cfg.ProviderToken,
+		"turn-username-suffix": &cfg.TURNUsernameSuffix,
+		"log-level":            &cfg.Logging.Level,
+	}
+	for name, target := range stringFlags {
+		if flags.Changed(name) {
+			*target, _ = flags.GetString(name)
+		}
+	}
+	if flags.Changed("use-ios-pair-cache") {
+		cfg.UseIOSPairCache, _ = flags.GetBool("use-ios-pair-cache")
+	}
+	if flags.Changed("mock-devices") {
+		cfg.MockDevices, _ = flags.GetInt("mock-devices")
+	}
+}
+
+// ApplyPlatformOverrides enables or disables the platforms set in the local config on the provider config
+func (c *LocalConfig) ApplyPlatformOverrides(provider *models.Provider) {
+	if c.Platforms.Android != nil {
+		provider.ProvideAndroid = *c.Platforms.Android
+	}
+	if c.Platforms.IOS != nil {
+		provider.ProvideIOS = *c.Platforms.IOS
+	}
+	if c.Platforms.Tizen != nil {
+		provider.ProvideTizen = *c.Platforms.Tizen
+	}
+	if c.Platforms.WebOS != nil {
+		provider.ProvideWebOS = *c.Platforms.WebOS
+	}
+	if c.Platforms.Roku != nil {
+		provider.ProvideRoku = *c.Platforms.Roku
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package config
+
+import (
+	"testing"
+)
+
+func TestApplyEnvOverrides_PortRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    PortRanges
+		wantErr bool
+	}{
+		{
+			name: "default range",
+			env:  map[string]string{"GADS_PROVIDER_PORT_MIN": "20000", "GADS_PROVIDER_PORT_MAX": "20999"},
+			want: PortRanges{Min: 20000, Max: 20999},
+		},
+		{
+			name: "purpose ranges",
+			env:  map[string]string{"GADS_PROVIDER_APPIUM_PORTS": "21000-21099", "GADS_PROVIDER_REMOTE_SERVER_PORTS": " 22000 - 22009 "},
+			want: PortRanges{Appium: PortRange{Min: 21000, Max: 21099}, RemoteServer: PortRange{Min: 22000, Max: 22009}},
+		},
+		{name: "invalid default bound", env: map[string]string{"GADS_PROVIDER_PORT_MIN": "low"}, wantErr: true},
+		{name: "purpose range without max", env: map[string]string{"GADS_PROVIDER_WDA_PORTS": "21000"}, wantErr: true},
+		{name: "purpose range with an empty bound", env: map[string]string{"GADS_PROVIDER_WDA_PORTS": "21000-"}, wantErr: true},
+		{name: "purpose range that is not numeric", env: map[string]string{"GADS_PROVIDER_STREAM_PORTS": "a-b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg := DefaultLocalConfig()
+			err := applyEnvOverrides(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("applyEnvOverrides() = %+v, want an error", cfg.Ports)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnvOverrides() error = %s", err)
+			}
+			if cfg.Ports != tt.want {
+				t.Errorf("ports = %+v, want %+v", cfg.Ports, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortRangeValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		portRange PortRange
+		wantErr   bool
+	}{
+		{"unset", PortRange{}, false},
+		{"single port", PortRange{Min: 20000, Max: 20000}, false},
+		{"range", PortRange{Min: 20000, Max: 20999}, false},
+		{"negative min", PortRange{Min: -1, Max: 10}, true},
+		{"max below min", PortRange{Min: 20999, Max: 20000}, true},
+		{"max above the last port", PortRange{Min: 65000, Max: 65536}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.portRange.validate("test"); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPortRangesRange(t *testing.T) {
+	ports := PortRanges{
+		Min:    20000,
+		Max:    20999,
+		Appium: PortRange{Min: 21000, Max: 21099},
+		// A purpose range without max is not configured
+		Stream: PortRange{Min: 22000},
+	}
+
+	tests := []struct {
+		purpose string
+		want    PortRange
+	}{
+		{PortAppium, PortRange{Min: 21000, Max: 21099}},
+		{PortStream, PortRange{Min: 20000, Max: 20999}},
+		{PortWDA, PortRange{Min: 20000, Max: 20999}},
+		{PortTunnel, PortRange{Min: 20000, Max: 20999}},
+	}
+	for _, tt := range tests {
+		if got := ports.Range(tt.purpose); got != tt.want {
+			t.Errorf("Range(%s) = %+v, want %+v", tt.purpose, got, tt.want)
+		}
+	}
+}
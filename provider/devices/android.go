@@ -10,20 +10,22 @@
 package devices
 
 import (
-	"GADS/common"
 	"GADS/common/auth"
-	"GADS/common/db"
 	"GADS/common/models"
 	"GADS/provider/config"
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
+	"GADS/provider/store"
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -31,6 +33,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -48,6 +51,15 @@ type AndroidDevice struct {
 	ADBPort                 string                  // host port forwarded to device adbd port 5555 (ADB tunnel)
 	ActiveDisplayID         string                  // display ID used for screencap; empty = device default
 	AvailableDisplays       []models.AndroidDisplay // all physical displays detected on the device
+	devToolsForwards        map[string]string       // DevTools abstract socket name -> forwarded host port
+	devToolsMu              sync.Mutex
+
+	locationMu       sync.Mutex
+	emulatorLocation *[2]string // latitude and longitude of the emulator before the first `geo fix`, restored on reset
+
+	packagesInfoMu     sync.Mutex
+	packagesInfo       map[string]models.DeviceApp // cached `dumpsys package packages` result, nil when it has to be refreshed
+	packagesInfoExpiry time.Time
 }
 
 const adbTCPPort = "5555"
@@ -67,65 +79,50 @@ func (d *AndroidDevice) Setup() (retErr error) {
 	d.SetupMutex.Lock()
 	defer d.SetupMutex.Unlock()
 
-	if time.Now().Before(d.setupBackoffUntil) {
+	if d.inSetupBackoff() {
 		return nil
 	}
-
-	defer func() {
-		switch {
-		case retErr == nil:
-			d.setupBackoffNext = 0
-			d.setupBackoffUntil = time.Time{}
-		case errors.Is(retErr, context.Canceled), errors.Is(retErr, context.DeadlineExceeded):
-			// external cancellation — do not apply backoff
-		default:
-			if d.setupBackoffNext == 0 {
-				d.setupBackoffNext = setupBackoffBase
-			} else {
-				d.setupBackoffNext = min(d.setupBackoffNext*2, setupBackoffMax)
-			}
-			d.setupBackoffUntil = time.Now().Add(d.setupBackoffNext)
-		}
-	}()
+	defer func() { d.updateSetupBackoff(retErr) }()
 
 	d.SetProviderState("preparing")
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Running setup for device `%v`", d.GetUDID()))
 
 	d.getHardwareModel()
 
-	if err := d.updateScreenSizeIfNeeded(); err != nil {
-		return d.resetWithError("update screen dimensions with adb", err)
+	if err := d.setupStep("update screen dimensions with adb", d.updateScreenSizeIfNeeded); err != nil {
+		return err
 	}
-	if err := d.disableAutoRotation(); err != nil {
-		return d.resetWithError("disable auto-rotation", err)
+	if err := d.setupStep("disable auto-rotation", d.disableAutoRotation); err != nil {
+		return err
 	}
-	if err := d.allocatePorts(); err != nil {
-		return d.resetWithError("allocate free host ports", err)
+	if err := d.setupStep("allocate free host ports", d.allocatePorts); err != nil {
+		return err
 	}
-	if err := d.enableADBTCPMode(); err != nil {
-		return d.resetWithError("enable ADB TCP mode", err)
+	if err := d.setupStep("enable ADB TCP mode", d.enableADBTCPMode); err != nil {
+		return err
 	}
-	if err := d.cleanupOldApps(); err != nil {
+	d.clearStaleHTTPProxy()
+	if err := d.recordStep("clean up old apps", d.cleanupOldApps); err != nil {
 		return err // already reset inside cleanupOldApps
 	}
-	if err := d.installGadsSettingsApp(); err != nil {
-		return d.resetWithError("install GADS Settings", err)
+	if err := d.setupStep("install GADS Settings", d.installGadsSettingsApp); err != nil {
+		return err
 	}
 	time.Sleep(1 * time.Second)
-	if err := d.pushGadsSettingsInTmpLocal(); err != nil {
-		return fmt.Errorf("push GADS Settings to /tmp/local", err)
+	if err := d.recordStep("push GADS Settings to /tmp/local", d.pushGadsSettingsInTmpLocal); err != nil {
+		return fmt.Errorf("push GADS Settings to /tmp/local - %w", err)
 	}
 	time.Sleep(2 * time.Second)
-	if err := d.startServicesAndStreaming(); err != nil {
+	if err := d.recordStep("start services and streaming", d.startServicesAndStreaming); err != nil {
 		return err // already reset inside
 	}
-	if err := d.forwardAndSetupPorts(); err != nil {
+	if err := d.recordStep("forward and set up ports", d.forwardAndSetupPorts); err != nil {
 		return err // already reset inside
 	}
-	if err := d.applyStreamConfig(); err != nil {
-		return d.resetWithError("apply device stream settings", err)
+	if err := d.setupStep("apply device stream settings", d.applyStreamConfig); err != nil {
+		return err
 	}
-	if err := d.setupAppiumIfNeeded(); err != nil {
+	if err := d.recordStep("set up Appium", d.setupAppiumIfNeeded); err != nil {
 		return err
 	}
 
@@ -146,25 +143,25 @@ func (d *AndroidDevice) updateScreenSizeIfNeeded() error {
 }
 
 func (d *AndroidDevice) allocatePorts() error {
-	streamPort, err := providerutil.GetFreePort()
+	streamPort, err := providerutil.AllocatePort(config.PortStream, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free host port for GADS-stream - %w", err)
 	}
 	d.StreamPort = streamPort
 
-	imePort, err := providerutil.GetFreePort()
+	imePort, err := providerutil.AllocatePort(config.PortIME, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free host port for GADS Android IME - %w", err)
 	}
 	d.AndroidIMEPort = imePort
 
-	remoteServerPort, err := providerutil.GetFreePort()
+	remoteServerPort, err := providerutil.AllocatePort(config.PortRemoteServer, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free host port for GADS Android remote control server - %w", err)
 	}
 	d.AndroidRemoteServerPort = remoteServerPort
 
-	adbPort, err := providerutil.GetFreePort()
+	adbPort, err := providerutil.AllocatePort(config.PortADB, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free host port for ADB tunnel - %w", err)
 	}
@@ -306,12 +303,8 @@ func (d *AndroidDevice) AppiumCapabilities() models.AppiumServerCapabilities {
 // Reset overrides RuntimeState.Reset to free Android-specific ports.
 func (d *AndroidDevice) Reset(reason string) {
 	if d.ResetBase(reason) {
-		common.MutexManager.LocalDevicePorts.Lock()
-		delete(providerutil.UsedPorts, d.StreamPort)
-		delete(providerutil.UsedPorts, d.AndroidIMEPort)
-		delete(providerutil.UsedPorts, d.AndroidRemoteServerPort)
-		delete(providerutil.UsedPorts, d.ADBPort)
-		common.MutexManager.LocalDevicePorts.Unlock()
+		providerutil.ReleasePort(d.StreamPort, d.AndroidIMEPort, d.AndroidRemoteServerPort, d.ADBPort)
+		d.releaseDevToolsForwards()
 	}
 }
 
@@ -329,7 +322,7 @@ func (d *AndroidDevice) androidRemoteServerRequest(method, endpoint string, requ
 }
 
 func (d *AndroidDevice) isStreamServiceRunning() (bool, error) {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "dumpsys", "activity", "services", d.getStreamServiceName())
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "dumpsys", "activity", "services", d.getStreamServiceName())
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return false, fmt.Errorf("isStreamServiceRunning: Error executing `%s` with combined output - %s", cmd.Args, err)
@@ -341,7 +334,7 @@ func (d *AndroidDevice) isStreamServiceRunning() (bool, error) {
 }
 
 func (d *AndroidDevice) stopStreamService() {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "am", "stopservice", d.getStreamServiceName())
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "am", "stopservice", d.getStreamServiceName())
 	if err := cmd.Run(); err != nil {
 		logger.ProviderLogger.LogWarn("android_device_setup", fmt.Sprintf("Failed to stop GADS-stream service properly - %s", err))
 	}
@@ -349,7 +342,7 @@ func (d *AndroidDevice) stopStreamService() {
 
 func (d *AndroidDevice) installGadsSettingsApp() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Installing GADS Settings apk on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "install", "-r", fmt.Sprintf("%s/gads-settings.apk", config.ProviderConfig.ProviderFolder))
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "install", "-r", fmt.Sprintf("%s/gads-settings.apk", config.ProviderConfig.ProviderFolder))
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("installGadsSettingsApp: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -358,7 +351,7 @@ func (d *AndroidDevice) installGadsSettingsApp() error {
 
 func (d *AndroidDevice) pushGadsSettingsInTmpLocal() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Pushing GADS Settings apk to /tmp/local on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "push", fmt.Sprintf("%s/gads-settings.apk", config.ProviderConfig.ProviderFolder), "/data/local/tmp/gads-settings")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "push", fmt.Sprintf("%s/gads-settings.apk", config.ProviderConfig.ProviderFolder), "/data/local/tmp/gads-settings")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("pushGadsSettingsInTmpLocal: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -366,11 +359,11 @@ func (d *AndroidDevice) pushGadsSettingsInTmpLocal() error {
 }
 
 func (d *AndroidDevice) startRemoteControlServer() {
-	killCmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "pkill -f RemoteControlServerKt")
+	killCmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pkill -f RemoteControlServerKt")
 	_ = killCmd.Run()
 	time.Sleep(1 * time.Second)
 
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell",
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell",
 		"CLASSPATH=/data/local/tmp/gads-settings app_process / com.shamanec.settings.RemoteControlServerKt 1994")
 
 	fmt.Println(cmd.Args)
@@ -386,11 +379,11 @@ func (d *AndroidDevice) startRemoteControlServer() {
 }
 
 func (d *AndroidDevice) startH264Stream() {
-	killCmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "pkill -f H264Server")
+	killCmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pkill -f H264Server")
 	_ = killCmd.Run()
 	time.Sleep(1 * time.Second)
 
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell",
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell",
 		"CLASSPATH=/data/local/tmp/gads-settings app_process / com.shamanec.settings.server.H264Server")
 
 	if err := cmd.Start(); err != nil {
@@ -406,14 +399,14 @@ func (d *AndroidDevice) startH264Stream() {
 
 func (d *AndroidDevice) setupIME() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Enabling GADS Android IME on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "ime", "enable", "com.gads.settings/com.shamanec.settings.RemoteKeyboardIME")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "ime", "enable", "com.gads.settings/com.shamanec.settings.RemoteKeyboardIME")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("enableGadsAndroidIME: Error executing `%s` - %s", cmd.Args, err)
 	}
 	time.Sleep(1 * time.Second)
 
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Setting GADS Android IME as active on device `%v`", d.GetUDID()))
-	cmd = exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "ime", "set", "com.gads.settings/com.shamanec.settings.RemoteKeyboardIME")
+	cmd = exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "ime", "set", "com.gads.settings/com.shamanec.settings.RemoteKeyboardIME")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("setGadsAndroidIMEAsActive: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -422,7 +415,7 @@ func (d *AndroidDevice) setupIME() error {
 
 func (d *AndroidDevice) addStreamRecordingPermissions() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Adding GADS-stream recording permissions on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "appops", "set", d.getStreamServicePackageName(), "PROJECT_MEDIA", "allow")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "appops", "set", d.getStreamServicePackageName(), "PROJECT_MEDIA", "allow")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("addStreamRecordingPermissions: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -431,7 +424,7 @@ func (d *AndroidDevice) addStreamRecordingPermissions() error {
 
 func (d *AndroidDevice) addStreamPostNotificationsPermission() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Adding GADS app post notification permissions on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "pm", "grant", d.getStreamServicePackageName(), "android.permission.POST_NOTIFICATIONS")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pm", "grant", d.getStreamServicePackageName(), "android.permission.POST_NOTIFICATIONS")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("addStreamPostNotificationsPermission: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -440,7 +433,7 @@ func (d *AndroidDevice) addStreamPostNotificationsPermission() error {
 
 func (d *AndroidDevice) startStreaming() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Starting GADS-stream app on `%s`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "am", "start", "-n", d.getStreamServiceActivityName())
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "am", "start", "-n", d.getStreamServiceActivityName())
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("startStreaming: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -448,14 +441,14 @@ func (d *AndroidDevice) startStreaming() error {
 }
 
 func (d *AndroidDevice) pressHomeButton() {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "input", "keyevent", "KEYCODE_HOME")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "input", "keyevent", "KEYCODE_HOME")
 	if err := cmd.Run(); err != nil {
 		logger.ProviderLogger.LogError("android_device_setup", fmt.Sprintf("pressHomeButton: Could not 'press' Home button - %v", err))
 	}
 }
 
 func (d *AndroidDevice) forwardPort(devicePort, hostPort string) error {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "forward", "tcp:"+hostPort, "tcp:"+devicePort)
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "forward", "tcp:"+hostPort, "tcp:"+devicePort)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("forwardPort: Error forwarding device port %s to host port %s - %s", devicePort, hostPort, err)
 	}
@@ -476,7 +469,7 @@ func (d *AndroidDevice) forwardRemoteServer() error {
 
 func (d *AndroidDevice) enableADBTCPMode() error {
 	// Check if tcpip mode is already enabled to avoid restarting adbd unnecessarily
-	checkCmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "getprop", "service.adb.tcp.port")
+	checkCmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "getprop", "service.adb.tcp.port")
 	var outBuffer bytes.Buffer
 	checkCmd.Stdout = &outBuffer
 	if err := checkCmd.Run(); err == nil {
@@ -487,7 +480,7 @@ func (d *AndroidDevice) enableADBTCPMode() error {
 	}
 
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Enabling ADB TCP mode on device `%v`", d.GetUDID()))
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "tcpip", adbTCPPort)
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "tcpip", adbTCPPort)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("enableADBTCPMode: Error executing `%s` - %s", cmd.Args, err)
 	}
@@ -504,7 +497,7 @@ func (d *AndroidDevice) updateScreenSizeADB() error {
 	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Attempting to automatically update the screen size for device `%v`", d.GetUDID()))
 
 	var outBuffer bytes.Buffer
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "wm", "size")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "wm", "size")
 	cmd.Stdout = &outBuffer
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("updateScreenSizeADB: Error executing `%s` - %s", cmd.Args, err)
@@ -525,7 +518,7 @@ func (d *AndroidDevice) updateScreenSizeADB() error {
 		d.DBDevice.ScreenHeight = strings.TrimSpace(screenDimensions[1])
 	}
 
-	if err := db.GlobalMongoStore.AddOrUpdateDevice(&d.DBDevice); err != nil {
+	if err := store.GlobalStore.AddOrUpdateDevice(&d.DBDevice); err != nil {
 		return fmt.Errorf("Failed to upsert new device screen dimensions to DB - %s", err)
 	}
 	return nil
@@ -534,7 +527,7 @@ func (d *AndroidDevice) updateScreenSizeADB() error {
 // GetInstalledAppBundleIDs returns the bundle identifiers (package names) of third-party installed apps.
 func (d *AndroidDevice) GetInstalledAppBundleIDs() []string {
 	installedApps := make([]string, 0)
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "cmd", "package", "list", "packages", "-3")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "cmd", "package", "list", "packages", "-3")
 
 	var outBuffer bytes.Buffer
 	cmd.Stdout = &outBuffer
@@ -574,31 +567,41 @@ func (d *AndroidDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 		d.Logger.LogError("get_installed_apps", fmt.Sprintf("Failed unmarshalling remote server response - %s", err.Error()))
 		return deviceApps, err
 	}
+
+	packagesInfo := d.getPackagesInfo(deviceApps)
+	for i := range deviceApps {
+		if info, ok := packagesInfo[deviceApps[i].BundleIdentifier]; ok {
+			deviceApps[i].Version = info.Version
+			deviceApps[i].InstallTime = info.InstallTime
+		}
+	}
 	return deviceApps, nil
 }
 
 // UninstallApp uninstalls an app by package name.
 func (d *AndroidDevice) UninstallApp(packageName string) error {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "uninstall", packageName)
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "uninstall", packageName)
 	if err := cmd.Run(); err != nil {
 		d.Logger.LogError("uninstall_app", fmt.Sprintf("Error uninstalling app `%s` - %v", packageName, err))
 		return err
 	}
+	d.invalidatePackagesInfo()
 	return nil
 }
 
 // InstallApp installs an app from a file in the provider folder.
 func (d *AndroidDevice) InstallApp(appName string) error {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "install", "-r", fmt.Sprintf("%s/%s", config.ProviderConfig.ProviderFolder, appName))
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "install", "-r", fmt.Sprintf("%s/%s", config.ProviderConfig.ProviderFolder, appName))
 	if err := cmd.Run(); err != nil {
 		d.Logger.LogError("install_app", fmt.Sprintf("Error installing app `%s` - %v", appName, err))
 		return err
 	}
+	d.invalidatePackagesInfo()
 	return nil
 }
 
 func (d *AndroidDevice) disableAutoRotation() error {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "settings", "put", "system", "accelerometer_rotation", "0")
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "settings", "put", "system", "accelerometer_rotation", "0")
 	if err := cmd.Run(); err != nil {
 		return err
 	}
@@ -683,7 +686,7 @@ func (d *AndroidDevice) UpdateStreamSettingsOnDevice() error {
 
 // UpdateWebRTCTURNConfig sends TURN configuration to the WebRTC service on the device.
 func (d *AndroidDevice) UpdateWebRTCTURNConfig() error {
-	turnConfig, err := db.GlobalMongoStore.GetTURNConfig()
+	turnConfig, err := store.GlobalStore.GetTURNConfig()
 	if err != nil {
 		return fmt.Errorf("failed to get TURN config from DB - %s", err)
 	}
@@ -739,7 +742,7 @@ func (d *AndroidDevice) GetHardwareModel() (string, error) {
 }
 
 func (d *AndroidDevice) getHardwareModel() {
-	brandCmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "getprop", "ro.product.brand")
+	brandCmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "getprop", "ro.product.brand")
 	var outBuffer bytes.Buffer
 	brandCmd.Stdout = &outBuffer
 	if err := brandCmd.Run(); err != nil {
@@ -748,7 +751,7 @@ func (d *AndroidDevice) getHardwareModel() {
 	brand := outBuffer.String()
 	outBuffer.Reset()
 
-	modelCmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "getprop", "ro.product.model")
+	modelCmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "getprop", "ro.product.model")
 	modelCmd.Stdout = &outBuffer
 	if err := modelCmd.Run(); err != nil {
 		d.HardwareModel = "Unknown"
@@ -807,7 +810,7 @@ func (d *AndroidDevice) GetAvailableDisplays() []models.AndroidDisplay {
 // getAndroidDisplayIDs runs dumpsys SurfaceFlinger --display-id and returns parsed displays.
 func getAndroidDisplayIDs(udid string) ([]models.AndroidDisplay, error) {
 	var out bytes.Buffer
-	cmd := exec.Command("adb", "-s", udid, "shell", "dumpsys", "SurfaceFlinger", "--display-id")
+	cmd := exec.Command(config.Local.Tools.ADB, "-s", udid, "shell", "dumpsys", "SurfaceFlinger", "--display-id")
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
 		return nil, fmt.Errorf("dumpsys SurfaceFlinger --display-id: %w", err)
@@ -862,27 +865,399 @@ func (d *AndroidDevice) LaunchApp(bundleID string) error {
 
 // KillApp force-stops an Android app by package name.
 func (d *AndroidDevice) KillApp(bundleIdentifier string) error {
-	cmd := exec.CommandContext(d.Context, "adb", "-s", d.GetUDID(), "shell", "am", "force-stop", bundleIdentifier)
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "am", "force-stop", bundleIdentifier)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("KillApp: Failed killing app with package name `%s` via adb shell", bundleIdentifier)
 	}
 	return nil
 }
 
+// Reboot restarts the device with `adb reboot`.
+func (d *AndroidDevice) Reboot() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, config.Local.Tools.ADB, "-s", d.GetUDID(), "reboot").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Reboot: adb reboot failed - %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// bootCompleted reports whether Android finished booting after a reboot
+func (d *AndroidDevice) bootCompleted() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "getprop", "sys.boot_completed").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "1"
+}
+
+// ClearAppData deletes all data of an app, same as clearing storage from the app settings.
+func (d *AndroidDevice) ClearAppData(packageName string) error {
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pm", "clear", packageName).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "Success") {
+		return fmt.Errorf("ClearAppData: failed clearing data of `%s` - %s", packageName, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// isAndroidRuntimePermission reports whether the value is a runtime permission like `android.permission.CAMERA`
+// as opposed to an app op like `SYSTEM_ALERT_WINDOW`
+func isAndroidRuntimePermission(permission string) bool {
+	return strings.Contains(permission, ".")
+}
+
+// GrantAppPermissions grants runtime permissions with `pm grant` and allows app ops with `appops set`.
+func (d *AndroidDevice) GrantAppPermissions(packageName string, permissions []string) error {
+	var errs []error
+	for _, permission := range permissions {
+		args := []string{"-s", d.GetUDID(), "shell", "pm", "grant", packageName, permission}
+		if !isAndroidRuntimePermission(permission) {
+			args = []string{"-s", d.GetUDID(), "shell", "appops", "set", packageName, permission, "allow"}
+		}
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, args...).CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Errorf("failed granting `%s` - %s", permission, strings.TrimSpace(string(out))))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("GrantAppPermissions: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// RevokeAppPermissions revokes runtime permissions with `pm revoke` and denies app ops with `appops set`.
+func (d *AndroidDevice) RevokeAppPermissions(packageName string, permissions []string) error {
+	var errs []error
+	for _, permission := range permissions {
+		args := []string{"-s", d.GetUDID(), "shell", "pm", "revoke", packageName, permission}
+		if !isAndroidRuntimePermission(permission) {
+			args = []string{"-s", d.GetUDID(), "shell", "appops", "set", packageName, permission, "deny"}
+		}
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, args...).CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Errorf("failed revoking `%s` - %s", permission, strings.TrimSpace(string(out))))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("RevokeAppPermissions: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ResetAppPermissions returns permissions to their initial state so the app prompts for them again.
+// If no permissions are provided all granted runtime permissions and all app ops of the app are reset.
+func (d *AndroidDevice) ResetAppPermissions(packageName string, permissions []string) error {
+	if len(permissions) == 0 {
+		granted, err := d.getGrantedRuntimePermissions(packageName)
+		if err != nil {
+			return fmt.Errorf("ResetAppPermissions: %w", err)
+		}
+		permissions = granted
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "appops", "reset", packageName).CombinedOutput(); err != nil {
+			return fmt.Errorf("ResetAppPermissions: failed resetting app ops of `%s` - %s", packageName, strings.TrimSpace(string(out)))
+		}
+	}
+
+	var errs []error
+	for _, permission := range permissions {
+		if !isAndroidRuntimePermission(permission) {
+			if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "appops", "set", packageName, permission, "default").CombinedOutput(); err != nil {
+				errs = append(errs, fmt.Errorf("failed resetting `%s` - %s", permission, strings.TrimSpace(string(out))))
+			}
+			continue
+		}
+		// Revoking alone leaves the user-set flags so the app would not be able to ask again
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pm", "revoke", packageName, permission).CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Errorf("failed revoking `%s` - %s", permission, strings.TrimSpace(string(out))))
+			continue
+		}
+		exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pm", "clear-permission-flags", packageName, permission, "user-set", "user-fixed").Run()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ResetAppPermissions: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (d *AndroidDevice) getGrantedRuntimePermissions(packageName string) ([]string, error) {
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "dumpsys", "package", packageName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting package info of `%s` - %w", packageName, err)
+	}
+
+	var granted []string
+	inRuntimePermissions := false
+	for _, line := range strings.Split(string(out), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "runtime permissions:") {
+			inRuntimePermissions = true
+			continue
+		}
+		if !inRuntimePermissions {
+			continue
+		}
+		permission, state, found := strings.Cut(trimmed, ": ")
+		if !found || !strings.Contains(permission, ".") {
+			inRuntimePermissions = false
+			continue
+		}
+		if strings.Contains(state, "granted=true") && !slices.Contains(granted, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	return granted, nil
+}
+
+// packagesInfoTTL is how long the `dumpsys package packages` result is reused, the full dump takes seconds on devices with many apps
+const packagesInfoTTL = 5 * time.Minute
+
+// getPackagesInfo returns the version and first install time of all packages from `dumpsys package packages`.
+// The result is cached and refreshed when it expires, when an app is installed or uninstalled through the provider
+// or when one of the listed apps is not in it, e.g. installed through Appium.
+func (d *AndroidDevice) getPackagesInfo(apps []models.DeviceApp) map[string]models.DeviceApp {
+	d.packagesInfoMu.Lock()
+	defer d.packagesInfoMu.Unlock()
+
+	if d.packagesInfo != nil && time.Now().Before(d.packagesInfoExpiry) && !slices.ContainsFunc(apps, func(app models.DeviceApp) bool {
+		_, ok := d.packagesInfo[app.BundleIdentifier]
+		return !ok
+	}) {
+		return d.packagesInfo
+	}
+
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "dumpsys", "package", "packages").Output()
+	if err != nil {
+		d.Logger.LogWarn("get_installed_apps", fmt.Sprintf("Failed getting packages info - %v", err))
+		return map[string]models.DeviceApp{}
+	}
+	d.packagesInfo = parseDumpsysPackages(string(out))
+	d.packagesInfoExpiry = time.Now().Add(packagesInfoTTL)
+	return d.packagesInfo
+}
+
+func (d *AndroidDevice) invalidatePackagesInfo() {
+	d.packagesInfoMu.Lock()
+	d.packagesInfo = nil
+	d.packagesInfoMu.Unlock()
+}
+
+var dumpsysPackageRegex = regexp.MustCompile(`^\s*Package \[([^\]]+)\]`)
+
+func parseDumpsysPackages(output string) map[string]models.DeviceApp {
+	packages := make(map[string]models.DeviceApp)
+
+	var current string
+	for _, line := range strings.Split(output, "\n") {
+		if match := dumpsysPackageRegex.FindStringSubmatch(line); match != nil {
+			current = match[1]
+			packages[current] = models.DeviceApp{BundleIdentifier: current}
+			continue
+		}
+		if current == "" {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		app := packages[current]
+		switch {
+		case strings.HasPrefix(trimmed, "versionName="):
+			app.Version = strings.TrimPrefix(trimmed, "versionName=")
+		case strings.HasPrefix(trimmed, "firstInstallTime="):
+			// The time is in the device timezone, we assume the provider is in the same one
+			installTime, err := time.ParseInLocation("2006-01-02 15:04:05", strings.TrimPrefix(trimmed, "firstInstallTime="), time.Local)
+			if err == nil {
+				app.InstallTime = installTime.UnixMilli()
+			}
+		}
+		packages[current] = app
+	}
+	return packages
+}
+
+// appiumSettingsPackage is the Appium Settings app the UiAutomator2 driver installs, its location service provides the mock locations
+const appiumSettingsPackage = "io.appium.settings"
+
+func (d *AndroidDevice) getMockLocationServiceName() string {
+	return appiumSettingsPackage + "/.LocationService"
+}
+
+// emulatorDefaultLocation is the location of a new emulator, used when the previous location is not known
+var emulatorDefaultLocation = [2]string{"37.4219983", "-122.0840000"}
+
+var lastGPSLocationRegex = regexp.MustCompile(`Location\[gps (-?[\d.]+),(-?[\d.]+)`)
+
+// SetLocation sets a mock GPS location through the location service of the Appium Settings app.
+// Emulators use the console `geo fix` command instead, their location before the first fix is kept so it can be restored.
+func (d *AndroidDevice) SetLocation(latitude, longitude float64) error {
+	lat := strconv.FormatFloat(latitude, 'f', 7, 64)
+	lon := strconv.FormatFloat(longitude, 'f', 7, 64)
+
+	if strings.HasPrefix(d.GetUDID(), "emulator-") {
+		d.locationMu.Lock()
+		if d.emulatorLocation == nil {
+			previous := emulatorDefaultLocation
+			if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "dumpsys", "location").Output(); err == nil {
+				if match := lastGPSLocationRegex.FindStringSubmatch(string(out)); match != nil {
+					previous = [2]string{match[1], match[2]}
+				}
+			}
+			d.emulatorLocation = &previous
+		}
+		d.locationMu.Unlock()
+
+		// Note that `geo fix` expects longitude first
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "emu", "geo", "fix", lon, lat).CombinedOutput(); err != nil {
+			return fmt.Errorf("SetLocation: failed setting emulator location - %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}
+
+	if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "pm", "path", appiumSettingsPackage).Output(); err != nil || !strings.HasPrefix(strings.TrimSpace(string(out)), "package:") {
+		return fmt.Errorf("%w - `%s` is installed by the Appium UiAutomator2 driver, run an Appium session on the device once or install the Appium Settings apk with `adb install`", ErrLocationAppMissing, appiumSettingsPackage)
+	}
+
+	// Appium Settings has to be allowed as mock location app before it can register a test provider
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "appops", "set", appiumSettingsPackage, "android:mock_location", "allow")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("SetLocation: failed allowing mock locations for Appium Settings - %w", err)
+	}
+
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "am", "start-foreground-service",
+		"--user", "0",
+		"-n", d.getMockLocationServiceName(),
+		"--es", "latitude", lat,
+		"--es", "longitude", lon).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("SetLocation: failed starting the Appium Settings location service - %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	// `am` exits with 0 even when the service does not exist
+	if strings.Contains(string(out), "Error") {
+		return fmt.Errorf("SetLocation: the Appium Settings location service is not available - %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ResetLocation stops the Appium Settings location service which removes the test GPS provider.
+// Emulators get the location they had before the first fix again.
+func (d *AndroidDevice) ResetLocation() error {
+	if strings.HasPrefix(d.GetUDID(), "emulator-") {
+		d.locationMu.Lock()
+		previous := d.emulatorLocation
+		d.emulatorLocation = nil
+		d.locationMu.Unlock()
+		if previous == nil {
+			return nil
+		}
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "emu", "geo", "fix", previous[1], previous[0]).CombinedOutput(); err != nil {
+			return fmt.Errorf("ResetLocation: failed restoring emulator location - %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}
+
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "am", "stopservice", d.getMockLocationServiceName())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ResetLocation: failed stopping the Appium Settings location service - %w", err)
+	}
+	return nil
+}
+
+// PressKeycode sends a key event with the provided Android keycode.
+func (d *AndroidDevice) PressKeycode(keycode int) error {
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "input", "keyevent", strconv.Itoa(keycode))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("PressKeycode: failed sending keycode %d - %s - %w", keycode, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// SetHTTPProxy sets the global proxy of the device to the shaping proxy on the provider host.
+// The port is reversed through adb so the device does not need network access to the provider host.
+func (d *AndroidDevice) SetHTTPProxy(port string) error {
+	if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "reverse", "tcp:"+port, "tcp:"+port).CombinedOutput(); err != nil {
+		return fmt.Errorf("SetHTTPProxy: failed reversing port %s - %s - %w", port, strings.TrimSpace(string(out)), err)
+	}
+	if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "settings", "put", "global", "http_proxy", "127.0.0.1:"+port).CombinedOutput(); err != nil {
+		return fmt.Errorf("SetHTTPProxy: failed setting global http_proxy - %s - %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// ClearHTTPProxy removes the global proxy of the device.
+// It does not use the device context because it also runs while the device is being reset.
+func (d *AndroidDevice) ClearHTTPProxy(port string) error {
+	if out, err := exec.Command(config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "settings", "put", "global", "http_proxy", ":0").CombinedOutput(); err != nil {
+		return fmt.Errorf("ClearHTTPProxy: failed clearing global http_proxy - %s - %w", strings.TrimSpace(string(out)), err)
+	}
+	exec.Command(config.Local.Tools.ADB, "-s", d.GetUDID(), "reverse", "--remove", "tcp:"+port).Run()
+	return nil
+}
+
+// ShapingProxyHost binds the shaping proxy to loopback, the device reaches it through `adb reverse`
+func (d *AndroidDevice) ShapingProxyHost() string {
+	return "127.0.0.1"
+}
+
+// AllowShapingClient accepts every client, only local processes and the reversed adb port can reach a loopback proxy
+func (d *AndroidDevice) AllowShapingClient(addr net.Addr) bool {
+	return true
+}
+
+// clearStaleHTTPProxy removes a shaping proxy left over from a previous provider run, otherwise the device would have no network
+func (d *AndroidDevice) clearStaleHTTPProxy() {
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "settings", "get", "global", "http_proxy").Output()
+	if err != nil {
+		return
+	}
+	proxy := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(proxy, "127.0.0.1:") {
+		return
+	}
+	logger.ProviderLogger.LogInfo("android_device_setup", fmt.Sprintf("Clearing stale network shaping proxy `%s` on device `%v`", proxy, d.GetUDID()))
+	if err := d.ClearHTTPProxy(strings.TrimPrefix(proxy, "127.0.0.1:")); err != nil {
+		logger.ProviderLogger.LogWarn("android_device_setup", fmt.Sprintf("Could not clear stale network shaping proxy on device `%v` - %s", d.GetUDID(), err))
+	}
+}
+
+// InstallCACertificate installs the certificate as system CA when adb runs as root.
+// Otherwise the certificate is pushed to the Download folder so it can be installed as user CA from the device settings.
+func (d *AndroidDevice) InstallCACertificate(cert *x509.Certificate) error {
+	certFile := filepath.Join(os.TempDir(), d.GetUDID()+"_"+androidCAFileName(cert))
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		return fmt.Errorf("InstallCACertificate: failed writing temporary certificate file - %w", err)
+	}
+	defer os.Remove(certFile)
+
+	out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "id", "-u").Output()
+	if err == nil && strings.TrimSpace(string(out)) == "0" {
+		systemPath := "/system/etc/security/cacerts/" + androidCAFileName(cert)
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "remount").CombinedOutput(); err != nil {
+			return fmt.Errorf("InstallCACertificate: failed remounting system partition - %s - %w", strings.TrimSpace(string(out)), err)
+		}
+		if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "push", certFile, systemPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("InstallCACertificate: failed pushing certificate to `%s` - %s - %w", systemPath, strings.TrimSpace(string(out)), err)
+		}
+		exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "chmod", "644", systemPath).Run()
+		return nil
+	}
+
+	if out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "push", certFile, "/sdcard/Download/gads-ca.crt").CombinedOutput(); err != nil {
+		return fmt.Errorf("InstallCACertificate: failed pushing certificate to the Download folder - %s - %w", strings.TrimSpace(string(out)), err)
+	}
+	return fmt.Errorf("%w - adb does not run as root, the certificate was pushed to /sdcard/Download/gads-ca.crt and can be installed from the security settings, apps also have to trust user CAs in their network security config", ErrCAManualInstallRequired)
+}
+
 // ApplyStreamSettings applies stream settings from DB to the device runtime state.
 func (d *AndroidDevice) ApplyStreamSettings() error {
 	return applyDeviceStreamSettings(d)
 }
 
 func DeleteAndroidSharedStorageFile(device *models.DBDevice, filePath string) error {
-	deleteFileCmd := exec.Command("adb", "-s", device.UDID, "shell", "rm", fmt.Sprintf("\"%s\"", filePath))
+	deleteFileCmd := exec.Command(config.Local.Tools.ADB, "-s", device.UDID, "shell", "rm", fmt.Sprintf("\"%s\"", filePath))
 	_, err := deleteFileCmd.Output()
 	return err
 }
 
 func PullAndroidSharedStorageFile(device *models.DBDevice, filePath string, fileName string) (string, error) {
 	var tempFilePath = filepath.Join(os.TempDir(), fileName)
-	pullFileCmd := exec.Command("adb", "-s", device.UDID, "pull", filePath, tempFilePath)
+	pullFileCmd := exec.Command(config.Local.Tools.ADB, "-s", device.UDID, "pull", filePath, tempFilePath)
 	_, err := pullFileCmd.Output()
 	return tempFilePath, err
 }
@@ -905,7 +1280,7 @@ func reconnectOfflineAndroid(udid string) {
 	androidOfflineReconnectMu.Unlock()
 
 	logger.ProviderLogger.LogInfo("android_reconnect", fmt.Sprintf("Device %s is offline in ADB, attempting reconnect", udid))
-	cmd := exec.CommandContext(context.Background(), "adb", "-s", udid, "reconnect")
+	cmd := exec.CommandContext(context.Background(), config.Local.Tools.ADB, "-s", udid, "reconnect")
 	if err := cmd.Run(); err != nil {
 		logger.ProviderLogger.LogError("android_reconnect", fmt.Sprintf("Failed to reconnect device %s: %v", udid, err))
 	} else {
@@ -917,7 +1292,7 @@ func reconnectOfflineAndroid(udid string) {
 func getConnectedDevicesAndroid() []string {
 	var connectedDevices []string
 
-	cmd := exec.Command("adb", "devices")
+	cmd := exec.Command(config.Local.Tools.ADB, "devices")
 	// Create a pipe to capture the command's output
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
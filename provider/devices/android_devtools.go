@@ -0,0 +1,110 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"GADS/provider/config"
+	"GADS/provider/providerutil"
+)
+
+// DevToolsSockets returns the abstract unix sockets on the device that expose the Chrome DevTools protocol.
+// Chrome exposes `chrome_devtools_remote`, debuggable WebViews expose `webview_devtools_remote_<pid>`.
+func (d *AndroidDevice) DevToolsSockets() ([]string, error) {
+	var outBuffer bytes.Buffer
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "cat", "/proc/net/unix")
+	cmd.Stdout = &outBuffer
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list unix sockets - %w", err)
+	}
+
+	sockets := []string{}
+	for _, line := range strings.Split(outBuffer.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[len(fields)-1]
+		if !strings.HasPrefix(name, "@") || !strings.Contains(name, "devtools_remote") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "@")
+		if !slices.Contains(sockets, name) {
+			sockets = append(sockets, name)
+		}
+	}
+
+	d.pruneDevToolsForwards(sockets)
+	return sockets, nil
+}
+
+// DevToolsSocketPackage returns the package that owns the DevTools socket if it can be determined
+func (d *AndroidDevice) DevToolsSocketPackage(socket string) string {
+	if socket == "chrome_devtools_remote" {
+		return "com.android.chrome"
+	}
+	if pid, ok := strings.CutPrefix(socket, "webview_devtools_remote_"); ok {
+		out, err := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "shell", "cat", "/proc/"+pid+"/cmdline").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.Trim(string(out), "\x00"))
+	}
+	// Other Chromium based browsers use `<package>_devtools_remote`
+	return strings.TrimSuffix(socket, "_devtools_remote")
+}
+
+// ForwardDevToolsSocket forwards the DevTools socket to a host port, forwards are reused until the socket disappears or the device is reset
+func (d *AndroidDevice) ForwardDevToolsSocket(socket string) (string, error) {
+	d.devToolsMu.Lock()
+	defer d.devToolsMu.Unlock()
+	if port, ok := d.devToolsForwards[socket]; ok {
+		return port, nil
+	}
+
+	port, err := providerutil.AllocatePort(config.PortDevTools, d.GetUDID())
+	if err != nil {
+		return "", fmt.Errorf("could not allocate free host port for DevTools socket - %w", err)
+	}
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "forward", "tcp:"+port, "localabstract:"+socket)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		releaseHostPort(port)
+		return "", fmt.Errorf("failed to forward DevTools socket `%s` - %s: %w", socket, strings.TrimSpace(string(out)), err)
+	}
+
+	if d.devToolsForwards == nil {
+		d.devToolsForwards = make(map[string]string)
+	}
+	d.devToolsForwards[socket] = port
+	return port, nil
+}
+
+// pruneDevToolsForwards removes the forwards of sockets that no longer exist, WebView sockets change with every app process
+func (d *AndroidDevice) pruneDevToolsForwards(sockets []string) {
+	d.devToolsMu.Lock()
+	defer d.devToolsMu.Unlock()
+	for socket, port := range d.devToolsForwards {
+		if slices.Contains(sockets, socket) {
+			continue
+		}
+		exec.Command(config.Local.Tools.ADB, "-s", d.GetUDID(), "forward", "--remove", "tcp:"+port).Run()
+		releaseHostPort(port)
+		delete(d.devToolsForwards, socket)
+	}
+}
+
+func (d *AndroidDevice) releaseDevToolsForwards() {
+	d.pruneDevToolsForwards(nil)
+}
@@ -0,0 +1,102 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"GADS/common/models"
+)
+
+func localUnixMilli(t *testing.T, value string) int64 {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.UnixMilli()
+}
+
+func TestParseDumpsysPackages(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   map[string]models.DeviceApp
+	}{
+		{
+			name:   "empty output",
+			output: "",
+			want:   map[string]models.DeviceApp{},
+		},
+		{
+			name: "multiple packages",
+			output: `Packages:
+  Package [com.example.app] (a1b2c3d):
+    userId=10123
+    pkg=Package{7e8f9a0 com.example.app}
+    versionCode=42 minSdk=24 targetSdk=34
+    versionName=1.2.3
+    timeStamp=2024-03-02 11:00:00
+    firstInstallTime=2024-03-01 10:15:30
+    lastUpdateTime=2024-03-02 11:00:00
+  Package [com.android.chrome] (e4f5a6b):
+    userId=10045
+    versionName=120.0.6099.144
+    firstInstallTime=2008-12-31 16:00:00
+`,
+			want: map[string]models.DeviceApp{
+				"com.example.app":    {BundleIdentifier: "com.example.app", Version: "1.2.3", InstallTime: localUnixMilli(t, "2024-03-01 10:15:30")},
+				"com.android.chrome": {BundleIdentifier: "com.android.chrome", Version: "120.0.6099.144", InstallTime: localUnixMilli(t, "2008-12-31 16:00:00")},
+			},
+		},
+		{
+			name: "package without version and install time",
+			output: `  Package [com.example.stub] (1234567):
+    userId=10200
+`,
+			want: map[string]models.DeviceApp{
+				"com.example.stub": {BundleIdentifier: "com.example.stub"},
+			},
+		},
+		{
+			name: "invalid install time is ignored",
+			output: `  Package [com.example.app] (1234567):
+    versionName=2.0
+    firstInstallTime=unknown
+`,
+			want: map[string]models.DeviceApp{
+				"com.example.app": {BundleIdentifier: "com.example.app", Version: "2.0"},
+			},
+		},
+		{
+			name: "lines before the first package are ignored",
+			output: `Database versions:
+  Internal:
+    versionName=ignored
+    firstInstallTime=2024-01-01 00:00:00
+  Package [com.example.app] (1234567):
+    versionName=3.1
+`,
+			want: map[string]models.DeviceApp{
+				"com.example.app": {BundleIdentifier: "com.example.app", Version: "3.1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDumpsysPackages(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDumpsysPackages() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ package devices
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"GADS/provider/config"
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
+	"GADS/provider/store"
 )
 
 // setupAppiumForDevice handles the full Appium setup for any platform device:
@@ -33,7 +35,7 @@ func setupAppiumForDevice(d PlatformDevice) error {
 		return err
 	}
 
-	appiumPort, err := providerutil.GetFreePort()
+	appiumPort, err := providerutil.AllocatePort(config.PortAppium, udid)
 	if err != nil {
 		logger.ProviderLogger.LogError("device_setup", fmt.Sprintf("Could not allocate free Appium port for device `%v` - %v", udid, err))
 		d.Reset("Failed to allocate free Appium port for device.")
@@ -41,8 +43,15 @@ func setupAppiumForDevice(d PlatformDevice) error {
 	}
 	d.SetAppiumPort(appiumPort)
 
+	appiumHome, err := prepareDeviceAppiumHome(d)
+	if err != nil {
+		logger.ProviderLogger.LogError("device_setup", fmt.Sprintf("Could not prepare the pinned Appium versions for device `%v` - %v", udid, err))
+		d.Reset("Failed to prepare the pinned Appium versions for device.")
+		return err
+	}
+
 	caps := d.AppiumCapabilities()
-	go startAppium(d, caps)
+	go startAppium(d, caps, appiumHome)
 
 	timeout := time.After(30 * time.Second)
 	ticker := time.NewTicker(200 * time.Millisecond)
@@ -65,12 +74,42 @@ AppiumLoop:
 	return nil
 }
 
-// startAppium starts the Appium server process with the given capabilities.
+// prepareDeviceAppiumHome returns the Appium installation of the versions pinned for the device workspace,
+// or for the provider if the workspace does not pin versions
+func prepareDeviceAppiumHome(d PlatformDevice) (providerutil.AppiumHome, error) {
+	versions := config.ProviderConfig.AppiumVersions
+	if workspaceID := d.GetDBDevice().WorkspaceID; workspaceID != "" {
+		workspace, err := store.GlobalStore.GetWorkspaceByID(workspaceID)
+		if err != nil {
+			return providerutil.AppiumHome{}, fmt.Errorf("failed to get the device workspace - %w", err)
+		}
+		if !workspace.AppiumVersions.IsEmpty() {
+			versions = workspace.AppiumVersions
+		}
+	}
+
+	appiumHome, err := providerutil.PrepareAppiumHome(versions)
+	if err != nil {
+		return appiumHome, err
+	}
+	providerutil.SetDeviceAppiumHome(d.GetUDID(), appiumHome)
+	return appiumHome, nil
+}
+
+// startAppium starts the Appium server process with the given capabilities from the given Appium installation.
 // It runs as a goroutine and blocks until the process exits.
-func startAppium(d PlatformDevice, capabilities models.AppiumServerCapabilities) {
+func startAppium(d PlatformDevice, capabilities models.AppiumServerCapabilities, appiumHome providerutil.AppiumHome) {
 	udid := d.GetUDID()
 	appiumPort := d.GetAppiumPort()
-	capabilitiesJson, _ := json.Marshal(capabilities)
+
+	// The capabilities are passed as a file readable only by the provider user, they can hold secrets like the Roku dev password
+	capabilitiesFile, err := writeAppiumCapabilities(capabilities)
+	if err != nil {
+		logger.ProviderLogger.LogError("device_setup", fmt.Sprintf("Failed to write the Appium capabilities for device `%s` - %v", udid, err))
+		d.Reset("Failed to write the Appium capabilities.")
+		return
+	}
+	defer os.Remove(capabilitiesFile)
 
 	pluginConfig := models.AppiumPluginConfiguration{
 		ProviderUrl:       fmt.Sprintf("http://%s:%v", config.ProviderConfig.HostAddress, config.ProviderConfig.Port),
@@ -81,7 +120,7 @@ func startAppium(d PlatformDevice, capabilities models.AppiumServerCapabilities)
 
 	cmd := exec.CommandContext(
 		d.GetContext(),
-		"appium",
+		appiumHome.Appium,
 		"-p",
 		appiumPort,
 		"--log-timestamp",
@@ -90,9 +129,10 @@ func startAppium(d PlatformDevice, capabilities models.AppiumServerCapabilities)
 		"--session-override",
 		"--log-no-colors",
 		"--relaxed-security",
-		"--default-capabilities", string(capabilitiesJson))
+		"--default-capabilities", capabilitiesFile)
+	cmd.Env = appiumHome.Env()
 
-	logger.ProviderLogger.LogDebug("device_setup", fmt.Sprintf("Starting Appium on device `%s` with command `%s`", udid, cmd.Args))
+	logger.ProviderLogger.LogDebug("device_setup", fmt.Sprintf("Starting Appium on device `%s` with command `%s` and capabilities `%s`", udid, cmd.Args, redactedAppiumCapabilities(capabilities)))
 
 	if err := cmd.Start(); err != nil {
 		logger.ProviderLogger.LogError("device_setup", fmt.Sprintf("Error executing `%s` for device `%v` - %v", cmd.Args, udid, err))
@@ -108,3 +148,30 @@ func startAppium(d PlatformDevice, capabilities models.AppiumServerCapabilities)
 		d.Reset("Appium command errored out or device was disconnected.")
 	}
 }
+
+// writeAppiumCapabilities writes the default capabilities to a temporary file only the provider user can read
+func writeAppiumCapabilities(capabilities models.AppiumServerCapabilities) (string, error) {
+	capabilitiesJson, err := json.Marshal(capabilities)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "gads-appium-capabilities-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(capabilitiesJson); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// redactedAppiumCapabilities returns the capabilities JSON for logging with the secrets masked
+func redactedAppiumCapabilities(capabilities models.AppiumServerCapabilities) string {
+	if capabilities.RokuPass != "" {
+		capabilities.RokuPass = "[REDACTED]"
+	}
+	capabilitiesJson, _ := json.Marshal(capabilities)
+	return string(capabilitiesJson)
+}
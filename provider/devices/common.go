@@ -10,9 +10,7 @@
 package devices
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -29,6 +27,7 @@ import (
 	"GADS/provider/config"
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
+	"GADS/provider/store"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -42,10 +41,15 @@ var netClient = &http.Client{
 var DevManager = NewDeviceStore()
 
 func Listener() {
+	registerMockDevices()
 	setupDevices()
 
 	Setup()
 
+	if config.ProviderConfig.ProvideRoku {
+		go watchRokuDevices()
+	}
+
 	// Start updating devices in a goroutine
 	go updateDevices()
 	// Start updating the local devices data to the hub in a goroutine
@@ -95,7 +99,8 @@ func syncDevicesToDB() {
 		}
 
 		// If the provider does not set up Appium servers, force usage to `control`
-		if !config.ProviderConfig.SetupAppiumServers {
+		// Mock devices always serve their stub Appium server
+		if !config.ProviderConfig.SetupAppiumServers && dbDevice.OS != "mock" {
 			if dbDevice.Usage != "disabled" {
 				dbDevice.Usage = "control"
 			}
@@ -126,56 +131,6 @@ func syncDevicesToDB() {
 	}
 }
 
-// updateProviderHub sends the current device state to the hub every second.
-func updateProviderHub() {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
-	var failureCounter = 1
-
-	for {
-		if failureCounter >= 30 {
-			log.Fatalf("Unsuccessfully attempted to update device data in hub 30 times, killing provider")
-		}
-		time.Sleep(1 * time.Second)
-
-		var syncPayload models.ProviderData
-		syncPayload.ProviderData = *config.ProviderConfig
-
-		allDevs := DevManager.All()
-		for _, platDev := range allDevs {
-			syncPayload.DeviceData = append(syncPayload.DeviceData, platDev.ToSyncUpdate())
-		}
-
-		jsonData, err := json.Marshal(syncPayload)
-		if err != nil {
-			failureCounter++
-			logger.ProviderLogger.LogError("hub_sync", "Failed marshaling provider data to json - "+err.Error())
-			continue
-		}
-		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/provider-update", config.ProviderConfig.HubAddress), bytes.NewBuffer(jsonData))
-		if err != nil {
-			failureCounter++
-			logger.ProviderLogger.LogError("hub_sync", "Failed to create request to update provider data in hub - "+err.Error())
-			continue
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			failureCounter++
-			logger.ProviderLogger.LogError("hub_sync", fmt.Sprintf("Failed to execute request to update provider data in hub, hub is probably down, current retry counter is `%v` - %s", failureCounter, err))
-			continue
-		}
-
-		if resp.StatusCode != 200 {
-			failureCounter++
-			logger.ProviderLogger.LogError("hub_sync", fmt.Sprintf("Executed request to update provider data in hub but it was not successful, current retry counter is `%v` - %s", failureCounter, err))
-			continue
-		}
-		failureCounter = 1
-	}
-}
-
 // initializeDevice initializes a single device: sets up DB-level fields, creates a
 // PlatformDevice with Logger/SemVer on RuntimeState, and stores it in DevManager.
 func initializeDevice(dbDevice *models.DBDevice) error {
@@ -184,7 +139,8 @@ func initializeDevice(dbDevice *models.DBDevice) error {
 		return fmt.Errorf("failed to get semver for device `%s` - %s", dbDevice.UDID, err)
 	}
 
-	if config.ProviderConfig.SetupAppiumServers {
+	// The hub creates the Appium logs collection for providers that do not use MongoDB
+	if config.ProviderConfig.SetupAppiumServers && !config.Local.HubManaged() {
 		// Check if a capped Appium logs collection already exists for the current device
 		exists, err := db.GlobalMongoStore.CheckCollectionExistsWithDB("appium_logs_new", dbDevice.UDID)
 		if err != nil {
@@ -289,6 +245,20 @@ func newPlatformDevice(dbDevice *models.DBDevice, deviceLogger models.CustomLogg
 		d.SemVer = sv
 		d.InitialSetupDone = true
 		return d
+	case "roku":
+		d := &RokuDevice{}
+		d.DBDevice = *dbDevice
+		d.Logger = deviceLogger
+		d.SemVer = sv
+		d.InitialSetupDone = true
+		return d
+	case "mock":
+		d := &MockDevice{}
+		d.DBDevice = *dbDevice
+		d.Logger = deviceLogger
+		d.SemVer = sv
+		d.InitialSetupDone = true
+		return d
 	default:
 		return nil
 	}
@@ -307,6 +277,13 @@ func updateDevices() {
 		defer tizenTicker.Stop()
 	}
 
+	var discoveryChan <-chan time.Time
+	if config.ProviderConfig.DeviceDiscovery {
+		discoveryTicker := time.NewTicker(10 * time.Second)
+		discoveryChan = discoveryTicker.C
+		defer discoveryTicker.Stop()
+	}
+
 	for {
 		select {
 		case <-ticker.C:
@@ -322,6 +299,11 @@ func updateDevices() {
 				if dbDevice.Usage == "disabled" {
 					continue
 				}
+				// Rebooting devices are set up once they are back, see waitForReboot
+				// and devices are not set up again after they were reset for a shutdown
+				if isRebooting(udid) || shuttingDown.Load() {
+					continue
+				}
 				if slices.Contains(connectedDevices, udid) {
 					platDev.SetConnected(true)
 					state := platDev.GetProviderState()
@@ -346,6 +328,9 @@ func updateDevices() {
 			if tizenChan != nil {
 				handleTizenAutoConnection(GetConnectedDevicesCommon())
 			}
+
+		case <-discoveryChan:
+			discoverDevices()
 		}
 	}
 }
@@ -367,6 +352,8 @@ func GetConnectedDevicesCommon() []string {
 	var iosDevices []string
 	var tizenDevices []string
 	var webosDevices []string
+	var rokuDevices []string
+	var mockDevices []string
 
 	if config.ProviderConfig.ProvideAndroid {
 		androidDevices = getConnectedDevicesAndroid()
@@ -384,10 +371,20 @@ func GetConnectedDevicesCommon() []string {
 		webosDevices = getConnectedDevicesWebOS()
 	}
 
+	if config.ProviderConfig.ProvideRoku {
+		rokuDevices = getConnectedDevicesRoku()
+	}
+
+	if config.Local.MockDevices > 0 {
+		mockDevices = getConnectedDevicesMock()
+	}
+
 	connectedDevices = append(connectedDevices, iosDevices...)
 	connectedDevices = append(connectedDevices, androidDevices...)
 	connectedDevices = append(connectedDevices, tizenDevices...)
 	connectedDevices = append(connectedDevices, webosDevices...)
+	connectedDevices = append(connectedDevices, rokuDevices...)
+	connectedDevices = append(connectedDevices, mockDevices...)
 
 	return connectedDevices
 }
@@ -399,7 +396,7 @@ func setContext(platDev PlatformDevice) {
 }
 
 func updateDeviceWithGlobalSettings(rcDev RemoteControllable) error {
-	globalSettings, err := db.GlobalMongoStore.GetGlobalStreamSettings()
+	globalSettings, err := store.GlobalStore.GetGlobalStreamSettings()
 	if err != nil {
 		return fmt.Errorf("failed to get global stream settings: %v", err)
 	}
@@ -422,7 +419,7 @@ func applyDeviceStreamSettings(rcDev RemoteControllable) error {
 	defer common.MutexManager.StreamSettings.Unlock()
 	// Get the DeviceStreamSettings for the current device
 	udid := rcDev.GetUDID()
-	deviceStreamSettings, err := db.GlobalMongoStore.GetDeviceStreamSettings(udid)
+	deviceStreamSettings, err := store.GlobalStore.GetDeviceStreamSettings(udid)
 
 	if err != nil {
 		// If there's an error (including not found), update the device with global settings
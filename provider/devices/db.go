@@ -12,15 +12,15 @@ package devices
 import (
 	"log"
 
-	"GADS/common/db"
 	"GADS/common/models"
 	"GADS/provider/config"
+	"GADS/provider/store"
 )
 
 func getDBProviderDevices() map[string]*models.DBDevice {
 	var deviceDataMap = make(map[string]*models.DBDevice)
 
-	deviceData, err := db.GlobalMongoStore.GetProviderDevices(config.ProviderConfig.Nickname)
+	deviceData, err := store.GlobalStore.GetProviderDevices(config.ProviderConfig.Nickname)
 	if err != nil {
 		return nil
 	}
@@ -29,16 +29,16 @@ func getDBProviderDevices() map[string]*models.DBDevice {
 		// The GetStream WebRTC integration was removed - fall back to GADS H264 WebRTC
 		if dbDevice.StreamType == models.AndroidWebRTCGetStreamStreamTypeId {
 			dbDevice.StreamType = models.AndroidWebRTCGadsH264StreamTypeId
-			if err := db.GlobalMongoStore.AddOrUpdateDevice(&dbDevice); err != nil {
+			if err := store.GlobalStore.AddOrUpdateDevice(&dbDevice); err != nil {
 				log.Printf("Failed to update device %s from removed GetStream stream type to GADS H264 - %s", dbDevice.UDID, err)
 			}
 		}
 		// Ensure that devices are associated with the Default workspace if not specified
 		if dbDevice.WorkspaceID == "" {
-			if defaultWorkspace, err := db.GlobalMongoStore.GetDefaultWorkspace(); err == nil {
+			if defaultWorkspace, err := store.GlobalStore.GetDefaultWorkspace(); err == nil {
 				dbDevice.WorkspaceID = defaultWorkspace.ID
 				// Persist the workspace association in the database
-				err := db.GlobalMongoStore.AddOrUpdateDevice(&dbDevice)
+				err := store.GlobalStore.AddOrUpdateDevice(&dbDevice)
 				if err != nil {
 					log.Printf("Failed to associate device %s with default workspace - %s", dbDevice.UDID, err)
 				}
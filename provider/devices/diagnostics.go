@@ -0,0 +1,149 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+)
+
+// diagnosticsCapacity is how many of the latest events are kept per device
+const diagnosticsCapacity = 200
+
+// deviceDiagnostics is the ring buffer of the device state transitions and setup steps
+type deviceDiagnostics struct {
+	mu           sync.Mutex
+	events       []models.DeviceDiagnosticEvent
+	next         int
+	currentStep  *models.DeviceSetupStep
+	backoffUntil time.Time
+	backoffNext  time.Duration
+}
+
+func (d *deviceDiagnostics) add(event models.DeviceDiagnosticEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.events) < diagnosticsCapacity {
+		d.events = append(d.events, event)
+		return
+	}
+	d.events[d.next] = event
+	d.next = (d.next + 1) % diagnosticsCapacity
+}
+
+// recordStateTransition adds a provider state change, unchanged states are skipped
+func (r *RuntimeState) recordStateTransition(from, to, reason string) {
+	if from == to {
+		return
+	}
+	r.diagnostics.add(models.DeviceDiagnosticEvent{
+		Type:      models.DiagnosticEventState,
+		Timestamp: time.Now().UnixMilli(),
+		FromState: from,
+		ToState:   to,
+		Reason:    reason,
+	})
+}
+
+// recordStep runs a setup step and records when it started, how long it took and how it failed
+func (r *RuntimeState) recordStep(step string, fn func() error) error {
+	startedAt := time.Now()
+	r.diagnostics.mu.Lock()
+	r.diagnostics.currentStep = &models.DeviceSetupStep{Step: step, StartedAt: startedAt.UnixMilli()}
+	r.diagnostics.mu.Unlock()
+
+	err := fn()
+
+	r.diagnostics.mu.Lock()
+	r.diagnostics.currentStep = nil
+	r.diagnostics.mu.Unlock()
+
+	event := models.DeviceDiagnosticEvent{
+		Type:       models.DiagnosticEventStep,
+		Timestamp:  startedAt.UnixMilli(),
+		Step:       step,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.diagnostics.add(event)
+	return err
+}
+
+// setupStep runs a setup step with recordStep and resets the device if it fails
+func (r *RuntimeState) setupStep(step string, fn func() error) error {
+	if err := r.recordStep(step, fn); err != nil {
+		return r.resetWithError(step, err)
+	}
+	return nil
+}
+
+// inSetupBackoff reports whether the device setup is skipped because a recent setup failed, SetupMutex must be held
+func (r *RuntimeState) inSetupBackoff() bool {
+	return time.Now().Before(r.setupBackoffUntil)
+}
+
+// updateSetupBackoff delays the next setup attempt exponentially after a failed setup, SetupMutex must be held
+func (r *RuntimeState) updateSetupBackoff(setupErr error) {
+	switch {
+	case setupErr == nil:
+		r.setupBackoffNext = 0
+		r.setupBackoffUntil = time.Time{}
+	case errors.Is(setupErr, context.Canceled), errors.Is(setupErr, context.DeadlineExceeded):
+		// external cancellation — do not apply backoff
+	default:
+		if r.setupBackoffNext == 0 {
+			r.setupBackoffNext = setupBackoffBase
+		} else {
+			r.setupBackoffNext = min(r.setupBackoffNext*2, setupBackoffMax)
+		}
+		r.setupBackoffUntil = time.Now().Add(r.setupBackoffNext)
+	}
+
+	// The diagnostics keep their own copy, SetupMutex is held for the whole setup
+	r.diagnostics.mu.Lock()
+	r.diagnostics.backoffUntil = r.setupBackoffUntil
+	r.diagnostics.backoffNext = r.setupBackoffNext
+	r.diagnostics.mu.Unlock()
+}
+
+// GetDiagnostics returns the recent state transitions and setup steps of the device, oldest first
+func (r *RuntimeState) GetDiagnostics() models.DeviceDiagnostics {
+	diagnostics := models.DeviceDiagnostics{
+		UDID:          r.GetUDID(),
+		ProviderState: r.GetProviderState(),
+		Connected:     r.IsConnected(),
+	}
+
+	r.diagnostics.mu.Lock()
+	diagnostics.Events = make([]models.DeviceDiagnosticEvent, 0, len(r.diagnostics.events))
+	diagnostics.Events = append(diagnostics.Events, r.diagnostics.events[r.diagnostics.next:]...)
+	diagnostics.Events = append(diagnostics.Events, r.diagnostics.events[:r.diagnostics.next]...)
+	if r.diagnostics.currentStep != nil {
+		currentStep := *r.diagnostics.currentStep
+		diagnostics.CurrentStep = &currentStep
+	}
+	if time.Now().Before(r.diagnostics.backoffUntil) {
+		diagnostics.SetupBackoffUntil = r.diagnostics.backoffUntil.UnixMilli()
+		diagnostics.SetupBackoffMs = r.diagnostics.backoffNext.Milliseconds()
+	}
+	r.diagnostics.mu.Unlock()
+
+	if r.GetOS() == "tizen" {
+		diagnostics.TizenRetry = getTizenRetryDiagnostics(r.GetUDID())
+	}
+	return diagnostics
+}
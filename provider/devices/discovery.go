@@ -0,0 +1,196 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/constants"
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+
+	"github.com/danielpaulus/go-ios/ios"
+)
+
+const discoveryCommandTimeout = 10 * time.Second
+
+var (
+	// discoveredDevices holds the connected devices that are not registered in the DB, by UDID
+	discoveredDevices   = make(map[string]models.DiscoveredDevice)
+	discoveredDevicesMu sync.Mutex
+)
+
+// GetDiscoveredDevices returns the connected devices that are waiting for approval on the hub
+func GetDiscoveredDevices() []models.DiscoveredDevice {
+	discoveredDevicesMu.Lock()
+	defer discoveredDevicesMu.Unlock()
+
+	var discovered []models.DiscoveredDevice
+	for _, device := range discoveredDevices {
+		discovered = append(discovered, device)
+	}
+	return discovered
+}
+
+// discoverDevices detects the connected Android and iOS devices that are not registered yet.
+// Device info is detected once per connection, devices are dropped when they disconnect or get registered.
+func discoverDevices() {
+	connected := make(map[string]string)
+	if config.ProviderConfig.ProvideAndroid {
+		for _, udid := range getConnectedDevicesAndroid() {
+			connected[udid] = "android"
+		}
+	}
+	if config.ProviderConfig.ProvideIOS {
+		for _, udid := range getConnectedDevicesIOS() {
+			connected[udid] = "ios"
+		}
+	}
+	if config.ProviderConfig.ProvideRoku {
+		for _, udid := range getConnectedDevicesRoku() {
+			connected[udid] = "roku"
+		}
+	}
+
+	discoveredDevicesMu.Lock()
+	for udid := range discoveredDevices {
+		_, registered := DevManager.Get(udid)
+		if _, ok := connected[udid]; !ok || registered {
+			delete(discoveredDevices, udid)
+		}
+	}
+	discoveredDevicesMu.Unlock()
+
+	for udid, deviceOS := range connected {
+		if _, registered := DevManager.Get(udid); registered {
+			continue
+		}
+		discoveredDevicesMu.Lock()
+		_, known := discoveredDevices[udid]
+		discoveredDevicesMu.Unlock()
+		if known {
+			continue
+		}
+
+		var device models.DiscoveredDevice
+		var err error
+		switch deviceOS {
+		case "android":
+			device, err = detectAndroidDevice(udid)
+		case "ios":
+			device, err = detectIOSDevice(udid)
+		case "roku":
+			device, err = detectRokuDevice(udid)
+		}
+		if err != nil {
+			logger.ProviderLogger.LogWarn("device_discovery", fmt.Sprintf("Failed to detect unregistered %s device `%s`, will retry - %s", deviceOS, udid, err))
+			continue
+		}
+		device.Provider = config.ProviderConfig.Nickname
+		device.DiscoveredAt = time.Now().UnixMilli()
+
+		discoveredDevicesMu.Lock()
+		discoveredDevices[udid] = device
+		discoveredDevicesMu.Unlock()
+		logger.ProviderLogger.LogInfo("device_discovery", fmt.Sprintf("Discovered unregistered %s device `%s` - %s, %s", deviceOS, udid, device.Model, device.OSVersion))
+	}
+}
+
+func detectAndroidDevice(udid string) (models.DiscoveredDevice, error) {
+	device := models.DiscoveredDevice{
+		UDID:       udid,
+		OS:         "android",
+		DeviceType: "real",
+	}
+
+	getprop := func(property string) (string, error) {
+		return adbShellOutput(udid, "getprop", property)
+	}
+
+	var err error
+	if device.OSVersion, err = getprop("ro.build.version.release"); err != nil {
+		return device, err
+	}
+	brand, _ := getprop("ro.product.brand")
+	model, _ := getprop("ro.product.model")
+	device.Model = strings.TrimSpace(brand + " " + model)
+	if qemu, _ := getprop("ro.kernel.qemu"); qemu == "1" || strings.HasPrefix(udid, "emulator-") {
+		device.DeviceType = "emulator"
+	}
+
+	device.Name, _ = adbShellOutput(udid, "settings", "get", "global", "device_name")
+	if device.Name == "" || device.Name == "null" {
+		device.Name = device.Model
+	}
+
+	// `wm size` prints the physical size and an override size if one is set, the override is what apps see
+	sizeOutput, err := adbShellOutput(udid, "wm", "size")
+	if err != nil {
+		return device, err
+	}
+	for _, line := range strings.Split(sizeOutput, "\n") {
+		_, size, found := strings.Cut(line, ": ")
+		if !found {
+			continue
+		}
+		if width, height, found := strings.Cut(strings.TrimSpace(size), "x"); found {
+			device.ScreenWidth, device.ScreenHeight = width, height
+		}
+	}
+
+	return device, nil
+}
+
+func adbShellOutput(udid string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.Local.Tools.ADB, append([]string{"-s", udid, "shell"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error executing `%s` - %w", cmd.Args, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func detectIOSDevice(udid string) (models.DiscoveredDevice, error) {
+	device := models.DiscoveredDevice{
+		UDID:       udid,
+		OS:         "ios",
+		DeviceType: "real",
+	}
+
+	entry, err := ios.GetDevice(udid)
+	if err != nil {
+		return device, fmt.Errorf("could not get device with go-ios - %w", err)
+	}
+	values, err := ios.GetValuesPlist(entry)
+	if err != nil {
+		return device, fmt.Errorf("could not get info plist values with go-ios - %w", err)
+	}
+
+	device.Name, _ = values["DeviceName"].(string)
+	device.OSVersion, _ = values["ProductVersion"].(string)
+	productType, _ := values["ProductType"].(string)
+	device.Model = productType
+	if info, ok := constants.IOSDeviceInfoMap[productType]; ok {
+		device.Model = info.Model
+		device.ScreenWidth = info.Width
+		device.ScreenHeight = info.Height
+	}
+
+	return device, nil
+}
@@ -0,0 +1,99 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+	"GADS/provider/providerutil"
+)
+
+const drainPollInterval = 2 * time.Second
+
+var (
+	// draining stops the hub from starting new sessions and remote control on the provider devices
+	draining atomic.Bool
+	// shuttingDown stops updateDevices from setting up devices again after they were reset for the shutdown
+	shuttingDown atomic.Bool
+	// activeStreams counts the open device video streams of remote control sessions
+	activeStreams atomic.Int64
+)
+
+// IsDraining reports whether the provider is draining
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// SetDraining toggles the drain mode, the live devices are reported to the hub in the `draining` state meanwhile
+func SetDraining(enabled bool) {
+	if draining.Swap(enabled) != enabled {
+		logger.ProviderLogger.LogInfo("provider_drain", fmt.Sprintf("Provider drain mode set to `%v`", enabled))
+		notifyStateChanged()
+	}
+}
+
+// TrackStream counts a device stream as an active session until the returned function is called
+func TrackStream() func() {
+	activeStreams.Add(1)
+	return func() {
+		activeStreams.Add(-1)
+	}
+}
+
+// GetDrainStatus returns the drain state with the sessions that are still active on the provider
+func GetDrainStatus() models.ProviderDrainStatus {
+	status := models.ProviderDrainStatus{
+		Draining:      IsDraining(),
+		ActiveStreams: int(activeStreams.Load()),
+	}
+	for _, dev := range DevManager.All() {
+		if dev.GetHasAppiumSession() {
+			status.AppiumSessions++
+		}
+	}
+	return status
+}
+
+// Shutdown drains the provider, waits up to the timeout for the active sessions to finish
+// and then resets all devices to stop Appium, WebDriverAgent, the streams and free their ports
+func Shutdown(timeout time.Duration) {
+	SetDraining(true)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status := GetDrainStatus()
+		if status.AppiumSessions == 0 && status.ActiveStreams == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			logger.ProviderLogger.LogWarn("provider_drain", fmt.Sprintf("Drain timeout of %v reached with %d Appium sessions and %d streams still active, shutting down anyway", timeout, status.AppiumSessions, status.ActiveStreams))
+			break
+		}
+		logger.ProviderLogger.LogInfo("provider_drain", fmt.Sprintf("Waiting for %d Appium sessions and %d streams to finish before shutting down", status.AppiumSessions, status.ActiveStreams))
+		time.Sleep(drainPollInterval)
+	}
+
+	shuttingDown.Store(true)
+	// Collect the ports before the device resets release them to remove their adb forwards after
+	allocations := providerutil.GetPortDiagnostics().Allocations
+	for _, dev := range DevManager.All() {
+		dev.Reset("Provider is shutting down")
+		dev.SetConnected(false)
+	}
+	if config.ProviderConfig.ProvideAndroid {
+		providerutil.RemoveAdbForwardedPorts(allocations)
+	}
+	logger.ProviderLogger.LogInfo("provider_drain", "Provider devices were cleaned up for the shutdown")
+}
@@ -0,0 +1,293 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"GADS/common/models"
+	"GADS/common/mux"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+
+	"github.com/gobwas/ws"
+)
+
+const (
+	hubSyncInterval   = 1 * time.Second
+	hubSyncMaxBackoff = 30 * time.Second
+	// hubChannelKeepalive is the ping interval of the hub channel, it is considered dead after three missed intervals
+	hubChannelKeepalive = 10 * time.Second
+)
+
+// stateChanged wakes up the hub channel to push device state changes right away instead of on the next heartbeat
+var stateChanged = make(chan struct{}, 1)
+
+// notifyStateChanged signals the hub channel that the device state changed
+func notifyStateChanged() {
+	select {
+	case stateChanged <- struct{}{}:
+	default:
+	}
+}
+
+// errChannelNotSupported is returned when the provider channel of the hub cannot be used,
+// the hub does not have the endpoint or the provider was not enrolled with a provider token
+var errChannelNotSupported = errors.New("the provider channel cannot be used")
+
+// updateProviderHub keeps the hub up to date with the device state.
+// It opens a persistent channel to the hub that pushes the device state changes and carries the hub requests
+// to the provider back over the same connection, so the hub does not need to reach the provider directly.
+// Hubs without the channel are sent the full state over HTTP every second.
+// While no hub is reachable the devices, Appium servers and tunnels are kept running and the connection is retried
+// with backoff, cycling through all hub addresses. The first update after reconnecting is a full sync.
+func updateProviderHub() {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	hubs := config.ProviderConfig.HubAddresses
+	if len(hubs) == 0 {
+		hubs = []string{config.ProviderConfig.HubAddress}
+	}
+
+	hubIndex := 0
+	failures := 0
+	backoff := hubSyncInterval
+	fullSync := true
+	var disconnectedAt time.Time
+	// legacyHubs are the hubs without the provider channel, they are retried over the channel after a failed update
+	legacyHubs := make(map[string]bool)
+
+	onConnected := func(hub string) {
+		if !disconnectedAt.IsZero() {
+			logger.ProviderLogger.LogInfo("hub_sync", fmt.Sprintf("Reconnected to hub `%s` after %v, provider state was resynced", hub, time.Since(disconnectedAt).Round(time.Second)))
+			disconnectedAt = time.Time{}
+		}
+		failures = 0
+		backoff = hubSyncInterval
+	}
+
+	for {
+		hub := hubs[hubIndex]
+
+		var err error
+		if legacyHubs[hub] {
+			var resyncRequired bool
+			resyncRequired, err = postProviderUpdate(client, hub, fullSync)
+			if err == nil {
+				onConnected(hub)
+				fullSync = resyncRequired
+				time.Sleep(hubSyncInterval)
+				continue
+			}
+			delete(legacyHubs, hub)
+		} else {
+			err = runHubChannel(hub, func() { onConnected(hub) })
+			if errors.Is(err, errChannelNotSupported) {
+				logger.ProviderLogger.LogInfo("hub_sync", fmt.Sprintf("Hub `%s` - %s, falling back to HTTP updates", hub, err))
+				legacyHubs[hub] = true
+				continue
+			}
+		}
+
+		if disconnectedAt.IsZero() {
+			disconnectedAt = time.Now()
+			logger.ProviderLogger.LogWarn("hub_sync", fmt.Sprintf("Lost connection to hub `%s`, keeping devices running and retrying - %s", hub, err))
+		} else {
+			logger.ProviderLogger.LogDebug("hub_sync", fmt.Sprintf("Failed to connect to hub `%s` - %s", hub, err))
+		}
+		fullSync = true
+		failures++
+
+		// Fail over to the next hub right away and back off once all hubs failed in a row
+		hubIndex = (hubIndex + 1) % len(hubs)
+		if failures%len(hubs) == 0 {
+			time.Sleep(backoff)
+			backoff = min(backoff*2, hubSyncMaxBackoff)
+		}
+	}
+}
+
+// hubAuthHeader authenticates the provider to the hub with its provider token, if it was enrolled with one
+func hubAuthHeader() http.Header {
+	header := http.Header{}
+	if config.Local.ProviderToken != "" {
+		header.Set("Authorization", "Bearer "+config.Local.ProviderToken)
+	}
+	return header
+}
+
+// runHubChannel connects to the provider channel of the hub and serves it until the connection is lost.
+// The first message is a full sync, then only the changed devices are sent as they change,
+// along with a heartbeat every second that keeps the devices available in the hub.
+func runHubChannel(hub string, onConnected func()) error {
+	// The hub only opens the channel to enrolled providers
+	if config.Local.ProviderToken == "" {
+		return fmt.Errorf("%w, it requires a provider token", errChannelNotSupported)
+	}
+	channelURL := strings.Replace(hub, "http", "ws", 1) + "/provider-channel"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	conn, br, _, err := ws.Dialer{Header: ws.HandshakeHeaderHTTP(hubAuthHeader())}.Dial(ctx, channelURL)
+	cancel()
+	if err != nil {
+		var statusErr ws.StatusError
+		if errors.As(err, &statusErr) && int(statusErr) == http.StatusNotFound {
+			return fmt.Errorf("%w, the hub does not have it", errChannelNotSupported)
+		}
+		return err
+	}
+	session := mux.NewSession(mux.NewWebSocketConn(conn, br, true), true, hubChannelKeepalive)
+	defer session.Close()
+
+	// The hub asks for a full sync if it does not know all the devices yet
+	var resyncRequired atomic.Bool
+	go func() {
+		for {
+			message, err := session.ReadMessage()
+			if err != nil {
+				return
+			}
+			var result models.ProviderUpdateResult
+			if json.Unmarshal(message, &result) == nil && result.ResyncRequired {
+				resyncRequired.Store(true)
+				notifyStateChanged()
+			}
+		}
+	}()
+	go serveHubStreams(session)
+
+	lastSent := make(map[string]models.ProviderDeviceSync)
+	if err := sendChannelUpdate(session, hub, lastSent, true); err != nil {
+		return err
+	}
+	onConnected()
+
+	heartbeat := time.NewTicker(hubSyncInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-session.Done():
+			return session.Err()
+		case <-stateChanged:
+		case <-heartbeat.C:
+		}
+		if err := sendChannelUpdate(session, hub, lastSent, resyncRequired.Swap(false)); err != nil {
+			return err
+		}
+	}
+}
+
+// sendChannelUpdate sends the devices whose state changed since the last update, or all of them on a full sync
+func sendChannelUpdate(session *mux.Session, hub string, lastSent map[string]models.ProviderDeviceSync, fullSync bool) error {
+	var syncPayload models.ProviderData
+	syncPayload.ProviderData = *config.ProviderConfig
+	syncPayload.ProviderData.HubAddress = hub
+	syncPayload.FullSync = fullSync
+	syncPayload.DeviceData = []models.ProviderDeviceSync{}
+
+	for _, platDev := range DevManager.All() {
+		update := platDev.ToSyncUpdate()
+		if !fullSync && lastSent[update.UDID] == update {
+			continue
+		}
+		lastSent[update.UDID] = update
+		if fullSync {
+			update.AppiumSessionID = platDev.GetAppiumSessionID()
+		}
+		syncPayload.DeviceData = append(syncPayload.DeviceData, update)
+	}
+	if config.ProviderConfig.DeviceDiscovery {
+		syncPayload.DiscoveredDevices = GetDiscoveredDevices()
+	}
+
+	jsonData, err := json.Marshal(syncPayload)
+	if err != nil {
+		return fmt.Errorf("failed marshaling provider data to json - %w", err)
+	}
+	return session.SendMessage(jsonData)
+}
+
+// serveHubStreams relays the streams the hub opens over the channel to the provider HTTP server
+func serveHubStreams(session *mux.Session) {
+	address := fmt.Sprintf("%s:%v", config.ProviderConfig.HostAddress, config.ProviderConfig.Port)
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			upstream, err := net.DialTimeout("tcp", address, 5*time.Second)
+			if err != nil {
+				logger.ProviderLogger.LogError("hub_sync", fmt.Sprintf("Failed to connect hub channel stream to the provider server at `%s` - %s", address, err))
+				stream.Close()
+				return
+			}
+			relayConns(stream, upstream)
+		}()
+	}
+}
+
+// postProviderUpdate sends the provider and device state to the hub and returns if the hub asked for a full sync
+func postProviderUpdate(client *http.Client, hub string, fullSync bool) (bool, error) {
+	var syncPayload models.ProviderData
+	syncPayload.ProviderData = *config.ProviderConfig
+	syncPayload.ProviderData.HubAddress = hub
+	syncPayload.FullSync = fullSync
+
+	for _, platDev := range DevManager.All() {
+		update := platDev.ToSyncUpdate()
+		if fullSync {
+			update.AppiumSessionID = platDev.GetAppiumSessionID()
+		}
+		syncPayload.DeviceData = append(syncPayload.DeviceData, update)
+	}
+	if config.ProviderConfig.DeviceDiscovery {
+		syncPayload.DiscoveredDevices = GetDiscoveredDevices()
+	}
+
+	jsonData, err := json.Marshal(syncPayload)
+	if err != nil {
+		return false, fmt.Errorf("failed marshaling provider data to json - %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/provider-update", hub), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return false, err
+	}
+	req.Header = hubAuthHeader()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("hub responded with status %d", resp.StatusCode)
+	}
+
+	var result models.APIResponse[models.ProviderUpdateResult]
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// Older hubs do not return a result
+		return false, nil
+	}
+	return result.Result.ResyncRequired, nil
+}
@@ -12,6 +12,7 @@ package devices
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,25 +21,28 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"GADS/common"
 	"GADS/common/constants"
-	"GADS/common/db"
 	"GADS/common/models"
 	"GADS/provider/config"
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
+	"GADS/provider/store"
 
 	"github.com/Masterminds/semver"
 	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/diagnostics"
 	"github.com/danielpaulus/go-ios/ios/forward"
 	"github.com/danielpaulus/go-ios/ios/imagemounter"
 	"github.com/danielpaulus/go-ios/ios/installationproxy"
 	"github.com/danielpaulus/go-ios/ios/instruments"
+	"github.com/danielpaulus/go-ios/ios/mcinstall"
+	"github.com/danielpaulus/go-ios/ios/simlocation"
 	"github.com/danielpaulus/go-ios/ios/testmanagerd"
 	"github.com/danielpaulus/go-ios/ios/tunnel"
 	"github.com/danielpaulus/go-ios/ios/zipconduit"
@@ -56,70 +60,75 @@ type IOSDevice struct {
 	GoIOSDeviceEntry ios.DeviceEntry // go-ios library device entry for USB communication
 	GoIOSTunnel      tunnel.Tunnel   // userspace tunnel for iOS 17.4+
 	WdaReadyChan     chan bool       // signals WebDriverAgent is up after start
+
+	locationMu      sync.Mutex
+	locationService *instruments.LocationSimulationService // kept open while simulating on iOS 17+, closing it stops the simulation
+
+	proxyMu      sync.Mutex
+	proxyWifiMAC string // Wi-Fi MAC address of the device, only connections from it are accepted by the shaping proxy
+
+	wdaSessionMu sync.Mutex // serializes looking up and creating the WebDriverAgent session
+
+	installTimesMu sync.Mutex
+	installTimes   map[string]int64 // app bundle path -> Unix ms of the install, the path changes when an app is reinstalled
 }
 
 // Port accessors for router access via type assertion.
 func (d *IOSDevice) GetStreamPort() string    { return d.StreamPort }
 func (d *IOSDevice) GetWDAPort() string       { return d.WDAPort }
 func (d *IOSDevice) GetWDAStreamPort() string { return d.WDAStreamPort }
-func (d *IOSDevice) GetWDASessionID() string  { return d.WDASessionID }
+func (d *IOSDevice) GetWDASessionID() string {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	return d.WDASessionID
+}
 
 // Setup runs the full iOS device provisioning sequence.
 func (d *IOSDevice) Setup() (retErr error) {
 	d.SetupMutex.Lock()
 	defer d.SetupMutex.Unlock()
 
-	if time.Now().Before(d.setupBackoffUntil) {
+	if d.inSetupBackoff() {
 		return nil
 	}
-
-	defer func() {
-		switch {
-		case retErr == nil:
-			d.setupBackoffNext = 0
-			d.setupBackoffUntil = time.Time{}
-		case errors.Is(retErr, context.Canceled), errors.Is(retErr, context.DeadlineExceeded):
-			// external cancellation — do not apply backoff
-		default:
-			if d.setupBackoffNext == 0 {
-				d.setupBackoffNext = setupBackoffBase
-			} else {
-				d.setupBackoffNext = min(d.setupBackoffNext*2, setupBackoffMax)
-			}
-			d.setupBackoffUntil = time.Now().Add(d.setupBackoffNext)
-		}
-	}()
+	defer func() { d.updateSetupBackoff(retErr) }()
 
 	d.SetProviderState("preparing")
 	logger.ProviderLogger.LogInfo("ios_device_setup", fmt.Sprintf("Running setup for device `%v`", d.GetUDID()))
 
-	if err := d.initGoIOSDevice(); err != nil {
-		return d.resetWithError("get go-ios DeviceEntry", err)
+	if err := d.setupStep("get go-ios DeviceEntry", d.initGoIOSDevice); err != nil {
+		return err
 	}
-	if err := d.pair(); err != nil {
-		return d.resetWithError("pair device", err)
+	if err := d.setupStep("pair device", d.pair); err != nil {
+		return err
+	}
+	// Remove a shaping proxy profile left over from a previous provider run, otherwise the device would have no network
+	if hasIOSProxyProfile(d.GetUDID()) {
+		if err := d.ClearHTTPProxy(""); err == nil {
+			logger.ProviderLogger.LogDebug("ios_device_setup", fmt.Sprintf("Removed stale proxy profile from device `%v`", d.GetUDID()))
+		}
 	}
-	if err := d.checkDeveloperMode(); err != nil {
-		return d.resetWithError("check developer mode status", err)
+	if err := d.setupStep("check developer mode status", d.checkDeveloperMode); err != nil {
+		return err
 	}
-	if err := d.mountDeveloperImage(); err != nil {
-		return d.resetWithError("mount Developer Disk Image (DDI)", err)
+	if err := d.setupStep("mount Developer Disk Image (DDI)", d.mountDeveloperImage); err != nil {
+		return err
 	}
-	if err := d.getDeviceInfoAndScreenSize(); err != nil {
+	if err := d.recordStep("get device info and screen size", d.getDeviceInfoAndScreenSize); err != nil {
 		return err // already reset inside
 	}
-	if err := d.setupTunnelIfNeeded(); err != nil {
+	if err := d.recordStep("set up tunnel", d.setupTunnelIfNeeded); err != nil {
 		return err // already reset inside
 	}
 
-	if err := d.allocateAndForwardPorts(); err != nil {
-		return d.resetWithError("allocate or forward ports", err)
+	if err := d.setupStep("allocate or forward ports", d.allocateAndForwardPorts); err != nil {
+		return err
 	}
 
-	if err := d.startWebDriverAgent(); err != nil {
+	if err := d.recordStep("start WebDriverAgent", d.startWebDriverAgent); err != nil {
 		return err // already reset inside
 	}
-	if err := d.waitForWebDriverAgent(); err != nil {
+	if err := d.recordStep("wait for WebDriverAgent", d.waitForWebDriverAgent); err != nil {
 		return err // already reset inside
 	}
 
@@ -167,10 +176,10 @@ func (d *IOSDevice) Setup() (retErr error) {
 		logger.ProviderLogger.LogInfo("ios_device_setup", fmt.Sprintf("Broadcast extension setup complete on device `%s", d.GetUDID()))
 	}
 
-	if err := d.applyStreamConfig(); err != nil {
-		return d.resetWithError("apply device stream settings", err)
+	if err := d.setupStep("apply device stream settings", d.applyStreamConfig); err != nil {
+		return err
 	}
-	if err := d.setupAppiumIfNeeded(); err != nil {
+	if err := d.recordStep("set up Appium", d.setupAppiumIfNeeded); err != nil {
 		return err
 	}
 
@@ -260,7 +269,7 @@ func (d *IOSDevice) getDeviceInfoAndScreenSize() error {
 }
 
 func (d *IOSDevice) setupTunnelIfNeeded() error {
-	tunnelPort, err := providerutil.GetFreePort()
+	tunnelPort, err := providerutil.AllocatePort(config.PortTunnel, d.GetUDID())
 	if err != nil {
 		logger.ProviderLogger.LogError("ios_device_setup", fmt.Sprintf("Could not allocate free tunnel port for device `%v` - %v", d.GetUDID(), err))
 		d.Reset("Failed to allocate free tunnel port for device.")
@@ -307,19 +316,19 @@ func (d *IOSDevice) disableBroadcastExtensionMemoryLimit() {
 }
 
 func (d *IOSDevice) allocateAndForwardPorts() error {
-	wdaPort, err := providerutil.GetFreePort()
+	wdaPort, err := providerutil.AllocatePort(config.PortWDA, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free WebDriverAgent port - %w", err)
 	}
 	d.WDAPort = wdaPort
 
-	streamPort, err := providerutil.GetFreePort()
+	streamPort, err := providerutil.AllocatePort(config.PortStream, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free iOS stream port - %w", err)
 	}
 	d.StreamPort = streamPort
 
-	wdaStreamPort, err := providerutil.GetFreePort()
+	wdaStreamPort, err := providerutil.AllocatePort(config.PortStream, d.GetUDID())
 	if err != nil {
 		return fmt.Errorf("could not allocate free WebDriverAgent stream port - %w", err)
 	}
@@ -387,11 +396,7 @@ func (d *IOSDevice) Reset(reason string) {
 		if d.GoIOSTunnel.Address != "" {
 			d.GoIOSTunnel.Close()
 		}
-		common.MutexManager.LocalDevicePorts.Lock()
-		delete(providerutil.UsedPorts, d.WDAPort)
-		delete(providerutil.UsedPorts, d.StreamPort)
-		delete(providerutil.UsedPorts, d.WDAStreamPort)
-		common.MutexManager.LocalDevicePorts.Unlock()
+		providerutil.ReleasePort(d.WDAPort, d.StreamPort, d.WDAStreamPort)
 	}
 }
 
@@ -679,10 +684,12 @@ func (d *IOSDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 		bundleIdToExecutable[app.CFBundleIdentifier()] = app.CFBundleExecutable()
 	}
 
+	userApps = slices.DeleteFunc(userApps, func(userApp installationproxy.AppInfo) bool {
+		return strings.Contains(userApp.CFBundleExecutable(), "WebDriverAgentRunner") || strings.Contains(userApp.CFBundleExecutable(), "h264-broadcast-extension")
+	})
+	installTimes := d.appInstallTimes(userApps)
 	for _, userApp := range userApps {
-		if !strings.Contains(userApp.CFBundleExecutable(), "WebDriverAgentRunner") && !strings.Contains(userApp.CFBundleExecutable(), "h264-broadcast-extension") {
-			installedApps = append(installedApps, models.DeviceApp{AppName: userApp.CFBundleExecutable(), BundleIdentifier: userApp.CFBundleIdentifier(), CanUninstall: true})
-		}
+		installedApps = append(installedApps, models.DeviceApp{AppName: userApp.CFBundleExecutable(), BundleIdentifier: userApp.CFBundleIdentifier(), CanUninstall: true, Version: userApp.CFBundleShortVersionString(), InstallTime: installTimes[userApp.CFBundleIdentifier()]})
 	}
 
 	for _, bundleId := range constants.IOSSystemAppsBundleIds {
@@ -841,7 +848,7 @@ func (d *IOSDevice) updateScreenSize(deviceMachineCode string) error {
 		return fmt.Errorf("could not find `%s` device machine code in the IOSDeviceInfoMap map", deviceMachineCode)
 	}
 
-	if err := db.GlobalMongoStore.AddOrUpdateDevice(&d.DBDevice); err != nil {
+	if err := store.GlobalStore.AddOrUpdateDevice(&d.DBDevice); err != nil {
 		return fmt.Errorf("failed to update DB with new device dimensions - %s", err)
 	}
 	return nil
@@ -1022,6 +1029,223 @@ func (d *IOSDevice) KillApp(bundleId string) error {
 	return nil
 }
 
+// Reboot restarts the device through the diagnostics relay service.
+func (d *IOSDevice) Reboot() error {
+	if err := diagnostics.Reboot(d.GoIOSDeviceEntry); err != nil {
+		return fmt.Errorf("Reboot: diagnostics restart failed - %w", err)
+	}
+	return nil
+}
+
+// ClearAppData is not possible on iOS without reinstalling the app.
+func (d *IOSDevice) ClearAppData(bundleID string) error {
+	return fmt.Errorf("ClearAppData: %w - iOS does not allow clearing app data, reinstall the app instead", ErrUnsupportedOperation)
+}
+
+// GrantAppPermissions is not possible on real iOS devices, privacy permissions can only be reset.
+func (d *IOSDevice) GrantAppPermissions(bundleID string, permissions []string) error {
+	return fmt.Errorf("GrantAppPermissions: %w - iOS privacy permissions can only be reset", ErrUnsupportedOperation)
+}
+
+// RevokeAppPermissions is not possible on real iOS devices, privacy permissions can only be reset.
+func (d *IOSDevice) RevokeAppPermissions(bundleID string, permissions []string) error {
+	return fmt.Errorf("RevokeAppPermissions: %w - iOS privacy permissions can only be reset", ErrUnsupportedOperation)
+}
+
+// iosProtectedResources maps privacy resource names to the XCUIProtectedResource values accepted by WebDriverAgent
+var iosProtectedResources = map[string]int{
+	"contacts":         1,
+	"calendar":         2,
+	"reminders":        3,
+	"photos":           4,
+	"microphone":       5,
+	"camera":           6,
+	"media_library":    7,
+	"homekit":          8,
+	"bluetooth":        -0x40000000,
+	"keyboard_network": -0x40000001,
+	"location":         -0x40000002,
+	"health":           -0x40000003,
+}
+
+// ResetAppPermissions resets the authorization status of privacy resources through WebDriverAgent so the user is prompted again.
+// WebDriverAgent resets the status for the active app which also terminates it, the app is never launched for this
+// so it has to be in the foreground, e.g. opened by the user or by the Appium session.
+// If no permissions are provided all known resources are reset.
+func (d *IOSDevice) ResetAppPermissions(bundleID string, permissions []string) error {
+	if len(permissions) == 0 {
+		for resource := range iosProtectedResources {
+			permissions = append(permissions, resource)
+		}
+	}
+
+	activeBundleID, err := d.getWDAActiveAppBundleID()
+	if err != nil {
+		return fmt.Errorf("ResetAppPermissions: %w", err)
+	}
+	if activeBundleID != bundleID {
+		return fmt.Errorf("ResetAppPermissions: `%s` has to be the active app to reset its permissions, the active app is `%s`", bundleID, activeBundleID)
+	}
+
+	sessionID, err := d.getWDASessionID()
+	if err != nil {
+		return fmt.Errorf("ResetAppPermissions: %w", err)
+	}
+
+	var errs []error
+	for _, permission := range permissions {
+		resource, ok := iosProtectedResources[strings.ToLower(permission)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown privacy resource `%s`", permission))
+			continue
+		}
+		body, _ := json.Marshal(map[string]int{"resource": resource})
+		url := fmt.Sprintf("http://localhost:%v/session/%s/wda/resetAppAuth", d.GetWDAPort(), sessionID)
+		resp, err := netClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed resetting `%s` - %w", permission, err))
+			continue
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			errs = append(errs, fmt.Errorf("failed resetting `%s` - WebDriverAgent returned %d: %s", permission, resp.StatusCode, string(respBody)))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ResetAppPermissions: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// getWDAActiveAppBundleID returns the bundle identifier of the app in the foreground using the sessionless WebDriverAgent endpoint
+func (d *IOSDevice) getWDAActiveAppBundleID() (string, error) {
+	resp, err := netClient.Get(fmt.Sprintf("http://localhost:%v/wda/activeAppInfo", d.GetWDAPort()))
+	if err != nil {
+		return "", fmt.Errorf("failed getting the active app from WebDriverAgent - %w", err)
+	}
+	defer resp.Body.Close()
+
+	var activeApp struct {
+		Value struct {
+			BundleID string `json:"bundleId"`
+		} `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&activeApp); err != nil {
+		return "", fmt.Errorf("failed decoding the WebDriverAgent active app response - %w", err)
+	}
+	return activeApp.Value.BundleID, nil
+}
+
+// getWDASessionID returns the active WebDriverAgent session, creating one if there is none.
+// An existing session is reused so we do not replace the session of a running Appium test,
+// a new session is created without an app so nothing is launched.
+func (d *IOSDevice) getWDASessionID() (string, error) {
+	d.wdaSessionMu.Lock()
+	defer d.wdaSessionMu.Unlock()
+
+	statusResp, err := netClient.Get(fmt.Sprintf("http://localhost:%v/status", d.GetWDAPort()))
+	if err != nil {
+		return "", fmt.Errorf("failed getting WebDriverAgent status - %w", err)
+	}
+	defer statusResp.Body.Close()
+
+	var status struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.NewDecoder(statusResp.Body).Decode(&status); err == nil && status.SessionID != "" {
+		d.setWDASessionID(status.SessionID)
+		return status.SessionID, nil
+	}
+
+	body, _ := json.Marshal(map[string]any{"capabilities": map[string]any{"alwaysMatch": map[string]any{"shouldWaitForQuiescence": false}}})
+	sessionResp, err := netClient.Post(fmt.Sprintf("http://localhost:%v/session", d.GetWDAPort()), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed creating WebDriverAgent session - %w", err)
+	}
+	defer sessionResp.Body.Close()
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+		Value     struct {
+			SessionID string `json:"sessionId"`
+		} `json:"value"`
+	}
+	if err := json.NewDecoder(sessionResp.Body).Decode(&session); err != nil {
+		return "", fmt.Errorf("failed decoding WebDriverAgent session response - %w", err)
+	}
+	sessionID := session.Value.SessionID
+	if sessionID == "" {
+		sessionID = session.SessionID
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("WebDriverAgent did not return a session ID")
+	}
+	d.setWDASessionID(sessionID)
+	return sessionID, nil
+}
+
+func (d *IOSDevice) setWDASessionID(sessionID string) {
+	d.Mutex.Lock()
+	d.WDASessionID = sessionID
+	d.Mutex.Unlock()
+}
+
+// SetLocation simulates a GPS location on the device.
+// iOS 17+ uses the instruments location simulation service, older versions the simulatelocation lockdown service.
+func (d *IOSDevice) SetLocation(latitude, longitude float64) error {
+	d.locationMu.Lock()
+	defer d.locationMu.Unlock()
+
+	if d.SemVer.Major() >= 17 {
+		if d.locationService == nil {
+			service, err := instruments.NewLocationSimulationService(d.GoIOSDeviceEntry)
+			if err != nil {
+				return fmt.Errorf("SetLocation: failed starting location simulation service - %w", err)
+			}
+			d.locationService = service
+		}
+		if err := d.locationService.StartSimulateLocation(latitude, longitude); err != nil {
+			d.locationService.Close()
+			d.locationService = nil
+			return fmt.Errorf("SetLocation: failed simulating location - %w", err)
+		}
+		return nil
+	}
+
+	err := simlocation.SetLocation(d.GoIOSDeviceEntry,
+		strconv.FormatFloat(latitude, 'f', 7, 64),
+		strconv.FormatFloat(longitude, 'f', 7, 64))
+	if err != nil {
+		return fmt.Errorf("SetLocation: failed simulating location - %w", err)
+	}
+	return nil
+}
+
+// ResetLocation stops the location simulation and restores the real device location.
+func (d *IOSDevice) ResetLocation() error {
+	d.locationMu.Lock()
+	defer d.locationMu.Unlock()
+
+	if d.SemVer.Major() >= 17 {
+		if d.locationService == nil {
+			return nil
+		}
+		// StopSimulateLocation also closes the service connection
+		err := d.locationService.StopSimulateLocation()
+		d.locationService = nil
+		if err != nil {
+			return fmt.Errorf("ResetLocation: failed stopping location simulation - %w", err)
+		}
+		return nil
+	}
+
+	if err := simlocation.ResetLocation(d.GoIOSDeviceEntry); err != nil {
+		return fmt.Errorf("ResetLocation: failed resetting location - %w", err)
+	}
+	return nil
+}
+
 // GetScreenSize returns the device screen dimensions.
 func (d *IOSDevice) GetScreenSize() (width, height string, err error) {
 	return d.DBDevice.ScreenWidth, d.DBDevice.ScreenHeight, nil
@@ -1105,3 +1329,181 @@ func getConnectedDevicesIOS() []string {
 	}
 	return connectedDevices
 }
+
+// PressButton presses a hardware button through WebDriverAgent, supported names are `home`, `volumeUp` and `volumeDown`.
+func (d *IOSDevice) PressButton(name string) error {
+	sessionID, err := d.getWDASessionID()
+	if err != nil {
+		return fmt.Errorf("PressButton: %w", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": name})
+	url := fmt.Sprintf("http://localhost:%v/session/%s/wda/pressButton", d.GetWDAPort(), sessionID)
+	resp, err := netClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("PressButton: failed pressing `%s` - %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PressButton: failed pressing `%s` - WebDriverAgent returned %d: %s", name, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// SetHTTPProxy installs a global HTTP proxy profile pointing to the shaping proxy on the provider host.
+// Global proxy profiles can be installed silently only on supervised devices.
+func (d *IOSDevice) SetHTTPProxy(port string) error {
+	if config.ProviderConfig.HostAddress == "" {
+		return fmt.Errorf("SetHTTPProxy: the provider host address is required so the device can reach the proxy")
+	}
+	p12, err := os.ReadFile(fmt.Sprintf("%s/supervision.p12", config.ProviderConfig.ProviderFolder))
+	if err != nil {
+		return fmt.Errorf("SetHTTPProxy: a proxy can only be set on supervised devices, could not read supervision.p12 - %w", err)
+	}
+	// The proxy listens on the network, it only accepts the device so it needs its Wi-Fi MAC address
+	wifiMAC, err := ios.GetWifiMac(d.GoIOSDeviceEntry)
+	if err != nil {
+		return fmt.Errorf("SetHTTPProxy: failed getting the device Wi-Fi MAC address - %w", err)
+	}
+	if wifiMAC, err = providerutil.NormalizeMACAddress(wifiMAC); err != nil {
+		return fmt.Errorf("SetHTTPProxy: failed parsing the device Wi-Fi MAC address - %w", err)
+	}
+	d.proxyMu.Lock()
+	d.proxyWifiMAC = wifiMAC
+	d.proxyMu.Unlock()
+
+	if err := mcinstall.SetHttpProxy(d.GoIOSDeviceEntry, config.ProviderConfig.HostAddress, port, "", "", p12, config.ProviderConfig.SupervisionPassword); err != nil {
+		return fmt.Errorf("SetHTTPProxy: failed installing proxy profile - %w", err)
+	}
+	setIOSProxyProfile(d.GetUDID(), true)
+	return nil
+}
+
+// ClearHTTPProxy removes the global HTTP proxy profile from the device.
+func (d *IOSDevice) ClearHTTPProxy(port string) error {
+	if err := mcinstall.RemoveProxy(d.GoIOSDeviceEntry); err != nil {
+		return fmt.Errorf("ClearHTTPProxy: failed removing proxy profile - %w", err)
+	}
+	setIOSProxyProfile(d.GetUDID(), false)
+	return nil
+}
+
+// ShapingProxyHost binds the shaping proxy to all interfaces, the device reaches it over Wi-Fi
+func (d *IOSDevice) ShapingProxyHost() string {
+	return ""
+}
+
+// AllowShapingClient accepts only connections from the device, identified by its IP address when it is known
+// or by the Wi-Fi MAC address the provider ARP table has for the client
+func (d *IOSDevice) AllowShapingClient(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	clientIP := tcpAddr.IP.String()
+	if ip := d.GetDBDevice().IPAddress; ip != "" {
+		return ip == clientIP
+	}
+
+	d.proxyMu.Lock()
+	wifiMAC := d.proxyWifiMAC
+	d.proxyMu.Unlock()
+	if wifiMAC == "" {
+		return false
+	}
+	clientMAC, err := providerutil.MACAddressOfIP(clientIP)
+	if err != nil {
+		logger.ProviderLogger.LogDebug("network_shaping", fmt.Sprintf("Rejected shaping proxy client %s for device `%v` - %s", clientIP, d.GetUDID(), err))
+		return false
+	}
+	return clientMAC == wifiMAC
+}
+
+var (
+	// iosProxyProfiles are the UDIDs of the devices the provider installed a proxy profile on. They are kept in the provider folder
+	// so a profile left over after a crash is removed on the next start, profiles the provider did not install are left alone
+	iosProxyProfiles   map[string]bool
+	iosProxyProfilesMu sync.Mutex
+)
+
+func iosProxyProfilesFile() string {
+	return filepath.Join(config.ProviderConfig.ProviderFolder, "ios_proxy_profiles.json")
+}
+
+// loadIOSProxyProfiles reads the stored proxy profile UDIDs once, iosProxyProfilesMu must be held
+func loadIOSProxyProfiles() {
+	if iosProxyProfiles != nil {
+		return
+	}
+	iosProxyProfiles = make(map[string]bool)
+	if data, err := os.ReadFile(iosProxyProfilesFile()); err == nil {
+		json.Unmarshal(data, &iosProxyProfiles)
+	}
+}
+
+func hasIOSProxyProfile(udid string) bool {
+	iosProxyProfilesMu.Lock()
+	defer iosProxyProfilesMu.Unlock()
+
+	loadIOSProxyProfiles()
+	return iosProxyProfiles[udid]
+}
+
+func setIOSProxyProfile(udid string, installed bool) {
+	iosProxyProfilesMu.Lock()
+	defer iosProxyProfilesMu.Unlock()
+
+	loadIOSProxyProfiles()
+	if iosProxyProfiles[udid] == installed {
+		return
+	}
+	if installed {
+		iosProxyProfiles[udid] = true
+	} else {
+		delete(iosProxyProfiles, udid)
+	}
+
+	data, _ := json.Marshal(iosProxyProfiles)
+	if err := os.WriteFile(iosProxyProfilesFile(), data, 0644); err != nil {
+		logger.ProviderLogger.LogWarn("network_shaping", fmt.Sprintf("Failed to save the proxy profile state of `%s` - %s", udid, err))
+	}
+}
+
+// InstallCACertificate installs a configuration profile with the certificate as trusted root.
+// Like the proxy profile it can be installed silently only on supervised devices.
+func (d *IOSDevice) InstallCACertificate(cert *x509.Certificate) error {
+	p12, err := os.ReadFile(fmt.Sprintf("%s/supervision.p12", config.ProviderConfig.ProviderFolder))
+	if err != nil {
+		return fmt.Errorf("InstallCACertificate: the profile can only be installed on supervised devices, could not read supervision.p12 - %w", err)
+	}
+
+	profile := map[string]any{
+		"PayloadContent": []any{
+			map[string]any{
+				"PayloadCertificateFileName": "gads-ca.cer",
+				"PayloadContent":             cert.Raw,
+				"PayloadDisplayName":         "GADS Network Capture CA",
+				"PayloadIdentifier":          "com.gads.ca.root",
+				"PayloadType":                "com.apple.security.root",
+				"PayloadUUID":                "6D3F1B0C-2A5E-4F7B-9C1D-8E2A4B6C0D13",
+				"PayloadVersion":             1,
+			},
+		},
+		"PayloadDisplayName":       "GADS Network Capture CA",
+		"PayloadIdentifier":        "com.gads.ca",
+		"PayloadRemovalDisallowed": false,
+		"PayloadType":              "Configuration",
+		"PayloadUUID":              "0B7E2C4A-9D1F-4E3B-8A6C-5F2D1E0A9B47",
+		"PayloadVersion":           1,
+	}
+	profileBytes, err := plist.Marshal(profile, plist.XMLFormat)
+	if err != nil {
+		return fmt.Errorf("InstallCACertificate: failed creating profile - %w", err)
+	}
+	if err := mcinstall.InstallProfileSilent(d.GoIOSDeviceEntry, p12, config.ProviderConfig.SupervisionPassword, profileBytes); err != nil {
+		return fmt.Errorf("InstallCACertificate: failed installing profile - %w", err)
+	}
+	return nil
+}
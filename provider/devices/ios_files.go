@@ -0,0 +1,227 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"GADS/common/models"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/afc"
+	"howett.net/plist"
+)
+
+const houseArrestServiceName = "com.apple.mobile.house_arrest"
+
+type vendContainerResponse struct {
+	Status string `plist:"Status,omitempty"`
+	Error  string `plist:"Error,omitempty"`
+}
+
+// openAppContainer connects to the container of the app through house_arrest and returns an AFC client rooted in it.
+// VendContainer exposes the whole container but only works for development signed apps,
+// so VendDocuments is used as fallback for apps that have file sharing enabled and expose only their Documents folder.
+func (d *IOSDevice) openAppContainer(bundleID string) (*afc.Client, error) {
+	client, err := vendAppContainer(d.GoIOSDeviceEntry, bundleID, "VendContainer")
+	if err == nil {
+		return client, nil
+	}
+
+	client, docsErr := vendAppContainer(d.GoIOSDeviceEntry, bundleID, "VendDocuments")
+	if docsErr != nil {
+		return nil, fmt.Errorf("could not access the container of app `%s`, make sure it is development signed or has file sharing enabled - %v / %v", bundleID, err, docsErr)
+	}
+	return client, nil
+}
+
+func vendAppContainer(entry ios.DeviceEntry, bundleID string, command string) (*afc.Client, error) {
+	deviceConn, err := vendAppContainerConn(entry, bundleID, command)
+	if err != nil {
+		return nil, err
+	}
+	return afc.NewFromConn(deviceConn), nil
+}
+
+// vendAppContainerConn returns the house_arrest connection switched to AFC for the container of the app
+func vendAppContainerConn(entry ios.DeviceEntry, bundleID string, command string) (ios.DeviceConnectionInterface, error) {
+	deviceConn, err := ios.ConnectToService(entry, houseArrestServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	plistCodec := ios.NewPlistCodec()
+	msg, err := plistCodec.Encode(map[string]interface{}{"Command": command, "Identifier": bundleID})
+	if err != nil {
+		deviceConn.Close()
+		return nil, err
+	}
+	if err := deviceConn.Send(msg); err != nil {
+		deviceConn.Close()
+		return nil, err
+	}
+	responseBytes, err := plistCodec.Decode(deviceConn.Reader())
+	if err != nil {
+		deviceConn.Close()
+		return nil, err
+	}
+
+	var response vendContainerResponse
+	if _, err := plist.Unmarshal(responseBytes, &response); err != nil {
+		deviceConn.Close()
+		return nil, err
+	}
+	if response.Status != "Complete" {
+		deviceConn.Close()
+		if response.Error != "" {
+			return nil, errors.New(response.Error)
+		}
+		return nil, fmt.Errorf("unknown error during %s", command)
+	}
+
+	return deviceConn, nil
+}
+
+// cleanAppContainerPath makes the provided path absolute to the container root and rejects escaping it.
+// Only `..` segments that remain after cleaning escape the root, names like `a..b.txt` are valid.
+func cleanAppContainerPath(p string) (string, error) {
+	cleaned := path.Clean(strings.TrimLeft(p, "/"))
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid path `%s`", p)
+		}
+	}
+	return path.Clean("/" + cleaned), nil
+}
+
+// GetIOSAppFileTree walks the container of the app and returns it in the same tree format as the Android shared storage.
+func (d *IOSDevice) GetIOSAppFileTree(bundleID string) (*models.AndroidFileNode, error) {
+	client, err := d.openAppContainer(bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	root := &models.AndroidFileNode{Name: bundleID, FullPath: "/", Children: make(map[string]*models.AndroidFileNode)}
+	nodes := map[string]*models.AndroidFileNode{"/": root}
+
+	err = client.WalkDir("/", func(filePath string, info afc.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		parent, ok := nodes[path.Dir(filePath)]
+		if !ok {
+			return fs.SkipDir
+		}
+		node := &models.AndroidFileNode{
+			Name:     path.Base(filePath),
+			FullPath: filePath,
+			IsFile:   !info.IsDir(),
+		}
+		if info.IsDir() {
+			node.Children = make(map[string]*models.AndroidFileNode)
+			nodes[filePath] = node
+		}
+		parent.Children[node.Name] = node
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed walking the container of app `%s` - %w", bundleID, err)
+	}
+
+	return root, nil
+}
+
+// PushIOSAppFile copies a local file to the destination path in the container of the app, creating missing folders.
+func (d *IOSDevice) PushIOSAppFile(bundleID string, localPath string, destPath string) error {
+	destPath, err := cleanAppContainerPath(destPath)
+	if err != nil {
+		return err
+	}
+
+	client, err := d.openAppContainer(bundleID)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Treat the destination as a folder when it exists as one, otherwise as the target file path
+	targetDir := path.Dir(destPath)
+	if info, err := client.Stat(destPath); err == nil && info.IsDir() {
+		targetDir = destPath
+	}
+	if targetDir != "/" {
+		if err := client.MkDir(targetDir); err != nil {
+			return fmt.Errorf("failed creating folder `%s` in the container of app `%s` - %w", targetDir, bundleID, err)
+		}
+	}
+
+	if err := client.Push(localPath, destPath); err != nil {
+		return fmt.Errorf("failed pushing file to `%s` in the container of app `%s` - %w", destPath, bundleID, err)
+	}
+	return nil
+}
+
+// PullIOSAppFile copies a file from the container of the app to a temporary file and returns its path.
+func (d *IOSDevice) PullIOSAppFile(bundleID string, filePath string, fileName string) (string, error) {
+	var tempFilePath = filepath.Join(os.TempDir(), fileName)
+
+	filePath, err := cleanAppContainerPath(filePath)
+	if err != nil {
+		return tempFilePath, err
+	}
+
+	client, err := d.openAppContainer(bundleID)
+	if err != nil {
+		return tempFilePath, err
+	}
+	defer client.Close()
+
+	info, err := client.Stat(filePath)
+	if err != nil {
+		return tempFilePath, fmt.Errorf("failed getting info for `%s` in the container of app `%s` - %w", filePath, bundleID, err)
+	}
+	if info.IsDir() {
+		return tempFilePath, fmt.Errorf("`%s` is a folder, only files can be pulled", filePath)
+	}
+
+	if err := client.PullSingleFile(filePath, tempFilePath); err != nil {
+		return tempFilePath, fmt.Errorf("failed pulling `%s` from the container of app `%s` - %w", filePath, bundleID, err)
+	}
+	return tempFilePath, nil
+}
+
+// DeleteIOSAppFile removes a file or folder from the container of the app.
+func (d *IOSDevice) DeleteIOSAppFile(bundleID string, filePath string) error {
+	filePath, err := cleanAppContainerPath(filePath)
+	if err != nil {
+		return err
+	}
+	if filePath == "/" {
+		return fmt.Errorf("cannot delete the container root")
+	}
+
+	client, err := d.openAppContainer(bundleID)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.RemoveAll(filePath); err != nil {
+		return fmt.Errorf("failed deleting `%s` from the container of app `%s` - %w", filePath, bundleID, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanAppContainerPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "", want: "/"},
+		{path: "/", want: "/"},
+		{path: "Documents", want: "/Documents"},
+		{path: "/Documents/notes.txt", want: "/Documents/notes.txt"},
+		{path: "Documents//nested/./notes.txt", want: "/Documents/nested/notes.txt"},
+		{path: "/Documents/", want: "/Documents"},
+		{path: "Documents/a..b.txt", want: "/Documents/a..b.txt"},
+		{path: "Documents/..hidden", want: "/Documents/..hidden"},
+		{path: "Documents/backup..", want: "/Documents/backup.."},
+		{path: "Documents/../Library/prefs.plist", want: "/Library/prefs.plist"},
+		{path: "Documents/..", want: "/"},
+		{path: "..", wantErr: true},
+		{path: "../tmp", wantErr: true},
+		{path: "/../tmp", wantErr: true},
+		{path: "Documents/../../tmp", wantErr: true},
+		{path: "//../../etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := cleanAppContainerPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("cleanAppContainerPath(%q) = %q, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("cleanAppContainerPath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// The container paths are validated before connecting to the device, so these run without one
+func TestIOSAppFilePaths_RejectedBeforeConnecting(t *testing.T) {
+	dev := &IOSDevice{}
+
+	tests := []struct {
+		name      string
+		operation func(filePath string) error
+		filePath  string
+		wantErr   string
+	}{
+		{
+			name:      "push outside of the container",
+			operation: func(filePath string) error { return dev.PushIOSAppFile("com.example.app", "local.txt", filePath) },
+			filePath:  "../outside.txt",
+			wantErr:   "invalid path",
+		},
+		{
+			name: "pull outside of the container",
+			operation: func(filePath string) error {
+				_, err := dev.PullIOSAppFile("com.example.app", filePath, "outside.txt")
+				return err
+			},
+			filePath: "Documents/../../outside.txt",
+			wantErr:  "invalid path",
+		},
+		{
+			name:      "delete outside of the container",
+			operation: func(filePath string) error { return dev.DeleteIOSAppFile("com.example.app", filePath) },
+			filePath:  "/../outside.txt",
+			wantErr:   "invalid path",
+		},
+		{
+			name:      "delete the container root",
+			operation: func(filePath string) error { return dev.DeleteIOSAppFile("com.example.app", filePath) },
+			filePath:  "Documents/..",
+			wantErr:   "container root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.operation(tt.filePath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
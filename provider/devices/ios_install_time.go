@@ -0,0 +1,138 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/installationproxy"
+	"golang.org/x/sync/errgroup"
+)
+
+// The installation proxy does not report when an app was installed, the creation time of the app data container is used instead.
+// go-ios drops the AFC timestamps so the file info request is done here.
+const (
+	afcMagic             = uint64(0x4141504c36414643)
+	afcHeaderSize        = uint64(40)
+	afcOperationStatus   = uint64(0x01)
+	afcOperationFileInfo = uint64(0x0A)
+)
+
+type afcHeader struct {
+	Magic     uint64
+	EntireLen uint64
+	ThisLen   uint64
+	PacketNum uint64
+	Operation uint64
+}
+
+// appInstallTimes returns the install time in Unix ms for the provided apps keyed by bundle identifier.
+// The times are cached by bundle path which changes when an app is reinstalled, apps whose container
+// cannot be vended (App Store apps on recent iOS versions) are cached with 0 so they are not retried.
+func (d *IOSDevice) appInstallTimes(apps []installationproxy.AppInfo) map[string]int64 {
+	d.installTimesMu.Lock()
+	defer d.installTimesMu.Unlock()
+	if d.installTimes == nil {
+		d.installTimes = make(map[string]int64)
+	}
+
+	var missing []installationproxy.AppInfo
+	for _, app := range apps {
+		if _, ok := d.installTimes[app.Path()]; !ok {
+			missing = append(missing, app)
+		}
+	}
+
+	times := make([]int64, len(missing))
+	g := new(errgroup.Group)
+	g.SetLimit(4)
+	for i, app := range missing {
+		g.Go(func() error {
+			installTime, err := appContainerBirthTime(d.GoIOSDeviceEntry, app.CFBundleIdentifier())
+			if err != nil {
+				d.GetLogger().LogDebug("get_installed_apps", fmt.Sprintf("Could not get the install time of `%s` - %s", app.CFBundleIdentifier(), err))
+			}
+			times[i] = installTime
+			return nil
+		})
+	}
+	g.Wait()
+	for i, app := range missing {
+		d.installTimes[app.Path()] = times[i]
+	}
+
+	installTimes := make(map[string]int64, len(apps))
+	for _, app := range apps {
+		installTimes[app.CFBundleIdentifier()] = d.installTimes[app.Path()]
+	}
+	return installTimes
+}
+
+// appContainerBirthTime returns the creation time of the app data container in Unix ms
+func appContainerBirthTime(entry ios.DeviceEntry, bundleID string) (int64, error) {
+	conn, err := vendAppContainerConn(entry, bundleID, "VendContainer")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	path := []byte("/\x00")
+	header := afcHeader{
+		Magic:     afcMagic,
+		EntireLen: afcHeaderSize + uint64(len(path)),
+		ThisLen:   afcHeaderSize + uint64(len(path)),
+		Operation: afcOperationFileInfo,
+	}
+	if err := binary.Write(conn.Writer(), binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Writer().Write(path); err != nil {
+		return 0, err
+	}
+
+	if err := binary.Read(conn.Reader(), binary.LittleEndian, &header); err != nil {
+		return 0, err
+	}
+	if header.Magic != afcMagic || header.ThisLen < afcHeaderSize || header.EntireLen < header.ThisLen || header.EntireLen > 1<<20 {
+		return 0, fmt.Errorf("invalid AFC response header")
+	}
+	packet := make([]byte, header.EntireLen-afcHeaderSize)
+	if _, err := io.ReadFull(conn.Reader(), packet); err != nil {
+		return 0, err
+	}
+	if header.Operation == afcOperationStatus {
+		return 0, fmt.Errorf("AFC file info failed with status %d", binary.LittleEndian.Uint64(append(packet, make([]byte, 8)...)))
+	}
+
+	birthTime, ok := parseAFCFileInfo(packet[header.ThisLen-afcHeaderSize:])["st_birthtime"]
+	if !ok {
+		return 0, fmt.Errorf("AFC file info has no birth time")
+	}
+	nanoseconds, err := strconv.ParseInt(birthTime, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return nanoseconds / 1e6, nil
+}
+
+// parseAFCFileInfo parses the null separated key value pairs of an AFC file info response
+func parseAFCFileInfo(payload []byte) map[string]string {
+	info := make(map[string]string)
+	fields := bytes.Split(bytes.TrimRight(payload, "\x00"), []byte{0})
+	for i := 0; i+1 < len(fields); i += 2 {
+		info[string(fields[i])] = string(fields[i+1])
+	}
+	return info
+}
@@ -0,0 +1,283 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"howett.net/plist"
+)
+
+// usbmuxd result codes
+const (
+	usbmuxResultOK         = 0
+	usbmuxResultBadCommand = 1
+	usbmuxResultBadDevice  = 2
+)
+
+const usbmuxMaxMessageSize = 1 << 20
+
+// pairRecordSecrets are the pair record keys that are never handed out through the relay. With the host private key
+// or the escrow bag the lock holder would keep lockdown access to the device after the lease ends.
+var pairRecordSecrets = []string{"HostPrivateKey", "RootPrivateKey", "EscrowBag"}
+
+// ServeUsbmux speaks the usbmuxd protocol on the connection on behalf of the provider usbmuxd.
+// Only this device is visible through it - device lists and attach events of other devices are filtered out,
+// connections can be made only to this device and only its pair record can be read, without the private keys.
+// Pair records on the provider cannot be changed or deleted.
+func (d *IOSDevice) ServeUsbmux(client net.Conn) error {
+	defer client.Close()
+
+	for {
+		header, request, err := readUsbmuxMessage(client)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		messageType, _ := request["MessageType"].(string)
+		if result := d.checkUsbmuxRequest(request); result != usbmuxResultOK {
+			d.GetLogger().LogDebug("usbmux", fmt.Sprintf("Rejected usbmux `%s` request", messageType))
+			if err := writeUsbmuxResult(client, header.Tag, result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Every request goes through its own upstream connection, Listen and Connect take over the connection
+		upstream, err := dialUsbmuxd()
+		if err != nil {
+			return err
+		}
+		if err := writeUsbmuxMessage(upstream, header.Tag, request); err != nil {
+			upstream.Close()
+			return err
+		}
+
+		switch messageType {
+		case "Listen":
+			return d.relayUsbmuxEvents(client, upstream)
+		case "Connect":
+			respHeader, response, err := readUsbmuxMessage(upstream)
+			if err != nil {
+				upstream.Close()
+				return err
+			}
+			if err := writeUsbmuxMessage(client, respHeader.Tag, response); err != nil {
+				upstream.Close()
+				return err
+			}
+			if usbmuxInt(response["Number"]) != usbmuxResultOK {
+				upstream.Close()
+				continue
+			}
+			// The connection now carries the raw stream of the device port
+			d.GetLogger().LogDebug("usbmux", fmt.Sprintf("Relaying usbmux connection to device port %d", usbmuxPort(request["PortNumber"])))
+			relayConns(client, upstream)
+			return nil
+		default:
+			respHeader, response, err := readUsbmuxMessage(upstream)
+			upstream.Close()
+			if err != nil {
+				return err
+			}
+			switch messageType {
+			case "ListDevices":
+				response["DeviceList"] = d.filterUsbmuxDevices(response["DeviceList"])
+			case "ReadPairRecord":
+				if err := stripPairRecordSecrets(response); err != nil {
+					d.GetLogger().LogWarn("usbmux", fmt.Sprintf("Rejected usbmux `ReadPairRecord` request - %s", err))
+					response = map[string]any{"MessageType": "Result", "Number": usbmuxResultBadDevice}
+				}
+			}
+			if err := writeUsbmuxMessage(client, respHeader.Tag, response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// relayUsbmuxEvents relays the result of a Listen request and the attach events of this device
+func (d *IOSDevice) relayUsbmuxEvents(client, upstream net.Conn) error {
+	defer upstream.Close()
+	go func() {
+		// Listen connections are not used for further requests, a read returns once the client disconnects
+		io.Copy(io.Discard, client)
+		upstream.Close()
+	}()
+
+	deviceIDs := map[int]bool{}
+	for {
+		header, event, err := readUsbmuxMessage(upstream)
+		if err != nil {
+			return nil
+		}
+		deviceID := usbmuxInt(event["DeviceID"])
+		switch event["MessageType"] {
+		case "Result":
+		case "Attached":
+			properties, _ := event["Properties"].(map[string]any)
+			if properties["SerialNumber"] != d.GetUDID() {
+				continue
+			}
+			deviceIDs[deviceID] = true
+		default:
+			if !deviceIDs[deviceID] {
+				continue
+			}
+		}
+		if err := writeUsbmuxMessage(client, header.Tag, event); err != nil {
+			return err
+		}
+	}
+}
+
+// checkUsbmuxRequest returns the usbmux result for a client request, usbmuxResultOK if it can be relayed
+func (d *IOSDevice) checkUsbmuxRequest(request map[string]any) int {
+	switch request["MessageType"] {
+	case "ListDevices", "Listen", "ReadBUID":
+		return usbmuxResultOK
+	case "Connect":
+		if usbmuxInt(request["DeviceID"]) != d.GoIOSDeviceEntry.DeviceID {
+			return usbmuxResultBadDevice
+		}
+		return usbmuxResultOK
+	case "ReadPairRecord":
+		if request["PairRecordID"] != d.GetUDID() {
+			return usbmuxResultBadDevice
+		}
+		return usbmuxResultOK
+	}
+	return usbmuxResultBadCommand
+}
+
+// stripPairRecordSecrets removes the private keys and the escrow bag from a ReadPairRecord response
+func stripPairRecordSecrets(response map[string]any) error {
+	data, ok := response["PairRecordData"].([]byte)
+	if !ok {
+		// Error results have no pair record
+		return nil
+	}
+	record := map[string]any{}
+	if _, err := plist.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("failed to decode the pair record - %w", err)
+	}
+	for _, key := range pairRecordSecrets {
+		delete(record, key)
+	}
+	stripped, err := plist.Marshal(record, plist.XMLFormat)
+	if err != nil {
+		return err
+	}
+	response["PairRecordData"] = stripped
+	return nil
+}
+
+func (d *IOSDevice) filterUsbmuxDevices(list any) []any {
+	entries, _ := list.([]any)
+	filtered := []any{}
+	for _, entry := range entries {
+		entryMap, _ := entry.(map[string]any)
+		properties, _ := entryMap["Properties"].(map[string]any)
+		if properties["SerialNumber"] == d.GetUDID() {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+func dialUsbmuxd() (net.Conn, error) {
+	network, address := ios.GetSocketTypeAndAddress(ios.GetUsbmuxdSocket())
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to usbmuxd - %w", err)
+	}
+	return conn, nil
+}
+
+func readUsbmuxMessage(r io.Reader) (ios.UsbMuxHeader, map[string]any, error) {
+	var header ios.UsbMuxHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return header, nil, err
+	}
+	if header.Length < 16 || header.Length > usbmuxMaxMessageSize {
+		return header, nil, fmt.Errorf("invalid usbmux message length %d", header.Length)
+	}
+	payload := make([]byte, header.Length-16)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return header, nil, err
+	}
+
+	message := map[string]any{}
+	if _, err := plist.Unmarshal(payload, &message); err != nil {
+		return header, nil, fmt.Errorf("failed to decode usbmux message - %w", err)
+	}
+	return header, message, nil
+}
+
+func writeUsbmuxMessage(w io.Writer, tag uint32, message map[string]any) error {
+	payload, err := plist.Marshal(message, plist.XMLFormat)
+	if err != nil {
+		return err
+	}
+	header := ios.UsbMuxHeader{Length: 16 + uint32(len(payload)), Version: 1, Request: 8, Tag: tag}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err = w.Write(payload)
+	return err
+}
+
+func writeUsbmuxResult(w io.Writer, tag uint32, result int) error {
+	return writeUsbmuxMessage(w, tag, map[string]any{"MessageType": "Result", "Number": result})
+}
+
+// usbmuxInt returns the integer value of a decoded plist number
+func usbmuxInt(v any) int {
+	switch n := v.(type) {
+	case uint64:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return -1
+}
+
+// usbmuxPort returns the port of a Connect request, it is sent in network byte order
+func usbmuxPort(v any) int {
+	port := uint16(usbmuxInt(v))
+	return int(port>>8 | port<<8)
+}
+
+// relayConns copies between the connections until one of them closes, then closes both
+func relayConns(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
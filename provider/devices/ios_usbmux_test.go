@@ -0,0 +1,127 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"howett.net/plist"
+)
+
+func newUsbmuxTestDevice() *IOSDevice {
+	d := &IOSDevice{GoIOSDeviceEntry: ios.DeviceEntry{DeviceID: 7}}
+	d.DBDevice.UDID = "00008030-TEST"
+	return d
+}
+
+func TestCheckUsbmuxRequest(t *testing.T) {
+	d := newUsbmuxTestDevice()
+
+	tests := []struct {
+		name    string
+		request map[string]any
+		want    int
+	}{
+		{"list devices", map[string]any{"MessageType": "ListDevices"}, usbmuxResultOK},
+		{"listen", map[string]any{"MessageType": "Listen"}, usbmuxResultOK},
+		{"read BUID", map[string]any{"MessageType": "ReadBUID"}, usbmuxResultOK},
+		{"connect to the device", map[string]any{"MessageType": "Connect", "DeviceID": uint64(7)}, usbmuxResultOK},
+		{"connect to another device", map[string]any{"MessageType": "Connect", "DeviceID": uint64(8)}, usbmuxResultBadDevice},
+		{"connect without device", map[string]any{"MessageType": "Connect"}, usbmuxResultBadDevice},
+		{"read the device pair record", map[string]any{"MessageType": "ReadPairRecord", "PairRecordID": "00008030-TEST"}, usbmuxResultOK},
+		{"read another pair record", map[string]any{"MessageType": "ReadPairRecord", "PairRecordID": "00008030-OTHER"}, usbmuxResultBadDevice},
+		{"save pair record", map[string]any{"MessageType": "SavePairRecord", "PairRecordID": "00008030-TEST"}, usbmuxResultBadCommand},
+		{"delete pair record", map[string]any{"MessageType": "DeletePairRecord", "PairRecordID": "00008030-TEST"}, usbmuxResultBadCommand},
+		{"missing message type", map[string]any{}, usbmuxResultBadCommand},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.checkUsbmuxRequest(tt.request); got != tt.want {
+				t.Errorf("checkUsbmuxRequest() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripPairRecordSecrets(t *testing.T) {
+	record := map[string]any{
+		"HostID":          "host-id",
+		"SystemBUID":      "buid",
+		"HostCertificate": []byte("host-cert"),
+		"HostPrivateKey":  []byte("host-key"),
+		"RootPrivateKey":  []byte("root-key"),
+		"EscrowBag":       []byte("escrow"),
+	}
+	data, err := plist.Marshal(record, plist.BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := map[string]any{"PairRecordData": data}
+
+	if err := stripPairRecordSecrets(response); err != nil {
+		t.Fatalf("stripPairRecordSecrets() error = %v", err)
+	}
+	stripped := map[string]any{}
+	if _, err := plist.Unmarshal(response["PairRecordData"].([]byte), &stripped); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range pairRecordSecrets {
+		if _, ok := stripped[key]; ok {
+			t.Errorf("Expected `%s` to be removed from the pair record", key)
+		}
+	}
+	if stripped["HostID"] != "host-id" || stripped["SystemBUID"] != "buid" || !bytes.Equal(stripped["HostCertificate"].([]byte), []byte("host-cert")) {
+		t.Errorf("Expected the public pair record values to be kept, got %v", stripped)
+	}
+
+	// Error results are relayed as they are
+	result := map[string]any{"MessageType": "Result", "Number": uint64(2)}
+	if err := stripPairRecordSecrets(result); err != nil || len(result) != 2 {
+		t.Errorf("Expected the error result to be unchanged, got %v - %v", result, err)
+	}
+
+	if err := stripPairRecordSecrets(map[string]any{"PairRecordData": []byte("not a plist")}); err == nil {
+		t.Errorf("Expected an error for an invalid pair record")
+	}
+}
+
+func TestFilterUsbmuxDevices(t *testing.T) {
+	d := newUsbmuxTestDevice()
+	device := map[string]any{"DeviceID": uint64(7), "Properties": map[string]any{"SerialNumber": "00008030-TEST"}}
+	other := map[string]any{"DeviceID": uint64(8), "Properties": map[string]any{"SerialNumber": "00008030-OTHER"}}
+
+	filtered := d.filterUsbmuxDevices([]any{other, device, map[string]any{}})
+	if len(filtered) != 1 || usbmuxInt(filtered[0].(map[string]any)["DeviceID"]) != 7 {
+		t.Errorf("Expected only the device to be listed, got %v", filtered)
+	}
+	if filtered := d.filterUsbmuxDevices(nil); filtered == nil || len(filtered) != 0 {
+		t.Errorf("Expected an empty device list, got %v", filtered)
+	}
+}
+
+func TestUsbmuxMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeUsbmuxMessage(&buf, 5, map[string]any{"MessageType": "Connect", "DeviceID": 7, "PortNumber": 0x7ef2}); err != nil {
+		t.Fatal(err)
+	}
+	header, message, err := readUsbmuxMessage(&buf)
+	if err != nil {
+		t.Fatalf("readUsbmuxMessage() error = %v", err)
+	}
+	if header.Tag != 5 || message["MessageType"] != "Connect" || usbmuxInt(message["DeviceID"]) != 7 {
+		t.Errorf("Unexpected message %v with tag %d", message, header.Tag)
+	}
+	// Ports are sent in network byte order
+	if port := usbmuxPort(message["PortNumber"]); port != 0xf27e {
+		t.Errorf("usbmuxPort() = %d, want %d", port, 0xf27e)
+	}
+}
@@ -0,0 +1,435 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/google/uuid"
+	"howett.net/plist"
+)
+
+const (
+	webInspectorService        = "com.apple.webinspector"
+	webInspectorShimService    = "com.apple.webinspector.shim.remote"
+	webInspectorListingTimeout = 3 * time.Second
+	webInspectorPollInterval   = 50 * time.Millisecond
+)
+
+// webInspectorMessage is the envelope of the webinspector service plist messages
+type webInspectorMessage struct {
+	Selector string         `plist:"__selector"`
+	Argument map[string]any `plist:"__argument"`
+}
+
+type webInspectorApp struct {
+	name     string
+	bundleID string
+	proxy    bool
+}
+
+// webInspector is a connection to the webinspector service of a device, it is shared by all inspection sessions of the device
+type webInspector struct {
+	conn         io.ReadWriteCloser
+	connectionID string
+	writeMu      sync.Mutex
+
+	mu           sync.Mutex
+	appsReceived bool
+	apps         map[string]webInspectorApp
+	listings     map[string][]models.WebInspectorPage
+	sessions     map[string]*WebInspectorSession
+	closed       bool
+}
+
+// WebInspectorSession relays WebKit Inspector protocol messages to a single page
+type WebInspectorSession struct {
+	inspector *webInspector
+	appID     string
+	pageID    int
+	senderID  string
+	messages  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	webInspectors   = make(map[string]*webInspector)
+	webInspectorsMu sync.Mutex
+)
+
+// getWebInspector returns the webinspector connection of the device, connecting if needed
+func (d *IOSDevice) getWebInspector() (*webInspector, error) {
+	webInspectorsMu.Lock()
+	defer webInspectorsMu.Unlock()
+
+	if inspector, ok := webInspectors[d.GetUDID()]; ok {
+		return inspector, nil
+	}
+
+	var conn ios.DeviceConnectionInterface
+	var err error
+	if d.GoIOSDeviceEntry.SupportsRsd() {
+		conn, err = ios.ConnectToShimService(d.GoIOSDeviceEntry, webInspectorShimService)
+	} else {
+		conn, err = ios.ConnectToService(d.GoIOSDeviceEntry, webInspectorService)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting to the webinspector service, make sure Web Inspector is enabled in Settings > Safari > Advanced - %w", err)
+	}
+
+	inspector := &webInspector{
+		conn:         conn,
+		connectionID: uuid.NewString(),
+		apps:         make(map[string]webInspectorApp),
+		listings:     make(map[string][]models.WebInspectorPage),
+		sessions:     make(map[string]*WebInspectorSession),
+	}
+	if err := inspector.send("_rpc_reportIdentifier:", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := inspector.send("_rpc_getConnectedApplications:", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	webInspectors[d.GetUDID()] = inspector
+	go func() {
+		inspector.readLoop()
+		webInspectorsMu.Lock()
+		if webInspectors[d.GetUDID()] == inspector {
+			delete(webInspectors, d.GetUDID())
+		}
+		webInspectorsMu.Unlock()
+	}()
+	go func() {
+		<-d.GetContext().Done()
+		inspector.close()
+	}()
+	return inspector, nil
+}
+
+// GetWebInspectorPages returns the inspectable pages of all applications on the device
+func (d *IOSDevice) GetWebInspectorPages() ([]models.WebInspectorPage, error) {
+	inspector, err := d.getWebInspector()
+	if err != nil {
+		return nil, err
+	}
+
+	if !inspector.waitFor(func() bool { return inspector.appsReceived }) {
+		return nil, fmt.Errorf("timed out waiting for the inspectable applications")
+	}
+
+	inspector.mu.Lock()
+	var appIDs []string
+	for appID, app := range inspector.apps {
+		// Proxy applications like the WebContent processes are listed through their host application
+		if app.proxy {
+			continue
+		}
+		appIDs = append(appIDs, appID)
+		delete(inspector.listings, appID)
+	}
+	inspector.mu.Unlock()
+
+	for _, appID := range appIDs {
+		if err := inspector.send("_rpc_forwardGetListing:", map[string]any{"WIRApplicationIdentifierKey": appID}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apps that do not answer in time are skipped, listings of the other apps are still useful
+	inspector.waitFor(func() bool {
+		for _, appID := range appIDs {
+			if _, ok := inspector.listings[appID]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+
+	inspector.mu.Lock()
+	defer inspector.mu.Unlock()
+	pages := []models.WebInspectorPage{}
+	for _, appID := range appIDs {
+		pages = append(pages, inspector.listings[appID]...)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].AppName != pages[j].AppName {
+			return pages[i].AppName < pages[j].AppName
+		}
+		return pages[i].PageID < pages[j].PageID
+	})
+	return pages, nil
+}
+
+// OpenWebInspectorSession attaches to the page, messages are raw WebKit Inspector protocol JSON
+func (d *IOSDevice) OpenWebInspectorSession(appID string, pageID int) (*WebInspectorSession, error) {
+	inspector, err := d.getWebInspector()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &WebInspectorSession{
+		inspector: inspector,
+		appID:     appID,
+		pageID:    pageID,
+		senderID:  uuid.NewString(),
+		messages:  make(chan []byte, 256),
+		done:      make(chan struct{}),
+	}
+
+	inspector.mu.Lock()
+	if inspector.closed {
+		inspector.mu.Unlock()
+		return nil, fmt.Errorf("webinspector connection is closed")
+	}
+	inspector.sessions[session.senderID] = session
+	inspector.mu.Unlock()
+
+	err = inspector.send("_rpc_forwardSocketSetup:", map[string]any{
+		"WIRApplicationIdentifierKey":         appID,
+		"WIRPageIdentifierKey":                pageID,
+		"WIRSenderKey":                        session.senderID,
+		"WIRMessageDataTypeChunkSupportedKey": 0,
+		"WIRAutomaticallyPause":               false,
+	})
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// Send forwards a protocol message to the page
+func (s *WebInspectorSession) Send(data []byte) error {
+	return s.inspector.send("_rpc_forwardSocketData:", map[string]any{
+		"WIRApplicationIdentifierKey": s.appID,
+		"WIRPageIdentifierKey":        s.pageID,
+		"WIRSenderKey":                s.senderID,
+		"WIRSocketDataKey":            data,
+	})
+}
+
+// Messages returns the protocol messages sent by the page
+func (s *WebInspectorSession) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Done is closed when the session ends, either by Close or because the page or the connection went away
+func (s *WebInspectorSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close detaches from the page
+func (s *WebInspectorSession) Close() {
+	s.closeOnce.Do(func() {
+		s.inspector.mu.Lock()
+		delete(s.inspector.sessions, s.senderID)
+		s.inspector.mu.Unlock()
+		s.inspector.send("_rpc_forwardDidClose:", map[string]any{
+			"WIRApplicationIdentifierKey": s.appID,
+			"WIRPageIdentifierKey":        s.pageID,
+			"WIRSenderKey":                s.senderID,
+		})
+		close(s.done)
+	})
+}
+
+func (wi *webInspector) send(selector string, argument map[string]any) error {
+	if argument == nil {
+		argument = make(map[string]any)
+	}
+	argument["WIRConnectionIdentifierKey"] = wi.connectionID
+
+	payload, err := plist.Marshal(webInspectorMessage{Selector: selector, Argument: argument}, plist.BinaryFormat)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+
+	wi.writeMu.Lock()
+	defer wi.writeMu.Unlock()
+	if _, err := wi.conn.Write(frame); err != nil {
+		return fmt.Errorf("failed sending `%s` to the webinspector service - %w", selector, err)
+	}
+	return nil
+}
+
+// waitFor polls the condition with the inspector state locked until it is true or the listing timeout passes
+func (wi *webInspector) waitFor(condition func() bool) bool {
+	deadline := time.Now().Add(webInspectorListingTimeout)
+	for time.Now().Before(deadline) {
+		wi.mu.Lock()
+		ok := condition()
+		closed := wi.closed
+		wi.mu.Unlock()
+		if ok {
+			return true
+		}
+		if closed {
+			return false
+		}
+		time.Sleep(webInspectorPollInterval)
+	}
+	return false
+}
+
+func (wi *webInspector) close() {
+	wi.conn.Close()
+
+	wi.mu.Lock()
+	wi.closed = true
+	sessions := wi.sessions
+	wi.sessions = make(map[string]*WebInspectorSession)
+	wi.mu.Unlock()
+
+	for _, session := range sessions {
+		session.closeOnce.Do(func() { close(session.done) })
+	}
+}
+
+func (wi *webInspector) readLoop() {
+	defer wi.close()
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(wi.conn, header); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(wi.conn, payload); err != nil {
+			return
+		}
+
+		var message webInspectorMessage
+		if _, err := plist.Unmarshal(payload, &message); err != nil {
+			continue
+		}
+		wi.handleMessage(message)
+	}
+}
+
+func (wi *webInspector) handleMessage(message webInspectorMessage) {
+	arg := message.Argument
+
+	switch message.Selector {
+	case "_rpc_reportConnectedApplicationList:":
+		apps, _ := arg["WIRApplicationDictionaryKey"].(map[string]any)
+		wi.mu.Lock()
+		for appID, app := range apps {
+			if appDict, ok := app.(map[string]any); ok {
+				wi.apps[appID] = parseWebInspectorApp(appDict)
+			}
+		}
+		wi.appsReceived = true
+		wi.mu.Unlock()
+	case "_rpc_applicationConnected:", "_rpc_applicationUpdated:":
+		appID, _ := arg["WIRApplicationIdentifierKey"].(string)
+		wi.mu.Lock()
+		wi.apps[appID] = parseWebInspectorApp(arg)
+		wi.mu.Unlock()
+	case "_rpc_applicationDisconnected:":
+		appID, _ := arg["WIRApplicationIdentifierKey"].(string)
+		wi.mu.Lock()
+		delete(wi.apps, appID)
+		delete(wi.listings, appID)
+		var closed []*WebInspectorSession
+		for _, session := range wi.sessions {
+			if session.appID == appID {
+				closed = append(closed, session)
+			}
+		}
+		wi.mu.Unlock()
+		for _, session := range closed {
+			session.Close()
+		}
+	case "_rpc_applicationSentListing:":
+		appID, _ := arg["WIRApplicationIdentifierKey"].(string)
+		listing, _ := arg["WIRListingKey"].(map[string]any)
+		wi.mu.Lock()
+		app := wi.apps[appID]
+		pages := []models.WebInspectorPage{}
+		for _, page := range listing {
+			pageDict, ok := page.(map[string]any)
+			if !ok {
+				continue
+			}
+			pageType, _ := pageDict["WIRTypeKey"].(string)
+			// Automation targets belong to safaridriver sessions and cannot be inspected
+			if pageType == "WIRTypeAutomation" {
+				continue
+			}
+			title, _ := pageDict["WIRTitleKey"].(string)
+			pageURL, _ := pageDict["WIRURLKey"].(string)
+			_, inUse := pageDict["WIRConnectionIdentifierKey"]
+			pages = append(pages, models.WebInspectorPage{
+				AppID:    appID,
+				AppName:  app.name,
+				BundleID: app.bundleID,
+				PageID:   plistInt(pageDict["WIRPageIdentifierKey"]),
+				Type:     pageType,
+				Title:    title,
+				URL:      pageURL,
+				InUse:    inUse,
+			})
+		}
+		wi.listings[appID] = pages
+		wi.mu.Unlock()
+	case "_rpc_applicationSentData:":
+		senderID, _ := arg["WIRDestinationKey"].(string)
+		data, _ := arg["WIRMessageDataKey"].([]byte)
+		wi.mu.Lock()
+		session, ok := wi.sessions[senderID]
+		wi.mu.Unlock()
+		if !ok {
+			return
+		}
+		select {
+		case session.messages <- data:
+		case <-session.done:
+		}
+	}
+}
+
+func parseWebInspectorApp(app map[string]any) webInspectorApp {
+	name, _ := app["WIRApplicationNameKey"].(string)
+	bundleID, _ := app["WIRApplicationBundleIdentifierKey"].(string)
+	proxy, _ := app["WIRIsApplicationProxyKey"].(bool)
+	return webInspectorApp{name: name, bundleID: bundleID, proxy: proxy}
+}
+
+// plistInt converts the integer types a plist can decode to
+func plistInt(v any) int {
+	switch value := v.(type) {
+	case uint64:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	case string:
+		i, _ := strconv.Atoi(value)
+		return i
+	}
+	return 0
+}
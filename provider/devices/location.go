@@ -0,0 +1,378 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+)
+
+const (
+	defaultRouteSpeedKmh  = 50
+	earthRadiusMeters     = 6371000
+	maxRouteDocumentBytes = 5 << 20
+)
+
+// routeUpdateInterval is how often a route replay moves the device location
+var routeUpdateInterval = 1 * time.Second
+
+// ErrLocationAppMissing is returned when the app the device mocks its location through is not installed
+var ErrLocationAppMissing = errors.New("the mock location app is not installed on the device")
+
+// LocationSimulator is implemented by devices that can spoof their GPS location.
+type LocationSimulator interface {
+	PlatformDevice
+
+	SetLocation(latitude, longitude float64) error
+	ResetLocation() error
+}
+
+type locationSimulation struct {
+	status models.LocationSimulationStatus
+	cancel context.CancelFunc
+	// done is closed when the route replay returns, nil for a fixed location
+	done chan struct{}
+}
+
+var (
+	locationSimulations   = make(map[string]*locationSimulation)
+	locationSimulationsMu sync.Mutex
+)
+
+// SimulateLocation stops any running route replay and sets a fixed location on the device.
+func SimulateLocation(dev LocationSimulator, latitude, longitude float64) error {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return err
+	}
+	stopRouteReplay(dev.GetUDID())
+
+	if err := dev.SetLocation(latitude, longitude); err != nil {
+		return err
+	}
+
+	locationSimulationsMu.Lock()
+	locationSimulations[dev.GetUDID()] = &locationSimulation{
+		status: models.LocationSimulationStatus{
+			Active:  true,
+			Current: &models.LocationPoint{Latitude: latitude, Longitude: longitude},
+		},
+	}
+	locationSimulationsMu.Unlock()
+	return nil
+}
+
+// SimulateLocationRoute replays the points of a route on the device at the provided speed.
+// The replay runs in the background until the route ends, the location is reset or the device is reset.
+func SimulateLocationRoute(dev LocationSimulator, points []models.LocationPoint, speedKmh float64, loop bool) error {
+	if len(points) == 0 {
+		return fmt.Errorf("route has no points")
+	}
+	for _, point := range points {
+		if err := validateCoordinates(point.Latitude, point.Longitude); err != nil {
+			return err
+		}
+	}
+	if speedKmh <= 0 {
+		speedKmh = defaultRouteSpeedKmh
+	}
+	stopRouteReplay(dev.GetUDID())
+
+	// Set the first point synchronously so the caller gets immediate feedback if simulation does not work at all
+	if err := dev.SetLocation(points[0].Latitude, points[0].Longitude); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(dev.GetContext())
+	sim := &locationSimulation{
+		status: models.LocationSimulationStatus{
+			Active:   true,
+			Route:    true,
+			Current:  &points[0],
+			Points:   len(points),
+			SpeedKmh: speedKmh,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	locationSimulationsMu.Lock()
+	locationSimulations[dev.GetUDID()] = sim
+	locationSimulationsMu.Unlock()
+
+	go func() {
+		defer close(sim.done)
+		replayRoute(ctx, dev, sim, points, speedKmh, loop)
+	}()
+	return nil
+}
+
+// StopLocationSimulation stops any running route replay and restores the real device location.
+func StopLocationSimulation(dev LocationSimulator) error {
+	stopRouteReplay(dev.GetUDID())
+
+	locationSimulationsMu.Lock()
+	delete(locationSimulations, dev.GetUDID())
+	locationSimulationsMu.Unlock()
+
+	return dev.ResetLocation()
+}
+
+// GetLocationSimulationStatus returns the current location simulation state of the device.
+func GetLocationSimulationStatus(udid string) models.LocationSimulationStatus {
+	locationSimulationsMu.Lock()
+	defer locationSimulationsMu.Unlock()
+
+	sim, ok := locationSimulations[udid]
+	if !ok {
+		return models.LocationSimulationStatus{}
+	}
+	status := sim.status
+	if status.Current != nil {
+		current := *status.Current
+		status.Current = &current
+	}
+	return status
+}
+
+// stopRouteReplay stops the route replay of the device and waits for it to return,
+// so no location it was setting lands after the location is reset or replaced
+func stopRouteReplay(udid string) {
+	locationSimulationsMu.Lock()
+	sim, ok := locationSimulations[udid]
+	if !ok {
+		locationSimulationsMu.Unlock()
+		return
+	}
+	if sim.cancel != nil {
+		sim.cancel()
+		sim.cancel = nil
+	}
+	done := sim.done
+	locationSimulationsMu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func replayRoute(ctx context.Context, dev LocationSimulator, sim *locationSimulation, points []models.LocationPoint, speedKmh float64, loop bool) {
+	metersPerSecond := speedKmh * 1000 / 3600
+	ticker := time.NewTicker(routeUpdateInterval)
+	defer ticker.Stop()
+
+	segment := 0
+	segmentProgress := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		remaining := metersPerSecond * routeUpdateInterval.Seconds()
+		for remaining > 0 && segment < len(points)-1 {
+			segmentLength := haversineMeters(points[segment], points[segment+1])
+			left := segmentLength - segmentProgress
+			if remaining < left {
+				segmentProgress += remaining
+				remaining = 0
+				break
+			}
+			remaining -= left
+			segment++
+			segmentProgress = 0
+		}
+
+		var position models.LocationPoint
+		if segment >= len(points)-1 {
+			position = points[len(points)-1]
+		} else {
+			segmentLength := haversineMeters(points[segment], points[segment+1])
+			fraction := 0.0
+			if segmentLength > 0 {
+				fraction = segmentProgress / segmentLength
+			}
+			position = interpolatePoint(points[segment], points[segment+1], fraction)
+		}
+
+		// The replay can be stopped while the position was computed
+		if ctx.Err() != nil {
+			return
+		}
+		if err := dev.SetLocation(position.Latitude, position.Longitude); err != nil {
+			dev.GetLogger().LogWarn("location_simulation", fmt.Sprintf("Failed to set route location - %s", err))
+		}
+
+		locationSimulationsMu.Lock()
+		sim.status.Current = &position
+		locationSimulationsMu.Unlock()
+
+		if segment >= len(points)-1 {
+			if !loop {
+				dev.GetLogger().LogInfo("location_simulation", "Finished replaying location route")
+				locationSimulationsMu.Lock()
+				if sim.cancel != nil {
+					sim.cancel()
+					sim.cancel = nil
+				}
+				locationSimulationsMu.Unlock()
+				return
+			}
+			segment = 0
+			segmentProgress = 0
+		}
+	}
+}
+
+func validateCoordinates(latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if longitude < -180 || longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+func haversineMeters(from, to models.LocationPoint) float64 {
+	lat1 := from.Latitude * math.Pi / 180
+	lat2 := to.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// interpolatePoint linearly interpolates between two points, which is accurate enough for the short segments of a route
+func interpolatePoint(from, to models.LocationPoint, fraction float64) models.LocationPoint {
+	return models.LocationPoint{
+		Latitude:  from.Latitude + (to.Latitude-from.Latitude)*fraction,
+		Longitude: from.Longitude + (to.Longitude-from.Longitude)*fraction,
+	}
+}
+
+// ParseLocationRoute extracts the route points from a GPX or KML document.
+// For GPX track and route points are used, falling back to waypoints if the document has neither.
+// For KML all `coordinates` elements are used in document order.
+func ParseLocationRoute(document []byte) ([]models.LocationPoint, error) {
+	if len(document) > maxRouteDocumentBytes {
+		return nil, fmt.Errorf("route document exceeds %d bytes", maxRouteDocumentBytes)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	var routePoints, waypoints []models.LocationPoint
+	inCoordinates := false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse route document - %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(element.Name.Local) {
+			case "trkpt", "rtept":
+				point, err := gpxPoint(element)
+				if err != nil {
+					return nil, err
+				}
+				routePoints = append(routePoints, point)
+			case "wpt":
+				point, err := gpxPoint(element)
+				if err != nil {
+					return nil, err
+				}
+				waypoints = append(waypoints, point)
+			case "coordinates":
+				inCoordinates = true
+			}
+		case xml.EndElement:
+			if strings.ToLower(element.Name.Local) == "coordinates" {
+				inCoordinates = false
+			}
+		case xml.CharData:
+			if !inCoordinates {
+				continue
+			}
+			points, err := kmlCoordinates(string(element))
+			if err != nil {
+				return nil, err
+			}
+			routePoints = append(routePoints, points...)
+		}
+	}
+
+	if len(routePoints) == 0 {
+		routePoints = waypoints
+	}
+	if len(routePoints) == 0 {
+		return nil, fmt.Errorf("route document has no GPX points or KML coordinates")
+	}
+	return routePoints, nil
+}
+
+func gpxPoint(element xml.StartElement) (models.LocationPoint, error) {
+	var point models.LocationPoint
+	var hasLat, hasLon bool
+	for _, attr := range element.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "lat":
+			point.Latitude, err = strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+			hasLat = true
+		case "lon":
+			point.Longitude, err = strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+			hasLon = true
+		}
+		if err != nil {
+			return point, fmt.Errorf("invalid GPX `%s` coordinate `%s`", attr.Name.Local, attr.Value)
+		}
+	}
+	if !hasLat || !hasLon {
+		return point, fmt.Errorf("GPX `%s` element is missing lat or lon", element.Name.Local)
+	}
+	return point, nil
+}
+
+// kmlCoordinates parses the whitespace separated `lon,lat[,alt]` tuples of a KML coordinates element
+func kmlCoordinates(value string) ([]models.LocationPoint, error) {
+	var points []models.LocationPoint
+	for _, tuple := range strings.Fields(value) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid KML coordinate `%s`", tuple)
+		}
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KML coordinate `%s`", tuple)
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KML coordinate `%s`", tuple)
+		}
+		points = append(points, models.LocationPoint{Latitude: lat, Longitude: lon})
+	}
+	return points, nil
+}
@@ -0,0 +1,258 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeLocationDevice records the locations set on it, setDelay slows down the route replay updates
+type fakeLocationDevice struct {
+	PlatformDevice
+	udid     string
+	ctx      context.Context
+	setDelay time.Duration
+
+	mu     sync.Mutex
+	events []string
+	points []models.LocationPoint
+}
+
+func newFakeLocationDevice(t *testing.T, udid string) *fakeLocationDevice {
+	t.Helper()
+	t.Cleanup(func() {
+		locationSimulationsMu.Lock()
+		delete(locationSimulations, udid)
+		locationSimulationsMu.Unlock()
+	})
+	return &fakeLocationDevice{udid: udid, ctx: context.Background()}
+}
+
+func (d *fakeLocationDevice) GetUDID() string             { return d.udid }
+func (d *fakeLocationDevice) GetContext() context.Context { return d.ctx }
+func (d *fakeLocationDevice) GetLogger() models.CustomLogger {
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	return &logger.CustomLogger{Logger: discard}
+}
+
+func (d *fakeLocationDevice) SetLocation(latitude, longitude float64) error {
+	d.mu.Lock()
+	replaying := len(d.points) > 0
+	d.mu.Unlock()
+	if replaying {
+		time.Sleep(d.setDelay)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, "set")
+	d.points = append(d.points, models.LocationPoint{Latitude: latitude, Longitude: longitude})
+	return nil
+}
+
+func (d *fakeLocationDevice) ResetLocation() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, "reset")
+	return nil
+}
+
+func (d *fakeLocationDevice) recorded() ([]string, []models.LocationPoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.events...), append([]models.LocationPoint(nil), d.points...)
+}
+
+func useRouteUpdateInterval(t *testing.T, interval time.Duration) {
+	t.Helper()
+	previous := routeUpdateInterval
+	routeUpdateInterval = interval
+	t.Cleanup(func() { routeUpdateInterval = previous })
+}
+
+func TestParseLocationRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []models.LocationPoint
+		wantErr  bool
+	}{
+		{
+			name: "GPX track points",
+			document: `<gpx><trk><trkseg>
+				<trkpt lat="42.69" lon="23.32"><ele>550</ele></trkpt>
+				<trkpt lat=" 42.70 " lon="23.33"/>
+			</trkseg></trk></gpx>`,
+			want: []models.LocationPoint{{Latitude: 42.69, Longitude: 23.32}, {Latitude: 42.70, Longitude: 23.33}},
+		},
+		{
+			name:     "GPX route points",
+			document: `<gpx><rte><rtept lat="1" lon="2"/><rtept lat="3" lon="4"/></rte></gpx>`,
+			want:     []models.LocationPoint{{Latitude: 1, Longitude: 2}, {Latitude: 3, Longitude: 4}},
+		},
+		{
+			name:     "GPX waypoints are ignored when there are track points",
+			document: `<gpx><wpt lat="9" lon="9"/><trk><trkseg><trkpt lat="1" lon="2"/></trkseg></trk></gpx>`,
+			want:     []models.LocationPoint{{Latitude: 1, Longitude: 2}},
+		},
+		{
+			name:     "GPX falls back to waypoints",
+			document: `<gpx><wpt lat="5" lon="6"/><wpt lat="7" lon="8"/></gpx>`,
+			want:     []models.LocationPoint{{Latitude: 5, Longitude: 6}, {Latitude: 7, Longitude: 8}},
+		},
+		{
+			name: "KML coordinates in document order",
+			document: `<kml><Document>
+				<Placemark><LineString><coordinates>23.32,42.69,0 23.33,42.70</coordinates></LineString></Placemark>
+				<Placemark><Point><coordinates>
+					23.34,42.71,10
+				</coordinates></Point></Placemark>
+			</Document></kml>`,
+			want: []models.LocationPoint{{Latitude: 42.69, Longitude: 23.32}, {Latitude: 42.70, Longitude: 23.33}, {Latitude: 42.71, Longitude: 23.34}},
+		},
+		{name: "malformed XML", document: `<gpx><trk><trkpt lat="1" lon="2"></gpx>`, wantErr: true},
+		{name: "invalid GPX coordinate", document: `<gpx><trkpt lat="north" lon="2"/></gpx>`, wantErr: true},
+		{name: "GPX point without lon", document: `<gpx><trkpt lat="1"/></gpx>`, wantErr: true},
+		{name: "invalid KML tuple", document: `<kml><coordinates>23.32</coordinates></kml>`, wantErr: true},
+		{name: "invalid KML number", document: `<kml><coordinates>east,42.69</coordinates></kml>`, wantErr: true},
+		{name: "empty GPX route", document: `<gpx><trk><trkseg></trkseg></trk></gpx>`, wantErr: true},
+		{name: "empty KML coordinates", document: `<kml><coordinates>  </coordinates></kml>`, wantErr: true},
+		{name: "empty document", document: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLocationRoute([]byte(tt.document))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseLocationRoute() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLocationRoute() error = %s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseLocationRoute() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseLocationRoute()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHaversineMeters(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to models.LocationPoint
+		want     float64
+	}{
+		{"same point", models.LocationPoint{Latitude: 42.69, Longitude: 23.32}, models.LocationPoint{Latitude: 42.69, Longitude: 23.32}, 0},
+		{"one degree of latitude", models.LocationPoint{Latitude: 0, Longitude: 0}, models.LocationPoint{Latitude: 1, Longitude: 0}, 111195},
+		{"one degree of longitude on the equator", models.LocationPoint{Latitude: 0, Longitude: 0}, models.LocationPoint{Latitude: 0, Longitude: 1}, 111195},
+		{"one degree of longitude at 60 degrees", models.LocationPoint{Latitude: 60, Longitude: 0}, models.LocationPoint{Latitude: 60, Longitude: 1}, 55597},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := haversineMeters(tt.from, tt.to); math.Abs(got-tt.want) > 1 {
+				t.Errorf("haversineMeters() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulateLocationRoute_ReplaysAtSpeed(t *testing.T) {
+	useRouteUpdateInterval(t, 10*time.Millisecond)
+	dev := newFakeLocationDevice(t, "location-replay")
+
+	// The segment is ~111m long and every update moves a quarter of it
+	points := []models.LocationPoint{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 0.001}}
+	segment := haversineMeters(points[0], points[1])
+	speedKmh := segment / 4 / routeUpdateInterval.Seconds() * 3600 / 1000
+
+	start := time.Now()
+	if err := SimulateLocationRoute(dev, points, speedKmh, false); err != nil {
+		t.Fatal(err)
+	}
+	locationSimulationsMu.Lock()
+	sim := locationSimulations[dev.udid]
+	locationSimulationsMu.Unlock()
+
+	select {
+	case <-sim.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the route replay did not finish")
+	}
+	if elapsed := time.Since(start); elapsed < 4*routeUpdateInterval {
+		t.Errorf("the route was replayed in %s, expected at least 4 updates of %s", elapsed, routeUpdateInterval)
+	}
+
+	_, got := dev.recorded()
+	want := []float64{0, 0.00025, 0.0005, 0.00075, 0.001}
+	if len(got) != len(want) {
+		t.Fatalf("set locations = %v, want longitudes %v", got, want)
+	}
+	for i, longitude := range want {
+		if math.Abs(got[i].Longitude-longitude) > 1e-7 || got[i].Latitude != 0 {
+			t.Errorf("set location %d = %v, want longitude %f", i, got[i], longitude)
+		}
+	}
+
+	status := GetLocationSimulationStatus(dev.udid)
+	if !status.Active || !status.Route || *status.Current != points[1] {
+		t.Errorf("status after the route ended = %+v", status)
+	}
+	locationSimulationsMu.Lock()
+	cancelled := sim.cancel == nil
+	locationSimulationsMu.Unlock()
+	if !cancelled {
+		t.Error("the replay context was not cancelled when the route ended")
+	}
+}
+
+func TestStopLocationSimulation_WaitsForReplay(t *testing.T) {
+	useRouteUpdateInterval(t, 5*time.Millisecond)
+	dev := newFakeLocationDevice(t, "location-stop")
+	dev.setDelay = 30 * time.Millisecond
+
+	points := []models.LocationPoint{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}
+	if err := SimulateLocationRoute(dev, points, 1000, true); err != nil {
+		t.Fatal(err)
+	}
+	// Stop while a replay update is setting the location
+	time.Sleep(20 * time.Millisecond)
+	if err := StopLocationSimulation(dev); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * dev.setDelay)
+
+	events, _ := dev.recorded()
+	if len(events) == 0 || events[len(events)-1] != "reset" {
+		t.Errorf("events = %v, the location must be reset after the last route update", events)
+	}
+	if status := GetLocationSimulationStatus(dev.udid); status.Active {
+		t.Errorf("status after stop = %+v, want inactive", status)
+	}
+}
@@ -0,0 +1,453 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/mockdevice"
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+	"GADS/provider/providerutil"
+	"GADS/provider/store"
+)
+
+const (
+	mockScreenWidth  = 720
+	mockScreenHeight = 1280
+	// mockRebootDuration is how long a rebooting mock device is reported as disconnected
+	mockRebootDuration = 5 * time.Second
+)
+
+// MockDevice is a fake remote controllable device for local development and end-to-end tests.
+// It streams a synthetic screen, logs the remote control commands, fakes app management
+// and serves a stub Appium server, see the mockdevice package.
+type MockDevice struct {
+	RuntimeState
+	StreamPort  string // host port of the JPEG websocket stream, like the Android stream
+	ControlPort string // host port of the control server that accepts the Android remote server commands
+
+	screen  *mockdevice.Screen
+	servers []*http.Server
+
+	appsMu      sync.Mutex
+	apps        map[string]models.DeviceApp
+	runningApps []string
+	rebootUntil time.Time
+}
+
+func (d *MockDevice) GetStreamPort() string  { return d.StreamPort }
+func (d *MockDevice) GetControlPort() string { return d.ControlPort }
+
+// mockDeviceUDIDs are the UDIDs of the `--mock-devices` devices of the provider
+func mockDeviceUDIDs() []string {
+	udids := make([]string, 0, config.Local.MockDevices)
+	for i := 1; i <= config.Local.MockDevices; i++ {
+		udids = append(udids, mockdevice.UDID(config.ProviderConfig.Nickname, i))
+	}
+	return udids
+}
+
+// registerMockDevices adds the mock devices that are not registered yet to the provider devices.
+// Registered mock devices are left as they are so changes made on the hub are kept.
+func registerMockDevices() {
+	if config.Local.MockDevices <= 0 {
+		return
+	}
+
+	registered, err := store.GlobalStore.GetProviderDevices(config.ProviderConfig.Nickname)
+	if err != nil {
+		logger.ProviderLogger.LogError("mock_devices", fmt.Sprintf("Failed to get the provider devices, mock devices are not registered - %s", err))
+		return
+	}
+
+	for i, udid := range mockDeviceUDIDs() {
+		if slices.ContainsFunc(registered, func(device models.DBDevice) bool { return device.UDID == udid }) {
+			continue
+		}
+		device := &models.DBDevice{
+			UDID:         udid,
+			OS:           "mock",
+			Name:         fmt.Sprintf("Mock %d", i+1),
+			OSVersion:    "1.0.0",
+			Provider:     config.ProviderConfig.Nickname,
+			Usage:        "enabled",
+			ScreenWidth:  strconv.Itoa(mockScreenWidth),
+			ScreenHeight: strconv.Itoa(mockScreenHeight),
+			DeviceType:   "emulator",
+			StreamType:   models.MJPEGStreamTypeId,
+		}
+		if err := store.GlobalStore.AddOrUpdateDevice(device); err != nil {
+			logger.ProviderLogger.LogError("mock_devices", fmt.Sprintf("Failed to register mock device `%s` - %s", udid, err))
+			continue
+		}
+		logger.ProviderLogger.LogInfo("mock_devices", fmt.Sprintf("Registered mock device `%s`", udid))
+	}
+}
+
+// getConnectedDevicesMock returns the mock devices, rebooting ones are reported as disconnected for a while
+func getConnectedDevicesMock() []string {
+	var connectedDevices []string
+	for _, udid := range mockDeviceUDIDs() {
+		if platDev, ok := DevManager.Get(udid); ok {
+			if mockDev, ok := platDev.(*MockDevice); ok && mockDev.isRebooting() {
+				continue
+			}
+		}
+		connectedDevices = append(connectedDevices, udid)
+	}
+	return connectedDevices
+}
+
+// Setup starts the mock device services and the stub Appium server.
+func (d *MockDevice) Setup() (retErr error) {
+	d.SetupMutex.Lock()
+	defer d.SetupMutex.Unlock()
+
+	if d.inSetupBackoff() {
+		return nil
+	}
+	defer func() { d.updateSetupBackoff(retErr) }()
+
+	d.SetProviderState("preparing")
+	logger.ProviderLogger.LogInfo("mock_device_setup", fmt.Sprintf("Running setup for mock device `%v`", d.GetUDID()))
+
+	d.SetHardwareModel("GADS Mock")
+	width, widthErr := strconv.Atoi(d.DBDevice.ScreenWidth)
+	height, heightErr := strconv.Atoi(d.DBDevice.ScreenHeight)
+	if widthErr != nil || heightErr != nil || width <= 0 || height <= 0 {
+		width, height = mockScreenWidth, mockScreenHeight
+		d.DBDevice.ScreenWidth = strconv.Itoa(width)
+		d.DBDevice.ScreenHeight = strconv.Itoa(height)
+	}
+	d.screen = mockdevice.NewScreen(width, height)
+
+	if err := d.setupStep("allocate free host ports", d.allocatePorts); err != nil {
+		return err
+	}
+	if err := d.setupStep("start mock control and stream servers", d.startServices); err != nil {
+		return err
+	}
+	if err := d.setupStep("apply device stream settings", d.ApplyStreamSettings); err != nil {
+		return err
+	}
+	if err := d.setupStep("start mock Appium server", d.startAppium); err != nil {
+		return err
+	}
+
+	d.SetProviderState("live")
+	return nil
+}
+
+func (d *MockDevice) allocatePorts() error {
+	streamPort, err := providerutil.AllocatePort(config.PortStream, d.GetUDID())
+	if err != nil {
+		return err
+	}
+	d.StreamPort = streamPort
+
+	controlPort, err := providerutil.AllocatePort(config.PortRemoteServer, d.GetUDID())
+	if err != nil {
+		return err
+	}
+	d.ControlPort = controlPort
+	return nil
+}
+
+func (d *MockDevice) startServices() error {
+	controller := mockdevice.NewController(d.screen)
+	controller.OnCommand = func(command mockdevice.Command) {
+		params, _ := json.Marshal(command.Params)
+		d.Logger.LogInfo("mock_device_command", fmt.Sprintf("Received `%s` command with params %s", command.Name, params))
+	}
+	if err := d.serve(d.ControlPort, controller); err != nil {
+		return err
+	}
+
+	stream := mockdevice.StreamHandler(d.screen, d.GetStreamTargetFPS, d.GetStreamJpegQuality)
+	return d.serve(d.StreamPort, stream)
+}
+
+// startAppium serves the stub Appium server on the Appium port, it updates the session state
+// of the device like the GADS Appium plugin does for real Appium servers
+func (d *MockDevice) startAppium() error {
+	appiumPort, err := providerutil.AllocatePort(config.PortAppium, d.GetUDID())
+	if err != nil {
+		return err
+	}
+	d.SetAppiumPort(appiumPort)
+
+	var defaultCaps map[string]any
+	capsJSON, _ := json.Marshal(d.AppiumCapabilities())
+	json.Unmarshal(capsJSON, &defaultCaps)
+
+	appium := mockdevice.NewAppiumServer(defaultCaps, d.screen)
+	appium.OnSessionCreated = func(sessionID string) {
+		d.SetAppiumLastPingTS(time.Now().UnixMilli())
+		d.SetHasAppiumSession(true)
+		d.SetAppiumSessionID(sessionID)
+	}
+	appium.OnSessionDeleted = func(sessionID string) {
+		d.SetAppiumLastPingTS(time.Now().UnixMilli())
+		d.SetHasAppiumSession(false)
+		d.SetAppiumSessionID("")
+	}
+	if err := d.serve(appiumPort, appium); err != nil {
+		return err
+	}
+
+	d.SetAppiumLastPingTS(time.Now().UnixMilli())
+	d.SetAppiumUp(true)
+	return nil
+}
+
+// serve starts a mock service on the host port, it is stopped when the device context is cancelled
+func (d *MockDevice) serve(port string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", "localhost:"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s - %w", port, err)
+	}
+	server := &http.Server{Handler: handler}
+	d.servers = append(d.servers, server)
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.Reset(fmt.Sprintf("Mock service on port %s stopped - %s", port, err))
+		}
+	}()
+	go func() {
+		<-d.Context.Done()
+		server.Close()
+	}()
+	return nil
+}
+
+// AppiumCapabilities returns the capabilities of the stub Appium server.
+func (d *MockDevice) AppiumCapabilities() models.AppiumServerCapabilities {
+	return models.AppiumServerCapabilities{
+		UDID:            d.GetUDID(),
+		AutomationName:  "mock",
+		PlatformName:    "mock",
+		PlatformVersion: d.DBDevice.OSVersion,
+		DeviceName:      d.DBDevice.Name,
+	}
+}
+
+// Reset overrides RuntimeState.Reset to stop the mock services and free their ports.
+func (d *MockDevice) Reset(reason string) {
+	if d.ResetBase(reason) {
+		for _, server := range d.servers {
+			server.Close()
+		}
+		d.servers = nil
+		d.SetAppiumUp(false)
+		d.SetHasAppiumSession(false)
+		d.SetAppiumSessionID("")
+		providerutil.ReleasePort(d.StreamPort, d.ControlPort)
+	}
+}
+
+// Screenshot returns the current frame of the synthetic screen as a JPEG.
+func (d *MockDevice) Screenshot() ([]byte, error) {
+	if d.screen == nil {
+		return nil, fmt.Errorf("mock device `%s` is not set up", d.GetUDID())
+	}
+	return d.screen.Frame(0)
+}
+
+// Reboot disconnects the mock device for a few seconds.
+func (d *MockDevice) Reboot() error {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	d.rebootUntil = time.Now().Add(mockRebootDuration)
+	d.runningApps = nil
+	return nil
+}
+
+func (d *MockDevice) isRebooting() bool {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	return time.Now().Before(d.rebootUntil)
+}
+
+// GetScreenSize returns the configured size of the synthetic screen.
+func (d *MockDevice) GetScreenSize() (width, height string, err error) {
+	return d.DBDevice.ScreenWidth, d.DBDevice.ScreenHeight, nil
+}
+
+// GetHardwareModel returns the hardware model string.
+func (d *MockDevice) GetHardwareModel() (string, error) {
+	return d.HardwareModel, nil
+}
+
+// GetCurrentRotation returns the rotation set with ChangeRotation, `portrait` by default.
+func (d *MockDevice) GetCurrentRotation() (string, error) {
+	if d.CurrentRotation == "" {
+		return "portrait", nil
+	}
+	return d.CurrentRotation, nil
+}
+
+// ChangeRotation only remembers the rotation, the synthetic screen is not rotated.
+func (d *MockDevice) ChangeRotation(rotation string) error {
+	if rotation != "portrait" && rotation != "landscape" {
+		return fmt.Errorf("invalid rotation `%s`, expected `portrait` or `landscape`", rotation)
+	}
+	d.SetCurrentRotation(rotation)
+	return nil
+}
+
+// ApplyStreamSettings applies stream settings from DB to the device runtime state.
+func (d *MockDevice) ApplyStreamSettings() error {
+	return applyDeviceStreamSettings(d)
+}
+
+// UpdateStreamSettingsOnDevice is a no-op, the mock stream reads the settings for every frame.
+func (d *MockDevice) UpdateStreamSettingsOnDevice() error {
+	return nil
+}
+
+// InstallApp fakes the installation of an app file from the provider folder,
+// the file name without its extension is used as the bundle identifier.
+func (d *MockDevice) InstallApp(appName string) error {
+	bundleID := strings.TrimSuffix(appName, filepath.Ext(appName))
+
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	if d.apps == nil {
+		d.apps = make(map[string]models.DeviceApp)
+	}
+	installTime := time.Now().UnixMilli()
+	if existing, ok := d.apps[bundleID]; ok {
+		installTime = existing.InstallTime
+	}
+	d.apps[bundleID] = models.DeviceApp{
+		AppName:          bundleID,
+		BundleIdentifier: bundleID,
+		CanUninstall:     true,
+		Version:          "1.0.0",
+		InstallTime:      installTime,
+	}
+	d.Logger.LogInfo("install_app", fmt.Sprintf("Installed mock app `%s` from `%s`", bundleID, appName))
+	return nil
+}
+
+// UninstallApp removes a fake installed app.
+func (d *MockDevice) UninstallApp(bundleID string) error {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	if _, ok := d.apps[bundleID]; !ok {
+		return fmt.Errorf("app `%s` is not installed", bundleID)
+	}
+	delete(d.apps, bundleID)
+	d.runningApps = slices.DeleteFunc(d.runningApps, func(app string) bool { return app == bundleID })
+	d.Logger.LogInfo("uninstall_app", fmt.Sprintf("Uninstalled mock app `%s`", bundleID))
+	return nil
+}
+
+// GetInstalledApps returns the fake installed apps.
+func (d *MockDevice) GetInstalledApps() ([]models.DeviceApp, error) {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	apps := make([]models.DeviceApp, 0, len(d.apps))
+	for _, app := range d.apps {
+		apps = append(apps, app)
+	}
+	slices.SortFunc(apps, func(a, b models.DeviceApp) int { return strings.Compare(a.BundleIdentifier, b.BundleIdentifier) })
+	return apps, nil
+}
+
+// GetInstalledAppBundleIDs returns bundle identifiers of the fake installed apps.
+func (d *MockDevice) GetInstalledAppBundleIDs() []string {
+	apps, _ := d.GetInstalledApps()
+	ids := make([]string, 0, len(apps))
+	for _, app := range apps {
+		ids = append(ids, app.BundleIdentifier)
+	}
+	return ids
+}
+
+// LaunchApp marks a fake installed app as running.
+func (d *MockDevice) LaunchApp(bundleID string) error {
+	if err := d.requireApp(bundleID); err != nil {
+		return err
+	}
+	d.appsMu.Lock()
+	if !slices.Contains(d.runningApps, bundleID) {
+		d.runningApps = append(d.runningApps, bundleID)
+	}
+	d.appsMu.Unlock()
+	d.Logger.LogInfo("launch_app", fmt.Sprintf("Launched mock app `%s`", bundleID))
+	return nil
+}
+
+// KillApp marks a fake installed app as not running.
+func (d *MockDevice) KillApp(bundleID string) error {
+	if err := d.requireApp(bundleID); err != nil {
+		return err
+	}
+	d.appsMu.Lock()
+	d.runningApps = slices.DeleteFunc(d.runningApps, func(app string) bool { return app == bundleID })
+	d.appsMu.Unlock()
+	d.Logger.LogInfo("kill_app", fmt.Sprintf("Killed mock app `%s`", bundleID))
+	return nil
+}
+
+// ClearAppData only logs the command for a fake installed app.
+func (d *MockDevice) ClearAppData(bundleID string) error {
+	if err := d.requireApp(bundleID); err != nil {
+		return err
+	}
+	d.Logger.LogInfo("clear_app_data", fmt.Sprintf("Cleared the data of mock app `%s`", bundleID))
+	return nil
+}
+
+// GrantAppPermissions only logs the command for a fake installed app.
+func (d *MockDevice) GrantAppPermissions(bundleID string, permissions []string) error {
+	return d.logPermissions(bundleID, "Granted", permissions)
+}
+
+// RevokeAppPermissions only logs the command for a fake installed app.
+func (d *MockDevice) RevokeAppPermissions(bundleID string, permissions []string) error {
+	return d.logPermissions(bundleID, "Revoked", permissions)
+}
+
+// ResetAppPermissions only logs the command for a fake installed app.
+func (d *MockDevice) ResetAppPermissions(bundleID string, permissions []string) error {
+	return d.logPermissions(bundleID, "Reset", permissions)
+}
+
+func (d *MockDevice) logPermissions(bundleID, action string, permissions []string) error {
+	if err := d.requireApp(bundleID); err != nil {
+		return err
+	}
+	d.Logger.LogInfo("app_permissions", fmt.Sprintf("%s permissions %v of mock app `%s`", action, permissions, bundleID))
+	return nil
+}
+
+func (d *MockDevice) requireApp(bundleID string) error {
+	d.appsMu.Lock()
+	defer d.appsMu.Unlock()
+	if _, ok := d.apps[bundleID]; !ok {
+		return fmt.Errorf("app `%s` is not installed", bundleID)
+	}
+	return nil
+}
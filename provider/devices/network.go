@@ -0,0 +1,443 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/providerutil"
+)
+
+const (
+	shapingChunkSize      = 16 * 1024
+	shapingDialTimeout    = 30 * time.Second
+	retransmissionTimeout = 200 * time.Millisecond
+)
+
+// NetworkShaper is implemented by devices that can route their HTTP/HTTPS traffic through a proxy on the provider host.
+type NetworkShaper interface {
+	PlatformDevice
+
+	SetHTTPProxy(port string) error
+	ClearHTTPProxy(port string) error
+	// ShapingProxyHost is the address the shaping proxy of the device listens on, empty for all interfaces
+	ShapingProxyHost() string
+	// AllowShapingClient reports whether a proxy client connection comes from the device
+	AllowShapingClient(addr net.Addr) bool
+}
+
+// NetworkProfiles are the predefined network conditions that can be applied by name
+var NetworkProfiles = map[string]models.NetworkProfile{
+	"edge":       {Name: "edge", LatencyMs: 650, DownloadKbps: 240, UploadKbps: 200},
+	"3g":         {Name: "3g", LatencyMs: 300, DownloadKbps: 750, UploadKbps: 250},
+	"4g":         {Name: "4g", LatencyMs: 60, DownloadKbps: 12000, UploadKbps: 6000},
+	"lossy-wifi": {Name: "lossy-wifi", LatencyMs: 40, DownloadKbps: 10000, UploadKbps: 5000, PacketLoss: 5},
+	"offline":    {Name: "offline", Offline: true},
+}
+
+type networkShaping struct {
+	mu       sync.Mutex
+	allow    func(addr net.Addr) bool
+	listener net.Listener
+	port     string
+	profile  models.NetworkProfile
+	conns    map[net.Conn]struct{}
+	capture  *networkCapture
+}
+
+var (
+	networkShapings   = make(map[string]*networkShaping)
+	networkShapingsMu sync.Mutex
+)
+
+// ResolveNetworkProfile returns the predefined profile with the provided name or validates the custom one.
+func ResolveNetworkProfile(name string, custom *models.NetworkProfile) (models.NetworkProfile, error) {
+	name = strings.ToLower(name)
+	if name == "custom" || (name == "" && custom != nil) {
+		if custom == nil {
+			return models.NetworkProfile{}, fmt.Errorf("custom network profile values are required")
+		}
+		if custom.LatencyMs < 0 || custom.DownloadKbps < 0 || custom.UploadKbps < 0 {
+			return models.NetworkProfile{}, fmt.Errorf("latency and bandwidth values cannot be negative")
+		}
+		if custom.PacketLoss < 0 || custom.PacketLoss > 100 {
+			return models.NetworkProfile{}, fmt.Errorf("packet loss must be between 0 and 100")
+		}
+		profile := *custom
+		profile.Name = "custom"
+		return profile, nil
+	}
+
+	profile, ok := NetworkProfiles[name]
+	if !ok {
+		return models.NetworkProfile{}, fmt.Errorf("unknown network profile `%s`", name)
+	}
+	return profile, nil
+}
+
+// ApplyNetworkProfile starts the shaping proxy for the device if it is not running and applies the profile to it.
+// Changing the profile of a running proxy takes effect immediately, including for already open connections.
+func ApplyNetworkProfile(dev NetworkShaper, profile models.NetworkProfile) error {
+	networkShapingsMu.Lock()
+	defer networkShapingsMu.Unlock()
+
+	if shaping, ok := networkShapings[dev.GetUDID()]; ok {
+		shaping.setProfile(profile)
+		return nil
+	}
+
+	_, err := startNetworkShapingLocked(dev, profile)
+	return err
+}
+
+// startNetworkShapingLocked starts the proxy and points the device to it, networkShapingsMu must be held
+func startNetworkShapingLocked(dev NetworkShaper, profile models.NetworkProfile) (*networkShaping, error) {
+	port, err := providerutil.AllocatePort(config.PortNetworkProxy, dev.GetUDID())
+	if err != nil {
+		return nil, fmt.Errorf("failed allocating port for the network shaping proxy - %w", err)
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(dev.ShapingProxyHost(), port))
+	if err != nil {
+		releaseHostPort(port)
+		return nil, fmt.Errorf("failed starting the network shaping proxy on port %s - %w", port, err)
+	}
+
+	shaping := &networkShaping{
+		allow:    dev.AllowShapingClient,
+		listener: listener,
+		port:     port,
+		profile:  profile,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go shaping.serve()
+
+	if err := dev.SetHTTPProxy(port); err != nil {
+		shaping.close()
+		releaseHostPort(port)
+		return nil, err
+	}
+
+	networkShapings[dev.GetUDID()] = shaping
+	go stopShapingOnDeviceReset(dev, shaping)
+	return shaping, nil
+}
+
+// StopNetworkShaping removes the proxy from the device and stops the shaping proxy.
+func StopNetworkShaping(dev NetworkShaper) error {
+	networkShapingsMu.Lock()
+	shaping, ok := networkShapings[dev.GetUDID()]
+	delete(networkShapings, dev.GetUDID())
+	networkShapingsMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	err := dev.ClearHTTPProxy(shaping.port)
+	shaping.close()
+	shaping.finishCapture()
+	releaseHostPort(shaping.port)
+	return err
+}
+
+// GetNetworkShapingStatus returns the active network profile for the device.
+func GetNetworkShapingStatus(udid string) models.NetworkShapingStatus {
+	networkShapingsMu.Lock()
+	shaping, ok := networkShapings[udid]
+	networkShapingsMu.Unlock()
+	if !ok {
+		return models.NetworkShapingStatus{}
+	}
+
+	profile := shaping.currentProfile()
+	return models.NetworkShapingStatus{Active: true, ProxyPort: shaping.port, Profile: &profile}
+}
+
+// stopShapingOnDeviceReset closes the proxy when the device is reset, the device proxy itself is cleared on the next setup
+func stopShapingOnDeviceReset(dev NetworkShaper, shaping *networkShaping) {
+	<-dev.GetContext().Done()
+
+	networkShapingsMu.Lock()
+	if networkShapings[dev.GetUDID()] != shaping {
+		networkShapingsMu.Unlock()
+		return
+	}
+	delete(networkShapings, dev.GetUDID())
+	networkShapingsMu.Unlock()
+
+	shaping.close()
+	shaping.finishCapture()
+	releaseHostPort(shaping.port)
+}
+
+func releaseHostPort(port string) {
+	providerutil.ReleasePort(port)
+}
+
+func (s *networkShaping) setProfile(profile models.NetworkProfile) {
+	s.mu.Lock()
+	s.profile = profile
+	s.mu.Unlock()
+}
+
+func (s *networkShaping) currentProfile() models.NetworkProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile
+}
+
+// finishCapture stores the active capture when the proxy is stopped before the capture
+func (s *networkShaping) finishCapture() {
+	s.mu.Lock()
+	capture := s.capture
+	s.capture = nil
+	s.mu.Unlock()
+	if capture != nil {
+		capture.save()
+	}
+}
+
+func (s *networkShaping) close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = make(map[net.Conn]struct{})
+}
+
+func (s *networkShaping) track(conn net.Conn) {
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *networkShaping) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *networkShaping) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		if !s.allow(conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle serves a single client connection of the forward proxy.
+// HTTPS is tunneled through CONNECT, plain HTTP requests are forwarded one request per connection.
+func (s *networkShaping) handle(clientConn net.Conn) {
+	s.track(clientConn)
+	defer s.untrack(clientConn)
+
+	profile := s.currentProfile()
+	if profile.Offline {
+		return
+	}
+
+	clientReader := bufio.NewReader(clientConn)
+	req, err := http.ReadRequest(clientReader)
+	if err != nil {
+		return
+	}
+
+	targetHost := req.Host
+	if req.URL.Host != "" {
+		targetHost = req.URL.Host
+	}
+	if _, _, err := net.SplitHostPort(targetHost); err != nil {
+		if req.Method == http.MethodConnect {
+			targetHost = net.JoinHostPort(targetHost, "443")
+		} else {
+			targetHost = net.JoinHostPort(targetHost, "80")
+		}
+	}
+
+	s.mu.Lock()
+	capture := s.capture
+	s.mu.Unlock()
+	if capture != nil {
+		if req.Method != http.MethodConnect {
+			s.handleCaptured(clientConn, req, capture)
+			return
+		}
+		if capture.info.InterceptTLS {
+			s.handleIntercepted(clientConn, targetHost, capture)
+			return
+		}
+	}
+
+	// Connection setup costs a full round trip
+	started := time.Now()
+	time.Sleep(time.Duration(profile.LatencyMs) * time.Millisecond)
+
+	targetConn, err := dialShapingTarget(context.Background(), "tcp", targetHost)
+	if err != nil {
+		if capture != nil {
+			capture.recordTunnel(targetHost, started, http.StatusBadGateway)
+		}
+		fmt.Fprintf(clientConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		return
+	}
+	s.track(targetConn)
+	defer s.untrack(targetConn)
+
+	if req.Method == http.MethodConnect {
+		if capture != nil {
+			capture.recordTunnel(targetHost, started, http.StatusOK)
+		}
+		if _, err := io.WriteString(clientConn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			return
+		}
+	} else {
+		req.Header.Del("Proxy-Connection")
+		req.Header.Del("Proxy-Authorization")
+		req.Close = true
+		if err := req.Write(targetConn); err != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		s.copyShaped(targetConn, clientReader, false)
+		done <- struct{}{}
+	}()
+	go func() {
+		s.copyShaped(clientConn, targetConn, true)
+		done <- struct{}{}
+	}()
+	// Once either side is done tear down both so the other copy returns
+	<-done
+}
+
+// dialShapingTarget dials a proxy target after resolving it. Loopback, link-local, unspecified and the provider host
+// interface addresses are refused so clients of the proxy cannot reach the services of the provider host or its link-local neighbours.
+func dialShapingTarget(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, shapingDialTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for `%s`", host)
+	}
+	for _, addr := range addrs {
+		if !allowedShapingTarget(addr.IP) {
+			return nil, fmt.Errorf("proxying to `%s` (%s) is not allowed", host, addr.IP)
+		}
+	}
+
+	var dialer net.Dialer
+	// Dial the checked address, resolving again could return a different one
+	return dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0].IP.String(), port))
+}
+
+func allowedShapingTarget(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	return !isHostAddress(ip)
+}
+
+// hostInterfaceAddrs returns the addresses of the provider host interfaces
+var hostInterfaceAddrs = net.InterfaceAddrs
+
+// isHostAddress reports whether the IP is assigned to an interface of the provider host, e.g. its LAN address.
+// If the interfaces cannot be listed every address is treated as a host address.
+func isHostAddress(ip net.IP) bool {
+	addrs, err := hostInterfaceAddrs()
+	if err != nil {
+		return true
+	}
+	for _, addr := range addrs {
+		switch hostAddr := addr.(type) {
+		case *net.IPNet:
+			if hostAddr.IP.Equal(ip) {
+				return true
+			}
+		case *net.IPAddr:
+			if hostAddr.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyShaped copies data in chunks applying the latency, bandwidth and loss of the current profile to each chunk
+func (s *networkShaping) copyShaped(dst io.Writer, src io.Reader, download bool) {
+	buf := make([]byte, shapingChunkSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			delay, offline := s.chunkDelay(n, download)
+			if offline {
+				return
+			}
+			time.Sleep(delay)
+
+			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// chunkDelay returns how long sending n bytes takes with the current profile and whether the network is offline
+func (s *networkShaping) chunkDelay(n int, download bool) (time.Duration, bool) {
+	profile := s.currentProfile()
+	if profile.Offline {
+		return 0, true
+	}
+
+	delay := time.Duration(profile.LatencyMs) * time.Millisecond / 2
+	kbps := profile.UploadKbps
+	if download {
+		kbps = profile.DownloadKbps
+	}
+	if kbps > 0 {
+		delay += time.Duration(float64(n*8) / float64(kbps*1000) * float64(time.Second))
+	}
+	if profile.PacketLoss > 0 && rand.Float64()*100 < profile.PacketLoss {
+		delay += retransmissionTimeout + time.Duration(profile.LatencyMs)*time.Millisecond
+	}
+	return delay, false
+}
@@ -0,0 +1,180 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"GADS/provider/config"
+)
+
+const (
+	gadsCACertFile = "gads-ca.pem"
+	gadsCAKeyFile  = "gads-ca-key.pem"
+	// iOS rejects TLS server certificates that are valid for more than 825 days
+	gadsLeafValidity = 397 * 24 * time.Hour
+)
+
+// CAInstaller is implemented by devices that can trust the GADS CA used for TLS interception.
+type CAInstaller interface {
+	PlatformDevice
+
+	InstallCACertificate(cert *x509.Certificate) error
+}
+
+var (
+	gadsCA      *tls.Certificate
+	gadsCAMu    sync.Mutex
+	leafCerts   = make(map[string]*tls.Certificate)
+	leafCertsMu sync.Mutex
+	// ErrCAManualInstallRequired is returned when the GADS CA was copied to the device but has to be trusted manually
+	ErrCAManualInstallRequired = errors.New("the GADS CA has to be installed manually on the device")
+)
+
+// LoadGadsCA returns the GADS CA from the provider folder, generating it on first use.
+func LoadGadsCA() (*tls.Certificate, error) {
+	gadsCAMu.Lock()
+	defer gadsCAMu.Unlock()
+	if gadsCA != nil {
+		return gadsCA, nil
+	}
+
+	certPath := filepath.Join(config.ProviderConfig.ProviderFolder, gadsCACertFile)
+	keyPath := filepath.Join(config.ProviderConfig.ProviderFolder, gadsCAKeyFile)
+
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		if err := generateGadsCA(certPath, keyPath); err != nil {
+			return nil, fmt.Errorf("failed generating GADS CA - %w", err)
+		}
+	}
+
+	ca, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading GADS CA from `%s` - %w", certPath, err)
+	}
+	ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing GADS CA - %w", err)
+	}
+	gadsCA = &ca
+	return gadsCA, nil
+}
+
+// GetGadsCACertificatePEM returns the PEM encoded GADS CA certificate so it can be installed manually.
+func GetGadsCACertificatePEM() ([]byte, error) {
+	ca, err := LoadGadsCA()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Leaf.Raw}), nil
+}
+
+func generateGadsCA(certPath, keyPath string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "GADS Network Capture CA", Organization: []string{"GADS"}},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+}
+
+// gadsLeafCertificate returns a certificate for the host signed by the GADS CA, certificates are cached per host
+func gadsLeafCertificate(host string) (*tls.Certificate, error) {
+	leafCertsMu.Lock()
+	defer leafCertsMu.Unlock()
+	if cert, ok := leafCerts[host]; ok && time.Now().Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+
+	ca, err := LoadGadsCA()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host, Organization: []string{"GADS"}},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(gadsLeafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Leaf, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{Certificate: [][]byte{der, ca.Leaf.Raw}, PrivateKey: key, Leaf: leaf}
+	leafCerts[host] = cert
+	return cert, nil
+}
+
+// androidCAFileName returns the `<subject_hash_old>.0` name Android expects for system CA certificates
+func androidCAFileName(cert *x509.Certificate) string {
+	sum := md5.Sum(cert.RawSubject)
+	hash := uint32(sum[0]) | uint32(sum[1])<<8 | uint32(sum[2])<<16 | uint32(sum[3])<<24
+	return fmt.Sprintf("%08x.0", hash)
+}
@@ -0,0 +1,550 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxCapturedBodyBytes is how much of each request and response body is recorded, the rest is only streamed
+	maxCapturedBodyBytes = 1 << 20
+	// maxCapturedEntries is how many entries a capture keeps, the oldest are dropped after that
+	maxCapturedEntries  = 2000
+	redactedHeaderValue = "[REDACTED]"
+)
+
+// defaultRedactedHeaders are always redacted from network captures, workspaces can add more
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"}
+
+var captureTransport = &http.Transport{
+	Proxy:                 nil,
+	DialContext:           dialShapingTarget,
+	TLSHandshakeTimeout:   shapingDialTimeout,
+	ResponseHeaderTimeout: 2 * time.Minute,
+	MaxIdleConnsPerHost:   4,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+type networkCapture struct {
+	mu   sync.Mutex
+	info models.NetworkCapture
+	// entries is a ring of the last maxCapturedEntries entries, next is where the next entry goes once it is full
+	entries      []models.HAREntry
+	next         int
+	redact       map[string]bool
+	startedProxy bool
+}
+
+// NetworkCapturesDir returns the folder where the HAR files of the device are stored
+func NetworkCapturesDir(udid string) string {
+	return filepath.Join(config.ProviderConfig.ProviderFolder, "device_"+udid, "har")
+}
+
+// StartNetworkCapture records the device traffic going through the shaping proxy, starting the proxy without shaping if needed.
+func StartNetworkCapture(dev NetworkShaper, interceptTLS bool, redactHeaders []string) (models.NetworkCapture, error) {
+	if interceptTLS {
+		if _, err := LoadGadsCA(); err != nil {
+			return models.NetworkCapture{}, err
+		}
+	}
+
+	networkShapingsMu.Lock()
+	defer networkShapingsMu.Unlock()
+
+	shaping, running := networkShapings[dev.GetUDID()]
+	if running {
+		shaping.mu.Lock()
+		capturing := shaping.capture != nil
+		shaping.mu.Unlock()
+		if capturing {
+			return models.NetworkCapture{}, fmt.Errorf("network capture is already running")
+		}
+	} else {
+		var err error
+		shaping, err = startNetworkShapingLocked(dev, models.NetworkProfile{Name: "none"})
+		if err != nil {
+			return models.NetworkCapture{}, err
+		}
+	}
+
+	capture := &networkCapture{
+		info: models.NetworkCapture{
+			ID:           uuid.NewString(),
+			UDID:         dev.GetUDID(),
+			SessionID:    dev.GetAppiumSessionID(),
+			InterceptTLS: interceptTLS,
+			Status:       models.NetworkCaptureStatusCapturing,
+			StartedAt:    time.Now().UnixMilli(),
+		},
+		redact:       make(map[string]bool),
+		startedProxy: !running,
+	}
+	for _, header := range append(defaultRedactedHeaders, redactHeaders...) {
+		capture.redact[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+
+	shaping.mu.Lock()
+	shaping.capture = capture
+	shaping.mu.Unlock()
+	return capture.info, nil
+}
+
+// StopNetworkCapture stops recording, stores the HAR file and stops the proxy if it was started only for the capture.
+func StopNetworkCapture(dev NetworkShaper) (models.NetworkCapture, error) {
+	networkShapingsMu.Lock()
+	shaping, ok := networkShapings[dev.GetUDID()]
+	networkShapingsMu.Unlock()
+	if !ok {
+		return models.NetworkCapture{}, fmt.Errorf("there is no active network capture")
+	}
+
+	shaping.mu.Lock()
+	capture := shaping.capture
+	shaping.capture = nil
+	profile := shaping.profile
+	shaping.mu.Unlock()
+	if capture == nil {
+		return models.NetworkCapture{}, fmt.Errorf("there is no active network capture")
+	}
+
+	info := capture.save()
+	if capture.startedProxy && profile.Name == "none" {
+		if err := StopNetworkShaping(dev); err != nil {
+			dev.GetLogger().LogWarn("network_capture", fmt.Sprintf("Failed to stop the proxy started for the network capture - %s", err))
+		}
+	}
+	return info, nil
+}
+
+// GetActiveNetworkCapture returns the running capture of the device if any
+func GetActiveNetworkCapture(udid string) (models.NetworkCapture, bool) {
+	networkShapingsMu.Lock()
+	shaping, ok := networkShapings[udid]
+	networkShapingsMu.Unlock()
+	if !ok {
+		return models.NetworkCapture{}, false
+	}
+
+	shaping.mu.Lock()
+	capture := shaping.capture
+	shaping.mu.Unlock()
+	if capture == nil {
+		return models.NetworkCapture{}, false
+	}
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	info := capture.info
+	info.EntryCount = len(capture.entries)
+	return info, true
+}
+
+// save writes the HAR file and its metadata to the device captures folder
+func (nc *networkCapture) save() models.NetworkCapture {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	nc.info.StoppedAt = time.Now().UnixMilli()
+	nc.info.EntryCount = len(nc.entries)
+	dir := NetworkCapturesDir(nc.info.UDID)
+
+	err := func() error {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+		data, err := json.Marshal(nc.harLocked())
+		if err != nil {
+			return err
+		}
+		harPath := filepath.Join(dir, nc.info.ID+".har")
+		if err := os.WriteFile(harPath, data, 0644); err != nil {
+			return err
+		}
+		nc.info.FileName = filepath.Base(harPath)
+		nc.info.SizeBytes = int64(len(data))
+		return nil
+	}()
+	if err != nil {
+		nc.info.Status = models.NetworkCaptureStatusFailed
+		nc.info.Error = err.Error()
+	} else {
+		nc.info.Status = models.NetworkCaptureStatusCompleted
+	}
+
+	if metadata, err := json.Marshal(nc.info); err == nil {
+		os.WriteFile(filepath.Join(dir, nc.info.ID+".json"), metadata, 0644)
+	}
+	nc.entries = nil
+	nc.next = 0
+	return nc.info
+}
+
+// harLocked returns the HAR of the recorded entries from the oldest, nc.mu must be held
+func (nc *networkCapture) harLocked() models.HAR {
+	entries := make([]models.HAREntry, 0, len(nc.entries))
+	entries = append(entries, nc.entries[nc.next:]...)
+	entries = append(entries, nc.entries[:nc.next]...)
+	return models.HAR{Log: models.HARLog{
+		Version: "1.2",
+		Creator: models.HARCreator{Name: "GADS", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+func (nc *networkCapture) add(entry models.HAREntry) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	if len(nc.entries) < maxCapturedEntries {
+		nc.entries = append(nc.entries, entry)
+		return
+	}
+	nc.entries[nc.next] = entry
+	nc.next = (nc.next + 1) % maxCapturedEntries
+	nc.info.DroppedEntries++
+}
+
+// recordTunnel adds an entry for a HTTPS tunnel that is not intercepted, only the host and timing are known
+func (nc *networkCapture) recordTunnel(host string, started time.Time, status int) {
+	nc.add(models.HAREntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            msSince(started),
+		Request: models.HARRequest{
+			Method:      http.MethodConnect,
+			URL:         "https://" + host,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []models.HARNameValue{},
+			Headers:     []models.HARNameValue{},
+			QueryString: []models.HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: models.HARResponse{
+			Status:      status,
+			StatusText:  http.StatusText(status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []models.HARNameValue{},
+			Headers:     []models.HARNameValue{},
+			HeadersSize: -1,
+		},
+		Timings: models.HARTimings{Wait: msSince(started)},
+		Comment: "TLS traffic not intercepted",
+	})
+}
+
+// handleCaptured forwards a single plain HTTP request and records it
+func (s *networkShaping) handleCaptured(clientConn net.Conn, req *http.Request, capture *networkCapture) {
+	if req.URL.Scheme == "" {
+		req.URL.Scheme = "http"
+	}
+	if req.URL.Host == "" {
+		req.URL.Host = req.Host
+	}
+	req.Header.Del("Proxy-Connection")
+	req.Header.Del("Proxy-Authorization")
+
+	resp, err := s.roundTripCaptured(req, capture)
+	if err != nil {
+		fmt.Fprintf(clientConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		return
+	}
+	// Write does not close the body of empty responses, the entry is recorded when it is closed
+	defer resp.Body.Close()
+	resp.Close = true
+	resp.Write(&shapedWriter{shaping: s, dst: clientConn, download: true})
+}
+
+// handleIntercepted terminates the TLS of a CONNECT tunnel with a certificate signed by the GADS CA and records the requests inside
+func (s *networkShaping) handleIntercepted(clientConn net.Conn, targetHost string, capture *networkCapture) {
+	if _, err := io.WriteString(clientConn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	hostname, _, err := net.SplitHostPort(targetHost)
+	if err != nil {
+		hostname = targetHost
+	}
+	tlsConn := tls.Server(clientConn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return gadsLeafCertificate(hello.ServerName)
+			}
+			return gadsLeafCertificate(hostname)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+	defer tlsConn.Close()
+	if err := tlsConn.Handshake(); err != nil {
+		// Most likely the device does not trust the GADS CA or the app pins its certificates
+		capture.recordTunnel(targetHost, time.Now(), http.StatusBadGateway)
+		return
+	}
+
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme = "https"
+		req.URL.Host = req.Host
+		if req.URL.Host == "" {
+			req.URL.Host = targetHost
+		}
+
+		resp, err := s.roundTripCaptured(req, capture)
+		if err != nil {
+			fmt.Fprintf(tlsConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			return
+		}
+		err = resp.Write(&shapedWriter{shaping: s, dst: tlsConn, download: true})
+		resp.Body.Close()
+		if err != nil || resp.Close || req.Close {
+			return
+		}
+	}
+}
+
+// roundTripCaptured sends the request upstream and returns the response with its body still streaming.
+// Up to maxCapturedBodyBytes of each body is kept for the capture, the entry is recorded once the response body is closed.
+func (s *networkShaping) roundTripCaptured(req *http.Request, capture *networkCapture) (*http.Response, error) {
+	started := time.Now()
+
+	reqBody := &cappedBuffer{}
+	outReq, err := http.NewRequest(req.Method, req.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		outReq.Body = io.NopCloser(io.TeeReader(req.Body, reqBody))
+	}
+	outReq.Header = req.Header.Clone()
+	outReq.Host = req.Host
+	outReq.ContentLength = req.ContentLength
+	outReq.TransferEncoding = req.TransferEncoding
+
+	profile := s.currentProfile()
+	if profile.Offline {
+		return nil, fmt.Errorf("network is offline")
+	}
+	time.Sleep(time.Duration(profile.LatencyMs) * time.Millisecond)
+
+	sent := time.Now()
+	resp, err := captureTransport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	waited := time.Now()
+
+	respBody := &cappedBuffer{}
+	resp.Body = &capturedBody{
+		ReadCloser: resp.Body,
+		buf:        respBody,
+		onClose: func() {
+			entry := models.HAREntry{
+				StartedDateTime: started.Format(time.RFC3339Nano),
+				Time:            msSince(started),
+				Request:         capture.harRequest(req, reqBody),
+				Response:        capture.harResponse(resp, respBody),
+				Timings: models.HARTimings{
+					Send:    float64(sent.Sub(started).Microseconds()) / 1000,
+					Wait:    float64(waited.Sub(sent).Microseconds()) / 1000,
+					Receive: msSince(waited),
+				},
+			}
+			if reqBody.truncated() || respBody.truncated() {
+				entry.Comment = fmt.Sprintf("Bodies are truncated to %d bytes", maxCapturedBodyBytes)
+			}
+			capture.add(entry)
+		},
+	}
+	return resp, nil
+}
+
+// cappedBuffer keeps the first maxCapturedBodyBytes written to it and counts the rest
+type cappedBuffer struct {
+	data []byte
+	size int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := maxCapturedBodyBytes - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(room, len(p))]...)
+	}
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b.size > int64(len(b.data))
+}
+
+// capturedBody copies a response body to the capture buffer while it is streamed to the client
+type capturedBody struct {
+	io.ReadCloser
+	buf     *cappedBuffer
+	once    sync.Once
+	onClose func()
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *capturedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
+
+func (nc *networkCapture) harHeaders(header http.Header) []models.HARNameValue {
+	headers := []models.HARNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			if nc.redact[http.CanonicalHeaderKey(name)] {
+				value = redactedHeaderValue
+			}
+			headers = append(headers, models.HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func (nc *networkCapture) harRequest(req *http.Request, body *cappedBuffer) models.HARRequest {
+	harReq := models.HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []models.HARNameValue{},
+		Headers:     nc.harHeaders(req.Header),
+		QueryString: []models.HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    body.size,
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, models.HARNameValue{Name: name, Value: value})
+		}
+	}
+	if !nc.redact["Cookie"] {
+		for _, cookie := range req.Cookies() {
+			harReq.Cookies = append(harReq.Cookies, models.HARNameValue{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+	if body.size > 0 {
+		text, _ := harBodyText(body.data, req.Header)
+		harReq.PostData = &models.HARPostData{MimeType: req.Header.Get("Content-Type"), Text: text}
+	}
+	return harReq
+}
+
+func (nc *networkCapture) harResponse(resp *http.Response, body *cappedBuffer) models.HARResponse {
+	harResp := models.HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []models.HARNameValue{},
+		Headers:     nc.harHeaders(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    body.size,
+	}
+	if !nc.redact["Set-Cookie"] {
+		for _, cookie := range resp.Cookies() {
+			harResp.Cookies = append(harResp.Cookies, models.HARNameValue{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+
+	text, encoding := harBodyText(body.data, resp.Header)
+	harResp.Content = models.HARContent{
+		Size:     body.size,
+		MimeType: resp.Header.Get("Content-Type"),
+		Text:     text,
+		Encoding: encoding,
+	}
+	return harResp
+}
+
+// harBodyText decompresses gzip bodies and returns text content as is and binary content base64 encoded
+func harBodyText(body []byte, header http.Header) (string, string) {
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		if gz, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			// A truncated body still decompresses up to where it was cut
+			if decoded, _ := io.ReadAll(io.LimitReader(gz, maxCapturedBodyBytes)); len(decoded) > 0 {
+				body = decoded
+			}
+		}
+	}
+	if len(body) > maxCapturedBodyBytes {
+		body = body[:maxCapturedBodyBytes]
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "text/") || strings.Contains(mediaType, "json") || strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "javascript") || mediaType == "application/x-www-form-urlencoded" {
+		return string(body), ""
+	}
+	if len(body) == 0 {
+		return "", ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+// shapedWriter applies the profile of the shaping proxy to each write
+type shapedWriter struct {
+	shaping  *networkShaping
+	dst      io.Writer
+	download bool
+}
+
+func (w *shapedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := min(written+shapingChunkSize, len(p))
+		delay, offline := w.shaping.chunkDelay(end-written, w.download)
+		if offline {
+			return written, fmt.Errorf("network is offline")
+		}
+		time.Sleep(delay)
+		n, err := w.dst.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,219 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+)
+
+func newTestCapture(redactHeaders ...string) *networkCapture {
+	capture := &networkCapture{
+		info:   models.NetworkCapture{ID: "capture-id", UDID: "test-udid", Status: models.NetworkCaptureStatusCapturing},
+		redact: make(map[string]bool),
+	}
+	for _, header := range append(defaultRedactedHeaders, redactHeaders...) {
+		capture.redact[http.CanonicalHeaderKey(header)] = true
+	}
+	return capture
+}
+
+func testHAREntry(url string) models.HAREntry {
+	return models.HAREntry{Request: models.HARRequest{Method: http.MethodGet, URL: url}}
+}
+
+func TestNetworkCaptureSave(t *testing.T) {
+	previousFolder := config.ProviderConfig.ProviderFolder
+	config.ProviderConfig.ProviderFolder = t.TempDir()
+	defer func() { config.ProviderConfig.ProviderFolder = previousFolder }()
+
+	capture := newTestCapture()
+	capture.add(testHAREntry("http://example.com/first"))
+	capture.add(testHAREntry("http://example.com/second"))
+
+	info := capture.save()
+	if info.Status != models.NetworkCaptureStatusCompleted || info.EntryCount != 2 || info.FileName != "capture-id.har" {
+		t.Fatalf("Unexpected capture info %+v", info)
+	}
+
+	data, err := os.ReadFile(filepath.Join(NetworkCapturesDir("test-udid"), info.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != info.SizeBytes {
+		t.Errorf("Expected size %d, got %d", len(data), info.SizeBytes)
+	}
+	var har models.HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("Expected a valid HAR file - %s", err)
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "GADS" || len(har.Log.Entries) != 2 || har.Log.Entries[1].Request.URL != "http://example.com/second" {
+		t.Errorf("Unexpected HAR log %+v", har.Log)
+	}
+
+	var metadata models.NetworkCapture
+	data, err = os.ReadFile(filepath.Join(NetworkCapturesDir("test-udid"), "capture-id.json"))
+	if err != nil || json.Unmarshal(data, &metadata) != nil || metadata.ID != "capture-id" {
+		t.Errorf("Expected the capture metadata to be stored, got %+v - %v", metadata, err)
+	}
+
+	// An empty capture still has an entries array
+	empty := newTestCapture()
+	empty.info.ID = "empty-id"
+	empty.save()
+	data, _ = os.ReadFile(filepath.Join(NetworkCapturesDir("test-udid"), "empty-id.har"))
+	if !bytes.Contains(data, []byte(`"entries":[]`)) {
+		t.Errorf("Expected an empty entries array, got %s", data)
+	}
+}
+
+func TestNetworkCaptureEntryRing(t *testing.T) {
+	capture := newTestCapture()
+	for i := 0; i < maxCapturedEntries+3; i++ {
+		capture.add(testHAREntry(fmt.Sprintf("http://example.com/%d", i)))
+	}
+
+	capture.mu.Lock()
+	har := capture.harLocked()
+	capture.mu.Unlock()
+	if len(har.Log.Entries) != maxCapturedEntries || capture.info.DroppedEntries != 3 {
+		t.Fatalf("Expected %d entries and 3 dropped, got %d and %d", maxCapturedEntries, len(har.Log.Entries), capture.info.DroppedEntries)
+	}
+	if first := har.Log.Entries[0].Request.URL; first != "http://example.com/3" {
+		t.Errorf("Expected the oldest kept entry first, got %s", first)
+	}
+	if last := har.Log.Entries[maxCapturedEntries-1].Request.URL; last != fmt.Sprintf("http://example.com/%d", maxCapturedEntries+2) {
+		t.Errorf("Expected the newest entry last, got %s", last)
+	}
+}
+
+func harValue(values []models.HARNameValue, name string) string {
+	for _, value := range values {
+		if http.CanonicalHeaderKey(value.Name) == name {
+			return value.Value
+		}
+	}
+	return ""
+}
+
+func TestNetworkCaptureRedaction(t *testing.T) {
+	capture := newTestCapture("x-session")
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/login?user=test", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Session", "secret")
+	req.Header.Set("Content-Type", "application/json")
+	body := &cappedBuffer{}
+	body.Write([]byte(`{"user":"test"}`))
+
+	harReq := capture.harRequest(req, body)
+	for _, header := range []string{"Authorization", "Cookie", "X-Session"} {
+		if value := harValue(harReq.Headers, header); value != redactedHeaderValue {
+			t.Errorf("Expected `%s` to be redacted, got `%s`", header, value)
+		}
+	}
+	if len(harReq.Cookies) != 0 {
+		t.Errorf("Expected no cookies while Cookie is redacted, got %v", harReq.Cookies)
+	}
+	if harValue(harReq.Headers, "Content-Type") != "application/json" || harValue(harReq.QueryString, "User") != "test" {
+		t.Errorf("Expected other headers and the query to be kept, got %v and %v", harReq.Headers, harReq.QueryString)
+	}
+	if harReq.PostData == nil || harReq.PostData.Text != `{"user":"test"}` || harReq.BodySize != 15 {
+		t.Errorf("Unexpected post data %+v with size %d", harReq.PostData, harReq.BodySize)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Proto: "HTTP/1.1", Header: http.Header{}}
+	resp.Header.Set("Set-Cookie", "session=secret")
+	resp.Header.Set("Content-Type", "application/octet-stream")
+	respBody := &cappedBuffer{}
+	respBody.Write([]byte{0, 1, 2})
+
+	harResp := capture.harResponse(resp, respBody)
+	if harValue(harResp.Headers, "Set-Cookie") != redactedHeaderValue || len(harResp.Cookies) != 0 {
+		t.Errorf("Expected Set-Cookie to be redacted, got %v and %v", harResp.Headers, harResp.Cookies)
+	}
+	if harResp.Content.Encoding != "base64" || harResp.Content.Text != "AAEC" {
+		t.Errorf("Expected binary content to be base64 encoded, got %+v", harResp.Content)
+	}
+}
+
+func TestRoundTripCapturedStreams(t *testing.T) {
+	// The capture transport refuses loopback targets, the test server is on loopback
+	previousDial := captureTransport.DialContext
+	captureTransport.DialContext = (&net.Dialer{}).DialContext
+	defer func() { captureTransport.DialContext = previousDial }()
+
+	payload := bytes.Repeat([]byte("a"), 2*maxCapturedBodyBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	capture := newTestCapture()
+	req := httptest.NewRequest(http.MethodPost, server.URL+"/upload", strings.NewReader("request body"))
+	req.RequestURI = ""
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := (&networkShaping{}).roundTripCaptured(req, capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture.mu.Lock()
+	recorded := len(capture.entries)
+	capture.mu.Unlock()
+	if recorded != 0 {
+		t.Errorf("Expected the entry to be recorded once the body is closed")
+	}
+
+	received, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+	if err != nil || len(received) != len(payload) {
+		t.Fatalf("Expected the full response body to be streamed, got %d bytes - %v", len(received), err)
+	}
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	if len(capture.entries) != 1 {
+		t.Fatalf("Expected one entry, got %d", len(capture.entries))
+	}
+	entry := capture.entries[0]
+	if entry.Response.Content.Size != int64(len(payload)) || len(entry.Response.Content.Text) != maxCapturedBodyBytes || entry.Comment == "" {
+		t.Errorf("Expected the recorded body to be truncated, got size %d, text %d bytes, comment `%s`", entry.Response.Content.Size, len(entry.Response.Content.Text), entry.Comment)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != "request body" {
+		t.Errorf("Expected the request body to be recorded, got %+v", entry.Request.PostData)
+	}
+}
+
+func TestDialShapingTargetRefusesLocalAddresses(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:80", "localhost:80", "[::1]:80", "169.254.169.254:80", "0.0.0.0:80"} {
+		if conn, err := dialShapingTarget(context.Background(), "tcp", address); err == nil {
+			conn.Close()
+			t.Errorf("Expected dialing `%s` to be refused", address)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"GADS/common/models"
+)
+
+func TestResolveNetworkProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		custom  *models.NetworkProfile
+		want    models.NetworkProfile
+		wantErr bool
+	}{
+		{name: "predefined profile", profile: "3g", want: NetworkProfiles["3g"]},
+		{name: "predefined profile is case insensitive", profile: "Lossy-WiFi", want: NetworkProfiles["lossy-wifi"]},
+		{name: "offline profile", profile: "offline", want: models.NetworkProfile{Name: "offline", Offline: true}},
+		{name: "unknown profile", profile: "5g", wantErr: true},
+		{name: "no profile", wantErr: true},
+		{
+			name:    "custom profile",
+			profile: "custom",
+			custom:  &models.NetworkProfile{Name: "mine", LatencyMs: 100, DownloadKbps: 2000, UploadKbps: 500, PacketLoss: 2.5},
+			want:    models.NetworkProfile{Name: "custom", LatencyMs: 100, DownloadKbps: 2000, UploadKbps: 500, PacketLoss: 2.5},
+		},
+		{
+			name:   "custom values without a profile name",
+			custom: &models.NetworkProfile{LatencyMs: 10},
+			want:   models.NetworkProfile{Name: "custom", LatencyMs: 10},
+		},
+		{
+			name:    "predefined profile ignores custom values",
+			profile: "edge",
+			custom:  &models.NetworkProfile{LatencyMs: 10},
+			want:    NetworkProfiles["edge"],
+		},
+		{name: "custom profile without values", profile: "custom", wantErr: true},
+		{name: "negative latency", profile: "custom", custom: &models.NetworkProfile{LatencyMs: -1}, wantErr: true},
+		{name: "negative download", profile: "custom", custom: &models.NetworkProfile{DownloadKbps: -1}, wantErr: true},
+		{name: "negative upload", profile: "custom", custom: &models.NetworkProfile{UploadKbps: -1}, wantErr: true},
+		{name: "negative packet loss", profile: "custom", custom: &models.NetworkProfile{PacketLoss: -0.1}, wantErr: true},
+		{name: "packet loss above 100", profile: "custom", custom: &models.NetworkProfile{PacketLoss: 100.1}, wantErr: true},
+		{name: "packet loss of 100", profile: "custom", custom: &models.NetworkProfile{PacketLoss: 100}, want: models.NetworkProfile{Name: "custom", PacketLoss: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveNetworkProfile(tt.profile, tt.custom)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ResolveNetworkProfile() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveNetworkProfile() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkDelay(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     models.NetworkProfile
+		bytes       int
+		download    bool
+		want        time.Duration
+		wantOffline bool
+	}{
+		{name: "unlimited", bytes: shapingChunkSize, want: 0},
+		{name: "half the latency per direction", profile: models.NetworkProfile{LatencyMs: 300}, bytes: 1, want: 150 * time.Millisecond},
+		// 1000 bytes are 8000 bits, 8 kbit/s sends them in a second
+		{name: "download throughput", profile: models.NetworkProfile{DownloadKbps: 8, UploadKbps: 80}, bytes: 1000, download: true, want: time.Second},
+		{name: "upload throughput", profile: models.NetworkProfile{DownloadKbps: 8, UploadKbps: 80}, bytes: 1000, want: 100 * time.Millisecond},
+		{name: "unlimited upload with limited download", profile: models.NetworkProfile{DownloadKbps: 8}, bytes: 1000, want: 0},
+		{name: "latency and throughput", profile: models.NetworkProfile{LatencyMs: 60, DownloadKbps: 12000}, bytes: 15000, download: true, want: 40 * time.Millisecond},
+		{name: "lost chunk is retransmitted", profile: models.NetworkProfile{LatencyMs: 100, PacketLoss: 100}, bytes: 1, want: 50*time.Millisecond + retransmissionTimeout + 100*time.Millisecond},
+		{name: "offline", profile: models.NetworkProfile{Offline: true, LatencyMs: 100}, bytes: 1, wantOffline: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shaping := &networkShaping{profile: tt.profile}
+			got, offline := shaping.chunkDelay(tt.bytes, tt.download)
+			if offline != tt.wantOffline || got != tt.want {
+				t.Errorf("chunkDelay() = %s, %v, want %s, %v", got, offline, tt.want, tt.wantOffline)
+			}
+		})
+	}
+}
+
+func TestCopyShaped_AppliesProfileChanges(t *testing.T) {
+	shaping := &networkShaping{profile: models.NetworkProfile{Offline: true}}
+	var dst bytes.Buffer
+	shaping.copyShaped(&dst, bytes.NewReader([]byte("payload")), true)
+	if dst.Len() != 0 {
+		t.Errorf("copied %q while offline", dst.String())
+	}
+
+	shaping.setProfile(models.NetworkProfile{LatencyMs: 20})
+	started := time.Now()
+	shaping.copyShaped(&dst, bytes.NewReader([]byte("payload")), true)
+	if dst.String() != "payload" {
+		t.Errorf("copied %q, want the payload", dst.String())
+	}
+	if elapsed := time.Since(started); elapsed < 10*time.Millisecond {
+		t.Errorf("copy took %s, want at least half the latency", elapsed)
+	}
+}
+
+func TestAllowedShapingTarget(t *testing.T) {
+	previous := hostInterfaceAddrs
+	defer func() { hostInterfaceAddrs = previous }()
+	hostInterfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{
+			&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+			&net.IPNet{IP: net.ParseIP("192.168.1.24"), Mask: net.CIDRMask(24, 32)},
+			&net.IPNet{IP: net.ParseIP("2001:db8::24"), Mask: net.CIDRMask(64, 128)},
+			&net.IPAddr{IP: net.ParseIP("10.8.0.2")},
+		}, nil
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"192.168.1.25", true},
+		{"192.168.1.24", false},
+		{"2001:db8::24", false},
+		{"10.8.0.2", false},
+		{"127.0.0.1", false},
+		{"127.0.0.53", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := allowedShapingTarget(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("allowedShapingTarget(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("interfaces cannot be listed", func(t *testing.T) {
+		hostInterfaceAddrs = func() ([]net.Addr, error) { return nil, errors.New("no interfaces") }
+		if allowedShapingTarget(net.ParseIP("93.184.216.34")) {
+			t.Error("expected targets to be refused when the host addresses are unknown")
+		}
+	})
+}
@@ -11,10 +11,14 @@ package devices
 
 import (
 	"context"
+	"errors"
 
 	"GADS/common/models"
 )
 
+// ErrUnsupportedOperation is wrapped by device methods that the platform cannot perform.
+var ErrUnsupportedOperation = errors.New("operation is not supported for this device")
+
 // PlatformDevice is the interface that each OS-specific device type implements.
 // It provides a unified API for device lifecycle and app management,
 // eliminating the need for switch/case on device.OS throughout the codebase.
@@ -22,6 +26,7 @@ type PlatformDevice interface {
 	// Lifecycle
 	Setup() error
 	Reset(reason string)
+	Reboot() error
 
 	// Apps
 	InstallApp(path string) error
@@ -30,6 +35,10 @@ type PlatformDevice interface {
 	GetInstalledAppBundleIDs() []string
 	LaunchApp(bundleID string) error
 	KillApp(bundleID string) error
+	ClearAppData(bundleID string) error
+	GrantAppPermissions(bundleID string, permissions []string) error
+	RevokeAppPermissions(bundleID string, permissions []string) error
+	ResetAppPermissions(bundleID string, permissions []string) error
 
 	// State accessors
 	GetUDID() string
@@ -45,6 +54,9 @@ type PlatformDevice interface {
 	// Hub sync - builds the lightweight update sent to the hub each second
 	ToSyncUpdate() models.ProviderDeviceSync
 
+	// Diagnostics - recent state transitions and setup steps
+	GetDiagnostics() models.DeviceDiagnostics
+
 	// Appium - returns platform-specific Appium server capabilities
 	AppiumCapabilities() models.AppiumServerCapabilities
 
@@ -61,6 +73,7 @@ type PlatformDevice interface {
 	SetAppiumUp(up bool)
 	SetAppiumLastPingTS(ts int64)
 	SetHasAppiumSession(has bool)
+	GetHasAppiumSession() bool
 	GetIsAppiumUp() bool
 
 	// Runtime state accessors (provider-only fields on RuntimeState)
@@ -72,9 +85,32 @@ type PlatformDevice interface {
 	SetInstalledAppIDs(apps []string)
 }
 
+// TVRemote extends PlatformDevice with the remote control of TV platforms (Tizen, WebOS, Roku),
+// they are controlled with remote keys without an Appium session.
+type TVRemote interface {
+	PlatformDevice
+
+	PressRemoteKey(key string) error
+	TypeText(text string) error
+	// Screenshot returns a PNG or JPEG screenshot, wraps ErrUnsupportedOperation if the TV cannot capture its screen
+	Screenshot() ([]byte, error)
+}
+
+// TVScreenCapturer is implemented by TVs that keep one connection open for consecutive screenshots,
+// the screenshot stream uses it instead of connecting to the TV for every frame.
+type TVScreenCapturer interface {
+	OpenScreenCapture() (TVScreenCapture, error)
+}
+
+// TVScreenCapture captures the TV screen over a connection that stays open until it is closed, it is not safe for concurrent use
+type TVScreenCapture interface {
+	Screenshot() ([]byte, error)
+	Close()
+}
+
 // RemoteControllable extends PlatformDevice with capabilities for devices that support
 // remote control, streaming, and screen interaction (Android, iOS).
-// TV platforms (Tizen, WebOS) do not implement this interface.
+// TV platforms (Tizen, WebOS, Roku) do not implement this interface, see TVRemote.
 type RemoteControllable interface {
 	PlatformDevice
 
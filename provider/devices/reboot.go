@@ -0,0 +1,101 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	rebootDisconnectTimeout = 2 * time.Minute
+	rebootReconnectTimeout  = 10 * time.Minute
+	rebootPollInterval      = 2 * time.Second
+)
+
+// ErrRebootInProgress is returned when a reboot is requested for a device that is already rebooting
+var ErrRebootInProgress = errors.New("device is already rebooting")
+
+// rebootingDevices holds the UDIDs of devices that are rebooting, updateDevices leaves them alone
+// until they are back so Setup() does not run against a device that is still shutting down
+var rebootingDevices sync.Map
+
+// isRebooting reports whether the device is being rebooted
+func isRebooting(udid string) bool {
+	_, ok := rebootingDevices.Load(udid)
+	return ok
+}
+
+// RebootDevice reboots the device and re-provisions it once it is connected again.
+// It returns as soon as the reboot was issued, waiting for the device happens in the background.
+func RebootDevice(dev PlatformDevice) error {
+	udid := dev.GetUDID()
+	if _, loaded := rebootingDevices.LoadOrStore(udid, struct{}{}); loaded {
+		return ErrRebootInProgress
+	}
+
+	if err := dev.Reboot(); err != nil {
+		rebootingDevices.Delete(udid)
+		return err
+	}
+	dev.GetLogger().LogInfo("reboot", "Device reboot issued, waiting for it to reconnect")
+	dev.Reset("Device is rebooting")
+	dev.SetProviderState("rebooting")
+
+	go waitForReboot(dev)
+	return nil
+}
+
+// waitForReboot waits for the device to disconnect and connect again, then hands it back to updateDevices for setup
+func waitForReboot(dev PlatformDevice) {
+	udid := dev.GetUDID()
+	defer func() {
+		dev.SetProviderState("init")
+		rebootingDevices.Delete(udid)
+	}()
+
+	connected := func() bool {
+		return slices.Contains(GetConnectedDevicesCommon(), udid)
+	}
+
+	// Some devices take a while to go down after the reboot command returns
+	if !waitUntil(rebootDisconnectTimeout, func() bool { return !connected() }) {
+		dev.GetLogger().LogWarn("reboot", fmt.Sprintf("Device did not disconnect within %v after the reboot, re-provisioning it anyway", rebootDisconnectTimeout))
+		return
+	}
+	dev.SetConnected(false)
+
+	if !waitUntil(rebootReconnectTimeout, connected) {
+		dev.GetLogger().LogError("reboot", fmt.Sprintf("Device did not reconnect within %v after the reboot", rebootReconnectTimeout))
+		return
+	}
+
+	if androidDev, ok := dev.(*AndroidDevice); ok {
+		if !waitUntil(rebootReconnectTimeout, androidDev.bootCompleted) {
+			dev.GetLogger().LogWarn("reboot", "Device did not report boot completed, re-provisioning it anyway")
+		}
+	}
+	dev.GetLogger().LogInfo("reboot", "Device reconnected after the reboot, re-provisioning it")
+}
+
+// waitUntil polls the condition until it is true or the timeout passes
+func waitUntil(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(rebootPollInterval)
+	}
+	return false
+}
@@ -0,0 +1,633 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+)
+
+var (
+	// rokuECPPort is the port of the Roku External Control Protocol REST API
+	rokuECPPort = 8060
+	// rokuWebPort is the port of the developer web installer
+	rokuWebPort = 80
+)
+
+const (
+	rokuDevUser = "rokudev"
+	// rokuDevAppID is the ID of the sideloaded channel, only one can be installed at a time
+	rokuDevAppID = "dev"
+	// rokuScanInterval is how often the Roku devices are searched with SSDP and checked through ECP
+	rokuScanInterval   = 10 * time.Second
+	rokuRequestTimeout = 5 * time.Second
+	rokuSSDPTimeout    = 3 * time.Second
+)
+
+// RokuDevice holds Roku-specific runtime state alongside the shared RuntimeState.
+// Roku devices are controlled through ECP and the developer web installer, the UDID is the device IP address.
+type RokuDevice struct {
+	RuntimeState
+	DeviceAddress string // IP address of the Roku device (same as UDID for Roku)
+}
+
+var rokuClient = &http.Client{Timeout: rokuRequestTimeout}
+
+var (
+	// rokuReachable holds the info of the Roku devices that answered ECP on the last scan, by IP address
+	rokuReachable   = make(map[string]rokuDeviceInfo)
+	rokuReachableMu sync.RWMutex
+)
+
+// rokuDeviceInfo is the ECP `/query/device-info` response
+type rokuDeviceInfo struct {
+	FriendlyName     string `xml:"friendly-device-name"`
+	ModelName        string `xml:"model-name"`
+	ModelNumber      string `xml:"model-number"`
+	SoftwareVersion  string `xml:"software-version"`
+	UIResolution     string `xml:"ui-resolution"`
+	DeveloperEnabled bool   `xml:"developer-enabled"`
+}
+
+// rokuApp is an app of the ECP `/query/apps` response
+type rokuApp struct {
+	ID      string `xml:"id,attr"`
+	Type    string `xml:"type,attr"`
+	Version string `xml:"version,attr"`
+	Name    string `xml:",chardata"`
+}
+
+// rokuResolutions maps the ECP UI resolutions to screen sizes
+var rokuResolutions = map[string][2]string{
+	"720p":  {"1280", "720"},
+	"1080p": {"1920", "1080"},
+	"4k":    {"3840", "2160"},
+	"2160p": {"3840", "2160"},
+}
+
+// Setup runs the full Roku device provisioning sequence.
+func (d *RokuDevice) Setup() (retErr error) {
+	d.SetupMutex.Lock()
+	defer d.SetupMutex.Unlock()
+
+	if d.inSetupBackoff() {
+		return nil
+	}
+	defer func() { d.updateSetupBackoff(retErr) }()
+
+	d.SetProviderState("preparing")
+	logger.ProviderLogger.LogInfo("roku_device_setup", fmt.Sprintf("Running setup for Roku device `%v`", d.GetUDID()))
+
+	d.DeviceAddress = d.GetUDID()
+	d.DBDevice.IPAddress = d.GetUDID()
+
+	if err := d.setupStep("get device info through ECP", d.getDeviceInfo); err != nil {
+		return err
+	}
+
+	if err := d.recordStep("set up Appium", func() error { return setupAppiumForDevice(d) }); err != nil {
+		return err
+	}
+
+	d.SetProviderState("live")
+	return nil
+}
+
+func (d *RokuDevice) getDeviceInfo() error {
+	info, err := queryRokuDeviceInfo(d.DeviceAddress)
+	if err != nil {
+		return err
+	}
+	if !info.DeveloperEnabled {
+		return fmt.Errorf("developer mode is not enabled on the device")
+	}
+
+	d.HardwareModel = strings.TrimSpace(info.ModelName + " " + info.ModelNumber)
+	d.DBDevice.OSVersion = info.SoftwareVersion
+	if size, ok := rokuResolutions[strings.ToLower(info.UIResolution)]; ok {
+		d.DBDevice.ScreenWidth = size[0]
+		d.DBDevice.ScreenHeight = size[1]
+	}
+	return nil
+}
+
+// AppiumCapabilities returns the Roku-specific Appium server capabilities for the Appium Roku driver.
+func (d *RokuDevice) AppiumCapabilities() models.AppiumServerCapabilities {
+	return models.AppiumServerCapabilities{
+		AutomationName: "Roku",
+		PlatformName:   "Roku",
+		UDID:           d.GetUDID(),
+		DeviceName:     d.DBDevice.Name,
+		RokuHost:       d.DeviceAddress,
+		RokuEcpPort:    rokuECPPort,
+		RokuWebPort:    rokuWebPort,
+		RokuUser:       rokuDevUser,
+		RokuPass:       config.Local.RokuDevPassword,
+	}
+}
+
+// watchRokuDevices periodically searches for Roku devices with SSDP and checks the registered ones through ECP.
+// ECP requests can take a while on a sleeping device so the connected devices are cached instead of queried every second.
+func watchRokuDevices() {
+	for {
+		scanRokuDevices()
+		time.Sleep(rokuScanInterval)
+	}
+}
+
+func scanRokuDevices() {
+	var addresses []string
+	for _, platDev := range DevManager.All() {
+		if platDev.GetOS() == "roku" {
+			addresses = append(addresses, platDev.GetUDID())
+		}
+	}
+
+	found, err := searchRokuSSDP(rokuSSDPTimeout)
+	if err != nil {
+		logger.ProviderLogger.LogWarn("roku_device_detection", fmt.Sprintf("Failed to search Roku devices with SSDP - %s", err))
+	}
+	for _, address := range found {
+		if !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+
+	reachable := make(map[string]rokuDeviceInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := queryRokuDeviceInfo(address)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			reachable[address] = info
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	rokuReachableMu.Lock()
+	rokuReachable = reachable
+	rokuReachableMu.Unlock()
+}
+
+// getConnectedDevicesRoku returns the Roku devices that answered ECP on the last scan
+func getConnectedDevicesRoku() []string {
+	rokuReachableMu.RLock()
+	defer rokuReachableMu.RUnlock()
+
+	var connectedDevices []string
+	for address := range rokuReachable {
+		connectedDevices = append(connectedDevices, address)
+	}
+	return connectedDevices
+}
+
+// detectRokuDevice returns the discovered device info of a reachable Roku device
+func detectRokuDevice(address string) (models.DiscoveredDevice, error) {
+	rokuReachableMu.RLock()
+	info, ok := rokuReachable[address]
+	rokuReachableMu.RUnlock()
+	if !ok {
+		return models.DiscoveredDevice{}, fmt.Errorf("device is not reachable through ECP")
+	}
+
+	device := models.DiscoveredDevice{
+		UDID:       address,
+		OS:         "roku",
+		Name:       info.FriendlyName,
+		Model:      strings.TrimSpace(info.ModelName + " " + info.ModelNumber),
+		OSVersion:  info.SoftwareVersion,
+		DeviceType: "real",
+	}
+	if device.Name == "" {
+		device.Name = device.Model
+	}
+	if size, ok := rokuResolutions[strings.ToLower(info.UIResolution)]; ok {
+		device.ScreenWidth, device.ScreenHeight = size[0], size[1]
+	}
+	return device, nil
+}
+
+// searchRokuSSDP sends an SSDP M-SEARCH for Roku ECP devices and returns the IP addresses of the devices that answered
+func searchRokuSSDP(timeout time.Duration) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket - %w", err)
+	}
+	defer conn.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"Host: 239.255.255.250:1900\r\n" +
+		"Man: \"ssdp:discover\"\r\n" +
+		"ST: roku:ecp\r\n" +
+		"MX: 2\r\n\r\n"
+	multicast := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	if _, err := conn.WriteTo([]byte(search), multicast); err != nil {
+		return nil, fmt.Errorf("failed to send SSDP search - %w", err)
+	}
+
+	var addresses []string
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// The read deadline ends the search
+			break
+		}
+		address, ok := parseRokuSSDPResponse(buf[:n])
+		if ok && !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
+// parseRokuSSDPResponse returns the IP address from the location of an SSDP response of a Roku ECP device
+func parseRokuSSDPResponse(data []byte) (string, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return "", false
+	}
+	resp.Body.Close()
+	if !strings.EqualFold(resp.Header.Get("ST"), "roku:ecp") {
+		return "", false
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Hostname() == "" {
+		return "", false
+	}
+	return location.Hostname(), true
+}
+
+// ecpRequest sends a request to the ECP REST API of a Roku device
+func ecpRequest(address, method, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/%s", net.JoinHostPort(address, fmt.Sprint(rokuECPPort)), path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rokuClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ECP request `%s %s` failed - %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ECP response - %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("ECP request `%s %s` returned status %d - %s", method, path, resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+func queryRokuDeviceInfo(address string) (rokuDeviceInfo, error) {
+	var info rokuDeviceInfo
+	body, err := ecpRequest(address, http.MethodGet, "query/device-info")
+	if err != nil {
+		return info, err
+	}
+	if err := xml.Unmarshal(body, &info); err != nil {
+		return info, fmt.Errorf("failed to parse ECP device info - %w", err)
+	}
+	return info, nil
+}
+
+// PressRemoteKey presses a remote key through ECP, e.g. `Home`, `Select` or `Lit_a` for a character
+func (d *RokuDevice) PressRemoteKey(key string) error {
+	_, err := ecpRequest(d.DeviceAddress, http.MethodPost, "keypress/"+url.PathEscape(key))
+	return err
+}
+
+// TypeText types the text into the focused field by pressing the `Lit_` key of every character
+func (d *RokuDevice) TypeText(text string) error {
+	for _, char := range text {
+		if err := d.PressRemoteKey("Lit_" + string(char)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *RokuDevice) queryApps() ([]rokuApp, error) {
+	body, err := ecpRequest(d.DeviceAddress, http.MethodGet, "query/apps")
+	if err != nil {
+		return nil, err
+	}
+	var apps struct {
+		Apps []rokuApp `xml:"app"`
+	}
+	if err := xml.Unmarshal(body, &apps); err != nil {
+		return nil, fmt.Errorf("failed to parse ECP apps - %w", err)
+	}
+	return apps.Apps, nil
+}
+
+// InstallApp sideloads a channel zip through the developer web installer, it replaces the installed dev channel.
+func (d *RokuDevice) InstallApp(appName string) error {
+	appPath := filepath.Join(config.ProviderConfig.ProviderFolder, appName)
+	archive, err := os.ReadFile(appPath)
+	if err != nil {
+		return fmt.Errorf("failed to read app file - %w", err)
+	}
+
+	logger.ProviderLogger.LogInfo("roku_install_app", fmt.Sprintf("Sideloading `%s` on device %s", appName, d.GetUDID()))
+	body, err := d.devInstallerPost("plugin_install", map[string]string{"mysubmit": "Install"}, filepath.Base(appPath), archive)
+	if err != nil {
+		return fmt.Errorf("failed to install app - %w", err)
+	}
+	if strings.Contains(body, "Install Failure") {
+		return fmt.Errorf("failed to install app - %s", devInstallerMessage(body))
+	}
+
+	logger.ProviderLogger.LogInfo("roku_install_app", fmt.Sprintf("Successfully installed app on device %s", d.GetUDID()))
+	return nil
+}
+
+// UninstallApp deletes the sideloaded dev channel, other channels cannot be removed through the developer web installer.
+func (d *RokuDevice) UninstallApp(appID string) error {
+	if appID != rokuDevAppID {
+		return fmt.Errorf("UninstallApp: only the `%s` channel can be uninstalled - %w", rokuDevAppID, ErrUnsupportedOperation)
+	}
+
+	logger.ProviderLogger.LogInfo("roku_uninstall_app", fmt.Sprintf("Deleting the dev channel from device %s", d.GetUDID()))
+	if _, err := d.devInstallerPost("plugin_install", map[string]string{"mysubmit": "Delete", "archive": ""}, "", nil); err != nil {
+		return fmt.Errorf("failed to uninstall app %s - %w", appID, err)
+	}
+	return nil
+}
+
+// GetInstalledApps returns the channels installed on the device.
+func (d *RokuDevice) GetInstalledApps() ([]models.DeviceApp, error) {
+	apps, err := d.queryApps()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.DeviceApp
+	for _, app := range apps {
+		result = append(result, models.DeviceApp{
+			AppName:          app.Name,
+			BundleIdentifier: app.ID,
+			CanUninstall:     app.ID == rokuDevAppID,
+			Version:          app.Version,
+		})
+	}
+	return result, nil
+}
+
+// GetInstalledAppBundleIDs returns the IDs of the channels installed on the device.
+func (d *RokuDevice) GetInstalledAppBundleIDs() []string {
+	apps, err := d.queryApps()
+	if err != nil {
+		logger.ProviderLogger.LogError("roku_list_apps", fmt.Sprintf("Failed to list apps for device %s - %s", d.GetUDID(), err))
+		return []string{}
+	}
+
+	var ids []string
+	for _, app := range apps {
+		ids = append(ids, app.ID)
+	}
+	return ids
+}
+
+// LaunchApp launches a channel through ECP.
+func (d *RokuDevice) LaunchApp(appID string) error {
+	logger.ProviderLogger.LogInfo("roku_launch_app", fmt.Sprintf("Launching app %s on device %s", appID, d.GetUDID()))
+	if _, err := ecpRequest(d.DeviceAddress, http.MethodPost, "launch/"+url.PathEscape(appID)); err != nil {
+		return fmt.Errorf("failed to launch app %s - %w", appID, err)
+	}
+	return nil
+}
+
+// KillApp exits the channel by going to the home screen, ECP cannot stop channels so it only works for the active one.
+func (d *RokuDevice) KillApp(appID string) error {
+	body, err := ecpRequest(d.DeviceAddress, http.MethodGet, "query/active-app")
+	if err != nil {
+		return fmt.Errorf("failed to get the active app - %w", err)
+	}
+	var active struct {
+		App rokuApp `xml:"app"`
+	}
+	if err := xml.Unmarshal(body, &active); err != nil {
+		return fmt.Errorf("failed to parse the active app - %w", err)
+	}
+	if active.App.ID != appID {
+		return nil
+	}
+
+	logger.ProviderLogger.LogInfo("roku_kill_app", fmt.Sprintf("Closing app %s on device %s", appID, d.GetUDID()))
+	return d.PressRemoteKey("Home")
+}
+
+var devInstallerScreenshotRegex = regexp.MustCompile(`pkgs/dev\.(jpg|png)(\?time=\d+)?`)
+
+// Screenshot takes a screenshot of the sideloaded channel through the developer web installer.
+func (d *RokuDevice) Screenshot() ([]byte, error) {
+	body, err := d.devInstallerPost("plugin_inspect", map[string]string{"mysubmit": "Screenshot", "passwd": "", "archive": ""}, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take screenshot - %w", err)
+	}
+	imagePath := devInstallerScreenshotRegex.FindString(body)
+	if imagePath == "" {
+		return nil, fmt.Errorf("failed to take screenshot, the dev channel has to be running - %s", devInstallerMessage(body))
+	}
+
+	resp, err := d.devInstallerRequest(http.MethodGet, "/"+imagePath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download screenshot - %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download screenshot, status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// devInstallerPost posts a multipart form to the developer web installer, the archive is only attached if a file name is given
+func (d *RokuDevice) devInstallerPost(path string, fields map[string]string, fileName string, archive []byte) (string, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	if fileName != "" {
+		part, err := writer.CreateFormFile("archive", fileName)
+		if err != nil {
+			return "", err
+		}
+		part.Write(archive)
+	}
+	writer.Close()
+
+	resp, err := d.devInstallerRequest(http.MethodPost, "/"+path, writer.FormDataContentType(), form.Bytes())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read developer web installer response - %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("developer web installer returned status %d", resp.StatusCode)
+	}
+	return string(body), nil
+}
+
+// devInstallerRequest sends a request to the developer web installer, which requires HTTP digest authentication
+func (d *RokuDevice) devInstallerRequest(method, path, contentType string, body []byte) (*http.Response, error) {
+	requestURL := fmt.Sprintf("http://%s%s", net.JoinHostPort(d.DeviceAddress, fmt.Sprint(rokuWebPort)), path)
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rokuClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	authorization, err := digestAuthorization(challenge, method, path, rokuDevUser, config.Local.RokuDevPassword)
+	if err != nil {
+		return nil, err
+	}
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err = rokuClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("developer web installer rejected the credentials, check the Roku dev password")
+	}
+	return resp, nil
+}
+
+var digestParamRegex = regexp.MustCompile(`(\w+)="?([^",]*)"?`)
+
+// digestAuthorization builds the Authorization header answering an HTTP digest authentication challenge
+func digestAuthorization(challenge, method, uri, username, password string) (string, error) {
+	scheme, paramsString, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return "", fmt.Errorf("unsupported authentication challenge `%s`", challenge)
+	}
+	params := make(map[string]string)
+	for _, match := range digestParamRegex.FindAllStringSubmatch(paramsString, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+
+	hash := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := hash(fmt.Sprintf("%s:%s:%s", username, params["realm"], password))
+	ha2 := hash(fmt.Sprintf("%s:%s", method, uri))
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, username, params["realm"], params["nonce"], uri)
+	if qop := params["qop"]; qop != "" {
+		// Only the `auth` quality of protection is supported, `auth-int` is not used by the Roku web installer
+		cnonceBytes := make([]byte, 8)
+		rand.Read(cnonceBytes)
+		cnonce := hex.EncodeToString(cnonceBytes)
+		nc := "00000001"
+		response := hash(fmt.Sprintf("%s:%s:%s:%s:auth:%s", ha1, params["nonce"], nc, cnonce, ha2))
+		authorization += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s", response="%s"`, nc, cnonce, response)
+	} else {
+		authorization += fmt.Sprintf(`, response="%s"`, hash(fmt.Sprintf("%s:%s:%s", ha1, params["nonce"], ha2)))
+	}
+	if opaque := params["opaque"]; opaque != "" {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization, nil
+}
+
+var devInstallerMessageRegex = regexp.MustCompile(`<font color="red">([^<]*)</font>`)
+
+// devInstallerMessage extracts the result message from a developer web installer page
+func devInstallerMessage(body string) string {
+	if match := devInstallerMessageRegex.FindStringSubmatch(body); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return "no result message in the developer web installer response"
+}
+
+// Reboot is not supported on Roku, ECP cannot restart the device.
+func (d *RokuDevice) Reboot() error {
+	return fmt.Errorf("Reboot: %w", ErrUnsupportedOperation)
+}
+
+// ClearAppData is not supported on Roku.
+func (d *RokuDevice) ClearAppData(appID string) error {
+	return fmt.Errorf("ClearAppData: %w", ErrUnsupportedOperation)
+}
+
+// GrantAppPermissions is not supported on Roku.
+func (d *RokuDevice) GrantAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("GrantAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// RevokeAppPermissions is not supported on Roku.
+func (d *RokuDevice) RevokeAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("RevokeAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// ResetAppPermissions is not supported on Roku.
+func (d *RokuDevice) ResetAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("ResetAppPermissions: %w", ErrUnsupportedOperation)
+}
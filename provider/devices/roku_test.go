@@ -0,0 +1,413 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"GADS/provider/config"
+	"GADS/provider/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const testRokuPassword = "dev-password"
+
+// newTestRokuDevice points the Roku ECP and developer web installer ports to local stand-in servers, a nil handler is not started
+func newTestRokuDevice(t *testing.T, ecp, web http.Handler) *RokuDevice {
+	t.Helper()
+	if logger.ProviderLogger == nil {
+		discard := logrus.New()
+		discard.SetOutput(io.Discard)
+		logger.ProviderLogger = &logger.CustomLogger{Logger: discard}
+	}
+
+	previousECPPort, previousWebPort, previousPassword := rokuECPPort, rokuWebPort, config.Local.RokuDevPassword
+	t.Cleanup(func() {
+		rokuECPPort, rokuWebPort, config.Local.RokuDevPassword = previousECPPort, previousWebPort, previousPassword
+	})
+	config.Local.RokuDevPassword = testRokuPassword
+
+	for _, server := range []struct {
+		handler http.Handler
+		port    *int
+	}{{ecp, &rokuECPPort}, {web, &rokuWebPort}} {
+		if server.handler == nil {
+			continue
+		}
+		s := httptest.NewServer(server.handler)
+		t.Cleanup(s.Close)
+		_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+		*server.port, _ = strconv.Atoi(port)
+	}
+
+	device := &RokuDevice{DeviceAddress: "127.0.0.1"}
+	device.DBDevice.UDID = "127.0.0.1"
+	return device
+}
+
+// ecpRecorder is a stand-in ECP server that records the requests it received
+type ecpRecorder struct {
+	mu        sync.Mutex
+	requests  []string
+	responses map[string]string
+}
+
+func (e *ecpRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	request := r.Method + " " + r.URL.Path
+	e.requests = append(e.requests, request)
+	if response, ok := e.responses[request]; ok {
+		w.Write([]byte(response))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+	}
+}
+
+func (e *ecpRecorder) received() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.requests...)
+}
+
+func TestRokuQueryApps(t *testing.T) {
+	ecp := &ecpRecorder{responses: map[string]string{
+		"GET /query/apps": `<?xml version="1.0" encoding="UTF-8" ?>
+<apps>
+	<app id="31012" type="menu" version="2.0.53">Movie Store and TV Store</app>
+	<app id="dev" type="appl" version="1.0.1">My Channel</app>
+</apps>`,
+	}}
+	device := newTestRokuDevice(t, ecp, nil)
+
+	apps, err := device.GetInstalledApps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 2 {
+		t.Fatalf("Expected 2 apps, got %+v", apps)
+	}
+	if apps[0].BundleIdentifier != "31012" || apps[0].AppName != "Movie Store and TV Store" || apps[0].Version != "2.0.53" || apps[0].CanUninstall {
+		t.Errorf("Unexpected app %+v", apps[0])
+	}
+	if apps[1].BundleIdentifier != rokuDevAppID || !apps[1].CanUninstall {
+		t.Errorf("Expected the dev channel to be uninstallable, got %+v", apps[1])
+	}
+	if ids := device.GetInstalledAppBundleIDs(); strings.Join(ids, ",") != "31012,dev" {
+		t.Errorf("Unexpected app IDs %v", ids)
+	}
+}
+
+func TestRokuQueryAppsInvalidResponse(t *testing.T) {
+	ecp := &ecpRecorder{responses: map[string]string{"GET /query/apps": "<apps><app"}}
+	device := newTestRokuDevice(t, ecp, nil)
+
+	if _, err := device.queryApps(); err == nil {
+		t.Error("Expected an error for an invalid ECP response")
+	}
+}
+
+func TestRokuLaunchApp(t *testing.T) {
+	ecp := &ecpRecorder{}
+	device := newTestRokuDevice(t, ecp, nil)
+
+	if err := device.LaunchApp("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := ecp.received(); len(requests) != 1 || requests[0] != "POST /launch/dev" {
+		t.Errorf("Unexpected ECP requests %v", requests)
+	}
+}
+
+func TestRokuKillApp(t *testing.T) {
+	tests := []struct {
+		name      string
+		activeApp string
+		appID     string
+		want      []string
+	}{
+		{
+			name:      "active app is closed",
+			activeApp: `<active-app><app id="dev" type="appl" version="1.0.1">My Channel</app></active-app>`,
+			appID:     "dev",
+			want:      []string{"GET /query/active-app", "POST /keypress/Home"},
+		},
+		{
+			name:      "other active app is left running",
+			activeApp: `<active-app><app id="12" type="appl" version="5.1">Netflix</app></active-app>`,
+			appID:     "dev",
+			want:      []string{"GET /query/active-app"},
+		},
+		{
+			name:      "home screen",
+			activeApp: `<active-app><app>Roku</app></active-app>`,
+			appID:     "dev",
+			want:      []string{"GET /query/active-app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecp := &ecpRecorder{responses: map[string]string{"GET /query/active-app": tt.activeApp}}
+			device := newTestRokuDevice(t, ecp, nil)
+
+			if err := device.KillApp(tt.appID); err != nil {
+				t.Fatal(err)
+			}
+			if requests := ecp.received(); strings.Join(requests, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected ECP requests %v, got %v", tt.want, requests)
+			}
+		})
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseDigestParams(header string) map[string]string {
+	params := make(map[string]string)
+	_, paramsString, _ := strings.Cut(header, " ")
+	for _, match := range digestParamRegex.FindAllStringSubmatch(paramsString, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	return params
+}
+
+// validDigestResponse checks the digest response of an Authorization header against the expected credentials
+func validDigestResponse(header, method, password string) bool {
+	params := parseDigestParams(header)
+	ha1 := md5Hex(fmt.Sprintf("%s:%s:%s", params["username"], params["realm"], password))
+	ha2 := md5Hex(fmt.Sprintf("%s:%s", method, params["uri"]))
+	expected := md5Hex(fmt.Sprintf("%s:%s:%s", ha1, params["nonce"], ha2))
+	if params["qop"] != "" {
+		expected = md5Hex(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2))
+	}
+	return params["response"] == expected
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		wantQop   bool
+	}{
+		{
+			name:      "qop auth",
+			challenge: `Digest qop="auth", realm="rokudev", nonce="1700000000", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			wantQop:   true,
+		},
+		{
+			name:      "no qop",
+			challenge: `Digest realm="rokudev", nonce="1700000000"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorization, err := digestAuthorization(tt.challenge, http.MethodPost, "/plugin_install", rokuDevUser, testRokuPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := parseDigestParams(authorization)
+			if params["username"] != rokuDevUser || params["realm"] != "rokudev" || params["nonce"] != "1700000000" || params["uri"] != "/plugin_install" {
+				t.Errorf("Unexpected authorization parameters %v", params)
+			}
+			if tt.wantQop != (params["qop"] == "auth" && params["nc"] == "00000001" && params["cnonce"] != "") {
+				t.Errorf("Unexpected quality of protection in %s", authorization)
+			}
+			if strings.Contains(tt.challenge, "opaque") != (params["opaque"] == "5ccc069c403ebaf9f0171e9517f40e41") {
+				t.Errorf("Expected the opaque value to be echoed only when provided, got %s", authorization)
+			}
+			if !validDigestResponse(authorization, http.MethodPost, testRokuPassword) {
+				t.Errorf("Invalid digest response in %s", authorization)
+			}
+			if validDigestResponse(authorization, http.MethodPost, "wrong-password") {
+				t.Errorf("Expected the digest response to depend on the password")
+			}
+		})
+	}
+
+	if _, err := digestAuthorization(`Basic realm="rokudev"`, http.MethodGet, "/", rokuDevUser, testRokuPassword); err == nil {
+		t.Error("Expected an error for a non digest challenge")
+	}
+}
+
+// devInstallerStandIn is a stand-in developer web installer that requires digest authentication
+type devInstallerStandIn struct {
+	mu       sync.Mutex
+	archive  string
+	submit   string
+	response string
+}
+
+func (s *devInstallerStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" || !validDigestResponse(authorization, r.Method, testRokuPassword) {
+		w.Header().Set("WWW-Authenticate", `Digest qop="auth", realm="rokudev", nonce="1700000000"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/plugin_install" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submit = r.FormValue("mysubmit")
+	if file, _, err := r.FormFile("archive"); err == nil {
+		data, _ := io.ReadAll(file)
+		s.archive = string(data)
+	}
+	w.Write([]byte(s.response))
+}
+
+func TestRokuInstallApp(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string
+	}{
+		{
+			name:     "installed",
+			response: `<html><font color="red">Application Received: 2048 bytes stored.</font> <font color="red">Install Success.</font></html>`,
+		},
+		{
+			name:     "install failure",
+			response: `<html><font color="red">Install Failure: Compilation Failed.</font></html>`,
+			wantErr:  "Install Failure: Compilation Failed.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installer := &devInstallerStandIn{response: tt.response}
+			device := newTestRokuDevice(t, nil, installer)
+			previousFolder := config.ProviderConfig.ProviderFolder
+			config.ProviderConfig.ProviderFolder = t.TempDir()
+			defer func() { config.ProviderConfig.ProviderFolder = previousFolder }()
+			if err := os.WriteFile(filepath.Join(config.ProviderConfig.ProviderFolder, "channel.zip"), []byte("zip data"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := device.InstallApp("channel.zip")
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Expected an error containing `%s`, got %v", tt.wantErr, err)
+			}
+
+			installer.mu.Lock()
+			defer installer.mu.Unlock()
+			if installer.submit != "Install" || installer.archive != "zip data" {
+				t.Errorf("Expected the archive to be submitted for install, got `%s` with `%s`", installer.submit, installer.archive)
+			}
+		})
+	}
+}
+
+func TestRokuInstallAppWrongPassword(t *testing.T) {
+	device := newTestRokuDevice(t, nil, &devInstallerStandIn{})
+	config.Local.RokuDevPassword = "wrong-password"
+	previousFolder := config.ProviderConfig.ProviderFolder
+	config.ProviderConfig.ProviderFolder = t.TempDir()
+	defer func() { config.ProviderConfig.ProviderFolder = previousFolder }()
+	os.WriteFile(filepath.Join(config.ProviderConfig.ProviderFolder, "channel.zip"), []byte("zip data"), 0644)
+
+	if err := device.InstallApp("channel.zip"); err == nil || !strings.Contains(err.Error(), "rejected the credentials") {
+		t.Errorf("Expected the credentials to be rejected, got %v", err)
+	}
+}
+
+func TestParseRokuSSDPResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantOK   bool
+	}{
+		{
+			name:     "roku device",
+			response: "HTTP/1.1 200 OK\r\nCache-Control: max-age=3600\r\nST: roku:ecp\r\nLocation: http://192.168.1.134:8060/\r\nUSN: uuid:roku:ecp:P0A070000007\r\n\r\n",
+			want:     "192.168.1.134",
+			wantOK:   true,
+		},
+		{
+			name:     "search target is case insensitive",
+			response: "HTTP/1.1 200 OK\r\nst: ROKU:ECP\r\nlocation: http://10.0.0.5:8060/\r\n\r\n",
+			want:     "10.0.0.5",
+			wantOK:   true,
+		},
+		{
+			name:     "other device",
+			response: "HTTP/1.1 200 OK\r\nST: urn:schemas-upnp-org:device:MediaRenderer:1\r\nLocation: http://192.168.1.20:1400/xml/device_description.xml\r\n\r\n",
+		},
+		{
+			name:     "missing location",
+			response: "HTTP/1.1 200 OK\r\nST: roku:ecp\r\n\r\n",
+		},
+		{
+			name:     "not an HTTP response",
+			response: "NOTIFY garbage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRokuSSDPResponse([]byte(tt.response))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRokuSSDPResponse() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRedactedAppiumCapabilities(t *testing.T) {
+	device := &RokuDevice{DeviceAddress: "192.168.1.134"}
+	previousPassword := config.Local.RokuDevPassword
+	config.Local.RokuDevPassword = testRokuPassword
+	defer func() { config.Local.RokuDevPassword = previousPassword }()
+
+	capabilities := device.AppiumCapabilities()
+	if redacted := redactedAppiumCapabilities(capabilities); strings.Contains(redacted, testRokuPassword) || !strings.Contains(redacted, `"appium:rokuPass":"[REDACTED]"`) {
+		t.Errorf("Expected the Roku password to be redacted, got %s", redacted)
+	}
+
+	capabilitiesFile, err := writeAppiumCapabilities(capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(capabilitiesFile)
+	info, err := os.Stat(capabilitiesFile)
+	if err != nil || info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("Expected the capabilities file to be private, got %v - %v", info.Mode(), err)
+	}
+	if data, _ := os.ReadFile(capabilitiesFile); !strings.Contains(string(data), testRokuPassword) {
+		t.Errorf("Expected the capabilities file to hold the password for Appium, got %s", data)
+	}
+}
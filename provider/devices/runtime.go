@@ -12,12 +12,12 @@ package devices
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/Masterminds/semver"
 
-	"GADS/common"
 	"GADS/common/models"
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
@@ -67,6 +67,9 @@ type RuntimeState struct {
 	// Per-device setup backoff state, protected by SetupMutex
 	setupBackoffUntil time.Time
 	setupBackoffNext  time.Duration
+
+	// Recent state transitions and setup steps, see GetDiagnostics
+	diagnostics deviceDiagnostics
 }
 
 // Common accessor implementations inherited by all platform types via embedding.
@@ -75,9 +78,7 @@ func (r *RuntimeState) GetUDID() string                              { return r.
 func (r *RuntimeState) GetOS() string                                { return r.DBDevice.OS }
 func (r *RuntimeState) GetDBDevice() *models.DBDevice                { return &r.DBDevice }
 func (r *RuntimeState) GetProviderState() string                     { return r.ProviderState }
-func (r *RuntimeState) SetProviderState(state string)                { r.ProviderState = state }
 func (r *RuntimeState) IsConnected() bool                            { return r.Connected }
-func (r *RuntimeState) SetConnected(connected bool)                  { r.Connected = connected }
 func (r *RuntimeState) GetHost() string                              { return r.Host }
 func (r *RuntimeState) SetHost(host string)                          { r.Host = host }
 func (r *RuntimeState) GetLogger() models.CustomLogger               { return r.Logger }
@@ -89,6 +90,7 @@ func (r *RuntimeState) SetAppiumSessionID(id string)                 { r.AppiumS
 func (r *RuntimeState) SetAppiumUp(up bool)                          { r.IsAppiumUp = up }
 func (r *RuntimeState) SetAppiumLastPingTS(ts int64)                 { r.AppiumLastPingTS = ts }
 func (r *RuntimeState) SetHasAppiumSession(has bool)                 { r.HasAppiumSession = has }
+func (r *RuntimeState) GetHasAppiumSession() bool                    { return r.HasAppiumSession }
 func (r *RuntimeState) GetIsResetting() bool                         { return r.IsResetting }
 func (r *RuntimeState) SetIsResetting(v bool)                        { r.IsResetting = v }
 func (r *RuntimeState) GetIsAppiumUp() bool                          { return r.IsAppiumUp }
@@ -108,18 +110,57 @@ func (r *RuntimeState) SetSupportedStreamTypes(types []models.StreamType) {
 }
 func (r *RuntimeState) GetInstalledAppIDs() []string     { return r.InstalledApps }
 func (r *RuntimeState) SetInstalledAppIDs(apps []string) { r.InstalledApps = apps }
+
+// SetProviderState and SetConnected also push the change to the hub right away
+func (r *RuntimeState) SetProviderState(state string) {
+	r.recordStateTransition(r.ProviderState, state, "")
+	r.ProviderState = state
+	notifyStateChanged()
+}
+func (r *RuntimeState) SetConnected(connected bool) {
+	r.Connected = connected
+	notifyStateChanged()
+}
+
 func (r *RuntimeState) SetNewContext(ctx context.Context, cancel context.CancelFunc) {
 	r.Context = ctx
 	r.CtxCancel = cancel
 }
 
-// ToSyncUpdate builds the lightweight struct sent to the hub each second.
+// ToSyncUpdate builds the lightweight struct sent to the hub when the device state changes.
+// Live devices of a draining provider are reported as `draining` so the hub does not give them out.
 func (r *RuntimeState) ToSyncUpdate() models.ProviderDeviceSync {
+	state := r.ProviderState
+	if state == "live" && IsDraining() {
+		state = models.ProviderStateDraining
+	}
 	return models.ProviderDeviceSync{
 		UDID:          r.DBDevice.UDID,
 		Host:          r.Host,
 		Connected:     r.Connected,
-		ProviderState: r.ProviderState,
+		ProviderState: state,
+	}
+}
+
+var (
+	resetHooks   []func(udid string)
+	resetHooksMu sync.RWMutex
+)
+
+// OnReset registers a hook that runs after a device was reset, e.g. to stop work the router started for the device
+func OnReset(hook func(udid string)) {
+	resetHooksMu.Lock()
+	defer resetHooksMu.Unlock()
+	resetHooks = append(resetHooks, hook)
+}
+
+// runResetHooks runs the reset hooks without holding the device mutex, they can take a while
+func runResetHooks(udid string) {
+	resetHooksMu.RLock()
+	hooks := slices.Clone(resetHooks)
+	resetHooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook(udid)
 	}
 }
 
@@ -135,13 +176,14 @@ func (r *RuntimeState) ResetBase(reason string) bool {
 		if r.CtxCancel != nil {
 			r.CtxCancel()
 		}
+		r.recordStateTransition(r.ProviderState, "init", reason)
 		r.ProviderState = "init"
 		r.IsResetting = false
+		notifyStateChanged()
 
 		// Free AppiumPort (common to all platforms)
-		common.MutexManager.LocalDevicePorts.Lock()
-		delete(providerutil.UsedPorts, r.AppiumPort)
-		common.MutexManager.LocalDevicePorts.Unlock()
+		providerutil.ReleasePort(r.AppiumPort)
+		go runResetHooks(r.DBDevice.UDID)
 		return true
 	}
 	return false
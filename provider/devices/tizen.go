@@ -1,6 +1,7 @@
 package devices
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -22,7 +23,8 @@ import (
 // TizenDevice holds Tizen TV-specific runtime state alongside the shared RuntimeState.
 type TizenDevice struct {
 	RuntimeState
-	DeviceAddress string // HOST_IP:PORT address of the Tizen TV
+	DeviceAddress string     // HOST_IP:PORT address of the Tizen TV
+	remoteMu      sync.Mutex // serializes the remote control connections so the TV asks to allow the provider only once
 }
 
 // Tizen auto-connection constants
@@ -54,13 +56,13 @@ func (d *TizenDevice) Setup() error {
 	d.SetProviderState("preparing")
 	logger.ProviderLogger.LogInfo("tizen_device_setup", fmt.Sprintf("Running setup for Tizen device `%v`", d.GetUDID()))
 
-	if err := d.getTVInfo(); err != nil {
+	if err := d.recordStep("get TV info", d.getTVInfo); err != nil {
 		logger.ProviderLogger.LogError("tizen_device_setup", fmt.Sprintf("Failed to get TV info for device `%v` - %v", d.GetUDID(), err))
 		d.Reset("Failed to retrieve TV information.")
 		return err
 	}
 
-	if err := setupAppiumForDevice(d); err != nil {
+	if err := d.recordStep("set up Appium", func() error { return setupAppiumForDevice(d) }); err != nil {
 		return err
 	}
 
@@ -129,7 +131,7 @@ func connectTizenDevice(deviceUDID string) error {
 		return fmt.Errorf("failed to extract IP from device UDID %s: %s", deviceUDID, err)
 	}
 
-	cmd := exec.Command("sdb", "connect", deviceIP)
+	cmd := exec.Command(config.Local.Tools.SDB, "connect", deviceIP)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to connect to Tizen device %s: %s. Output: %s", deviceUDID, err, string(output))
@@ -148,6 +150,25 @@ func getTizenRetryState(deviceID string) *tizenRetryState {
 	return tizenRetryTracker[deviceID]
 }
 
+// getTizenRetryDiagnostics returns the connection retry state of a Tizen TV for its diagnostics, nil if it was never retried
+func getTizenRetryDiagnostics(deviceID string) *models.TizenRetryDiagnostics {
+	state := getTizenRetryState(deviceID)
+	if state == nil {
+		return nil
+	}
+	diagnostics := &models.TizenRetryDiagnostics{
+		RetryCount: state.retryCount,
+		Paused:     state.isPaused,
+	}
+	if !state.lastAttempt.IsZero() {
+		diagnostics.LastAttempt = state.lastAttempt.UnixMilli()
+	}
+	if !state.pauseUntil.IsZero() {
+		diagnostics.PauseUntil = state.pauseUntil.UnixMilli()
+	}
+	return diagnostics
+}
+
 func updateTizenRetryState(deviceID string, retryCount int, lastAttempt time.Time, isPaused bool, pauseUntil time.Time) {
 	tizenRetryMutex.Lock()
 	defer tizenRetryMutex.Unlock()
@@ -230,7 +251,14 @@ func attemptTizenConnection(deviceUDID string) {
 	now := time.Now()
 	newRetryCount := state.retryCount + 1
 
-	err := connectTizenDevice(deviceUDID)
+	connect := func() error { return connectTizenDevice(deviceUDID) }
+	var err error
+	dev, _ := DevManager.Get(deviceUDID)
+	if tizenDev, ok := dev.(*TizenDevice); ok {
+		err = tizenDev.recordStep("connect with sdb", connect)
+	} else {
+		err = connect()
+	}
 	if err != nil {
 		updateTizenRetryState(deviceUDID, newRetryCount, now, false, time.Time{})
 		if newRetryCount >= tizenMaxRetries {
@@ -342,6 +370,7 @@ func (d *TizenDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 			AppName:          app.Title,
 			BundleIdentifier: app.AppID,
 			CanUninstall:     app.IsDevApp,
+			Version:          app.Version,
 		})
 	}
 	return result, nil
@@ -350,7 +379,7 @@ func (d *TizenDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 func (d *TizenDevice) getInstalledAppsTizen() []TizenApp {
 	apps := []TizenApp{}
 
-	cmd := exec.Command("sdb", "-s", d.GetUDID(), "shell", "0", "vd_applist")
+	cmd := exec.Command(config.Local.Tools.SDB, "-s", d.GetUDID(), "shell", "0", "vd_applist")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("tizen_list_apps", fmt.Sprintf("Failed to list apps for device %s: %v", d.GetUDID(), err))
@@ -430,7 +459,7 @@ func (d *TizenDevice) LaunchApp(appID string) error {
 
 // CloseApp closes an app on the Tizen device.
 func (d *TizenDevice) CloseApp(appID string) error {
-	cmd := exec.Command("sdb", "-s", d.GetUDID(), "shell", "0", "was_kill", appID)
+	cmd := exec.Command(config.Local.Tools.SDB, "-s", d.GetUDID(), "shell", "0", "was_kill", appID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to close app %s: %s. Output: %s", appID, err, string(output))
@@ -443,9 +472,40 @@ func (d *TizenDevice) KillApp(appID string) error {
 	return d.CloseApp(appID)
 }
 
+// Reboot restarts the TV with `sdb shell reboot`, the sdb connection drops until it is connected again after the boot.
+func (d *TizenDevice) Reboot() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, config.Local.Tools.SDB, "-s", d.GetUDID(), "shell", "reboot").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Reboot: sdb reboot failed - %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// ClearAppData is not supported on Tizen.
+func (d *TizenDevice) ClearAppData(appID string) error {
+	return fmt.Errorf("ClearAppData: %w", ErrUnsupportedOperation)
+}
+
+// GrantAppPermissions is not supported on Tizen.
+func (d *TizenDevice) GrantAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("GrantAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// RevokeAppPermissions is not supported on Tizen.
+func (d *TizenDevice) RevokeAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("RevokeAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// ResetAppPermissions is not supported on Tizen.
+func (d *TizenDevice) ResetAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("ResetAppPermissions: %w", ErrUnsupportedOperation)
+}
+
 func getConnectedDevicesTizen() []string {
 	var devices []string
-	cmd := exec.Command("sdb", "devices")
+	cmd := exec.Command(config.Local.Tools.SDB, "devices")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("device_setup", fmt.Sprintf("Failed to get connected Tizen devices - %s", err))
@@ -0,0 +1,138 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// samsungRemoteName is the remote name shown on the TV when it asks to allow the provider
+const samsungRemoteName = "GADS"
+
+// samsungRemoteEvent is a message of the Samsung remote control WebSocket API
+type samsungRemoteEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+// remoteConnect connects to the Samsung remote control WebSocket API of the TV.
+// Newer TVs only accept the secure API on port 8002 and give a token once the provider is allowed on the TV,
+// older ones only have the plain API on port 8001.
+func (d *TizenDevice) remoteConnect() (net.Conn, error) {
+	host, err := getTizenTVHost(d.GetUDID())
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"name": {base64.StdEncoding.EncodeToString([]byte(samsungRemoteName))}}
+	if token := getTVRemoteKey(d.GetUDID()); token != "" {
+		query.Set("token", token)
+	}
+	path := "/api/v2/channels/samsung.remote.control?" + query.Encode()
+
+	dialer := ws.Dialer{
+		Timeout: tvRemoteDialTimeout,
+		// The TVs use self-signed certificates
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	conn, _, _, err := dialer.Dial(context.Background(), fmt.Sprintf("wss://%s:8002%s", host, path))
+	if err != nil {
+		var plainErr error
+		conn, _, _, plainErr = dialer.Dial(context.Background(), fmt.Sprintf("ws://%s:8001%s", host, path))
+		if plainErr != nil {
+			return nil, fmt.Errorf("failed to connect to the TV remote control API - %w", err)
+		}
+	}
+
+	// The TV answers when the provider is allowed, a new remote waits for the prompt on the TV
+	conn.SetReadDeadline(time.Now().Add(tvRemotePairingTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		message, err := wsutil.ReadServerText(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("the TV did not accept the remote control connection, allow `%s` on the TV - %w", samsungRemoteName, err)
+		}
+		var event samsungRemoteEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			continue
+		}
+		switch event.Event {
+		case "ms.channel.connect":
+			if event.Data.Token != "" {
+				setTVRemoteKey(d.GetUDID(), event.Data.Token)
+			}
+			return conn, nil
+		case "ms.channel.unauthorized", "ms.channel.timeOut":
+			conn.Close()
+			return nil, fmt.Errorf("remote control of the TV was denied, allow `%s` on the TV", samsungRemoteName)
+		}
+	}
+}
+
+// remoteControl sends a remote control command to the TV
+func (d *TizenDevice) remoteControl(params map[string]string) error {
+	d.remoteMu.Lock()
+	defer d.remoteMu.Unlock()
+
+	conn, err := d.remoteConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	message, err := json.Marshal(map[string]any{
+		"method": "ms.remote.control",
+		"params": params,
+	})
+	if err != nil {
+		return err
+	}
+	if err := wsutil.WriteClientText(conn, message); err != nil {
+		return fmt.Errorf("failed to send the remote control command - %w", err)
+	}
+	// Close gracefully so the TV handles the command before the connection goes away
+	return wsutil.WriteClientMessage(conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+}
+
+// PressRemoteKey presses a Samsung remote key, e.g. `KEY_HOME`
+func (d *TizenDevice) PressRemoteKey(key string) error {
+	return d.remoteControl(map[string]string{
+		"Cmd":          "Click",
+		"DataOfCmd":    key,
+		"Option":       "false",
+		"TypeOfRemote": "SendRemoteKey",
+	})
+}
+
+// TypeText types the text into the focused input field of the TV
+func (d *TizenDevice) TypeText(text string) error {
+	return d.remoteControl(map[string]string{
+		"Cmd":          base64.StdEncoding.EncodeToString([]byte(text)),
+		"DataOfCmd":    "base64",
+		"TypeOfRemote": "SendInputString",
+	})
+}
+
+// Screenshot is not supported on Tizen, the remote control API cannot capture the screen.
+func (d *TizenDevice) Screenshot() ([]byte, error) {
+	return nil, fmt.Errorf("Screenshot: %w", ErrUnsupportedOperation)
+}
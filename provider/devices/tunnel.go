@@ -0,0 +1,121 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/provider/config"
+	"GADS/provider/providerutil"
+
+	"github.com/danielpaulus/go-ios/ios"
+)
+
+const tunnelDialTimeout = 10 * time.Second
+
+// cleanupConn runs a cleanup once the connection is closed, e.g. to remove the adb forward it goes through
+type cleanupConn struct {
+	net.Conn
+	once    sync.Once
+	cleanup func()
+}
+
+func (c *cleanupConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.cleanup)
+	return err
+}
+
+// DialDevicePort opens a TCP connection to a port on the device itself
+func DialDevicePort(dev PlatformDevice, port int) (net.Conn, error) {
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+
+	switch d := dev.(type) {
+	case *AndroidDevice:
+		return d.dialDevicePort(port)
+	case *IOSDevice:
+		return d.dialDevicePort(port)
+	default:
+		return nil, fmt.Errorf("tunnels to device ports are not supported for %s devices", dev.GetOS())
+	}
+}
+
+// DialProviderPort opens a TCP connection to a provider host port that belongs to the device.
+// Only ports allocated for the device are allowed so tunnels cannot reach other services on the provider host.
+func DialProviderPort(dev PlatformDevice, port int) (net.Conn, error) {
+	portString := strconv.Itoa(port)
+	if !slices.Contains(DeviceHostPorts(dev), portString) {
+		return nil, fmt.Errorf("provider port %d is not allocated for device %s", port, dev.GetUDID())
+	}
+	return net.DialTimeout("tcp", "localhost:"+portString, tunnelDialTimeout)
+}
+
+// DeviceHostPorts returns the provider host ports currently allocated for the device
+func DeviceHostPorts(dev PlatformDevice) []string {
+	ports := []string{dev.GetAppiumPort()}
+	switch d := dev.(type) {
+	case *AndroidDevice:
+		ports = append(ports, d.StreamPort, d.AndroidIMEPort, d.AndroidRemoteServerPort, d.ADBPort)
+		d.devToolsMu.Lock()
+		for _, port := range d.devToolsForwards {
+			ports = append(ports, port)
+		}
+		d.devToolsMu.Unlock()
+	case *IOSDevice:
+		ports = append(ports, d.WDAPort, d.WDAStreamPort, d.StreamPort)
+	}
+	return slices.DeleteFunc(ports, func(port string) bool { return port == "" })
+}
+
+// dialDevicePort forwards a free host port to the device port for the lifetime of the connection
+func (d *AndroidDevice) dialDevicePort(port int) (net.Conn, error) {
+	hostPort, err := providerutil.AllocatePort(config.PortTunnel, d.GetUDID())
+	if err != nil {
+		return nil, fmt.Errorf("could not allocate free host port for the tunnel - %w", err)
+	}
+	cmd := exec.CommandContext(d.Context, config.Local.Tools.ADB, "-s", d.GetUDID(), "forward", "tcp:"+hostPort, "tcp:"+strconv.Itoa(port))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		releaseHostPort(hostPort)
+		return nil, fmt.Errorf("failed to forward device port %d - %s: %w", port, strings.TrimSpace(string(out)), err)
+	}
+	removeForward := func() {
+		exec.Command(config.Local.Tools.ADB, "-s", d.GetUDID(), "forward", "--remove", "tcp:"+hostPort).Run()
+		releaseHostPort(hostPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", "localhost:"+hostPort, tunnelDialTimeout)
+	if err != nil {
+		removeForward()
+		return nil, err
+	}
+	return &cleanupConn{Conn: conn, cleanup: removeForward}, nil
+}
+
+// dialDevicePort connects to the device port through usbmuxd
+func (d *IOSDevice) dialDevicePort(port int) (net.Conn, error) {
+	muxConn, err := ios.NewUsbMuxConnectionSimple()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to usbmuxd - %w", err)
+	}
+	if err := muxConn.Connect(d.GoIOSDeviceEntry.DeviceID, uint16(port)); err != nil {
+		muxConn.Close()
+		return nil, fmt.Errorf("failed to connect to device port %d - %w", port, err)
+	}
+	return muxConn.ReleaseDeviceConnection().Conn(), nil
+}
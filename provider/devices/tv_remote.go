@@ -0,0 +1,85 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"GADS/provider/config"
+	"GADS/provider/logger"
+)
+
+const (
+	// tvRemoteDialTimeout is the timeout for connecting to the TV remote control APIs
+	tvRemoteDialTimeout = 5 * time.Second
+	// tvRemotePairingTimeout is how long the TV remote control APIs wait for the pairing prompt on the TV to be accepted
+	tvRemotePairingTimeout = 60 * time.Second
+)
+
+var (
+	// tvRemoteKeys are the pairing keys the TVs gave the provider, by UDID. They are kept in the provider folder
+	// so the TVs do not ask to allow the provider again after it restarts
+	tvRemoteKeys       map[string]string
+	tvRemoteKeysMu     sync.Mutex
+	tvRemoteKeysLoaded bool
+)
+
+func tvRemoteKeysFile() string {
+	return filepath.Join(config.ProviderConfig.ProviderFolder, "tv_remote_keys.json")
+}
+
+// getTVRemoteKey returns the pairing key of the TV, empty if it was not paired yet
+func getTVRemoteKey(udid string) string {
+	tvRemoteKeysMu.Lock()
+	defer tvRemoteKeysMu.Unlock()
+
+	loadTVRemoteKeys()
+	return tvRemoteKeys[udid]
+}
+
+// setTVRemoteKey stores the pairing key of the TV
+func setTVRemoteKey(udid, key string) {
+	tvRemoteKeysMu.Lock()
+	defer tvRemoteKeysMu.Unlock()
+
+	loadTVRemoteKeys()
+	if tvRemoteKeys[udid] == key {
+		return
+	}
+	tvRemoteKeys[udid] = key
+
+	data, _ := json.MarshalIndent(tvRemoteKeys, "", "  ")
+	if err := os.WriteFile(tvRemoteKeysFile(), data, 0600); err != nil {
+		logger.ProviderLogger.LogWarn("tv_remote", fmt.Sprintf("Failed to save the remote control pairing key of `%s` - %s", udid, err))
+	}
+}
+
+// loadTVRemoteKeys reads the stored pairing keys once, tvRemoteKeysMu must be held
+func loadTVRemoteKeys() {
+	if tvRemoteKeysLoaded {
+		return
+	}
+	tvRemoteKeysLoaded = true
+	tvRemoteKeys = make(map[string]string)
+
+	data, err := os.ReadFile(tvRemoteKeysFile())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &tvRemoteKeys); err != nil {
+		logger.ProviderLogger.LogWarn("tv_remote", fmt.Sprintf("Failed to parse the remote control pairing keys, the TVs will ask to allow the provider again - %s", err))
+		tvRemoteKeys = make(map[string]string)
+	}
+}
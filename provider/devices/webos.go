@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"GADS/common/models"
 	"GADS/common/utils"
@@ -16,7 +17,8 @@ import (
 // WebOSDevice holds WebOS TV-specific runtime state alongside the shared RuntimeState.
 type WebOSDevice struct {
 	RuntimeState
-	DeviceAddress string // IP address of the WebOS TV (same as UDID for WebOS)
+	DeviceAddress string     // IP address of the WebOS TV (same as UDID for WebOS)
+	remoteMu      sync.Mutex // serializes the remote control connections so the TV asks to allow the provider only once
 }
 
 // connectedWebOSDevice represents a WebOS device returned by ares-setup-device --list
@@ -35,7 +37,7 @@ func (d *WebOSDevice) Setup() error {
 
 	d.DBDevice.IPAddress = d.GetUDID()
 
-	if err := setupAppiumForDevice(d); err != nil {
+	if err := d.recordStep("set up Appium", func() error { return setupAppiumForDevice(d) }); err != nil {
 		return err
 	}
 
@@ -59,7 +61,7 @@ func (d *WebOSDevice) AppiumCapabilities() models.AppiumServerCapabilities {
 
 // getConnectedDevicesWebOS gets the connected WebOS devices using ares-setup-device
 func getConnectedDevicesWebOS() []string {
-	cmd := exec.Command("ares-setup-device", "--list")
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares-setup-device"), "--list")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("webos_device_detection", fmt.Sprintf("Failed to get WebOS devices: %s", err))
@@ -108,7 +110,7 @@ func (d *WebOSDevice) InstallApp(appName string) error {
 	if strings.HasSuffix(appName, ".ipk") {
 		logger.ProviderLogger.LogInfo("webos_install_app", fmt.Sprintf("Installing .ipk file directly on device %s", d.GetUDID()))
 
-		installCmd := exec.Command("ares-install", "--device", d.DBDevice.Name, appPath)
+		installCmd := exec.Command(config.Local.Tools.AresCommand("ares-install"), "--device", d.DBDevice.Name, appPath)
 		output, err := installCmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("failed to install .ipk: %s. Output: %s", err, string(output))
@@ -133,7 +135,7 @@ func (d *WebOSDevice) InstallApp(appName string) error {
 
 	logger.ProviderLogger.LogInfo("webos_install_app", fmt.Sprintf("Packaging app for device %s", d.GetUDID()))
 
-	packageCmd := exec.Command("ares-package", tempDir)
+	packageCmd := exec.Command(config.Local.Tools.AresCommand("ares-package"), tempDir)
 	output, err := packageCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to package app: %s. Output: %s", err, string(output))
@@ -160,7 +162,7 @@ func (d *WebOSDevice) InstallApp(appName string) error {
 
 	logger.ProviderLogger.LogInfo("webos_install_app", fmt.Sprintf("Installing app on device %s", d.GetUDID()))
 
-	installCmd := exec.Command("ares-install", "--device", d.DBDevice.Name, ipkFile)
+	installCmd := exec.Command(config.Local.Tools.AresCommand("ares-install"), "--device", d.DBDevice.Name, ipkFile)
 	output, err = installCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to install app: %s. Output: %s", err, string(output))
@@ -174,7 +176,7 @@ func (d *WebOSDevice) InstallApp(appName string) error {
 func (d *WebOSDevice) UninstallApp(appID string) error {
 	logger.ProviderLogger.LogInfo("webos_uninstall_app", fmt.Sprintf("Uninstalling app %s from device %s", appID, d.GetUDID()))
 
-	cmd := exec.Command("ares-install", "--device", d.DBDevice.Name, "--remove", appID)
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares-install"), "--device", d.DBDevice.Name, "--remove", appID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("webos_uninstall_app", fmt.Sprintf("Failed to uninstall app %s from device %s: %v. Output: %s", appID, d.GetUDID(), err, string(output)))
@@ -202,6 +204,7 @@ func (d *WebOSDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 			AppName:          app.Title,
 			BundleIdentifier: app.AppID,
 			CanUninstall:     app.IsDevApp,
+			Version:          app.Version,
 		})
 	}
 	return result, nil
@@ -210,7 +213,7 @@ func (d *WebOSDevice) GetInstalledApps() ([]models.DeviceApp, error) {
 func (d *WebOSDevice) getInstalledAppsWebOS() []WebOSApp {
 	apps := []WebOSApp{}
 
-	cmd := exec.Command("ares-install", "--device", d.DBDevice.Name, "--listfull")
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares-install"), "--device", d.DBDevice.Name, "--listfull")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("webos_list_apps", fmt.Sprintf("Failed to list apps for device %s: %v. Output: %s", d.GetUDID(), err, string(output)))
@@ -274,7 +277,18 @@ func (d *WebOSDevice) GetInstalledAppBundleIDs() []string {
 func (d *WebOSDevice) LaunchApp(appID string) error {
 	logger.ProviderLogger.LogInfo("webos_launch_app", fmt.Sprintf("Launching app %s on device %s", appID, d.GetUDID()))
 
-	cmd := exec.Command("ares-launch", "--device", d.DBDevice.Name, appID)
+	// A paired TV launches the app through SSAP without the `ares` tools, they are the fallback
+	// so launching never waits for the pairing prompt on the TV
+	if getTVRemoteKey(d.GetUDID()) != "" {
+		ssapErr := d.remoteRequest("ssap://system.launcher/launch", map[string]string{"id": appID}, nil)
+		if ssapErr == nil {
+			logger.ProviderLogger.LogInfo("webos_launch_app", fmt.Sprintf("Successfully launched app %s on device %s", appID, d.GetUDID()))
+			return nil
+		}
+		logger.ProviderLogger.LogDebug("webos_launch_app", fmt.Sprintf("Failed to launch app %s on device %s through SSAP, falling back to ares-launch - %s", appID, d.GetUDID(), ssapErr))
+	}
+
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares-launch"), "--device", d.DBDevice.Name, appID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("webos_launch_app", fmt.Sprintf("Failed to launch app %s on device %s: %v. Output: %s", appID, d.GetUDID(), err, string(output)))
@@ -289,7 +303,7 @@ func (d *WebOSDevice) LaunchApp(appID string) error {
 func (d *WebOSDevice) CloseApp(appID string) error {
 	logger.ProviderLogger.LogInfo("webos_close_app", fmt.Sprintf("Closing app %s on device %s", appID, d.GetUDID()))
 
-	cmd := exec.Command("ares-launch", "--device", d.DBDevice.Name, "--close", appID)
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares-launch"), "--device", d.DBDevice.Name, "--close", appID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.ProviderLogger.LogError("webos_close_app", fmt.Sprintf("Failed to close app %s on device %s: %v. Output: %s", appID, d.GetUDID(), err, string(output)))
@@ -305,3 +319,27 @@ func (d *WebOSDevice) KillApp(appID string) error {
 	return d.CloseApp(appID)
 }
 
+// Reboot is not supported on WebOS, the `ares` tools cannot restart the TV.
+func (d *WebOSDevice) Reboot() error {
+	return fmt.Errorf("Reboot: %w", ErrUnsupportedOperation)
+}
+
+// ClearAppData is not supported on WebOS.
+func (d *WebOSDevice) ClearAppData(appID string) error {
+	return fmt.Errorf("ClearAppData: %w", ErrUnsupportedOperation)
+}
+
+// GrantAppPermissions is not supported on WebOS.
+func (d *WebOSDevice) GrantAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("GrantAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// RevokeAppPermissions is not supported on WebOS.
+func (d *WebOSDevice) RevokeAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("RevokeAppPermissions: %w", ErrUnsupportedOperation)
+}
+
+// ResetAppPermissions is not supported on WebOS.
+func (d *WebOSDevice) ResetAppPermissions(appID string, permissions []string) error {
+	return fmt.Errorf("ResetAppPermissions: %w", ErrUnsupportedOperation)
+}
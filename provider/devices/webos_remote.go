@@ -0,0 +1,348 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// webOSScreenshotPath is where the TV keeps the captured screen until the provider downloads it
+const webOSScreenshotPath = "/tmp/gads_capture.png"
+
+// webOSPermissions are the permissions the provider asks for when the TV is paired
+var webOSPermissions = []string{
+	"LAUNCH",
+	"LAUNCH_WEBAPP",
+	"APP_TO_APP",
+	"CLOSE",
+	"CONTROL_INPUT_TEXT",
+	"CONTROL_MOUSE_AND_KEYBOARD",
+	"CONTROL_INPUT_MEDIA_PLAYBACK",
+	"CONTROL_AUDIO",
+	"CONTROL_DISPLAY",
+	"CONTROL_TV_SCREEN",
+	"READ_INSTALLED_APPS",
+	"READ_RUNNING_APPS",
+}
+
+// ssapMessage is a message of the LG SSAP (Simple Service Access Protocol) WebSocket API
+type ssapMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	URI     string          `json:"uri,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// ssapConn is a registered SSAP connection to a WebOS TV
+type ssapConn struct {
+	conn   net.Conn
+	nextID int
+}
+
+var webOSDialer = ws.Dialer{
+	Timeout: tvRemoteDialTimeout,
+	// The TVs use self-signed certificates
+	TLSConfig: &tls.Config{InsecureSkipVerify: true},
+}
+
+var webOSHTTPClient = &http.Client{
+	Timeout:   tvRemoteDialTimeout,
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+}
+
+// remoteConnect connects to the SSAP API of the TV and registers the provider.
+// Newer TVs only accept the secure API on port 3001, older ones only have the plain API on port 3000.
+// The first registration waits for the prompt on the TV to be accepted and keeps the client key it gives.
+func (d *WebOSDevice) remoteConnect() (*ssapConn, error) {
+	host := webOSHost(d.GetUDID())
+	conn, _, _, err := webOSDialer.Dial(context.Background(), fmt.Sprintf("wss://%s:3001", host))
+	if err != nil {
+		var plainErr error
+		conn, _, _, plainErr = webOSDialer.Dial(context.Background(), fmt.Sprintf("ws://%s:3000", host))
+		if plainErr != nil {
+			return nil, fmt.Errorf("failed to connect to the TV remote control API - %w", err)
+		}
+	}
+	s := &ssapConn{conn: conn}
+
+	registerPayload := map[string]any{
+		"forcePairing": false,
+		"pairingType":  "PROMPT",
+		"manifest": map[string]any{
+			"manifestVersion": 1,
+			"appVersion":      "1.0",
+			"permissions":     webOSPermissions,
+		},
+	}
+	if clientKey := getTVRemoteKey(d.GetUDID()); clientKey != "" {
+		registerPayload["client-key"] = clientKey
+	}
+	if err := s.send("register", "register_0", "", registerPayload); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(tvRemotePairingTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		message, err := s.read()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("the TV did not accept the remote control connection, allow the provider on the TV - %w", err)
+		}
+		if message.ID != "register_0" {
+			continue
+		}
+		switch message.Type {
+		case "registered":
+			var registered struct {
+				ClientKey string `json:"client-key"`
+			}
+			json.Unmarshal(message.Payload, &registered)
+			if registered.ClientKey != "" {
+				setTVRemoteKey(d.GetUDID(), registered.ClientKey)
+			}
+			return s, nil
+		case "error":
+			conn.Close()
+			return nil, fmt.Errorf("remote control of the TV was denied - %s", message.Error)
+		}
+	}
+}
+
+// webOSHost returns the IP address of the TV, the UDID can include the `ares` connection port
+func webOSHost(udid string) string {
+	if host, _, err := net.SplitHostPort(udid); err == nil {
+		return host
+	}
+	return udid
+}
+
+func (s *ssapConn) send(messageType, id, uri string, payload any) error {
+	message := ssapMessage{Type: messageType, ID: id, URI: uri}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		message.Payload = data
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return wsutil.WriteClientText(s.conn, data)
+}
+
+func (s *ssapConn) read() (ssapMessage, error) {
+	var message ssapMessage
+	data, err := wsutil.ReadServerText(s.conn)
+	if err != nil {
+		return message, err
+	}
+	err = json.Unmarshal(data, &message)
+	return message, err
+}
+
+// request calls an SSAP URI, e.g. `ssap://system.launcher/launch`, and decodes the response payload into result
+func (s *ssapConn) request(uri string, payload any, result any) error {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	if err := s.send("request", id, uri, payload); err != nil {
+		return fmt.Errorf("failed to send `%s` - %w", uri, err)
+	}
+
+	s.conn.SetReadDeadline(time.Now().Add(tvRemoteDialTimeout))
+	defer s.conn.SetReadDeadline(time.Time{})
+	for {
+		message, err := s.read()
+		if err != nil {
+			return fmt.Errorf("failed to read the response of `%s` - %w", uri, err)
+		}
+		if message.ID != id {
+			continue
+		}
+		if message.Type == "error" {
+			return fmt.Errorf("`%s` failed - %s", uri, message.Error)
+		}
+
+		var status struct {
+			ReturnValue bool   `json:"returnValue"`
+			ErrorText   string `json:"errorText"`
+		}
+		if err := json.Unmarshal(message.Payload, &status); err != nil {
+			return fmt.Errorf("failed to parse the response of `%s` - %w", uri, err)
+		}
+		if !status.ReturnValue {
+			return fmt.Errorf("`%s` failed - %s", uri, status.ErrorText)
+		}
+		if result != nil {
+			return json.Unmarshal(message.Payload, result)
+		}
+		return nil
+	}
+}
+
+func (s *ssapConn) close() {
+	wsutil.WriteClientMessage(s.conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+	s.conn.Close()
+}
+
+// remoteRequest calls an SSAP URI on a new registered connection to the TV
+func (d *WebOSDevice) remoteRequest(uri string, payload any, result any) error {
+	d.remoteMu.Lock()
+	defer d.remoteMu.Unlock()
+
+	s, err := d.remoteConnect()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	return s.request(uri, payload, result)
+}
+
+// PressRemoteKey presses a WebOS remote button, e.g. `HOME`, through the pointer input socket of the TV
+func (d *WebOSDevice) PressRemoteKey(key string) error {
+	d.remoteMu.Lock()
+	defer d.remoteMu.Unlock()
+
+	s, err := d.remoteConnect()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	var pointer struct {
+		SocketPath string `json:"socketPath"`
+	}
+	if err := s.request("ssap://com.webos.service.networkinput/getPointerInputSocket", nil, &pointer); err != nil {
+		return err
+	}
+
+	pointerConn, _, _, err := webOSDialer.Dial(context.Background(), pointer.SocketPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the pointer input socket - %w", err)
+	}
+	defer pointerConn.Close()
+
+	if err := wsutil.WriteClientText(pointerConn, []byte(fmt.Sprintf("type:button\nname:%s\n\n", key))); err != nil {
+		return fmt.Errorf("failed to press `%s` - %w", key, err)
+	}
+	return wsutil.WriteClientMessage(pointerConn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+}
+
+// TypeText types the text into the focused input field of the TV
+func (d *WebOSDevice) TypeText(text string) error {
+	return d.remoteRequest("ssap://com.webos.service.ime/insertText", map[string]any{"text": text, "replace": 0}, nil)
+}
+
+// Screenshot captures the TV screen as PNG
+func (d *WebOSDevice) Screenshot() ([]byte, error) {
+	d.remoteMu.Lock()
+	defer d.remoteMu.Unlock()
+
+	s, err := d.remoteConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	return s.screenshot()
+}
+
+// webOSScreenCapture keeps one registered SSAP connection for consecutive screenshots, it reconnects after a failure
+type webOSScreenCapture struct {
+	device *WebOSDevice
+	conn   *ssapConn
+}
+
+// OpenScreenCapture registers a connection to the TV that is kept for the screenshots until the capture is closed.
+// The connection is separate from the one of the remote commands so keys can be pressed during a stream.
+func (d *WebOSDevice) OpenScreenCapture() (TVScreenCapture, error) {
+	capture := &webOSScreenCapture{device: d}
+	if err := capture.connect(); err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
+
+func (c *webOSScreenCapture) connect() error {
+	// Registering can show the pairing prompt on the TV, so it is not done concurrently with the remote commands
+	c.device.remoteMu.Lock()
+	defer c.device.remoteMu.Unlock()
+
+	s, err := c.device.remoteConnect()
+	if err != nil {
+		return err
+	}
+	c.conn = s
+	return nil
+}
+
+func (c *webOSScreenCapture) Screenshot() ([]byte, error) {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	screenshot, err := c.conn.screenshot()
+	if err != nil {
+		// The connection can break while the TV switches apps, the next screenshot connects again
+		c.Close()
+		return nil, err
+	}
+	return screenshot, nil
+}
+
+func (c *webOSScreenCapture) Close() {
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+}
+
+// screenshot captures the TV screen as PNG on the registered connection
+func (s *ssapConn) screenshot() ([]byte, error) {
+	var capture struct {
+		ImageURI string `json:"imageUri"`
+	}
+	err := s.request("ssap://tv/executeOneShot", map[string]string{
+		"path":   webOSScreenshotPath,
+		"method": "DISPLAY",
+		"format": "PNG",
+	}, &capture)
+	if err != nil {
+		return nil, err
+	}
+	if capture.ImageURI == "" {
+		return nil, fmt.Errorf("the TV did not return the screenshot location")
+	}
+
+	resp, err := webOSHTTPClient.Get(capture.ImageURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the screenshot - %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download the screenshot, status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
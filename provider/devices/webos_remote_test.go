@@ -0,0 +1,114 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package devices
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws/wsutil"
+)
+
+// fakeSSAPTV answers the first SSAP request on the connection with the payload, or with an error message when errorText is set.
+// The request it received is sent to the returned channel.
+func fakeSSAPTV(t *testing.T, conn net.Conn, payload map[string]any, errorText string) <-chan ssapMessage {
+	t.Helper()
+	received := make(chan ssapMessage, 1)
+	go func() {
+		defer close(received)
+		data, err := wsutil.ReadClientText(conn)
+		if err != nil {
+			return
+		}
+		var request ssapMessage
+		if err := json.Unmarshal(data, &request); err != nil {
+			return
+		}
+		received <- request
+
+		response := ssapMessage{Type: "response", ID: request.ID}
+		if errorText != "" {
+			response.Type = "error"
+			response.Error = errorText
+		} else {
+			response.Payload, _ = json.Marshal(payload)
+		}
+		// A message of another request is skipped by the client
+		other, _ := json.Marshal(ssapMessage{Type: "response", ID: "other", Payload: json.RawMessage(`{"returnValue":true}`)})
+		wsutil.WriteServerText(conn, other)
+		data, _ = json.Marshal(response)
+		wsutil.WriteServerText(conn, data)
+	}()
+	return received
+}
+
+func TestSSAPScreenshot(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nscreen")
+	tvHTTP := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/resources/capture.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(png)
+	}))
+	defer tvHTTP.Close()
+
+	tests := []struct {
+		name      string
+		payload   map[string]any
+		errorText string
+		wantErr   string
+	}{
+		{
+			name:    "screenshot is downloaded",
+			payload: map[string]any{"returnValue": true, "imageUri": tvHTTP.URL + "/resources/capture.png"},
+		},
+		{name: "TV refuses the capture", errorText: "401 insufficient permissions", wantErr: "insufficient permissions"},
+		{name: "capture fails", payload: map[string]any{"returnValue": false, "errorText": "no display"}, wantErr: "no display"},
+		{name: "no screenshot location", payload: map[string]any{"returnValue": true}, wantErr: "did not return the screenshot location"},
+		{
+			name:    "screenshot cannot be downloaded",
+			payload: map[string]any{"returnValue": true, "imageUri": tvHTTP.URL + "/resources/missing.png"},
+			wantErr: "status code 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, tv := net.Pipe()
+			defer client.Close()
+			defer tv.Close()
+			received := fakeSSAPTV(t, tv, tt.payload, tt.errorText)
+
+			screenshot, err := (&ssapConn{conn: client}).screenshot()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("screenshot() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil || string(screenshot) != string(png) {
+				t.Errorf("screenshot() = %q, %v, want the PNG", screenshot, err)
+			}
+
+			request := <-received
+			if request.Type != "request" || request.URI != "ssap://tv/executeOneShot" {
+				t.Fatalf("TV received %s `%s`, want a request of ssap://tv/executeOneShot", request.Type, request.URI)
+			}
+			var capture map[string]string
+			json.Unmarshal(request.Payload, &capture)
+			if capture["path"] != webOSScreenshotPath || capture["method"] != "DISPLAY" || capture["format"] != "PNG" {
+				t.Errorf("capture payload = %v", capture)
+			}
+		})
+	}
+}
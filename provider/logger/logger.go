@@ -42,7 +42,9 @@ func SetupLogging(level string) {
 	logLevel = level
 
 	var err error
-	fmt.Println(fmt.Sprintf("Provider will be logging to `%s/provider.log`", config.ProviderConfig.ProviderFolder))
+	if config.Local.Logging.File {
+		fmt.Println(fmt.Sprintf("Provider will be logging to `%s/provider.log`", config.ProviderConfig.ProviderFolder))
+	}
 	ProviderLogger, err = CreateCustomLogger(fmt.Sprintf("%s/provider.log", config.ProviderConfig.ProviderFolder), config.ProviderConfig.Nickname)
 	if err != nil {
 		log.Fatalf("Failed to create custom logger for the provider instance - %s", err)
@@ -88,27 +90,34 @@ func (l CustomLogger) LogPanic(eventName string, message string) {
 func CreateCustomLogger(logFilePath, collection string) (*CustomLogger, error) {
 	// Create a new logger instance
 	logger := log.New()
-	ctx, _ := context.WithCancel(db.GlobalMongoStore.Ctx)
 
 	// Configure the logger
 	logger.SetFormatter(&log.JSONFormatter{})
 	logger.SetLevel(logLevelMapping[logLevel])
 
-	// Open the log file
-	logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return &CustomLogger{}, fmt.Errorf("Could not set log output - %v", err)
+	// Set the output to the enabled sinks - the log file and standard output (console)
+	var outputs []io.Writer
+	if config.Local.Logging.File {
+		logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			return &CustomLogger{}, fmt.Errorf("Could not set log output - %v", err)
+		}
+		outputs = append(outputs, logFile)
+	}
+	if config.Local.Logging.Stdout {
+		outputs = append(outputs, os.Stdout)
+	}
+	logger.SetOutput(io.MultiWriter(outputs...))
+
+	if config.Local.Logging.MongoDB && db.GlobalMongoStore != nil {
+		ctx, _ := context.WithCancel(db.GlobalMongoStore.Ctx)
+		logger.AddHook(&MongoDBHook{
+			Client:     db.GlobalMongoStore.Client,
+			DB:         "logs",
+			Collection: collection,
+			Ctx:        ctx,
+		})
 	}
-
-	// Set the output to both the log file and standard output (console)
-	logger.SetOutput(io.MultiWriter(logFile, os.Stdout))
-
-	logger.AddHook(&MongoDBHook{
-		Client:     db.GlobalMongoStore.Client,
-		DB:         "logs",
-		Collection: collection,
-		Ctx:        ctx,
-	})
 
 	return &CustomLogger{Logger: logger}, nil
 }
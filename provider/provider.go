@@ -17,27 +17,32 @@ import (
 	"GADS/provider/logger"
 	"GADS/provider/providerutil"
 	"GADS/provider/router"
+	"GADS/provider/store"
 	"embed"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
 	"time"
 
 	"github.com/spf13/pflag"
 )
 
-var targetAppiumPluginVersion = "0.0.11"
-
 func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
-	logLevel, _ := flags.GetString("log-level")
-	nickname, _ := flags.GetString("nickname")
-	mongoDb, _ := flags.GetString("mongo-db")
-	providerFolder, _ := flags.GetString("provider-folder")
-	hubAddress, _ := flags.GetString("hub")
-	turnUsernameSuffix, _ := flags.GetString("turn-username-suffix")
-	useIOSPairCache, _ := flags.GetBool("use-ios-pair-cache")
+	drainTimeout, _ := flags.GetDuration("drain-timeout")
+
+	localConfig, err := config.LoadLocalConfig(flags)
+	if err != nil {
+		log.Fatalf("Failed to load the provider configuration - %s", err)
+	}
+	config.Local = localConfig
+
+	nickname := localConfig.Nickname
+	providerFolder := localConfig.ProviderFolder
+	hubAddress := localConfig.Hub
 
 	if nickname == "" {
 		log.Fatalf("Please provide valid provider instance nickname via the --nickname flag, e.g. --nickname=Provider1")
@@ -54,38 +59,47 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 	fmt.Println("Preparing...")
 
 	// Create the provider folder if needed
-	err := os.MkdirAll(providerFolder, os.ModePerm)
+	err = os.MkdirAll(providerFolder, os.ModePerm)
 	if err != nil {
 		log.Fatalf("Failed to create provider folder `%s` - %s", providerFolder, err)
 	}
 
-	db.InitMongo(mongoDb, "gads")
-	defer db.GlobalMongoStore.Close()
+	// Providers with a provider token get their configuration and data from the hub and do not connect to MongoDB
+	if localConfig.HubManaged() {
+		store.GlobalStore = store.NewHubStore(config.ParseHubAddresses(hubAddress), localConfig.ProviderToken, localConfig.MockDevices)
+	} else {
+		db.InitMongo(localConfig.MongoDB, "gads")
+		defer db.GlobalMongoStore.Close()
+		store.GlobalStore = db.GlobalMongoStore
+	}
 
 	// Set up the provider configuration
 	config.SetupConfig(nickname, providerFolder, hubAddress)
 	config.ProviderConfig.OS = runtime.GOOS
-	config.ProviderConfig.TURNUsernameSuffix = turnUsernameSuffix
-	config.ProviderConfig.UseIOSPairCache = useIOSPairCache
+	config.ProviderConfig.TURNUsernameSuffix = localConfig.TURNUsernameSuffix
+	config.ProviderConfig.UseIOSPairCache = localConfig.UseIOSPairCache
+	localConfig.ApplyPlatformOverrides(config.ProviderConfig)
 
 	// Setup logging for the provider itself
-	logger.SetupLogging(logLevel)
+	logger.SetupLogging(localConfig.Logging.Level)
 	logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Starting provider on port `%v`", config.ProviderConfig.Port))
 
-	// Check if the default workspace exists
-	defaultWorkspace, err := db.GlobalMongoStore.GetDefaultWorkspace()
-	if err != nil {
-		// Create default workspace if none exist
-		defaultWorkspace = models.Workspace{
-			Name:        "Default Workspace",
-			Description: "This is the default workspace.",
-			IsDefault:   true,
-		}
-		err := db.GlobalMongoStore.AddWorkspace(&defaultWorkspace)
+	// Check if the default workspace exists, the hub creates it for providers that do not use MongoDB
+	if !localConfig.HubManaged() {
+		_, err := db.GlobalMongoStore.GetDefaultWorkspace()
 		if err != nil {
-			log.Fatalf("Failed to create default workspace - %s", err)
+			// Create default workspace if none exist
+			defaultWorkspace := models.Workspace{
+				Name:        "Default Workspace",
+				Description: "This is the default workspace.",
+				IsDefault:   true,
+			}
+			err := db.GlobalMongoStore.AddWorkspace(&defaultWorkspace)
+			if err != nil {
+				log.Fatalf("Failed to create default workspace - %s", err)
+			}
+			logger.ProviderLogger.LogInfo("provider_setup", "Created default workspace")
 		}
-		logger.ProviderLogger.LogInfo("provider_setup", "Created default workspace")
 	}
 
 	if config.ProviderConfig.SetupAppiumServers {
@@ -101,47 +115,47 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 		var didUpdateAppiumPluginNPM = false
 		logger.ProviderLogger.LogInfo("provider_setup", "Checking if GADS Appium plugin is installed on the host NPM")
 		if !providerutil.IsAppiumPluginInstalledNPM() {
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Installing GADS Appium plugin version `%s` globally on host NPM", targetAppiumPluginVersion))
-			err = providerutil.InstallAppiumPluginNPM(targetAppiumPluginVersion)
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Installing GADS Appium plugin version `%s` globally on host NPM", providerutil.AppiumPluginVersion))
+			err = providerutil.InstallAppiumPluginNPM(providerutil.AppiumPluginVersion)
 			if err != nil {
-				log.Fatalf("Failed to install GADS Appium plugin version `%s` on NPM - %s", targetAppiumPluginVersion, err)
+				log.Fatalf("Failed to install GADS Appium plugin version `%s` on NPM - %s", providerutil.AppiumPluginVersion, err)
 			}
 			didUpdateAppiumPluginNPM = true
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS Appium plugin version `%s` globally on host NPM", targetAppiumPluginVersion))
-		} else if providerutil.ShouldUpdateAppiumPluginNPM(targetAppiumPluginVersion) {
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Updating GADS Appium plugin to version `%s` globally on host NPM", targetAppiumPluginVersion))
-			err = providerutil.InstallAppiumPluginNPM(targetAppiumPluginVersion)
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS Appium plugin version `%s` globally on host NPM", providerutil.AppiumPluginVersion))
+		} else if providerutil.ShouldUpdateAppiumPluginNPM(providerutil.AppiumPluginVersion) {
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Updating GADS Appium plugin to version `%s` globally on host NPM", providerutil.AppiumPluginVersion))
+			err = providerutil.InstallAppiumPluginNPM(providerutil.AppiumPluginVersion)
 			if err != nil {
-				log.Fatalf("Failed to update GADS Appium plugin to version `%s` on NPM - %s", targetAppiumPluginVersion, err)
+				log.Fatalf("Failed to update GADS Appium plugin to version `%s` on NPM - %s", providerutil.AppiumPluginVersion, err)
 			}
 			didUpdateAppiumPluginNPM = true
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully update GADS Appium plugin to version `%s` globally on host NPM", targetAppiumPluginVersion))
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully update GADS Appium plugin to version `%s` globally on host NPM", providerutil.AppiumPluginVersion))
 		}
 
 		// Lastly we check if the GADS plugin is installed on Appium at all and install it if not
 		// In case the plugin is installed but we did an update of the version on NPM then we uninstall it from the Appium plugins and then install it again using the target version
 		logger.ProviderLogger.LogInfo("provider_setup", "Checking if GADS plugin is installed on Appium")
 		if !providerutil.IsAppiumPluginInstalled() {
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("GADS plugin version `%s` is not installed on Appium, installing", targetAppiumPluginVersion))
-			err = providerutil.InstallAppiumPlugin(targetAppiumPluginVersion)
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("GADS plugin version `%s` is not installed on Appium, installing", providerutil.AppiumPluginVersion))
+			err = providerutil.InstallAppiumPlugin(providerutil.AppiumPluginVersion)
 			if err != nil {
-				log.Fatalf("Failed to install GADS plugin version `%s` on Appium - %s", targetAppiumPluginVersion, err)
+				log.Fatalf("Failed to install GADS plugin version `%s` on Appium - %s", providerutil.AppiumPluginVersion, err)
 			}
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS plugin version `%s` on Appium", targetAppiumPluginVersion))
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS plugin version `%s` on Appium", providerutil.AppiumPluginVersion))
 		} else if didUpdateAppiumPluginNPM {
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("GADS plugin was updated on NPM to version `%s` and is already installed on Appium, updating for Appium", targetAppiumPluginVersion))
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("GADS plugin was updated on NPM to version `%s` and is already installed on Appium, updating for Appium", providerutil.AppiumPluginVersion))
 			logger.ProviderLogger.LogInfo("provider_setup", "Uninstalling current plugin in case GADS plugin version was downgraded or update will not work")
 			err = providerutil.UninstallAppiumPlugin()
 			if err != nil {
 				log.Fatalf("Failed to uninstall GADS plugin on Appium - %s", err)
 			}
 
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Installing GADS plugin version `%s` on Appium", targetAppiumPluginVersion))
-			err = providerutil.InstallAppiumPlugin(targetAppiumPluginVersion)
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Installing GADS plugin version `%s` on Appium", providerutil.AppiumPluginVersion))
+			err = providerutil.InstallAppiumPlugin(providerutil.AppiumPluginVersion)
 			if err != nil {
-				log.Fatalf("Failed to install GADS plugin version `%s` on Appium - %s", targetAppiumPluginVersion, err)
+				log.Fatalf("Failed to install GADS plugin version `%s` on Appium - %s", providerutil.AppiumPluginVersion, err)
 			}
-			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS plugin version `%s` on Appium", targetAppiumPluginVersion))
+			logger.ProviderLogger.LogInfo("provider_setup", fmt.Sprintf("Successfully installed GADS plugin version `%s` on Appium", providerutil.AppiumPluginVersion))
 		}
 	} else {
 		logger.ProviderLogger.LogInfo("provider_setup", "Provider is not configured to set up Appium servers, skipped Appium and GADS Appium plugin checks")
@@ -174,6 +188,8 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 		log.Fatalf("Failed to extract embedded resource files - %s", err)
 	}
 
+	previousPortAllocations := providerutil.LoadPreviousPortAllocations()
+
 	// If we want to provide Android devices check if adb is available on PATH
 	if config.ProviderConfig.ProvideAndroid {
 		if !providerutil.AdbAvailable() {
@@ -183,7 +199,7 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 		}
 
 		// Try to remove potentially hanging ports forwarded by adb
-		providerutil.RemoveAdbForwardedPorts()
+		providerutil.RemoveAdbForwardedPorts(previousPortAllocations)
 	}
 
 	config.ProviderConfig.RegularizeProviderState()
@@ -209,6 +225,8 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 	// Start a goroutine that will start updating devices on provider start
 	go devices.Listener()
 
+	go handleShutdownSignals(drainTimeout)
+
 	// Start the provider server
 	err = startHTTPServer()
 	if err != nil {
@@ -219,8 +237,10 @@ func StartProvider(flags *pflag.FlagSet, resourceFiles embed.FS) {
 func startHTTPServer() error {
 	// Handle the endpoints
 	r := router.HandleRequests()
-	// Start periodically updating the provider data in the DB
-	go updateProviderInDB()
+	// Start periodically updating the provider data in the DB, the hub does it for providers that do not use MongoDB
+	if !config.Local.HubManaged() {
+		go updateProviderInDB()
+	}
 	// Start the provider
 	address := fmt.Sprintf("%s:%v", config.ProviderConfig.HostAddress, config.ProviderConfig.Port)
 	err := r.Run(address)
@@ -230,6 +250,24 @@ func startHTTPServer() error {
 	return fmt.Errorf("HTTP server stopped due to an unknown reason")
 }
 
+// handleShutdownSignals drains the provider on SIGTERM or SIGINT and exits once the devices are cleaned up.
+// A second signal exits immediately.
+func handleShutdownSignals(drainTimeout time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	logger.ProviderLogger.LogInfo("provider_shutdown", fmt.Sprintf("Received %s, draining the provider for up to %v before shutting down", sig, drainTimeout))
+	go func() {
+		<-signals
+		logger.ProviderLogger.LogWarn("provider_shutdown", "Received a second signal, shutting down immediately")
+		os.Exit(1)
+	}()
+
+	devices.Shutdown(drainTimeout)
+	os.Exit(0)
+}
+
 // Periodically send current provider data updates to MongoDB
 func updateProviderInDB() {
 	for {
@@ -0,0 +1,287 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package providerutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+)
+
+// AppiumPluginVersion is the GADS Appium plugin version expected by the current GADS binary
+const AppiumPluginVersion = "0.0.11"
+
+// appiumHomeManifest is written in an APPIUM_HOME once everything is installed in it
+const appiumHomeManifest = "gads-appium.json"
+
+// officialAppiumDrivers are installed by name, other drivers are installed as npm packages
+var officialAppiumDrivers = []string{"uiautomator2", "xcuitest", "espresso", "mac2", "windows", "safari", "gecko", "chromium"}
+
+// AppiumHome is the Appium installation a device Appium server runs from
+type AppiumHome struct {
+	// Dir is the isolated APPIUM_HOME of pinned versions, empty for the Appium installed on the host
+	Dir string
+	// Appium is the Appium executable
+	Appium string
+}
+
+// Env returns the environment of the Appium processes that use the installation, nil keeps the provider environment
+func (h AppiumHome) Env() []string {
+	if h.Dir == "" {
+		return nil
+	}
+	return append(os.Environ(), "APPIUM_HOME="+h.Dir)
+}
+
+type appiumHomeState struct {
+	Pinned        models.AppiumVersions     `json:"pinned"`
+	PluginVersion string                    `json:"plugin_version"`
+	Installation  models.AppiumInstallation `json:"installation"`
+}
+
+var (
+	appiumHomesMu sync.Mutex
+	// appiumHomeLocks serialize the installation of each APPIUM_HOME when several devices are set up at once
+	appiumHomeLocks     = make(map[string]*sync.Mutex)
+	appiumInstallations = make(map[string]models.AppiumInstallation)
+	deviceAppiumHomes   = make(map[string]string)
+
+	hostAppiumOnce         sync.Once
+	hostAppiumInstallation models.AppiumInstallation
+)
+
+// PrepareAppiumHome returns the Appium installation for the pinned versions, installing them in an isolated APPIUM_HOME
+// in the provider folder if needed. Without pinned versions the Appium installed on the host is used.
+func PrepareAppiumHome(versions *models.AppiumVersions) (AppiumHome, error) {
+	if versions.IsEmpty() {
+		return AppiumHome{Appium: config.Local.Tools.Appium}, nil
+	}
+
+	dir, err := filepath.Abs(filepath.Join(config.ProviderConfig.ProviderFolder, "appium", appiumHomeName(versions)))
+	if err != nil {
+		return AppiumHome{}, err
+	}
+	home := AppiumHome{Dir: dir, Appium: config.Local.Tools.Appium}
+	if versions.Appium != "" {
+		home.Appium = filepath.Join(dir, "node_modules", ".bin", "appium")
+		if runtime.GOOS == "windows" {
+			home.Appium += ".cmd"
+		}
+	}
+
+	appiumHomesMu.Lock()
+	lock, ok := appiumHomeLocks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		appiumHomeLocks[dir] = lock
+	}
+	appiumHomesMu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if state, err := readAppiumHomeState(dir); err == nil && state.PluginVersion == AppiumPluginVersion {
+		setAppiumInstallation(state.Installation)
+		return home, nil
+	}
+
+	logger.ProviderLogger.LogInfo("appium_home", fmt.Sprintf("Installing pinned Appium versions in `%s`", dir))
+	if err := installAppiumHome(home, versions); err != nil {
+		return AppiumHome{}, fmt.Errorf("failed to install the pinned Appium versions in `%s` - %w", dir, err)
+	}
+	logger.ProviderLogger.LogInfo("appium_home", fmt.Sprintf("Installed pinned Appium versions in `%s`", dir))
+
+	installation := models.AppiumInstallation{Home: dir, Drivers: make(map[string]string)}
+	if version, err := appiumVersion(home); err == nil {
+		installation.Appium = version
+	}
+	if drivers, err := installedAppiumDrivers(home); err == nil {
+		installation.Drivers = drivers
+	}
+
+	state := appiumHomeState{Pinned: *versions, PluginVersion: AppiumPluginVersion, Installation: installation}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, appiumHomeManifest), data, 0644); err != nil {
+		return AppiumHome{}, fmt.Errorf("failed to write the APPIUM_HOME manifest - %w", err)
+	}
+	setAppiumInstallation(installation)
+
+	return home, nil
+}
+
+// appiumHomeName returns the folder name of the pinned versions, e.g. `appium-2.11.3_uiautomator2-3.8.0`
+func appiumHomeName(versions *models.AppiumVersions) string {
+	appiumVersion := versions.Appium
+	if appiumVersion == "" {
+		appiumVersion = "host"
+	}
+	parts := []string{"appium-" + appiumVersion}
+
+	drivers := make([]string, 0, len(versions.Drivers))
+	for driver := range versions.Drivers {
+		drivers = append(drivers, driver)
+	}
+	sort.Strings(drivers)
+	for _, driver := range drivers {
+		name := strings.ReplaceAll(strings.TrimPrefix(driver, "@"), "/", "-")
+		parts = append(parts, name+"-"+versions.Drivers[driver])
+	}
+	return strings.Join(parts, "_")
+}
+
+func readAppiumHomeState(dir string) (appiumHomeState, error) {
+	var state appiumHomeState
+	data, err := os.ReadFile(filepath.Join(dir, appiumHomeManifest))
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// installAppiumHome installs Appium, the drivers and the GADS plugin in a clean APPIUM_HOME
+func installAppiumHome(home AppiumHome, versions *models.AppiumVersions) error {
+	// Start from scratch, a previous installation could have failed halfway
+	if err := os.RemoveAll(home.Dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(home.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	if versions.Appium != "" {
+		cmd := exec.Command("npm", "install", "--prefix", home.Dir, fmt.Sprintf("appium@%s", versions.Appium))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to install Appium %s - %s", versions.Appium, strings.TrimSpace(string(out)))
+		}
+	}
+
+	for driver, version := range versions.Drivers {
+		args := []string{"driver", "install"}
+		if !slices.Contains(officialAppiumDrivers, driver) {
+			args = append(args, "--source=npm")
+		}
+		args = append(args, fmt.Sprintf("%s@%s", driver, version))
+		if out, err := runAppium(home, args...); err != nil {
+			return fmt.Errorf("failed to install Appium driver %s@%s - %s", driver, version, out)
+		}
+	}
+
+	if out, err := runAppium(home, "plugin", "install", "--source=npm", fmt.Sprintf("appium-gads@%s", AppiumPluginVersion)); err != nil {
+		return fmt.Errorf("failed to install GADS Appium plugin %s - %s", AppiumPluginVersion, out)
+	}
+	return nil
+}
+
+func runAppium(home AppiumHome, args ...string) (string, error) {
+	cmd := exec.Command(home.Appium, args...)
+	cmd.Env = home.Env()
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func appiumVersion(home AppiumHome) (string, error) {
+	return runAppium(home, "-v")
+}
+
+var installedDriverRegex = regexp.MustCompile(`(?m)-\s(\S+)@(\S+)\s\[installed`)
+
+// installedAppiumDrivers returns the versions of the drivers installed in the Appium installation by driver name
+func installedAppiumDrivers(home AppiumHome) (map[string]string, error) {
+	output, err := runAppium(home, "driver", "list", "--installed")
+	if err != nil {
+		return nil, err
+	}
+	// Appium driver list has coloured output
+	// So we must strip the ANSI color codes
+	ansiRegex := regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	output = ansiRegex.ReplaceAllString(output, "")
+
+	drivers := make(map[string]string)
+	for _, match := range installedDriverRegex.FindAllStringSubmatch(output, -1) {
+		drivers[match[1]] = match[2]
+	}
+	return drivers, nil
+}
+
+func setAppiumInstallation(installation models.AppiumInstallation) {
+	appiumHomesMu.Lock()
+	defer appiumHomesMu.Unlock()
+	appiumInstallations[installation.Home] = installation
+}
+
+// SetDeviceAppiumHome records the Appium installation the device Appium server runs from
+func SetDeviceAppiumHome(udid string, home AppiumHome) {
+	appiumHomesMu.Lock()
+	defer appiumHomesMu.Unlock()
+	deviceAppiumHomes[udid] = home.Dir
+}
+
+// AppiumInstallations returns the Appium installations of the devices accepted by `include` and their installed versions
+func AppiumInstallations(include func(udid string) bool) []models.AppiumInstallation {
+	appiumHomesMu.Lock()
+	byHome := make(map[string]*models.AppiumInstallation)
+	for udid, dir := range deviceAppiumHomes {
+		if !include(udid) {
+			continue
+		}
+		installation, ok := byHome[dir]
+		if !ok {
+			installation = &models.AppiumInstallation{}
+			if dir != "" {
+				*installation = appiumInstallations[dir]
+			}
+			installation.Devices = nil
+			byHome[dir] = installation
+		}
+		installation.Devices = append(installation.Devices, udid)
+	}
+	appiumHomesMu.Unlock()
+
+	installations := make([]models.AppiumInstallation, 0, len(byHome))
+	for dir, installation := range byHome {
+		if dir == "" {
+			host := getHostAppiumInstallation()
+			installation.Appium = host.Appium
+			installation.Drivers = host.Drivers
+		}
+		sort.Strings(installation.Devices)
+		installations = append(installations, *installation)
+	}
+	sort.Slice(installations, func(i, j int) bool { return installations[i].Home < installations[j].Home })
+	return installations
+}
+
+// getHostAppiumInstallation returns the versions of the Appium installed on the host, they are checked once
+func getHostAppiumInstallation() models.AppiumInstallation {
+	hostAppiumOnce.Do(func() {
+		home := AppiumHome{Appium: config.Local.Tools.Appium}
+		hostAppiumInstallation.Drivers = make(map[string]string)
+		if version, err := appiumVersion(home); err == nil {
+			hostAppiumInstallation.Appium = version
+		}
+		if drivers, err := installedAppiumDrivers(home); err == nil {
+			hostAppiumInstallation.Drivers = drivers
+		}
+	})
+	return hostAppiumInstallation
+}
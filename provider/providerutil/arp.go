@@ -0,0 +1,72 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package providerutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var macAddressRegex = regexp.MustCompile(`(?i)([0-9a-f]{1,2}[:-]){5}[0-9a-f]{1,2}`)
+
+// MACAddressOfIP returns the MAC address the host ARP table has for a neighbour IP address, normalized to `aa:bb:cc:dd:ee:ff`
+func MACAddressOfIP(ip string) (string, error) {
+	var output string
+	if runtime.GOOS == "linux" {
+		data, err := os.ReadFile("/proc/net/arp")
+		if err != nil {
+			return "", err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[0] == ip {
+				output = fields[3]
+				break
+			}
+		}
+	} else {
+		args := []string{"-n", ip}
+		if runtime.GOOS == "windows" {
+			args = []string{"-a", ip}
+		}
+		out, err := exec.Command("arp", args...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the ARP table - %s - %w", strings.TrimSpace(string(out)), err)
+		}
+		output = string(out)
+	}
+
+	match := macAddressRegex.FindString(output)
+	if match == "" {
+		return "", fmt.Errorf("no ARP entry for `%s`", ip)
+	}
+	return NormalizeMACAddress(match)
+}
+
+// NormalizeMACAddress returns the MAC address in lowercase colon separated form with two digits per octet,
+// `arp` on macOS drops leading zeros and Windows separates the octets with dashes
+func NormalizeMACAddress(mac string) (string, error) {
+	octets := strings.FieldsFunc(mac, func(r rune) bool { return r == ':' || r == '-' })
+	for i, octet := range octets {
+		if len(octet) == 1 {
+			octets[i] = "0" + octet
+		}
+	}
+	hw, err := net.ParseMAC(strings.Join(octets, ":"))
+	if err != nil {
+		return "", err
+	}
+	return hw.String(), nil
+}
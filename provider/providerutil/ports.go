@@ -0,0 +1,263 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package providerutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"GADS/common"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+)
+
+// PortAllocation is a host port allocated by the provider for a device service
+type PortAllocation struct {
+	Port        string    `json:"port"`
+	Purpose     string    `json:"purpose"`
+	UDID        string    `json:"udid,omitempty"`
+	AllocatedAt time.Time `json:"allocated_at"`
+}
+
+// PortDiagnostics are the configured port ranges and the current allocations
+type PortDiagnostics struct {
+	Ranges      map[string]config.PortRange `json:"ranges"`
+	Allocations []PortAllocation            `json:"allocations"`
+}
+
+var (
+	// portAllocations are the allocated ports, guarded by common.MutexManager.LocalDevicePorts
+	portAllocations = make(map[string]PortAllocation)
+	// previousAllocations are the ports of the previous provider run by `udid/purpose`.
+	// They are reused while free so a restarted provider keeps the same ports for the same devices.
+	previousAllocations = make(map[string][]string)
+)
+
+func portAllocationsFile() string {
+	return filepath.Join(config.ProviderConfig.ProviderFolder, "ports.json")
+}
+
+// AllocatePort allocates a free host port for the purpose from its configured range.
+// The allocations are persisted in the provider folder until released.
+func AllocatePort(purpose, udid string) (string, error) {
+	common.MutexManager.LocalDevicePorts.Lock()
+	defer common.MutexManager.LocalDevicePorts.Unlock()
+
+	portRange := config.Local.Ports.Range(purpose)
+	port := takePreviousPort(purpose, udid, portRange)
+	if port == "" {
+		var err error
+		if portRange.Max > 0 {
+			port, err = freePortInRange(portRange)
+		} else {
+			port, err = freeEphemeralPort()
+		}
+		if err != nil {
+			logger.ProviderLogger.LogError("port_allocation", fmt.Sprintf("Failed to allocate %s port for device `%s` - %s", purpose, udid, err))
+			return "", err
+		}
+	}
+
+	portAllocations[port] = PortAllocation{
+		Port:        port,
+		Purpose:     purpose,
+		UDID:        udid,
+		AllocatedAt: time.Now(),
+	}
+	savePortAllocations()
+	logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Allocated %s port %s for device `%s`", purpose, port, udid))
+	return port, nil
+}
+
+// ReleasePort frees allocated host ports, empty ports are ignored
+func ReleasePort(ports ...string) {
+	common.MutexManager.LocalDevicePorts.Lock()
+	defer common.MutexManager.LocalDevicePorts.Unlock()
+
+	released := false
+	for _, port := range ports {
+		if _, ok := portAllocations[port]; ok {
+			delete(portAllocations, port)
+			released = true
+		}
+	}
+	if released {
+		savePortAllocations()
+	}
+}
+
+// takePreviousPort returns a port the device had for the purpose in the previous provider run if it is still free
+func takePreviousPort(purpose, udid string, portRange config.PortRange) string {
+	if udid == "" {
+		return ""
+	}
+	key := udid + "/" + purpose
+	for len(previousAllocations[key]) > 0 {
+		port := previousAllocations[key][0]
+		previousAllocations[key] = previousAllocations[key][1:]
+
+		portInt, _ := strconv.Atoi(port)
+		if portRange.Max > 0 && (portInt < portRange.Min || portInt > portRange.Max) {
+			continue
+		}
+		if _, used := portAllocations[port]; !used && isPortFree(port) {
+			return port
+		}
+	}
+	return ""
+}
+
+// freePortInRange returns the first port of the range that is not allocated and is free on the host
+func freePortInRange(portRange config.PortRange) (string, error) {
+	for port := portRange.Min; port <= portRange.Max; port++ {
+		portString := strconv.Itoa(port)
+		if _, used := portAllocations[portString]; used || isPreviousPort(portString) {
+			continue
+		}
+		if isPortFree(portString) {
+			return portString, nil
+		}
+	}
+	// The ports of the previous run are only kept for their devices while there are other free ports
+	for port := portRange.Min; port <= portRange.Max; port++ {
+		portString := strconv.Itoa(port)
+		if _, used := portAllocations[portString]; !used && isPortFree(portString) {
+			return portString, nil
+		}
+	}
+	return "", fmt.Errorf("no free port left in the range %d-%d", portRange.Min, portRange.Max)
+}
+
+// freeEphemeralPort returns a free port picked by the host when no range is configured
+func freeEphemeralPort() (string, error) {
+	const maxAttempts = 10
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		a, err := net.ResolveTCPAddr("tcp", "localhost:0")
+		if err != nil {
+			return "", fmt.Errorf("Failed to resolve tcp address trying to get new port - %s", err)
+		}
+		l, err := net.ListenTCP("tcp", a)
+		if err != nil {
+			return "", fmt.Errorf("Failed to listen tcp trying to get new port - %s", err)
+		}
+		portString := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+		l.Close()
+
+		if _, used := portAllocations[portString]; !used {
+			return portString, nil
+		}
+		logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Port %s is already in use, trying again", portString))
+	}
+	return "", fmt.Errorf("failed to find a free port after %d attempts", maxAttempts)
+}
+
+func isPreviousPort(port string) bool {
+	for _, ports := range previousAllocations {
+		if slices.Contains(ports, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPortFree checks that the port can be bound on loopback and on all interfaces,
+// adb forwards listen on loopback while the provider listeners and `adb -a` forwards bind all interfaces
+func isPortFree(port string) bool {
+	for _, host := range []string{"localhost", "0.0.0.0"} {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return false
+		}
+		l.Close()
+	}
+	return true
+}
+
+// savePortAllocations persists the allocations, common.MutexManager.LocalDevicePorts must be held
+func savePortAllocations() {
+	data, err := json.MarshalIndent(sortedPortAllocations(), "", "  ")
+	if err != nil {
+		return
+	}
+	tempFile := portAllocationsFile() + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Failed to persist port allocations - %s", err))
+		return
+	}
+	if err := os.Rename(tempFile, portAllocationsFile()); err != nil {
+		logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Failed to persist port allocations - %s", err))
+	}
+}
+
+func sortedPortAllocations() []PortAllocation {
+	allocations := make([]PortAllocation, 0, len(portAllocations))
+	for _, allocation := range portAllocations {
+		allocations = append(allocations, allocation)
+	}
+	slices.SortFunc(allocations, func(a, b PortAllocation) int {
+		portA, _ := strconv.Atoi(a.Port)
+		portB, _ := strconv.Atoi(b.Port)
+		return portA - portB
+	})
+	return allocations
+}
+
+// LoadPreviousPortAllocations reads the allocations persisted by the previous provider run, which are still
+// in the file if the provider crashed or was killed. Their ports are preferred for the same devices.
+func LoadPreviousPortAllocations() []PortAllocation {
+	data, err := os.ReadFile(portAllocationsFile())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.ProviderLogger.LogWarn("port_allocation", fmt.Sprintf("Failed to read the previous port allocations - %s", err))
+		}
+		return nil
+	}
+
+	var allocations []PortAllocation
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		logger.ProviderLogger.LogWarn("port_allocation", fmt.Sprintf("Failed to parse the previous port allocations - %s", err))
+		return nil
+	}
+
+	common.MutexManager.LocalDevicePorts.Lock()
+	defer common.MutexManager.LocalDevicePorts.Unlock()
+	for _, allocation := range allocations {
+		if allocation.UDID != "" {
+			key := allocation.UDID + "/" + allocation.Purpose
+			previousAllocations[key] = append(previousAllocations[key], allocation.Port)
+		}
+	}
+	return allocations
+}
+
+// GetPortDiagnostics returns the configured port ranges and the current allocations
+func GetPortDiagnostics() PortDiagnostics {
+	common.MutexManager.LocalDevicePorts.Lock()
+	defer common.MutexManager.LocalDevicePorts.Unlock()
+
+	ranges := map[string]config.PortRange{
+		"default": {Min: config.Local.Ports.Min, Max: config.Local.Ports.Max},
+	}
+	for purpose := range config.Local.Ports.Purposes() {
+		ranges[purpose] = config.Local.Ports.Range(purpose)
+	}
+	return PortDiagnostics{
+		Ranges:      ranges,
+		Allocations: sortedPortAllocations(),
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package providerutil
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"GADS/provider/config"
+	"GADS/provider/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// usePortState runs the test with empty allocations in a temporary provider folder
+func usePortState(t *testing.T) {
+	t.Helper()
+	if logger.ProviderLogger == nil {
+		discard := logrus.New()
+		discard.SetOutput(io.Discard)
+		logger.ProviderLogger = &logger.CustomLogger{Logger: discard}
+	}
+
+	previousFolder, previousPorts := config.ProviderConfig.ProviderFolder, config.Local.Ports
+	previousAllocated, previousRun := portAllocations, previousAllocations
+	t.Cleanup(func() {
+		config.ProviderConfig.ProviderFolder, config.Local.Ports = previousFolder, previousPorts
+		portAllocations, previousAllocations = previousAllocated, previousRun
+	})
+	config.ProviderConfig.ProviderFolder = t.TempDir()
+	portAllocations = make(map[string]PortAllocation)
+	previousAllocations = make(map[string][]string)
+}
+
+// freePortBlock returns the first of `size` consecutive ports that are free on the host
+func freePortBlock(t *testing.T, size int) int {
+	t.Helper()
+	for attempt := 0; attempt < 20; attempt++ {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		if first+size > 65535 {
+			continue
+		}
+
+		free := true
+		for port := first; port < first+size; port++ {
+			free = free && isPortFree(strconv.Itoa(port))
+		}
+		if free {
+			return first
+		}
+	}
+	t.Fatalf("no block of %d free ports found", size)
+	return 0
+}
+
+func readPortAllocationsFile(t *testing.T) []PortAllocation {
+	t.Helper()
+	data, err := os.ReadFile(portAllocationsFile())
+	if err != nil {
+		t.Fatalf("failed to read the persisted allocations - %s", err)
+	}
+	var allocations []PortAllocation
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		t.Fatalf("failed to parse the persisted allocations - %s", err)
+	}
+	return allocations
+}
+
+func TestAllocatePort(t *testing.T) {
+	// Ports are given as offsets from the start of a block of free host ports, the range is the first two
+	tests := []struct {
+		name      string
+		allocated []int
+		busy      []int
+		previous  map[string][]int
+		want      int
+		wantErr   bool
+	}{
+		{name: "first port of the range", want: 0},
+		{name: "allocated ports are skipped", allocated: []int{0}, want: 1},
+		{name: "ports in use on the host are skipped", busy: []int{0}, want: 1},
+		{name: "range exhausted by allocations", allocated: []int{0, 1}, wantErr: true},
+		{name: "range exhausted by the host", allocated: []int{0}, busy: []int{1}, wantErr: true},
+		{name: "previous port of the device is reused", previous: map[string][]int{"device/appium": {1}}, want: 1},
+		{name: "previous ports are tried in order", previous: map[string][]int{"device/appium": {1, 0}}, want: 1},
+		{name: "allocated previous port is not reused", allocated: []int{1}, previous: map[string][]int{"device/appium": {1}}, want: 0},
+		{name: "busy previous port is not reused", busy: []int{1}, previous: map[string][]int{"device/appium": {1}}, want: 0},
+		{name: "previous port out of the range is ignored", previous: map[string][]int{"device/appium": {5}}, want: 0},
+		{name: "previous port of another purpose is ignored", previous: map[string][]int{"device/stream": {1}}, want: 0},
+		{name: "previous port of another device is kept while there are other free ports", previous: map[string][]int{"other/appium": {0}}, want: 1},
+		{name: "previous port of another device is used when no other port is free", allocated: []int{1}, previous: map[string][]int{"other/appium": {0}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePortState(t)
+			first := freePortBlock(t, 6)
+			config.Local.Ports = config.PortRanges{Appium: config.PortRange{Min: first, Max: first + 1}}
+
+			for _, offset := range tt.allocated {
+				port := strconv.Itoa(first + offset)
+				portAllocations[port] = PortAllocation{Port: port, Purpose: config.PortAppium, UDID: "allocated"}
+			}
+			for _, offset := range tt.busy {
+				l, err := net.Listen("tcp", net.JoinHostPort("0.0.0.0", strconv.Itoa(first+offset)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer l.Close()
+			}
+			for key, offsets := range tt.previous {
+				for _, offset := range offsets {
+					previousAllocations[key] = append(previousAllocations[key], strconv.Itoa(first+offset))
+				}
+			}
+
+			port, err := AllocatePort(config.PortAppium, "device")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("AllocatePort() = %s, want an error", port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AllocatePort() error = %s", err)
+			}
+			if want := strconv.Itoa(first + tt.want); port != want {
+				t.Errorf("AllocatePort() = %s, want %s", port, want)
+			}
+			if allocation := portAllocations[port]; allocation.Purpose != config.PortAppium || allocation.UDID != "device" {
+				t.Errorf("allocation = %+v", allocation)
+			}
+		})
+	}
+}
+
+func TestAllocatePort_PurposeUsesDefaultRange(t *testing.T) {
+	usePortState(t)
+	first := freePortBlock(t, 3)
+	config.Local.Ports = config.PortRanges{Min: first + 2, Max: first + 2, Appium: config.PortRange{Min: first, Max: first + 1}}
+
+	port, err := AllocatePort(config.PortTunnel, "device")
+	if err != nil || port != strconv.Itoa(first+2) {
+		t.Errorf("AllocatePort() = %s, %v, want the port of the default range %d", port, err, first+2)
+	}
+}
+
+func TestAllocatePort_EphemeralWithoutRange(t *testing.T) {
+	usePortState(t)
+	config.Local.Ports = config.PortRanges{}
+
+	port, err := AllocatePort(config.PortAppium, "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portInt, _ := strconv.Atoi(port); portInt <= 0 {
+		t.Errorf("AllocatePort() = %s, want a host picked port", port)
+	}
+}
+
+func TestReleasePort_PersistRoundTrip(t *testing.T) {
+	usePortState(t)
+	first := freePortBlock(t, 4)
+	config.Local.Ports = config.PortRanges{Min: first, Max: first + 3}
+
+	appiumPort, err := AllocatePort(config.PortAppium, "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamPort, err := AllocatePort(config.PortStream, "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if persisted := readPortAllocationsFile(t); len(persisted) != 2 || persisted[0].Port != appiumPort || persisted[1].Port != streamPort {
+		t.Fatalf("persisted allocations = %+v, want ports %s and %s", persisted, appiumPort, streamPort)
+	}
+
+	// Unknown and empty ports are ignored
+	ReleasePort(streamPort, "", "1")
+	persisted := readPortAllocationsFile(t)
+	if len(persisted) != 1 || persisted[0].Port != appiumPort || persisted[0].Purpose != config.PortAppium || persisted[0].UDID != "device" {
+		t.Fatalf("persisted allocations after release = %+v, want only %s", persisted, appiumPort)
+	}
+
+	// A provider that crashed left the allocation in the file, after a restart it is kept for the same device
+	portAllocations = make(map[string]PortAllocation)
+	previous := LoadPreviousPortAllocations()
+	if len(previous) != 1 || previous[0].Port != appiumPort {
+		t.Fatalf("LoadPreviousPortAllocations() = %+v, want %s", previous, appiumPort)
+	}
+	if port, err := AllocatePort(config.PortAppium, "other"); err != nil || port == appiumPort {
+		t.Errorf("AllocatePort() for another device = %s, %v, want a port other than %s", port, err, appiumPort)
+	}
+	if port, err := AllocatePort(config.PortAppium, "device"); err != nil || port != appiumPort {
+		t.Errorf("AllocatePort() after restart = %s, %v, want the previous port %s", port, err, appiumPort)
+	}
+
+	ReleasePort(appiumPort)
+	for _, allocation := range readPortAllocationsFile(t) {
+		if allocation.Port == appiumPort {
+			t.Errorf("released port %s is still persisted", appiumPort)
+		}
+	}
+}
+
+func TestLoadPreviousPortAllocations_MissingOrInvalidFile(t *testing.T) {
+	usePortState(t)
+
+	if previous := LoadPreviousPortAllocations(); previous != nil {
+		t.Errorf("LoadPreviousPortAllocations() without a file = %+v", previous)
+	}
+
+	if err := os.WriteFile(portAllocationsFile(), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if previous := LoadPreviousPortAllocations(); previous != nil {
+		t.Errorf("LoadPreviousPortAllocations() with an invalid file = %+v", previous)
+	}
+	if len(previousAllocations) != 0 {
+		t.Errorf("previous allocations = %+v, want none", previousAllocations)
+	}
+}
@@ -12,18 +12,15 @@ package providerutil
 import (
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
-	"time"
 
 	"runtime"
 
-	"GADS/common"
 	"GADS/common/cli"
 	"GADS/common/utils"
 	"GADS/provider/config"
@@ -32,67 +29,13 @@ import (
 	"github.com/Masterminds/semver"
 )
 
-var UsedPorts = make(map[string]bool)
 var gadsStreamURL = "https://github.com/shamanec/GADS-Android-stream/releases/latest/download/gads-stream.apk"
 
-// Use this function to get a free port on the host for any service that might need one
-// We keep a map of used ports so we don't allocate same ports to different services
-func GetFreePort() (string, error) {
-	const (
-		maxAttempts = 10
-		baseBackoff = 50 * time.Millisecond
-	)
-
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		logger.ProviderLogger.LogDebug("port_allocation", "Trying to get a free port")
-
-		a, err := net.ResolveTCPAddr("tcp", "localhost:0")
-		if err != nil {
-			logger.ProviderLogger.LogError("port_allocation", fmt.Sprintf("Failed to resolve tcp address trying to get new port - %s", err))
-			return "", fmt.Errorf("Failed to resolve tcp address trying to get new port - %s", err)
-		}
-		logger.ProviderLogger.LogDebug("port_allocation", "Resolved TCP address successfully")
-
-		logger.ProviderLogger.LogDebug("port_allocation", "Attempting to listen on the resolved TCP address")
-		l, err := net.ListenTCP("tcp", a)
-		if err != nil {
-			logger.ProviderLogger.LogError("port_allocation", fmt.Sprintf("Failed to listen tcp trying to get new port - %s", err))
-			return "", fmt.Errorf("Failed to listen tcp trying to get new port - %s", err)
-		}
-		logger.ProviderLogger.LogDebug("port_allocation", "Listening on TCP address successfully")
-
-		portInt := l.Addr().(*net.TCPAddr).Port
-		portString := strconv.Itoa(portInt)
-
-		logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Acquired free port: %s", portString))
-
-		logger.ProviderLogger.LogDebug("port_allocation", "Attempting to acquire lock for used ports")
-		common.MutexManager.LocalDevicePorts.Lock()
-		logger.ProviderLogger.LogDebug("port_allocation", "Successfully acquired lock for used ports")
-		if _, exists := UsedPorts[portString]; !exists {
-			UsedPorts[portString] = true
-			logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Port %s is free and has been allocated", portString))
-			logger.ProviderLogger.LogDebug("port_allocation", "Releasing lock for used ports")
-			common.MutexManager.LocalDevicePorts.Unlock()
-			l.Close()
-			return portString, nil
-		}
-		logger.ProviderLogger.LogDebug("port_allocation", fmt.Sprintf("Port %s is already in use, trying again", portString))
-		logger.ProviderLogger.LogDebug("port_allocation", "Releasing lock for used ports")
-		common.MutexManager.LocalDevicePorts.Unlock()
-		l.Close()
-
-		// Simple incremental backoff
-		time.Sleep(time.Duration(attempt) * baseBackoff)
-	}
-	return "", fmt.Errorf("failed to find a free port after %d attempts", maxAttempts)
-}
-
 // Check if adb is available on the host by starting the server
 func AdbAvailable() bool {
 	logger.ProviderLogger.LogInfo("provider_setup", "Checking if adb is set up and available on the host PATH")
 
-	cmd := exec.Command("adb", "start-server")
+	cmd := exec.Command(config.Local.Tools.ADB, "start-server")
 	err := cmd.Run()
 	if err != nil {
 		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("adbAvailable: Error executing `adb start-server`, `adb` is not available on host or command failed - %s", err))
@@ -106,7 +49,7 @@ func AdbAvailable() bool {
 func AppiumAvailable() bool {
 	logger.ProviderLogger.LogInfo("provider_setup", "Checking if Appium is set up and available on the host PATH")
 
-	cmd := exec.Command("appium", "--version")
+	cmd := exec.Command(config.Local.Tools.Appium, "--version")
 	err := cmd.Run()
 	if err != nil {
 		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("AppiumAvailable: Appium is not available or command failed - %s", err))
@@ -171,7 +114,7 @@ func InstallAppiumPluginNPM(targetVersion string) error {
 
 // Check if the GADS plugin is installed on Appium
 func IsAppiumPluginInstalled() bool {
-	cmd := exec.Command("appium", "plugin", "list")
+	cmd := exec.Command(config.Local.Tools.Appium, "plugin", "list")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return false
@@ -187,7 +130,7 @@ func IsAppiumPluginInstalled() bool {
 
 // Install the GADS Appium plugin on Appium
 func InstallAppiumPlugin(targetVersion string) error {
-	cmd := exec.Command("appium", "plugin", "install", "--source=npm", fmt.Sprintf("appium-gads@%s", targetVersion))
+	cmd := exec.Command(config.Local.Tools.Appium, "plugin", "install", "--source=npm", fmt.Sprintf("appium-gads@%s", targetVersion))
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -199,7 +142,7 @@ func InstallAppiumPlugin(targetVersion string) error {
 
 // Uninstall the GADS Appium plugin from Appium
 func UninstallAppiumPlugin() error {
-	cmd := exec.Command("appium", "plugin", "uninstall", "gads")
+	cmd := exec.Command(config.Local.Tools.Appium, "plugin", "uninstall", "gads")
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -209,19 +152,55 @@ func UninstallAppiumPlugin() error {
 	return nil
 }
 
-// Remove all adb forwarded ports(if any) on provider start
-func RemoveAdbForwardedPorts() {
-	logger.ProviderLogger.LogInfo("provider_setup", "Attempting to remove all `adb` forwarded ports on provider start")
+// Remove the adb forwarded and reversed ports of the previous provider run on provider start, they survive a provider crash.
+// The ports of the previous run are only known after a crash, after a clean shutdown the allocations are empty,
+// so all forwards and reverse forwards of the connected devices and the devices of the allocations are removed.
+func RemoveAdbForwardedPorts(previousAllocations []PortAllocation) {
+	logger.ProviderLogger.LogInfo("provider_setup", "Attempting to remove the `adb` forwarded ports of the previous provider run")
 
-	cmd := exec.Command("adb", "forward", "--remove-all")
-	err := cmd.Run()
+	udids := connectedAdbDevices()
+	for _, allocation := range previousAllocations {
+		if allocation.UDID != "" && !slices.Contains(udids, allocation.UDID) {
+			udids = append(udids, allocation.UDID)
+		}
+	}
+
+	for _, udid := range udids {
+		// iOS devices of the allocations are unknown to adb, so failures are expected
+		for _, command := range []string{"forward", "reverse"} {
+			cmd := exec.Command(config.Local.Tools.ADB, "-s", udid, command, "--remove-all")
+			if err := cmd.Run(); err != nil {
+				logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("removeAdbForwardedPorts: Could not remove `adb %s` ports of device `%s` - %s", command, udid, err))
+				continue
+			}
+			logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("Removed `adb %s` ports of device `%s`", command, udid))
+		}
+	}
+}
+
+// connectedAdbDevices returns the serials of the devices `adb devices` lists as online
+func connectedAdbDevices() []string {
+	out, err := exec.Command(config.Local.Tools.ADB, "devices").Output()
 	if err != nil {
-		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("removeAdbForwardedPorts: Could not remove `adb` forwarded ports, there was an error or no devices are connected - %s", err))
+		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("connectedAdbDevices: Could not list `adb` devices - %s", err))
+		return nil
+	}
+	return parseAdbDevices(string(out))
+}
+
+func parseAdbDevices(output string) []string {
+	var udids []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == "device" {
+			udids = append(udids, fields[0])
+		}
 	}
+	return udids
 }
 
 func GetAppiumVersion() (string, error) {
-	versionOutput, err := cli.ExecuteCommand("appium", "-v")
+	versionOutput, err := cli.ExecuteCommand(config.Local.Tools.Appium, "-v")
 	if err != nil {
 		return "", err
 	}
@@ -230,7 +209,7 @@ func GetAppiumVersion() (string, error) {
 }
 
 func GetAppiumDriverVersion(driverName string) (string, error) {
-	output, err := cli.ExecuteCommand("appium", "driver", "list")
+	output, err := cli.ExecuteCommand(config.Local.Tools.Appium, "driver", "list")
 	if err != nil {
 		return "", err
 	}
@@ -260,7 +239,7 @@ func GetUiAutomator2DriverVersion() (string, error) {
 func SdbAvailable() bool {
 	logger.ProviderLogger.LogInfo("provider_setup", "Checking if sdb is set up and available on the host PATH")
 
-	cmd := exec.Command("sdb", "version")
+	cmd := exec.Command(config.Local.Tools.SDB, "version")
 	err := cmd.Run()
 	if err != nil {
 		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("sdbAvailable: sdb is not available or command failed - %s", err))
@@ -273,7 +252,7 @@ func SdbAvailable() bool {
 func AresAvailable() bool {
 	logger.ProviderLogger.LogInfo("provider_setup", "Checking if ares-setup-device is set up and available on the host PATH")
 
-	cmd := exec.Command("ares", "-V")
+	cmd := exec.Command(config.Local.Tools.AresCommand("ares"), "-V")
 	err := cmd.Run()
 	if err != nil {
 		logger.ProviderLogger.LogDebug("provider_setup", fmt.Sprintf("aresAvailable: ares-setup-device is not available or command failed - %s", err))
@@ -0,0 +1,48 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package providerutil
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAdbDevices(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{name: "no devices", output: "List of devices attached\n\n", want: nil},
+		{
+			name:   "online devices",
+			output: "List of devices attached\nemulator-5554\tdevice\n192.168.1.10:5555\tdevice\n",
+			want:   []string{"emulator-5554", "192.168.1.10:5555"},
+		},
+		{
+			name:   "offline and unauthorized devices are skipped",
+			output: "List of devices attached\nR58M123\toffline\nR58M456\tunauthorized\nR58M789\tdevice\n",
+			want:   []string{"R58M789"},
+		},
+		{
+			name:   "daemon messages and long format",
+			output: "* daemon not running; starting now at tcp:5037\n* daemon started successfully\nList of devices attached\nR58M789 device usb:1-1 product:a51 model:SM_A515F\r\n",
+			want:   []string{"R58M789"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAdbDevices(tt.output); !slices.Equal(got, tt.want) {
+				t.Errorf("parseAdbDevices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondAppStateError maps unsupported operations to 501 so clients can tell them apart from failures
+func respondAppStateError(c *gin.Context, platDev devices.PlatformDevice, message string, err error) {
+	platDev.GetLogger().LogError("app_state", fmt.Sprintf("%s - %s", message, err))
+	if errors.Is(err, devices.ErrUnsupportedOperation) {
+		api.ErrorResponse(c, http.StatusNotImplemented, err.Error())
+		return
+	}
+	api.InternalError(c, fmt.Sprintf("%s - %s", message, err))
+}
+
+func ClearAppData(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
+		return
+	}
+
+	var payload ProcessApp
+	if err := json.NewDecoder(c.Request.Body).Decode(&payload); err != nil || payload.App == "" {
+		api.BadRequest(c, "Invalid payload")
+		return
+	}
+
+	if err := platDev.ClearAppData(payload.App); err != nil {
+		respondAppStateError(c, platDev, fmt.Sprintf("Failed clearing data of app `%s`", payload.App), err)
+		return
+	}
+
+	platDev.GetLogger().LogInfo("app_state", fmt.Sprintf("Cleared data of app `%s`", payload.App))
+	api.OKMessage(c, fmt.Sprintf("Successfully cleared data of app `%s`", payload.App))
+}
+
+// decodeAppPermissionRequest reads the payload of the permission endpoints, permissions are optional only for reset
+func decodeAppPermissionRequest(c *gin.Context, requirePermissions bool) (models.AppPermissionRequest, bool) {
+	var payload models.AppPermissionRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&payload); err != nil || payload.App == "" {
+		api.BadRequest(c, "Invalid payload")
+		return payload, false
+	}
+	if requirePermissions && len(payload.Permissions) == 0 {
+		api.BadRequest(c, "At least one permission is required")
+		return payload, false
+	}
+	return payload, true
+}
+
+func GrantAppPermissions(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
+		return
+	}
+
+	payload, ok := decodeAppPermissionRequest(c, true)
+	if !ok {
+		return
+	}
+
+	if err := platDev.GrantAppPermissions(payload.App, payload.Permissions); err != nil {
+		respondAppStateError(c, platDev, fmt.Sprintf("Failed granting permissions to app `%s`", payload.App), err)
+		return
+	}
+
+	platDev.GetLogger().LogInfo("app_state", fmt.Sprintf("Granted permissions %v to app `%s`", payload.Permissions, payload.App))
+	api.OKMessage(c, fmt.Sprintf("Successfully granted permissions to app `%s`", payload.App))
+}
+
+func RevokeAppPermissions(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
+		return
+	}
+
+	payload, ok := decodeAppPermissionRequest(c, true)
+	if !ok {
+		return
+	}
+
+	if err := platDev.RevokeAppPermissions(payload.App, payload.Permissions); err != nil {
+		respondAppStateError(c, platDev, fmt.Sprintf("Failed revoking permissions of app `%s`", payload.App), err)
+		return
+	}
+
+	platDev.GetLogger().LogInfo("app_state", fmt.Sprintf("Revoked permissions %v of app `%s`", payload.Permissions, payload.App))
+	api.OKMessage(c, fmt.Sprintf("Successfully revoked permissions of app `%s`", payload.App))
+}
+
+func ResetAppPermissions(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
+		return
+	}
+
+	payload, ok := decodeAppPermissionRequest(c, false)
+	if !ok {
+		return
+	}
+
+	if err := platDev.ResetAppPermissions(payload.App, payload.Permissions); err != nil {
+		respondAppStateError(c, platDev, fmt.Sprintf("Failed resetting permissions of app `%s`", payload.App), err)
+		return
+	}
+
+	platDev.GetLogger().LogInfo("app_state", fmt.Sprintf("Reset permissions of app `%s`", payload.App))
+	api.OKMessage(c, fmt.Sprintf("Successfully reset permissions of app `%s`", payload.App))
+}
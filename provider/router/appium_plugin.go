@@ -2,9 +2,9 @@ package router
 
 import (
 	"GADS/common/api"
-	"GADS/common/db"
 	"GADS/common/models"
 	"GADS/provider/devices"
+	"GADS/provider/store"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -29,7 +29,7 @@ func AppiumPluginLog(c *gin.Context) {
 		var appiumPluginLog models.AppiumPluginLog
 		err = json.Unmarshal(body, &appiumPluginLog)
 
-		db.GlobalMongoStore.AddAppiumLog(udid, appiumPluginLog)
+		store.GlobalStore.AddAppiumLog(udid, appiumPluginLog)
 		api.OKMessage(c, "Logged successfully")
 		return
 	}
@@ -0,0 +1,108 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/auth"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	localAddresses     map[string]bool
+	localAddressesOnce sync.Once
+)
+
+// isLocalRequest reports whether the request comes from the provider host itself, e.g. from the Appium plugin
+func isLocalRequest(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	localAddressesOnce.Do(func() {
+		localAddresses = make(map[string]bool)
+		addresses, err := net.InterfaceAddrs()
+		if err != nil {
+			return
+		}
+		for _, address := range addresses {
+			if ipNet, ok := address.(*net.IPNet); ok {
+				localAddresses[ipNet.IP.String()] = true
+			}
+		}
+	})
+	return localAddresses[ip.String()]
+}
+
+// localDiagnosticsRoutes are the read-only provider routes served to the provider host itself without the hub signature
+var localDiagnosticsRoutes = map[string]bool{
+	"/info":    true,
+	"/devices": true,
+	"/ports":   true,
+	"/drain":   true,
+}
+
+// isLocalDiagnosticsRequest reports whether the request is a read-only diagnostics or profiling request from the provider host
+func isLocalDiagnosticsRequest(c *gin.Context) bool {
+	if !isLocalRequest(c) {
+		return false
+	}
+	path := c.Request.URL.Path
+	return strings.HasPrefix(path, "/debug/pprof/") || (c.Request.Method == http.MethodGet && localDiagnosticsRoutes[path])
+}
+
+// hubAuth rejects the requests that are not signed by the hub when the provider was enrolled with a provider token.
+// Only read-only diagnostics and profiling requests from the provider host itself are allowed without the signature,
+// anything that changes the provider or controls the devices must always come from the hub.
+func hubAuth() gin.HandlerFunc {
+	if config.Local.ProviderToken == "" {
+		logger.ProviderLogger.LogWarn("provider_auth", "Provider was started without a provider token, its endpoints accept requests from anyone on the network")
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	signingKey := auth.ProviderSigningKey(config.Local.ProviderToken)
+	return func(c *gin.Context) {
+		if isLocalDiagnosticsRequest(c) {
+			c.Next()
+			return
+		}
+		if err := auth.VerifyProviderRequest(c.Request, signingKey); err != nil {
+			logger.ProviderLogger.LogWarn("provider_auth", fmt.Sprintf("Rejected request to `%s` from `%s` - %s", c.Request.URL.Path, c.RemoteIP(), err))
+			api.ErrorResponse(c, http.StatusUnauthorized, "Only the hub can access the provider")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// localOnly restricts an endpoint to requests from the provider host itself
+func localOnly(c *gin.Context) {
+	if !isLocalRequest(c) {
+		api.ErrorResponse(c, http.StatusForbidden, "Only available from the provider host")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,82 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"GADS/common/auth"
+	"GADS/provider/config"
+	"GADS/provider/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHubAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	logger.ProviderLogger = &logger.CustomLogger{Logger: discard}
+
+	token := auth.ProviderTokenPrefix + "secret"
+	previousToken := config.Local.ProviderToken
+	config.Local.ProviderToken = token
+	defer func() { config.Local.ProviderToken = previousToken }()
+
+	r := gin.New()
+	r.Use(hubAuth())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/info", ok)
+	r.GET("/drain", ok)
+	r.POST("/drain", ok)
+	r.POST("/uploadFile", ok)
+	r.GET("/debug/pprof/", ok)
+	r.GET("/device/:udid/info", ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		signed     bool
+		want       int
+	}{
+		{"local read-only diagnostics", http.MethodGet, "/info", "127.0.0.1:5000", false, http.StatusOK},
+		{"local drain status", http.MethodGet, "/drain", "127.0.0.1:5000", false, http.StatusOK},
+		{"local profiling", http.MethodGet, "/debug/pprof/", "127.0.0.1:5000", false, http.StatusOK},
+		{"local drain", http.MethodPost, "/drain", "127.0.0.1:5000", false, http.StatusUnauthorized},
+		{"local upload", http.MethodPost, "/uploadFile", "127.0.0.1:5000", false, http.StatusUnauthorized},
+		{"local device route", http.MethodGet, "/device/udid/info", "127.0.0.1:5000", false, http.StatusUnauthorized},
+		{"remote diagnostics", http.MethodGet, "/info", "192.0.2.10:5000", false, http.StatusUnauthorized},
+		{"signed drain from the hub", http.MethodPost, "/drain", "192.0.2.10:5000", true, http.StatusOK},
+		{"signed device route from the hub", http.MethodGet, "/device/udid/info", "192.0.2.10:5000", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.signed {
+				if err := auth.SignProviderRequest(req, auth.ProviderSigningKey(token)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s from %s = %d, want %d", tt.method, tt.path, tt.remoteAddr, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -22,12 +22,24 @@ var controlNetClient = &http.Client{
 	Timeout: time.Second * 120,
 }
 
+// remoteServerPort returns the port of the GADS Android remote server, mock devices accept the same commands
+func remoteServerPort(dev devices.PlatformDevice) (string, error) {
+	switch d := dev.(type) {
+	case *devices.AndroidDevice:
+		return d.GetAndroidRemoteServerPort(), nil
+	case *devices.MockDevice:
+		return d.GetControlPort(), nil
+	default:
+		return "", fmt.Errorf("device %s is not an Android device", dev.GetUDID())
+	}
+}
+
 func androidRemoteServerRequest(dev devices.PlatformDevice, method, endpoint string, requestBody io.Reader) (*http.Response, error) {
-	andDev, ok := dev.(*devices.AndroidDevice)
-	if !ok {
-		return nil, fmt.Errorf("device %s is not an Android device", dev.GetUDID())
+	port, err := remoteServerPort(dev)
+	if err != nil {
+		return nil, err
 	}
-	url := fmt.Sprintf("http://localhost:%s/%s", andDev.GetAndroidRemoteServerPort(), endpoint)
+	url := fmt.Sprintf("http://localhost:%s/%s", port, endpoint)
 	dev.GetLogger().LogDebug("androidRemoteServerRequest", fmt.Sprintf("Calling `%s` for device `%s`", url, dev.GetUDID()))
 	req, err := http.NewRequest(method, url, requestBody)
 	if err != nil {
@@ -37,11 +49,11 @@ func androidRemoteServerRequest(dev devices.PlatformDevice, method, endpoint str
 }
 
 func androidRemoteServerRequestJson(dev devices.PlatformDevice, method, endpoint string, requestBody io.Reader) (*http.Response, error) {
-	andDev, ok := dev.(*devices.AndroidDevice)
-	if !ok {
-		return nil, fmt.Errorf("device %s is not an Android device", dev.GetUDID())
+	port, err := remoteServerPort(dev)
+	if err != nil {
+		return nil, err
 	}
-	url := fmt.Sprintf("http://localhost:%s/%s", andDev.GetAndroidRemoteServerPort(), endpoint)
+	url := fmt.Sprintf("http://localhost:%s/%s", port, endpoint)
 	dev.GetLogger().LogDebug("androidRemoteServerRequest", fmt.Sprintf("Calling `%s` for device `%s`", url, dev.GetUDID()))
 	req, err := http.NewRequest(method, url, requestBody)
 	if err != nil {
@@ -94,6 +106,7 @@ func deviceLock(dev devices.PlatformDevice, lock string) (*http.Response, error)
 }
 
 func deviceTap(dev devices.PlatformDevice, x float64, y float64) (*http.Response, error) {
+	trackRecordingInteraction(dev, "tap", x, y, 0, 0, 0)
 	requestBody := struct {
 		X float64 `json:"x"`
 		Y float64 `json:"y"`
@@ -114,6 +127,7 @@ func deviceTap(dev devices.PlatformDevice, x float64, y float64) (*http.Response
 }
 
 func deviceTouchAndHold(dev devices.PlatformDevice, x float64, y float64, duration float64) (*http.Response, error) {
+	trackRecordingInteraction(dev, "touch_and_hold", x, y, 0, 0, duration)
 	if dev.GetOS() == "ios" {
 		duration = float64(duration) / 1000
 	}
@@ -148,7 +162,7 @@ func androidScreenshot(udid, displayID string) ([]byte, error) {
 	}
 
 	var out bytes.Buffer
-	cmd := exec.Command("adb", args...)
+	cmd := exec.Command(config.Local.Tools.ADB, args...)
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
 		return nil, err
@@ -157,6 +171,20 @@ func androidScreenshot(udid, displayID string) ([]byte, error) {
 }
 
 func deviceScreenshot(dev devices.PlatformDevice) (string, error) {
+	if tvDev, ok := dev.(devices.TVRemote); ok {
+		imageBytes, err := tvDev.Screenshot()
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(imageBytes), nil
+	}
+	if mockDev, ok := dev.(*devices.MockDevice); ok {
+		imageBytes, err := mockDev.Screenshot()
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(imageBytes), nil
+	}
 	if dev.GetOS() == "android" {
 		andDev, ok := dev.(*devices.AndroidDevice)
 		if !ok {
@@ -188,6 +216,7 @@ func deviceScreenshot(dev devices.PlatformDevice) (string, error) {
 }
 
 func deviceSwipe(dev devices.PlatformDevice, x, y, endX, endY float64) (*http.Response, error) {
+	trackRecordingInteraction(dev, "swipe", x, y, endX, endY, 0)
 	if dev.GetOS() == "ios" {
 		requestBody := struct {
 			X     float64 `json:"startX"`
@@ -296,6 +325,9 @@ func deviceDoubleTap(dev devices.PlatformDevice, x, y float64) (*http.Response,
 }
 
 func deviceHome(dev devices.PlatformDevice) (*http.Response, error) {
+	if _, ok := dev.(devices.TVRemote); ok {
+		return devicePressKey(dev, "home", 0)
+	}
 	if dev.GetOS() == "ios" {
 		return wdaRequest(dev, http.MethodPost, "wda/homescreen", nil)
 	} else {
@@ -320,7 +352,7 @@ func iOSAppSwitcher(dev devices.PlatformDevice) (*http.Response, error) {
 
 func androidRecents(dev devices.PlatformDevice) error {
 	if dev.GetOS() == "android" {
-		cmd := exec.CommandContext(dev.GetContext(), "adb", "-s", dev.GetUDID(), "shell", "input", "keyevent", "KEYCODE_APP_SWITCH")
+		cmd := exec.CommandContext(dev.GetContext(), config.Local.Tools.ADB, "-s", dev.GetUDID(), "shell", "input", "keyevent", "KEYCODE_APP_SWITCH")
 		return cmd.Run()
 	}
 	return fmt.Errorf("Device is not an Android device")
@@ -390,6 +422,13 @@ func executeTypeText(dev devices.PlatformDevice, text string) (*http.Response, e
 
 	if dev.GetOS() == "ios" {
 		return wdaRequest(dev, http.MethodPost, "wda/type", bytes.NewBuffer(typeJSON))
+	} else if dev.GetOS() == "mock" {
+		return androidRemoteServerRequestJson(dev, http.MethodPost, "type", bytes.NewBuffer(typeJSON))
+	} else if tvDev, ok := dev.(devices.TVRemote); ok {
+		if err := tvDev.TypeText(text); err != nil {
+			return nil, err
+		}
+		return localActionResponse("Typed text"), nil
 	} else {
 		andDev, ok := dev.(*devices.AndroidDevice)
 		if !ok {
@@ -499,6 +538,14 @@ func executeCustomAction(dev devices.PlatformDevice, actionType string, params m
 		y := utils.GetFloat(params, "y", 500)
 		return devicePinch(dev, x, y, 2.0)
 
+	case "set_location", "location_route", "reset_location":
+		return executeLocationAction(dev, actionType, params)
+
+	case "key":
+		key := utils.GetString(params, "key", "")
+		keycode := int(utils.GetFloat(params, "keycode", 0))
+		return devicePressKey(dev, key, keycode)
+
 	default:
 		return nil, fmt.Errorf("unsupported action type: %s", actionType)
 	}
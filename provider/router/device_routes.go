@@ -56,6 +56,18 @@ func DeviceHealth(c *gin.Context) {
 	api.InternalError(c, "Device is not healthy")
 }
 
+// DeviceDiagnostics returns the recent state transitions and setup steps of the device
+func DeviceDiagnostics(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+
+	api.OK(c, "Successfully retrieved device diagnostics", platDev.GetDiagnostics())
+}
+
 // Call the respective Appium/WDA endpoint to go to Homescreen
 func DeviceHome(c *gin.Context) {
 	udid := c.Param("udid")
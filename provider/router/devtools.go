@@ -0,0 +1,135 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"time"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+)
+
+var devToolsClient = &http.Client{Timeout: 5 * time.Second}
+
+func getDevToolsDevice(c *gin.Context) (*devices.AndroidDevice, bool) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return nil, false
+	}
+	androidDev, ok := platDev.(*devices.AndroidDevice)
+	if !ok {
+		api.BadRequest(c, "DevTools debugging is only available for Android devices")
+		return nil, false
+	}
+	return androidDev, true
+}
+
+func getDevToolsJSON(port, endpoint string, v any) error {
+	resp, err := devToolsClient.Get(fmt.Sprintf("http://localhost:%s%s", port, endpoint))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// DeviceDevToolsTargets lists the debuggable Chrome tabs and WebViews on the device
+func DeviceDevToolsTargets(c *gin.Context) {
+	androidDev, ok := getDevToolsDevice(c)
+	if !ok {
+		return
+	}
+
+	sockets, err := androidDev.DevToolsSockets()
+	if err != nil {
+		androidDev.GetLogger().LogError("devtools", fmt.Sprintf("Failed to list DevTools sockets - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to list DevTools sockets - %s", err))
+		return
+	}
+
+	result := []models.DevToolsSocket{}
+	for _, socket := range sockets {
+		port, err := androidDev.ForwardDevToolsSocket(socket)
+		if err != nil {
+			androidDev.GetLogger().LogWarn("devtools", err.Error())
+			continue
+		}
+
+		devToolsSocket := models.DevToolsSocket{
+			Socket:      socket,
+			PackageName: androidDev.DevToolsSocketPackage(socket),
+			Targets:     []models.DevToolsTarget{},
+		}
+		var version struct {
+			Browser string `json:"Browser"`
+		}
+		if err := getDevToolsJSON(port, "/json/version", &version); err == nil {
+			devToolsSocket.Browser = version.Browser
+		}
+		if err := getDevToolsJSON(port, "/json/list", &devToolsSocket.Targets); err != nil {
+			// The socket is listed while the app is alive but the WebView might not accept connections yet
+			androidDev.GetLogger().LogDebug("devtools", fmt.Sprintf("Failed to get DevTools targets of `%s` - %s", socket, err))
+		}
+		result = append(result, devToolsSocket)
+	}
+
+	api.OK(c, "", result)
+}
+
+// DeviceDevToolsProxy proxies the DevTools HTTP endpoints and WebSocket connections of a single socket
+func DeviceDevToolsProxy(c *gin.Context) {
+	androidDev, ok := getDevToolsDevice(c)
+	if !ok {
+		return
+	}
+
+	// Only forward sockets that are actually DevTools sockets, any abstract socket could be forwarded otherwise
+	socket := c.Param("socket")
+	sockets, err := androidDev.DevToolsSockets()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to list DevTools sockets - %s", err))
+		return
+	}
+	if !slices.Contains(sockets, socket) {
+		api.NotFound(c, fmt.Sprintf("DevTools socket `%s` not found", socket))
+		return
+	}
+
+	port, err := androidDev.ForwardDevToolsSocket(socket)
+	if err != nil {
+		api.InternalError(c, err.Error())
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = "localhost:" + port
+			req.URL.Path = c.Param("path")
+			// DevTools rejects requests whose Host is not an IP or localhost and WebSocket connections from unknown origins
+			req.Host = req.URL.Host
+			req.Header.Del("Origin")
+		},
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,44 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDrainStatus returns the drain state of the provider and the sessions still active on it
+func GetDrainStatus(c *gin.Context) {
+	api.OK(c, "", devices.GetDrainStatus())
+}
+
+// SetDrain toggles the drain mode of the provider
+func SetDrain(c *gin.Context) {
+	var request models.ProviderDrainRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	devices.SetDraining(request.Draining)
+	api.OK(c, "", devices.GetDrainStatus())
+}
+
+// trackStream counts the device stream as an active session so a shutdown waits for it
+func trackStream(c *gin.Context) {
+	done := devices.TrackStream()
+	defer done()
+	c.Next()
+}
@@ -23,12 +23,17 @@ func HandleRequests() *gin.Engine {
 	rConfig.AllowAllOrigins = true
 	rConfig.AllowHeaders = []string{"Authorization", "Content-Type"}
 	r.Use(cors.New(rConfig))
+	r.Use(hubAuth())
 
 	r.GET("/info", GetProviderData)
 	r.GET("/devices", DevicesInfo)
+	r.GET("/ports", GetPortAllocations)
 	r.POST("/uploadFile", UploadAndInstallApp)
+	r.GET("/drain", GetDrainStatus)
+	r.POST("/drain", SetDrain)
 
-	pprofGroup := r.Group("/debug/pprof")
+	// Profiling exposes the provider internals so it is only served to the provider host
+	pprofGroup := r.Group("/debug/pprof", localOnly)
 	{
 		pprofGroup.GET("/", gin.WrapF(pprof.Index))
 		pprofGroup.GET("/cmdline", gin.WrapF(pprof.Cmdline))
@@ -54,40 +59,74 @@ func HandleRequests() *gin.Engine {
 	deviceGroup.POST("/files/pull", PullFileFromSharedStorage)
 	deviceGroup.GET("/apps", DeviceInstalledApps)
 	deviceGroup.GET("/health", DeviceHealth)
+	deviceGroup.GET("/diagnostics", DeviceDiagnostics)
 	deviceGroup.POST("/tap", DeviceTap)
 	deviceGroup.POST("/touchAndHold", DeviceTouchAndHold)
 	deviceGroup.POST("/home", DeviceHome)
 	deviceGroup.POST("/recents", DeviceRecents)
 	deviceGroup.POST("/lock", DeviceLock)
 	deviceGroup.POST("/unlock", DeviceUnlock)
+	deviceGroup.POST("/key", DeviceKey)
 	deviceGroup.POST("/screenshot", DeviceScreenshot)
+	deviceGroup.POST("/recording/start", DeviceStartRecording)
+	deviceGroup.POST("/recording/stop", DeviceStopRecording)
+	deviceGroup.GET("/recordings", DeviceGetRecordings)
+	deviceGroup.GET("/recordings/:id", DeviceDownloadRecording)
+	deviceGroup.DELETE("/recordings/:id", DeviceDeleteRecording)
 	deviceGroup.GET("/displays", DeviceGetDisplays)
 	deviceGroup.POST("/display", DeviceSetActiveDisplay)
 	deviceGroup.POST("/swipe", DeviceSwipe)
 	deviceGroup.POST("/custom-action", DeviceExecuteCustomAction)
+	deviceGroup.GET("/location", DeviceGetLocation)
+	deviceGroup.POST("/location", DeviceSetLocation)
+	deviceGroup.POST("/location/route", DeviceSimulateLocationRoute)
+	deviceGroup.DELETE("/location", DeviceResetLocation)
+	deviceGroup.GET("/network", DeviceGetNetworkProfile)
+	deviceGroup.GET("/network/profiles", DeviceNetworkProfiles)
+	deviceGroup.POST("/network", DeviceSetNetworkProfile)
+	deviceGroup.DELETE("/network", DeviceResetNetworkProfile)
+	deviceGroup.POST("/network/capture/start", DeviceStartNetworkCapture)
+	deviceGroup.POST("/network/capture/stop", DeviceStopNetworkCapture)
+	deviceGroup.GET("/network/captures", DeviceGetNetworkCaptures)
+	deviceGroup.GET("/network/captures/:id", DeviceDownloadNetworkCapture)
+	deviceGroup.DELETE("/network/captures/:id", DeviceDeleteNetworkCapture)
+	deviceGroup.GET("/network/ca", DeviceDownloadCACertificate)
+	deviceGroup.POST("/network/ca/install", DeviceInstallCACertificate)
 	deviceGroup.GET("/appiumSource", DeviceAppiumSource)
 	deviceGroup.POST("/typeText", DeviceTypeText)
 	deviceGroup.GET("/getClipboard", DeviceGetClipboard)
 	deviceGroup.Any("/appium/*proxyPath", AppiumReverseProxy)
 	deviceGroup.GET("/adb-tunnel", ADBTunnelProxy)
-	deviceGroup.GET("/android-stream", AndroidStreamProxy)
-	deviceGroup.GET("/android-stream-mjpeg", AndroidStreamMJPEG)
+	deviceGroup.GET("/tunnel", TunnelProxy)
+	deviceGroup.GET("/usbmux", UsbmuxProxy)
+	deviceGroup.GET("/devtools", DeviceDevToolsTargets)
+	deviceGroup.Any("/devtools/:socket/*path", DeviceDevToolsProxy)
+	deviceGroup.GET("/webinspector", DeviceWebInspectorPages)
+	deviceGroup.GET("/webinspector/:app/:page", DeviceWebInspectorSocket)
+	deviceGroup.GET("/android-stream", trackStream, AndroidStreamProxy)
+	deviceGroup.GET("/android-stream-mjpeg", trackStream, AndroidStreamMJPEG)
 	deviceGroup.POST("/update-stream-settings", UpdateDeviceStreamSettings)
 	if config.ProviderConfig.UseGadsIosStream {
-		deviceGroup.GET("/ios-stream", IosStreamProxyGADS)
-		deviceGroup.GET("/ios-stream-mjpeg", IOSStreamMJPEG)
+		deviceGroup.GET("/ios-stream", trackStream, IosStreamProxyGADS)
+		deviceGroup.GET("/ios-stream-mjpeg", trackStream, IOSStreamMJPEG)
 	} else {
-		deviceGroup.GET("/ios-stream", IosStreamProxyWDA)
-		deviceGroup.GET("/ios-stream-mjpeg", IOSStreamMJPEGWda)
+		deviceGroup.GET("/ios-stream", trackStream, IosStreamProxyWDA)
+		deviceGroup.GET("/ios-stream-mjpeg", trackStream, IOSStreamMJPEGWda)
 	}
-	deviceGroup.GET("/ios-webrtc", IOSWebRTCSocket)
-	deviceGroup.GET("/android-webrtc", AndroidWebRTCSocket)
-	deviceGroup.GET("/ios-webrtc-broadcast", IOSBroadcastWebRTCSocket)
+	deviceGroup.GET("/tv-stream-mjpeg", trackStream, TVStreamMJPEG)
+	deviceGroup.GET("/ios-webrtc", trackStream, IOSWebRTCSocket)
+	deviceGroup.GET("/android-webrtc", trackStream, AndroidWebRTCSocket)
+	deviceGroup.GET("/ios-webrtc-broadcast", trackStream, IOSBroadcastWebRTCSocket)
 	deviceGroup.POST("/uninstallApp", UninstallApp)
 	deviceGroup.POST("/launchApp", LaunchApp)
 	deviceGroup.POST("/closeApp", CloseApp)
 	deviceGroup.POST("/reset", ResetDevice)
+	deviceGroup.POST("/reboot", RebootDevice)
 	deviceGroup.POST("/killApp", KillApp)
+	deviceGroup.POST("/clearAppData", ClearAppData)
+	deviceGroup.POST("/grantPermissions", GrantAppPermissions)
+	deviceGroup.POST("/revokePermissions", RevokeAppPermissions)
+	deviceGroup.POST("/resetPermissions", ResetAppPermissions)
 	deviceGroup.POST("/uploadAndInstallApp", UploadAndInstallApp)
 	deviceAppiumPluginGroup := deviceGroup.Group("/appium-plugin")
 	deviceAppiumPluginGroup.POST("/log", AppiumPluginLog)
@@ -12,6 +12,7 @@ package router
 import (
 	"GADS/common/models"
 	"GADS/common/utils"
+	"GADS/provider/config"
 	"GADS/provider/devices"
 	"GADS/provider/logger"
 	"bytes"
@@ -313,7 +314,7 @@ func NewFFmpegH264Encoder(device *models.DBDevice, streamTargetFPS int, jpegInpu
 	// -f h264: output raw H.264 stream
 	// -: write to stdout
 	cmd := exec.CommandContext(ctx,
-		"ffmpeg",
+		config.Local.Tools.FFmpeg,
 		"-probesize", "32",
 		"-fflags", "nobuffer",
 		"-flags", "low_delay",
@@ -0,0 +1,259 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errUnsupportedKey = errors.New("unsupported key")
+
+var androidKeycodes = map[string]int{
+	"home":         3,
+	"back":         4,
+	"up":           19,
+	"down":         20,
+	"left":         21,
+	"right":        22,
+	"center":       23,
+	"volume_up":    24,
+	"volume_down":  25,
+	"power":        26,
+	"tab":          61,
+	"enter":        66,
+	"delete":       67,
+	"menu":         82,
+	"search":       84,
+	"play_pause":   85,
+	"stop":         86,
+	"next":         87,
+	"previous":     88,
+	"rewind":       89,
+	"fast_forward": 90,
+	"escape":       111,
+	"mute":         164,
+	"recents":      187,
+	"sleep":        223,
+	"wakeup":       224,
+	"media_play":   126,
+	"media_pause":  127,
+	"channel_up":   166,
+	"channel_down": 167,
+}
+
+// iosButtons maps the key names to the WebDriverAgent pressButton names
+var iosButtons = map[string]string{
+	"home":        "home",
+	"volume_up":   "volumeUp",
+	"volume_down": "volumeDown",
+}
+
+// tizenKeys maps the key names to the Samsung TV remote keys
+var tizenKeys = map[string]string{
+	"home":         "KEY_HOME",
+	"back":         "KEY_RETURN",
+	"up":           "KEY_UP",
+	"down":         "KEY_DOWN",
+	"left":         "KEY_LEFT",
+	"right":        "KEY_RIGHT",
+	"enter":        "KEY_ENTER",
+	"center":       "KEY_ENTER",
+	"volume_up":    "KEY_VOLUP",
+	"volume_down":  "KEY_VOLDOWN",
+	"mute":         "KEY_MUTE",
+	"power":        "KEY_POWER",
+	"menu":         "KEY_MENU",
+	"play_pause":   "KEY_PLAY",
+	"media_play":   "KEY_PLAY",
+	"media_pause":  "KEY_PAUSE",
+	"stop":         "KEY_STOP",
+	"rewind":       "KEY_REWIND",
+	"fast_forward": "KEY_FF",
+	"channel_up":   "KEY_CHUP",
+	"channel_down": "KEY_CHDOWN",
+}
+
+// webOSKeys maps the key names to the WebOS remote buttons of the SSAP pointer input socket
+var webOSKeys = map[string]string{
+	"home":         "HOME",
+	"back":         "BACK",
+	"up":           "UP",
+	"down":         "DOWN",
+	"left":         "LEFT",
+	"right":        "RIGHT",
+	"enter":        "ENTER",
+	"center":       "ENTER",
+	"volume_up":    "VOLUMEUP",
+	"volume_down":  "VOLUMEDOWN",
+	"mute":         "MUTE",
+	"menu":         "MENU",
+	"play_pause":   "PLAY",
+	"media_play":   "PLAY",
+	"media_pause":  "PAUSE",
+	"stop":         "STOP",
+	"rewind":       "REWIND",
+	"fast_forward": "FASTFORWARD",
+	"channel_up":   "CHANNELUP",
+	"channel_down": "CHANNELDOWN",
+}
+
+// rokuKeys maps the key names to the Roku ECP keypress keys
+var rokuKeys = map[string]string{
+	"home":         "Home",
+	"back":         "Back",
+	"up":           "Up",
+	"down":         "Down",
+	"left":         "Left",
+	"right":        "Right",
+	"enter":        "Select",
+	"center":       "Select",
+	"volume_up":    "VolumeUp",
+	"volume_down":  "VolumeDown",
+	"mute":         "VolumeMute",
+	"power":        "PowerOff",
+	"menu":         "Info",
+	"search":       "Search",
+	"delete":       "Backspace",
+	"play_pause":   "Play",
+	"media_play":   "Play",
+	"media_pause":  "Play",
+	"rewind":       "Rev",
+	"fast_forward": "Fwd",
+	"channel_up":   "ChannelUp",
+	"channel_down": "ChannelDown",
+}
+
+// devicePressKey presses a hardware/remote key on the device by its name or an Android keycode
+func devicePressKey(dev devices.PlatformDevice, key string, keycode int) (*http.Response, error) {
+	key = strings.ToLower(key)
+
+	switch platDev := dev.(type) {
+	case *devices.AndroidDevice:
+		keycode, err := androidKeycode(key, keycode)
+		if err != nil {
+			return nil, err
+		}
+		if err := platDev.PressKeycode(keycode); err != nil {
+			return nil, err
+		}
+		return localActionResponse(fmt.Sprintf("Pressed keycode %d", keycode)), nil
+
+	case *devices.IOSDevice:
+		switch key {
+		case "power", "lock":
+			return deviceLock(dev, "lock")
+		case "wakeup", "unlock":
+			return deviceLock(dev, "unlock")
+		case "recents":
+			return iOSAppSwitcher(dev)
+		case "enter":
+			return executeTypeText(dev, "\n")
+		}
+		button, ok := iosButtons[key]
+		if !ok {
+			return nil, fmt.Errorf("%w `%s` for iOS devices", errUnsupportedKey, key)
+		}
+		if err := platDev.PressButton(button); err != nil {
+			return nil, err
+		}
+		return localActionResponse(fmt.Sprintf("Pressed `%s` button", key)), nil
+
+	case *devices.TizenDevice:
+		return tvPressRemoteKey(platDev, tizenKeys, key)
+
+	case *devices.WebOSDevice:
+		return tvPressRemoteKey(platDev, webOSKeys, key)
+
+	case *devices.RokuDevice:
+		return tvPressRemoteKey(platDev, rokuKeys, key)
+
+	default:
+		return nil, fmt.Errorf("%w - key presses are not supported for %s devices", errUnsupportedKey, dev.GetOS())
+	}
+}
+
+// androidKeycode returns the keycode of the key name, or the provided keycode when no key name is given
+func androidKeycode(key string, keycode int) (int, error) {
+	if key != "" {
+		code, ok := androidKeycodes[key]
+		if !ok {
+			return 0, fmt.Errorf("%w `%s` for Android devices", errUnsupportedKey, key)
+		}
+		return code, nil
+	}
+	if keycode <= 0 {
+		return 0, fmt.Errorf("%w - key or keycode is required", errUnsupportedKey)
+	}
+	return keycode, nil
+}
+
+// tvPressRemoteKey presses the TV remote key mapped to the key name
+func tvPressRemoteKey(dev devices.TVRemote, keys map[string]string, key string) (*http.Response, error) {
+	remoteKey, ok := keys[key]
+	if !ok {
+		return nil, fmt.Errorf("%w `%s` for %s devices", errUnsupportedKey, key, dev.GetOS())
+	}
+	if err := dev.PressRemoteKey(remoteKey); err != nil {
+		return nil, err
+	}
+	return localActionResponse(fmt.Sprintf("Pressed `%s` key", key)), nil
+}
+
+func DeviceKey(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+
+	var req models.AndroidKeycodePayload
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+	if req.Key == "" && req.Keycode <= 0 {
+		api.BadRequest(c, "Either `key` or `keycode` is required")
+		return
+	}
+	platDev.GetLogger().LogInfo("appium_interact", fmt.Sprintf("Pressing key `%s` / keycode %d", req.Key, req.Keycode))
+
+	keyResponse, err := devicePressKey(platDev, req.Key, req.Keycode)
+	if err != nil {
+		if errors.Is(err, errUnsupportedKey) {
+			api.BadRequest(c, err.Error())
+			return
+		}
+		platDev.GetLogger().LogError("appium_interact", fmt.Sprintf("Failed to press key `%s` / keycode %d - %s", req.Key, req.Keycode, err))
+		api.InternalError(c, fmt.Sprintf("Failed to press key - %s", err))
+		return
+	}
+	defer keyResponse.Body.Close()
+
+	keyResponseBody, err := io.ReadAll(keyResponse.Body)
+	if err != nil {
+		platDev.GetLogger().LogError("appium_interact", fmt.Sprintf("Failed to read key press response - %s", err))
+		api.InternalError(c, err.Error())
+		return
+	}
+
+	c.JSON(keyResponse.StatusCode, models.APIResponse[any]{Success: keyResponse.StatusCode < 400, Message: string(keyResponseBody)})
+}
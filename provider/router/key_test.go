@@ -0,0 +1,188 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GADS/common/models"
+	"GADS/provider/devices"
+	"GADS/provider/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeKeyDevice is a device of an OS without key press support
+type fakeKeyDevice struct {
+	devices.PlatformDevice
+	udid string
+}
+
+func (d *fakeKeyDevice) GetUDID() string { return d.udid }
+func (d *fakeKeyDevice) GetOS() string   { return "mock" }
+func (d *fakeKeyDevice) GetLogger() models.CustomLogger {
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	return &logger.CustomLogger{Logger: discard}
+}
+
+// fakeTVRemote records the remote keys pressed on it
+type fakeTVRemote struct {
+	devices.TVRemote
+	pressed []string
+}
+
+func (d *fakeTVRemote) GetOS() string { return "tv" }
+func (d *fakeTVRemote) PressRemoteKey(key string) error {
+	d.pressed = append(d.pressed, key)
+	return nil
+}
+
+func TestAndroidKeycode(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		keycode int
+		want    int
+		wantErr bool
+	}{
+		{name: "home", key: "home", want: 3},
+		{name: "back", key: "back", want: 4},
+		{name: "d-pad center", key: "center", want: 23},
+		{name: "volume up", key: "volume_up", want: 24},
+		{name: "power", key: "power", want: 26},
+		{name: "enter", key: "enter", want: 66},
+		{name: "recents", key: "recents", want: 187},
+		{name: "wakeup", key: "wakeup", want: 224},
+		{name: "key name wins over keycode", key: "home", keycode: 4, want: 3},
+		{name: "raw keycode", keycode: 82, want: 82},
+		{name: "unsupported key", key: "eject", wantErr: true},
+		{name: "unsupported key with keycode", key: "eject", keycode: 3, wantErr: true},
+		{name: "no key or keycode", wantErr: true},
+		{name: "negative keycode", keycode: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := androidKeycode(tt.key, tt.keycode)
+			if tt.wantErr {
+				if !errors.Is(err, errUnsupportedKey) {
+					t.Errorf("androidKeycode() = %d, %v, want an unsupported key error", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("androidKeycode() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// The unsupported keys are rejected before the device is called, so these run without a device
+func TestDevicePressKey_UnsupportedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		dev  devices.PlatformDevice
+		key  string
+	}{
+		{name: "iOS has no back button", dev: &devices.IOSDevice{}, key: "back"},
+		{name: "iOS has no d-pad", dev: &devices.IOSDevice{}, key: "up"},
+		{name: "iOS unknown key", dev: &devices.IOSDevice{}, key: "eject"},
+		{name: "iOS without key name", dev: &devices.IOSDevice{}, key: ""},
+		{name: "Android unknown key", dev: &devices.AndroidDevice{}, key: "eject"},
+		{name: "Android without key or keycode", dev: &devices.AndroidDevice{}, key: ""},
+		{name: "OS without key presses", dev: &fakeKeyDevice{}, key: "home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := devicePressKey(tt.dev, tt.key, 0); !errors.Is(err, errUnsupportedKey) {
+				t.Errorf("devicePressKey() error = %v, want an unsupported key error", err)
+			}
+		})
+	}
+}
+
+func TestDeviceKey_BadRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	udid := "key-router-test"
+	devices.DevManager.Set(udid, &fakeKeyDevice{udid: udid})
+	defer devices.DevManager.Delete(udid)
+
+	r := gin.New()
+	r.POST("/device/:udid/key", DeviceKey)
+
+	for _, body := range []string{`{"key":"home"}`, `{}`, `{"keycode":0}`, `not json`} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/device/"+udid+"/key", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want %d - %s", body, w.Code, http.StatusBadRequest, w.Body.String())
+		}
+	}
+}
+
+func TestTVPressRemoteKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string]string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "Tizen home", keys: tizenKeys, key: "home", want: "KEY_HOME"},
+		{name: "Tizen back", keys: tizenKeys, key: "back", want: "KEY_RETURN"},
+		{name: "Tizen center", keys: tizenKeys, key: "center", want: "KEY_ENTER"},
+		{name: "Tizen fast forward", keys: tizenKeys, key: "fast_forward", want: "KEY_FF"},
+		{name: "Tizen has no search", keys: tizenKeys, key: "search", wantErr: true},
+		{name: "WebOS home", keys: webOSKeys, key: "home", want: "HOME"},
+		{name: "WebOS volume up", keys: webOSKeys, key: "volume_up", want: "VOLUMEUP"},
+		{name: "WebOS channel down", keys: webOSKeys, key: "channel_down", want: "CHANNELDOWN"},
+		{name: "WebOS has no power", keys: webOSKeys, key: "power", wantErr: true},
+		{name: "Roku enter", keys: rokuKeys, key: "enter", want: "Select"},
+		{name: "Roku mute", keys: rokuKeys, key: "mute", want: "VolumeMute"},
+		{name: "Roku delete", keys: rokuKeys, key: "delete", want: "Backspace"},
+		{name: "Roku has no stop", keys: rokuKeys, key: "stop", wantErr: true},
+		{name: "Android key name", keys: rokuKeys, key: "recents", wantErr: true},
+		{name: "no key name", keys: tizenKeys, key: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &fakeTVRemote{}
+			_, err := tvPressRemoteKey(dev, tt.keys, tt.key)
+			if tt.wantErr {
+				if !errors.Is(err, errUnsupportedKey) || len(dev.pressed) != 0 {
+					t.Errorf("tvPressRemoteKey() error = %v, pressed %v, want an unsupported key error", err, dev.pressed)
+				}
+				return
+			}
+			if err != nil || len(dev.pressed) != 1 || dev.pressed[0] != tt.want {
+				t.Errorf("tvPressRemoteKey() error = %v, pressed %v, want %s", err, dev.pressed, tt.want)
+			}
+		})
+	}
+}
+
+// Every key name of a TV platform has to map to a remote key
+func TestTVKeys_NotEmpty(t *testing.T) {
+	for platform, keys := range map[string]map[string]string{"tizen": tizenKeys, "webos": webOSKeys, "roku": rokuKeys} {
+		for key, remoteKey := range keys {
+			if key != strings.ToLower(key) || remoteKey == "" {
+				t.Errorf("%s key `%s` maps to `%s`", platform, key, remoteKey)
+			}
+		}
+	}
+}
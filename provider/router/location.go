@@ -0,0 +1,172 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getLocationSimulator(c *gin.Context) (devices.LocationSimulator, bool) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return nil, false
+	}
+	locDev, ok := platDev.(devices.LocationSimulator)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Location simulation is not supported for %s devices", platDev.GetOS()))
+		return nil, false
+	}
+	return locDev, true
+}
+
+// locationErrorResponse responds with 412 when the device is missing the app it mocks its location through, the user can fix that
+func locationErrorResponse(c *gin.Context, message string, err error) {
+	if errors.Is(err, devices.ErrLocationAppMissing) {
+		api.ErrorResponse(c, http.StatusPreconditionFailed, fmt.Sprintf("%s - %s", message, err))
+		return
+	}
+	api.InternalError(c, fmt.Sprintf("%s - %s", message, err))
+}
+
+func DeviceGetLocation(c *gin.Context) {
+	locDev, ok := getLocationSimulator(c)
+	if !ok {
+		return
+	}
+
+	api.OK(c, "", devices.GetLocationSimulationStatus(locDev.GetUDID()))
+}
+
+func DeviceSetLocation(c *gin.Context) {
+	locDev, ok := getLocationSimulator(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetLocationRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	if err := devices.SimulateLocation(locDev, req.Latitude, req.Longitude); err != nil {
+		locDev.GetLogger().LogError("location_simulation", fmt.Sprintf("Failed to set location to %f,%f - %s", req.Latitude, req.Longitude, err))
+		locationErrorResponse(c, "Failed to set location", err)
+		return
+	}
+
+	locDev.GetLogger().LogInfo("location_simulation", fmt.Sprintf("Set location to %f,%f", req.Latitude, req.Longitude))
+	api.OKMessage(c, "Location set")
+}
+
+func DeviceSimulateLocationRoute(c *gin.Context) {
+	locDev, ok := getLocationSimulator(c)
+	if !ok {
+		return
+	}
+
+	var req models.LocationRouteRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	points, err := devices.ParseLocationRoute([]byte(req.Route))
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := devices.SimulateLocationRoute(locDev, points, req.SpeedKmh, req.Loop); err != nil {
+		locDev.GetLogger().LogError("location_simulation", fmt.Sprintf("Failed to start location route - %s", err))
+		locationErrorResponse(c, "Failed to start location route", err)
+		return
+	}
+
+	locDev.GetLogger().LogInfo("location_simulation", fmt.Sprintf("Started replaying location route with %d points", len(points)))
+	api.OK(c, "Location route started", devices.GetLocationSimulationStatus(locDev.GetUDID()))
+}
+
+func DeviceResetLocation(c *gin.Context) {
+	locDev, ok := getLocationSimulator(c)
+	if !ok {
+		return
+	}
+
+	if err := devices.StopLocationSimulation(locDev); err != nil {
+		locDev.GetLogger().LogError("location_simulation", fmt.Sprintf("Failed to reset location - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to reset location - %s", err))
+		return
+	}
+
+	locDev.GetLogger().LogInfo("location_simulation", "Reset device location")
+	api.OKMessage(c, "Location reset")
+}
+
+func executeLocationAction(dev devices.PlatformDevice, actionType string, params map[string]any) (*http.Response, error) {
+	locDev, ok := dev.(devices.LocationSimulator)
+	if !ok {
+		return nil, fmt.Errorf("location simulation is not supported for %s devices", dev.GetOS())
+	}
+
+	switch actionType {
+	case "set_location":
+		latitude, latOk := params["latitude"].(float64)
+		longitude, lonOk := params["longitude"].(float64)
+		if !latOk || !lonOk {
+			return nil, fmt.Errorf("parameters 'latitude' and 'longitude' are required for set_location")
+		}
+		if err := devices.SimulateLocation(locDev, latitude, longitude); err != nil {
+			return nil, err
+		}
+		return localActionResponse("Location set"), nil
+
+	case "location_route":
+		routeDocument, _ := params["route"].(string)
+		points, err := devices.ParseLocationRoute([]byte(routeDocument))
+		if err != nil {
+			return nil, err
+		}
+		speed, _ := params["speed_kmh"].(float64)
+		loop, _ := params["loop"].(bool)
+		if err := devices.SimulateLocationRoute(locDev, points, speed, loop); err != nil {
+			return nil, err
+		}
+		return localActionResponse("Location route started"), nil
+
+	default:
+		if err := devices.StopLocationSimulation(locDev); err != nil {
+			return nil, err
+		}
+		return localActionResponse("Location reset"), nil
+	}
+}
+
+// localActionResponse wraps the result of custom actions that are executed by the provider itself
+// instead of being forwarded to a device server, so they can be handled like the rest
+func localActionResponse(message string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(message)),
+	}
+}
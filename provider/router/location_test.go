@@ -0,0 +1,71 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GADS/common/models"
+	"GADS/provider/devices"
+	"GADS/provider/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeLocationDevice struct {
+	devices.PlatformDevice
+	udid        string
+	setLocation error
+}
+
+func (d *fakeLocationDevice) GetUDID() string { return d.udid }
+func (d *fakeLocationDevice) GetLogger() models.CustomLogger {
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	return &logger.CustomLogger{Logger: discard}
+}
+func (d *fakeLocationDevice) SetLocation(latitude, longitude float64) error { return d.setLocation }
+func (d *fakeLocationDevice) ResetLocation() error                          { return nil }
+
+func TestDeviceSetLocation_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"mock location app missing", fmt.Errorf("%w - install it", devices.ErrLocationAppMissing), http.StatusPreconditionFailed},
+		{"other failure", fmt.Errorf("adb failed"), http.StatusInternalServerError},
+		{"location set", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udid := "location-router-test"
+			devices.DevManager.Set(udid, &fakeLocationDevice{udid: udid, setLocation: tt.err})
+			defer devices.DevManager.Delete(udid)
+
+			r := gin.New()
+			r.POST("/device/:udid/location", DeviceSetLocation)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/device/"+udid+"/location", strings.NewReader(`{"latitude":42.69,"longitude":23.32}`)))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d - %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getNetworkShaper(c *gin.Context) (devices.NetworkShaper, bool) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return nil, false
+	}
+	shaper, ok := platDev.(devices.NetworkShaper)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Network shaping is not supported for %s devices", platDev.GetOS()))
+		return nil, false
+	}
+	return shaper, true
+}
+
+func DeviceNetworkProfiles(c *gin.Context) {
+	api.OK(c, "", devices.NetworkProfiles)
+}
+
+func DeviceGetNetworkProfile(c *gin.Context) {
+	shaper, ok := getNetworkShaper(c)
+	if !ok {
+		return
+	}
+
+	api.OK(c, "", devices.GetNetworkShapingStatus(shaper.GetUDID()))
+}
+
+func DeviceSetNetworkProfile(c *gin.Context) {
+	shaper, ok := getNetworkShaper(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetNetworkProfileRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	profile, err := devices.ResolveNetworkProfile(req.Profile, req.Custom)
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := devices.ApplyNetworkProfile(shaper, profile); err != nil {
+		shaper.GetLogger().LogError("network_shaping", fmt.Sprintf("Failed to apply network profile `%s` - %s", profile.Name, err))
+		api.InternalError(c, fmt.Sprintf("Failed to apply network profile - %s", err))
+		return
+	}
+
+	shaper.GetLogger().LogInfo("network_shaping", fmt.Sprintf("Applied network profile `%s`", profile.Name))
+	api.OK(c, "Network profile applied", devices.GetNetworkShapingStatus(shaper.GetUDID()))
+}
+
+func DeviceResetNetworkProfile(c *gin.Context) {
+	shaper, ok := getNetworkShaper(c)
+	if !ok {
+		return
+	}
+
+	if err := devices.StopNetworkShaping(shaper); err != nil {
+		shaper.GetLogger().LogError("network_shaping", fmt.Sprintf("Failed to reset network profile - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to reset network profile - %s", err))
+		return
+	}
+
+	shaper.GetLogger().LogInfo("network_shaping", "Reset network profile")
+	api.OKMessage(c, "Network profile reset")
+}
@@ -0,0 +1,221 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/devices"
+	"GADS/provider/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// workspaceRedactedHeaders returns the additional headers the workspace of the device wants redacted from captures
+func workspaceRedactedHeaders(dev devices.PlatformDevice) []string {
+	workspaceID := dev.GetDBDevice().WorkspaceID
+	if workspaceID == "" {
+		return nil
+	}
+	workspace, err := store.GlobalStore.GetWorkspaceByID(workspaceID)
+	if err != nil {
+		dev.GetLogger().LogWarn("network_capture", fmt.Sprintf("Failed to get workspace `%s` for header redaction - %s", workspaceID, err))
+		return nil
+	}
+	return workspace.HARRedactedHeaders
+}
+
+func DeviceStartNetworkCapture(c *gin.Context) {
+	shaper, ok := getNetworkShaper(c)
+	if !ok {
+		return
+	}
+
+	var req models.StartNetworkCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	if _, capturing := devices.GetActiveNetworkCapture(shaper.GetUDID()); capturing {
+		api.Conflict(c, fmt.Sprintf("Network traffic of device %s is already being captured", shaper.GetUDID()))
+		return
+	}
+
+	info, err := devices.StartNetworkCapture(shaper, req.InterceptTLS, workspaceRedactedHeaders(shaper))
+	if err != nil {
+		shaper.GetLogger().LogError("network_capture", fmt.Sprintf("Failed to start network capture - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to start network capture - %s", err))
+		return
+	}
+
+	shaper.GetLogger().LogInfo("network_capture", fmt.Sprintf("Started network capture `%s`", info.ID))
+	api.Created(c, "Network capture started", info)
+}
+
+func DeviceStopNetworkCapture(c *gin.Context) {
+	shaper, ok := getNetworkShaper(c)
+	if !ok {
+		return
+	}
+
+	if _, capturing := devices.GetActiveNetworkCapture(shaper.GetUDID()); !capturing {
+		api.NotFound(c, fmt.Sprintf("There is no active network capture for device %s", shaper.GetUDID()))
+		return
+	}
+
+	info, err := devices.StopNetworkCapture(shaper)
+	if err != nil {
+		api.NotFound(c, err.Error())
+		return
+	}
+	if info.Status == models.NetworkCaptureStatusFailed {
+		shaper.GetLogger().LogError("network_capture", fmt.Sprintf("Network capture `%s` failed - %s", info.ID, info.Error))
+		api.InternalError(c, fmt.Sprintf("Network capture failed - %s", info.Error))
+		return
+	}
+
+	shaper.GetLogger().LogInfo("network_capture", fmt.Sprintf("Stopped network capture `%s` with %d entries", info.ID, info.EntryCount))
+	api.OK(c, "Network capture stopped", info)
+}
+
+func DeviceGetNetworkCaptures(c *gin.Context) {
+	udid := c.Param("udid")
+	if _, ok := devices.DevManager.Get(udid); !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+	sessionID := c.Query("session_id")
+
+	captures := []models.NetworkCapture{}
+	if info, ok := devices.GetActiveNetworkCapture(udid); ok && (sessionID == "" || info.SessionID == sessionID) {
+		captures = append(captures, info)
+	}
+
+	metadataFiles, _ := filepath.Glob(filepath.Join(devices.NetworkCapturesDir(udid), "*.json"))
+	for _, metadataFile := range metadataFiles {
+		data, err := os.ReadFile(metadataFile)
+		if err != nil {
+			continue
+		}
+		var info models.NetworkCapture
+		if err := json.Unmarshal(data, &info); err != nil {
+			continue
+		}
+		if sessionID != "" && info.SessionID != sessionID {
+			continue
+		}
+		captures = append(captures, info)
+	}
+	sort.Slice(captures, func(i, j int) bool {
+		return captures[i].StartedAt > captures[j].StartedAt
+	})
+
+	api.OK(c, "", captures)
+}
+
+// networkCaptureFilePath validates the capture ID to avoid path traversal and returns the path to the HAR file
+func networkCaptureFilePath(udid, captureID string) (string, error) {
+	if _, err := uuid.Parse(captureID); err != nil {
+		return "", fmt.Errorf("invalid network capture ID")
+	}
+	return filepath.Join(devices.NetworkCapturesDir(udid), captureID+".har"), nil
+}
+
+func DeviceDownloadNetworkCapture(c *gin.Context) {
+	udid := c.Param("udid")
+	harPath, err := networkCaptureFilePath(udid, c.Param("id"))
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+	if _, err := os.Stat(harPath); err != nil {
+		api.NotFound(c, "Network capture not found")
+		return
+	}
+
+	c.FileAttachment(harPath, fmt.Sprintf("%s_%s", udid, filepath.Base(harPath)))
+}
+
+func DeviceDeleteNetworkCapture(c *gin.Context) {
+	udid := c.Param("udid")
+	harPath, err := networkCaptureFilePath(udid, c.Param("id"))
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+	if err := os.Remove(harPath); err != nil {
+		if os.IsNotExist(err) {
+			api.NotFound(c, "Network capture not found")
+			return
+		}
+		api.InternalError(c, fmt.Sprintf("Failed to delete network capture - %s", err))
+		return
+	}
+	os.Remove(strings.TrimSuffix(harPath, ".har") + ".json")
+
+	api.OKMessage(c, "Network capture deleted")
+}
+
+func DeviceDownloadCACertificate(c *gin.Context) {
+	certPEM, err := devices.GetGadsCACertificatePEM()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to load GADS CA - %s", err))
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="gads-ca.crt"`)
+	c.Data(http.StatusOK, "application/x-x509-ca-cert", certPEM)
+}
+
+func DeviceInstallCACertificate(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+	installer, ok := platDev.(devices.CAInstaller)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Installing the GADS CA is not supported for %s devices", platDev.GetOS()))
+		return
+	}
+
+	ca, err := devices.LoadGadsCA()
+	if err != nil {
+		api.InternalError(c, fmt.Sprintf("Failed to load GADS CA - %s", err))
+		return
+	}
+
+	if err := installer.InstallCACertificate(ca.Leaf); err != nil {
+		if errors.Is(err, devices.ErrCAManualInstallRequired) {
+			platDev.GetLogger().LogWarn("network_capture", err.Error())
+			api.ErrorResponse(c, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+		platDev.GetLogger().LogError("network_capture", fmt.Sprintf("Failed to install GADS CA - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to install GADS CA - %s", err))
+		return
+	}
+
+	platDev.GetLogger().LogInfo("network_capture", "Installed GADS CA")
+	api.OKMessage(c, "GADS CA installed")
+}
@@ -0,0 +1,651 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GADS/common/api"
+	"GADS/common/models"
+	"GADS/provider/config"
+	"GADS/provider/devices"
+	"GADS/provider/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
+)
+
+const (
+	// screenrecord refuses to record for more than 3 minutes so we record in chunks and stitch them afterwards
+	screenrecordChunkSeconds = 180
+	recordingOutputFPS       = 30
+	overlayMarkerSize        = 40
+	overlayTapDuration       = 0.5
+)
+
+// recordingStopTimeout is how long stopping waits for the recording to be finalized before its processes are killed
+var recordingStopTimeout = 30 * time.Second
+
+type recordingEvent struct {
+	Type string
+	// Offset from the start of the recording in seconds
+	Offset float64
+	// Coordinates are normalized to 0..1 of the screen size so they are independent of the video resolution
+	X, Y, EndX, EndY float64
+	Duration         float64
+}
+
+type activeRecording struct {
+	mu           sync.Mutex
+	info         models.DeviceRecording
+	dir          string
+	startedAt    time.Time
+	screenWidth  float64
+	screenHeight float64
+	events       []recordingEvent
+	// cancel asks the capture to stop and finalize the file, kill also ends the capture processes and stream connections
+	cancel context.CancelFunc
+	kill   context.CancelFunc
+	done   chan error
+	// finalized is closed once the stopped recording is processed and its metadata is stored
+	finalized chan struct{}
+}
+
+var (
+	activeRecordings   = make(map[string]*activeRecording)
+	activeRecordingsMu sync.Mutex
+)
+
+func init() {
+	// Recording processes must not outlive the device session
+	devices.OnReset(func(udid string) {
+		if info, ok := stopDeviceRecording(udid); ok {
+			logger.ProviderLogger.LogInfo("recording", fmt.Sprintf("Stopping screen recording `%s` of device `%s` after the device was reset", info.ID, udid))
+		}
+	})
+}
+
+func recordingsDir(udid string) string {
+	return filepath.Join(config.ProviderConfig.ProviderFolder, "device_"+udid, "recordings")
+}
+
+func DeviceStartRecording(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+	rcDev, ok := platDev.(devices.RemoteControllable)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Device %s does not support screen recording", udid))
+		return
+	}
+
+	var req models.StartRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.BadRequest(c, fmt.Sprintf("Invalid request body - %s", err))
+		return
+	}
+
+	switch platDev.GetOS() {
+	case "android":
+		if req.Source == "" {
+			req.Source = models.RecordingSourceScreenrecord
+		}
+	case "ios":
+		if req.Source == "" {
+			req.Source = models.RecordingSourceStream
+		}
+		if req.Source != models.RecordingSourceStream {
+			api.BadRequest(c, "iOS devices support only the `stream` recording source")
+			return
+		}
+	default:
+		api.BadRequest(c, fmt.Sprintf("Screen recording is not supported for %s devices", platDev.GetOS()))
+		return
+	}
+	if req.Source != models.RecordingSourceScreenrecord && req.Source != models.RecordingSourceStream {
+		api.BadRequest(c, fmt.Sprintf("Unknown recording source `%s`", req.Source))
+		return
+	}
+
+	rec := &activeRecording{
+		info: models.DeviceRecording{
+			ID:      uuid.NewString(),
+			UDID:    udid,
+			Source:  req.Source,
+			Overlay: req.Overlay,
+			Status:  models.RecordingStatusRecording,
+		},
+		dir:       recordingsDir(udid),
+		done:      make(chan error, 1),
+		finalized: make(chan struct{}),
+	}
+	if err := os.MkdirAll(rec.dir, os.ModePerm); err != nil {
+		platDev.GetLogger().LogError("recording", fmt.Sprintf("Failed to create recordings folder - %s", err))
+		api.InternalError(c, "Failed to create recordings folder")
+		return
+	}
+
+	width, height, _ := rcDev.GetScreenSize()
+	rec.screenWidth, _ = strconv.ParseFloat(width, 64)
+	rec.screenHeight, _ = strconv.ParseFloat(height, 64)
+
+	procCtx, kill := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(procCtx)
+	rec.cancel = cancel
+	rec.kill = kill
+	rec.startedAt = time.Now()
+	rec.info.StartedAt = rec.startedAt.UnixMilli()
+	outputPath := filepath.Join(rec.dir, rec.info.ID+".mp4")
+
+	// Reserve the device before connecting to it, the lock is not held during the network I/O
+	if err := reserveRecording(rec); err != nil {
+		kill()
+		api.Conflict(c, err.Error())
+		return
+	}
+
+	if req.Source == models.RecordingSourceScreenrecord {
+		go func() {
+			rec.done <- recordAndroidScreen(ctx, procCtx, udid, rec.dir, rec.info.ID, outputPath)
+		}()
+	} else {
+		frames, closeFrames, err := recordingFrameSource(procCtx, rcDev)
+		if err != nil {
+			kill()
+			rec.done <- err
+			activeRecordingsMu.Lock()
+			if activeRecordings[udid] == rec {
+				delete(activeRecordings, udid)
+			}
+			activeRecordingsMu.Unlock()
+			platDev.GetLogger().LogError("recording", fmt.Sprintf("Failed to connect to device stream - %s", err))
+			api.InternalError(c, fmt.Sprintf("Failed to connect to device stream - %s", err))
+			return
+		}
+		go func() {
+			defer closeFrames()
+			rec.done <- encodeJPEGFramesToMP4(ctx, procCtx, frames, outputPath)
+		}()
+	}
+
+	platDev.GetLogger().LogInfo("recording", fmt.Sprintf("Started screen recording `%s` using `%s`", rec.info.ID, rec.info.Source))
+	api.Created(c, "Recording started", rec.info)
+}
+
+func DeviceStopRecording(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+
+	info, exists := stopDeviceRecording(udid)
+	if !exists {
+		api.NotFound(c, fmt.Sprintf("There is no active recording for device %s", udid))
+		return
+	}
+
+	platDev.GetLogger().LogInfo("recording", fmt.Sprintf("Stopping screen recording `%s`", info.ID))
+	api.OK(c, "Recording stopped, it is available once processed", info)
+}
+
+// reserveRecording makes rec the active recording of its device.
+// It fails while the device is being recorded or its previous recording is still processed.
+func reserveRecording(rec *activeRecording) error {
+	activeRecordingsMu.Lock()
+	defer activeRecordingsMu.Unlock()
+
+	if existing, exists := activeRecordings[rec.info.UDID]; exists {
+		existing.mu.Lock()
+		status := existing.info.Status
+		existing.mu.Unlock()
+		if status == models.RecordingStatusProcessing {
+			return fmt.Errorf("The previous recording of device %s is still being processed", rec.info.UDID)
+		}
+		return fmt.Errorf("Device %s is already being recorded", rec.info.UDID)
+	}
+	activeRecordings[rec.info.UDID] = rec
+	return nil
+}
+
+// stopDeviceRecording stops the active recording of the device and finalizes it in the background, false if there is none.
+// The recording stays active in the `processing` state until its file and metadata are stored.
+func stopDeviceRecording(udid string) (models.DeviceRecording, bool) {
+	activeRecordingsMu.Lock()
+	rec, exists := activeRecordings[udid]
+	activeRecordingsMu.Unlock()
+	if !exists {
+		return models.DeviceRecording{}, false
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	// Stopping a recording that is already processed only returns its state
+	if rec.info.Status == models.RecordingStatusRecording {
+		rec.info.Status = models.RecordingStatusProcessing
+		rec.info.StoppedAt = time.Now().UnixMilli()
+		rec.info.EventCount = len(rec.events)
+		go rec.finalize()
+	}
+	return rec.info, true
+}
+
+// finalize processes the stopped recording, stores its metadata and releases the device for a new recording
+func (r *activeRecording) finalize() {
+	defer close(r.finalized)
+
+	info := r.stop()
+	if info.Status == models.RecordingStatusFailed {
+		logger.ProviderLogger.LogError("recording", fmt.Sprintf("Screen recording `%s` of device `%s` failed - %s", info.ID, info.UDID, info.Error))
+	} else {
+		logger.ProviderLogger.LogInfo("recording", fmt.Sprintf("Screen recording `%s` of device `%s` is completed", info.ID, info.UDID))
+	}
+
+	// The metadata is written before the recording stops being active so listing the recordings never misses it
+	if err := writeRecordingMetadata(r.dir, info); err != nil {
+		logger.ProviderLogger.LogWarn("recording", fmt.Sprintf("Failed to write metadata for recording `%s` - %s", info.ID, err))
+	}
+	activeRecordingsMu.Lock()
+	if activeRecordings[info.UDID] == r {
+		delete(activeRecordings, info.UDID)
+	}
+	activeRecordingsMu.Unlock()
+}
+
+// stop ends the capture, waits for the encoder to finalize the file and applies the overlay if requested.
+// If the file is not finalized in time the capture processes are killed so nothing outlives the recording.
+func (r *activeRecording) stop() models.DeviceRecording {
+	r.cancel()
+	defer r.kill()
+
+	var err error
+	select {
+	case err = <-r.done:
+	case <-time.After(recordingStopTimeout):
+		r.kill()
+		err = fmt.Errorf("timed out waiting for the recording to finalize")
+	}
+
+	// No events are added once the recording is processing, the overlay is applied without holding the lock
+	r.mu.Lock()
+	events := r.events
+	overlay := r.info.Overlay
+	r.mu.Unlock()
+	outputPath := filepath.Join(r.dir, r.info.ID+".mp4")
+
+	if err == nil && overlay && len(events) > 0 {
+		err = applyInteractionOverlay(r.dir, outputPath, events)
+	}
+	var stat os.FileInfo
+	if err == nil {
+		stat, err = os.Stat(outputPath)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.info.Status = models.RecordingStatusFailed
+		r.info.Error = err.Error()
+		return r.info
+	}
+	r.info.Status = models.RecordingStatusCompleted
+	r.info.FileName = filepath.Base(outputPath)
+	r.info.SizeBytes = stat.Size()
+	return r.info
+}
+
+// trackRecordingInteraction stores a tap or gesture for the overlay if the device is currently being recorded
+func trackRecordingInteraction(dev devices.PlatformDevice, eventType string, x, y, endX, endY, duration float64) {
+	activeRecordingsMu.Lock()
+	rec, ok := activeRecordings[dev.GetUDID()]
+	activeRecordingsMu.Unlock()
+	if !ok || !rec.info.Overlay || rec.screenWidth == 0 || rec.screenHeight == 0 {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.info.Status != models.RecordingStatusRecording {
+		return
+	}
+	rec.events = append(rec.events, recordingEvent{
+		Type:     eventType,
+		Offset:   time.Since(rec.startedAt).Seconds(),
+		X:        x / rec.screenWidth,
+		Y:        y / rec.screenHeight,
+		EndX:     endX / rec.screenWidth,
+		EndY:     endY / rec.screenHeight,
+		Duration: duration,
+	})
+}
+
+// recordAndroidScreen runs `screenrecord` in chunks until ctx is cancelled and stitches them in a single file.
+// Cancelling procCtx kills the processes.
+func recordAndroidScreen(ctx, procCtx context.Context, udid, dir, recordingID, outputPath string) error {
+	var chunks []string
+	defer func() {
+		for _, chunk := range chunks {
+			os.Remove(chunk)
+		}
+	}()
+
+	for i := 0; ; i++ {
+		remotePath := fmt.Sprintf("/data/local/tmp/gads_recording_%s_%d.mp4", recordingID, i)
+		cmd := exec.CommandContext(procCtx, config.Local.Tools.ADB, "-s", udid, "shell", "screenrecord", "--time-limit", strconv.Itoa(screenrecordChunkSeconds), remotePath)
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start screenrecord - %w", err)
+		}
+		waitErr := make(chan error, 1)
+		go func() {
+			waitErr <- cmd.Wait()
+		}()
+
+		stopped := false
+		select {
+		case <-ctx.Done():
+			// screenrecord finalizes the mp4 only when interrupted with SIGINT, killing adb would leave a broken file
+			exec.CommandContext(procCtx, config.Local.Tools.ADB, "-s", udid, "shell", "pkill", "-INT", "screenrecord").Run()
+			select {
+			case <-waitErr:
+			case <-time.After(10 * time.Second):
+				cmd.Process.Kill()
+			}
+			stopped = true
+		case err := <-waitErr:
+			if err != nil {
+				return fmt.Errorf("screenrecord exited unexpectedly - %w", err)
+			}
+		}
+
+		localPath := filepath.Join(dir, fmt.Sprintf("%s_chunk_%03d.mp4", recordingID, i))
+		if out, err := exec.CommandContext(procCtx, config.Local.Tools.ADB, "-s", udid, "pull", remotePath, localPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to pull recording chunk - %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		exec.Command(config.Local.Tools.ADB, "-s", udid, "shell", "rm", "-f", remotePath).Run()
+		chunks = append(chunks, localPath)
+
+		if stopped {
+			return concatRecordingChunks(procCtx, dir, recordingID, chunks, outputPath)
+		}
+	}
+}
+
+func concatRecordingChunks(ctx context.Context, dir, recordingID string, chunks []string, outputPath string) error {
+	if len(chunks) == 1 {
+		return os.Rename(chunks[0], outputPath)
+	}
+
+	listPath := filepath.Join(dir, recordingID+"_chunks.txt")
+	var list strings.Builder
+	for _, chunk := range chunks {
+		list.WriteString(fmt.Sprintf("file '%s'\n", chunk))
+	}
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+
+	out, err := exec.CommandContext(ctx, config.Local.Tools.FFmpeg, "-y", "-f", "concat", "-safe", "0", "-i", listPath, "-c", "copy", "-movflags", "+faststart", outputPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to stitch recording chunks - %s: %w", lastLines(string(out), 3), err)
+	}
+	return nil
+}
+
+// recordingFrameSource returns the JPEG frames of the device MJPEG stream and a func to release the stream connection.
+// The connection is also released when ctx is cancelled so the reader does not outlive a recording that did not stop in time.
+func recordingFrameSource(ctx context.Context, rcDev devices.RemoteControllable) (<-chan []byte, func(), error) {
+	if iosDev, ok := rcDev.(*devices.IOSDevice); ok {
+		extractor, err := NewWDAJPEGExtractor(iosDev.GetDBDevice(), iosDev.GetWDAStreamPort())
+		if err != nil {
+			return nil, nil, err
+		}
+		extractor.Start()
+		var closeOnce sync.Once
+		closeExtractor := func() { closeOnce.Do(extractor.Close) }
+		context.AfterFunc(ctx, closeExtractor)
+		return extractor.GetJPEGChannel(), closeExtractor, nil
+	}
+
+	dialCtx, cancelDial := context.WithTimeout(ctx, 10*time.Second)
+	conn, reader, err := dialDeviceStream(dialCtx, rcDev.GetStreamPort())
+	cancelDial()
+	if err != nil {
+		return nil, nil, err
+	}
+	stopClosing := context.AfterFunc(ctx, func() { conn.Close() })
+
+	frames := make(chan []byte, 5)
+	go func() {
+		defer close(frames)
+		for {
+			data, _, err := wsutil.ReadServerData(reader)
+			if err != nil {
+				return
+			}
+			select {
+			case frames <- data:
+			case <-ctx.Done():
+				return
+			default:
+				// Drop the frame if the encoder cannot keep up
+			}
+		}
+	}()
+
+	return frames, func() {
+		stopClosing()
+		conn.Close()
+	}, nil
+}
+
+// encodeJPEGFramesToMP4 pipes JPEG frames into ffmpeg until ctx is cancelled or the frames channel is closed.
+// Frames are timestamped by arrival so the video plays in real time regardless of the stream FPS. Cancelling procCtx kills ffmpeg.
+func encodeJPEGFramesToMP4(ctx, procCtx context.Context, frames <-chan []byte, outputPath string) error {
+	cmd := exec.CommandContext(procCtx, config.Local.Tools.FFmpeg,
+		"-y",
+		"-use_wallclock_as_timestamps", "1",
+		"-f", "image2pipe",
+		"-c:v", "mjpeg",
+		"-i", "-",
+		"-vf", "scale='trunc(iw/2)*2:trunc(ih/2)*2'",
+		"-r", strconv.Itoa(recordingOutputFPS),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create ffmpeg stdin pipe - %w", err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg - %w", err)
+	}
+
+	writing := true
+	for writing {
+		select {
+		case <-ctx.Done():
+			writing = false
+		case frame, ok := <-frames:
+			if !ok {
+				writing = false
+				break
+			}
+			if _, err := stdin.Write(frame); err != nil {
+				writing = false
+			}
+		}
+	}
+
+	// Closing stdin lets ffmpeg flush and write the moov atom
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed - %s: %w", lastLines(stderr.String(), 3), err)
+	}
+	return nil
+}
+
+// applyInteractionOverlay re-encodes the recording drawing a marker on each tap and on the start and end points of gestures
+func applyInteractionOverlay(dir, videoPath string, events []recordingEvent) error {
+	var filters []string
+	marker := func(x, y, from, to float64, color string) string {
+		return fmt.Sprintf("drawbox=x=iw*%.4f-%d:y=ih*%.4f-%d:w=%d:h=%d:color=%s@0.6:t=fill:enable='between(t,%.2f,%.2f)'",
+			x, overlayMarkerSize/2, y, overlayMarkerSize/2, overlayMarkerSize, overlayMarkerSize, color, from, to)
+	}
+	for _, event := range events {
+		switch event.Type {
+		case "swipe":
+			filters = append(filters,
+				marker(event.X, event.Y, event.Offset, event.Offset+overlayTapDuration, "green"),
+				marker(event.EndX, event.EndY, event.Offset, event.Offset+overlayTapDuration, "red"))
+		case "touch_and_hold":
+			filters = append(filters, marker(event.X, event.Y, event.Offset, event.Offset+overlayTapDuration+event.Duration/1000, "orange"))
+		default:
+			filters = append(filters, marker(event.X, event.Y, event.Offset, event.Offset+overlayTapDuration, "red"))
+		}
+	}
+
+	// The filter graph can get long for lengthy sessions so pass it through a script file instead of the arguments
+	scriptPath := videoPath + ".filter"
+	if err := os.WriteFile(scriptPath, []byte(strings.Join(filters, ",")), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(scriptPath)
+
+	overlayPath := filepath.Join(dir, "overlay_"+filepath.Base(videoPath))
+	out, err := exec.Command(config.Local.Tools.FFmpeg, "-y", "-i", videoPath, "-filter_script:v", scriptPath,
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p", "-movflags", "+faststart", overlayPath).CombinedOutput()
+	if err != nil {
+		os.Remove(overlayPath)
+		return fmt.Errorf("failed to apply interaction overlay - %s: %w", lastLines(string(out), 3), err)
+	}
+	return os.Rename(overlayPath, videoPath)
+}
+
+func writeRecordingMetadata(dir string, info models.DeviceRecording) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, info.ID+".json"), data, 0644)
+}
+
+func DeviceGetRecordings(c *gin.Context) {
+	udid := c.Param("udid")
+	if _, ok := devices.DevManager.Get(udid); !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return
+	}
+
+	recordings := []models.DeviceRecording{}
+	activeID := ""
+	activeRecordingsMu.Lock()
+	if rec, ok := activeRecordings[udid]; ok {
+		rec.mu.Lock()
+		info := rec.info
+		info.EventCount = len(rec.events)
+		rec.mu.Unlock()
+		recordings = append(recordings, info)
+		activeID = info.ID
+	}
+	activeRecordingsMu.Unlock()
+
+	metadataFiles, _ := filepath.Glob(filepath.Join(recordingsDir(udid), "*.json"))
+	for _, metadataFile := range metadataFiles {
+		data, err := os.ReadFile(metadataFile)
+		if err != nil {
+			continue
+		}
+		var info models.DeviceRecording
+		// The active recording can be finalized while the folder is read
+		if err := json.Unmarshal(data, &info); err != nil || info.ID == activeID {
+			continue
+		}
+		recordings = append(recordings, info)
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt > recordings[j].StartedAt
+	})
+
+	api.OK(c, "", recordings)
+}
+
+// recordingFilePath validates the recording ID to avoid path traversal and returns the path to the video
+func recordingFilePath(udid, recordingID string) (string, error) {
+	if _, err := uuid.Parse(recordingID); err != nil {
+		return "", fmt.Errorf("invalid recording ID")
+	}
+	return filepath.Join(recordingsDir(udid), recordingID+".mp4"), nil
+}
+
+func DeviceDownloadRecording(c *gin.Context) {
+	udid := c.Param("udid")
+	recordingPath, err := recordingFilePath(udid, c.Param("id"))
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+	if _, err := os.Stat(recordingPath); err != nil {
+		api.NotFound(c, "Recording not found")
+		return
+	}
+
+	c.FileAttachment(recordingPath, fmt.Sprintf("%s_%s", udid, filepath.Base(recordingPath)))
+}
+
+func DeviceDeleteRecording(c *gin.Context) {
+	udid := c.Param("udid")
+	recordingPath, err := recordingFilePath(udid, c.Param("id"))
+	if err != nil {
+		api.BadRequest(c, err.Error())
+		return
+	}
+	if err := os.Remove(recordingPath); err != nil {
+		if os.IsNotExist(err) {
+			api.NotFound(c, "Recording not found")
+			return
+		}
+		api.InternalError(c, fmt.Sprintf("Failed to delete recording - %s", err))
+		return
+	}
+	os.Remove(strings.TrimSuffix(recordingPath, ".mp4") + ".json")
+
+	api.OKMessage(c, "Recording deleted")
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
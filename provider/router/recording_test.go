@@ -0,0 +1,203 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"GADS/common/models"
+	"GADS/provider/config"
+
+	"github.com/google/uuid"
+)
+
+// newTestRecording registers an active recording whose capture is run by the provided func until it returns
+func newTestRecording(t *testing.T, udid string, capture func(ctx, procCtx context.Context, outputPath string) error) *activeRecording {
+	t.Helper()
+	procCtx, kill := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(procCtx)
+	rec := &activeRecording{
+		info:      models.DeviceRecording{ID: uuid.NewString(), UDID: udid, Status: models.RecordingStatusRecording},
+		dir:       recordingsDir(udid),
+		cancel:    cancel,
+		kill:      kill,
+		done:      make(chan error, 1),
+		finalized: make(chan struct{}),
+	}
+	if err := os.MkdirAll(rec.dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		rec.done <- capture(ctx, procCtx, filepath.Join(rec.dir, rec.info.ID+".mp4"))
+	}()
+
+	activeRecordingsMu.Lock()
+	activeRecordings[udid] = rec
+	activeRecordingsMu.Unlock()
+	return rec
+}
+
+func useTestProviderFolder(t *testing.T) {
+	previousFolder := config.ProviderConfig.ProviderFolder
+	config.ProviderConfig.ProviderFolder = t.TempDir()
+	t.Cleanup(func() { config.ProviderConfig.ProviderFolder = previousFolder })
+}
+
+// waitForFinalize waits for the stopped recording to be processed and returns its stored metadata
+func waitForFinalize(t *testing.T, rec *activeRecording) models.DeviceRecording {
+	t.Helper()
+	select {
+	case <-rec.finalized:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the recording to be finalized")
+	}
+
+	var metadata models.DeviceRecording
+	data, err := os.ReadFile(filepath.Join(rec.dir, rec.info.ID+".json"))
+	if err != nil || json.Unmarshal(data, &metadata) != nil {
+		t.Fatalf("Expected the recording metadata to be stored - %v", err)
+	}
+	return metadata
+}
+
+func TestStopDeviceRecording(t *testing.T) {
+	useTestProviderFolder(t)
+
+	release := make(chan struct{})
+	rec := newTestRecording(t, "test-udid", func(ctx, procCtx context.Context, outputPath string) error {
+		<-ctx.Done()
+		// Processing takes until the test releases it
+		<-release
+		return os.WriteFile(outputPath, []byte("video"), 0644)
+	})
+
+	info, ok := stopDeviceRecording("test-udid")
+	if !ok {
+		t.Fatal("Expected the active recording to be stopped")
+	}
+	if info.Status != models.RecordingStatusProcessing || info.StoppedAt == 0 {
+		t.Errorf("Expected the recording to be processing after stop, got %+v", info)
+	}
+
+	// Stopping again returns the processing recording without finalizing it twice
+	if info, ok := stopDeviceRecording("test-udid"); !ok || info.Status != models.RecordingStatusProcessing {
+		t.Errorf("Expected the processing recording to be returned, got %+v - %v", info, ok)
+	}
+
+	next := &activeRecording{info: models.DeviceRecording{ID: uuid.NewString(), UDID: "test-udid", Status: models.RecordingStatusRecording}}
+	if err := reserveRecording(next); err == nil {
+		t.Errorf("Expected a new recording to be rejected while the previous one is processing")
+	}
+
+	close(release)
+	metadata := waitForFinalize(t, rec)
+	if metadata.Status != models.RecordingStatusCompleted || metadata.FileName != rec.info.ID+".mp4" || metadata.SizeBytes != 5 {
+		t.Errorf("Unexpected recording metadata %+v", metadata)
+	}
+
+	if _, ok := stopDeviceRecording("test-udid"); ok {
+		t.Errorf("Expected no active recording after it was finalized")
+	}
+	if err := reserveRecording(next); err != nil {
+		t.Errorf("Expected a new recording to start after the previous one was finalized - %s", err)
+	}
+	activeRecordingsMu.Lock()
+	delete(activeRecordings, "test-udid")
+	activeRecordingsMu.Unlock()
+}
+
+func TestReserveRecording_AlreadyRecording(t *testing.T) {
+	useTestProviderFolder(t)
+
+	rec := newTestRecording(t, "test-udid", func(ctx, procCtx context.Context, outputPath string) error {
+		<-ctx.Done()
+		return os.WriteFile(outputPath, []byte("video"), 0644)
+	})
+
+	next := &activeRecording{info: models.DeviceRecording{ID: uuid.NewString(), UDID: "test-udid", Status: models.RecordingStatusRecording}}
+	if err := reserveRecording(next); err == nil {
+		t.Errorf("Expected a second recording of the device to be rejected")
+	}
+
+	stopDeviceRecording("test-udid")
+	waitForFinalize(t, rec)
+}
+
+func TestStopDeviceRecording_Timeout(t *testing.T) {
+	useTestProviderFolder(t)
+	previousTimeout := recordingStopTimeout
+	recordingStopTimeout = 50 * time.Millisecond
+	defer func() { recordingStopTimeout = previousTimeout }()
+
+	killed := make(chan struct{})
+	rec := newTestRecording(t, "test-udid", func(ctx, procCtx context.Context, outputPath string) error {
+		// The capture ignores the stop request and only ends when it is killed, like a hanging ffmpeg
+		<-procCtx.Done()
+		close(killed)
+		return procCtx.Err()
+	})
+
+	if _, ok := stopDeviceRecording("test-udid"); !ok {
+		t.Fatal("Expected the active recording to be stopped")
+	}
+	if metadata := waitForFinalize(t, rec); metadata.Status != models.RecordingStatusFailed || metadata.Error == "" {
+		t.Errorf("Expected the recording to fail, got %+v", metadata)
+	}
+	select {
+	case <-killed:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the capture to be killed after the stop timeout")
+	}
+}
+
+func TestRecordingFilePath(t *testing.T) {
+	useTestProviderFolder(t)
+
+	id := uuid.NewString()
+	path, err := recordingFilePath("test-udid", id)
+	if err != nil || path != filepath.Join(recordingsDir("test-udid"), id+".mp4") {
+		t.Errorf("Unexpected recording path %s - %v", path, err)
+	}
+	for _, invalid := range []string{"../../etc/passwd", "", "recording"} {
+		if _, err := recordingFilePath("test-udid", invalid); err == nil {
+			t.Errorf("Expected `%s` to be rejected", invalid)
+		}
+	}
+}
+
+func TestConcatRecordingChunks_SingleChunk(t *testing.T) {
+	dir := t.TempDir()
+	chunk := filepath.Join(dir, "chunk_000.mp4")
+	if err := os.WriteFile(chunk, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputPath := filepath.Join(dir, "output.mp4")
+	if err := concatRecordingChunks(context.Background(), dir, "id", []string{chunk}, outputPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(outputPath); err != nil || string(data) != "video" {
+		t.Errorf("Expected the single chunk to become the recording, got %q - %v", data, err)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	if got := lastLines("one\ntwo\nthree\nfour\n", 2); got != "three | four" {
+		t.Errorf("lastLines() = %q", got)
+	}
+	if got := lastLines("one", 3); got != "one" {
+		t.Errorf("lastLines() = %q", got)
+	}
+}
@@ -11,14 +11,16 @@ package router
 
 import (
 	"GADS/common/api"
-	"GADS/common/db"
 	"GADS/common/models"
 	"GADS/common/utils"
 	"GADS/provider/config"
 	"GADS/provider/devices"
 	"GADS/provider/logger"
+	"GADS/provider/providerutil"
+	"GADS/provider/store"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -50,20 +52,27 @@ func AppiumReverseProxy(c *gin.Context) {
 
 	udid := c.Param("udid")
 
-	if !config.ProviderConfig.SetupAppiumServers {
-		c.JSON(http.StatusServiceUnavailable, createAppiumErrorResponse("Appium server not available for device"))
-		return
-	}
-
 	platDev, ok := devices.DevManager.Get(udid)
 	if !ok {
 		c.JSON(http.StatusNotFound, createAppiumErrorResponse("Device not found"))
 		return
 	}
 
+	// Mock devices always serve their stub Appium server
+	if !config.ProviderConfig.SetupAppiumServers && platDev.GetOS() != "mock" {
+		c.JSON(http.StatusServiceUnavailable, createAppiumErrorResponse("Appium server not available for device"))
+		return
+	}
+
 	target := "http://localhost:" + platDev.GetAppiumPort()
 	path := c.Param("proxyPath")
 
+	// Running sessions are served while draining but no new ones are started
+	if devices.IsDraining() && c.Request.Method == http.MethodPost && strings.HasSuffix(strings.TrimSuffix(path, "/"), "/session") {
+		c.JSON(http.StatusServiceUnavailable, createAppiumErrorResponse("Provider is draining and does not accept new sessions"))
+		return
+	}
+
 	proxy := newAppiumProxy(target, path)
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
@@ -232,6 +241,11 @@ func UploadAndInstallApp(c *gin.Context) {
 	api.NotFound(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
 }
 
+// GetPortAllocations returns the configured port ranges and the ports allocated for the device services
+func GetPortAllocations(c *gin.Context) {
+	api.OK(c, "Successfully retrieved port allocations", providerutil.GetPortDiagnostics())
+}
+
 func GetProviderData(c *gin.Context) {
 	var providerData models.ProviderData
 
@@ -243,6 +257,10 @@ func GetProviderData(c *gin.Context) {
 
 	providerData.ProviderData = *config.ProviderConfig
 	providerData.DeviceData = syncData
+	providerData.AppiumInstallations = providerutil.AppiumInstallations(func(udid string) bool {
+		dev, ok := devices.DevManager.Get(udid)
+		return ok && dev.GetIsAppiumUp()
+	})
 
 	api.OK(c, "Successfully retrieved provider data", providerData)
 }
@@ -594,6 +612,32 @@ func ResetDevice(c *gin.Context) {
 	api.OKMessage(c, "Initiated device re-provisioning")
 }
 
+// RebootDevice reboots the device, it is re-provisioned automatically once it reconnects
+func RebootDevice(c *gin.Context) {
+	udid := c.Param("udid")
+
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.BadRequest(c, fmt.Sprintf("Did not find device with udid `%s`", udid))
+		return
+	}
+
+	if err := devices.RebootDevice(platDev); err != nil {
+		switch {
+		case errors.Is(err, devices.ErrRebootInProgress):
+			api.Conflict(c, err.Error())
+		case errors.Is(err, devices.ErrUnsupportedOperation):
+			api.ErrorResponse(c, http.StatusNotImplemented, err.Error())
+		default:
+			platDev.GetLogger().LogError("reboot", fmt.Sprintf("Failed to reboot device - %s", err))
+			api.InternalError(c, fmt.Sprintf("Failed to reboot device - %s", err))
+		}
+		return
+	}
+
+	api.OKMessage(c, "Initiated device reboot, the device will be re-provisioned once it reconnects")
+}
+
 func UpdateDeviceStreamSettings(c *gin.Context) {
 	udid := c.Param("udid")
 
@@ -646,7 +690,7 @@ func UpdateDeviceStreamSettings(c *gin.Context) {
 		StreamScalingFactor: rcDev.GetStreamScalingFactor(),
 	}
 
-	err = db.GlobalMongoStore.UpdateDeviceStreamSettings(udid, deviceStreamSettings)
+	err = store.GlobalStore.UpdateDeviceStreamSettings(udid, deviceStreamSettings)
 	if err != nil {
 		api.InternalError(c, "Failed to update device stream settings in the DB")
 		return
@@ -664,6 +708,22 @@ func DeviceFiles(c *gin.Context) {
 		return
 	}
 
+	if iosDev, ok := platDev.(*devices.IOSDevice); ok {
+		bundleID := c.Query("app")
+		if bundleID == "" {
+			api.BadRequest(c, "Missing `app` bundle identifier, only app containers can be browsed on iOS")
+			return
+		}
+		fileTree, err := iosDev.GetIOSAppFileTree(bundleID)
+		if err != nil {
+			platDev.GetLogger().LogError("app_files", fmt.Sprintf("Failed to get file tree of app `%s` - %s", bundleID, err))
+			api.InternalError(c, fmt.Sprintf("Failed to get file tree of app `%s` - %s", bundleID, err))
+			return
+		}
+		api.OK(c, fmt.Sprintf("Successfully got file tree of app `%s`", bundleID), fileTree)
+		return
+	}
+
 	if platDev.GetOS() == "android" {
 		filesResp, err := androidRemoteServerRequest(platDev, http.MethodGet, "files", nil)
 		if err != nil {
@@ -687,7 +747,7 @@ func DeviceFiles(c *gin.Context) {
 		api.OK(c, "Successfully got shared storage file tree", fileTree)
 		return
 	}
-	api.BadRequest(c, "Functionality not supported on iOS")
+	api.BadRequest(c, fmt.Sprintf("Functionality not supported on %s", platDev.GetOS()))
 }
 
 func PushFileToSharedStorage(c *gin.Context) {
@@ -699,8 +759,10 @@ func PushFileToSharedStorage(c *gin.Context) {
 		return
 	}
 
-	if platDev.GetOS() == "ios" {
-		api.BadRequest(c, "Functionality not supported for iOS devices")
+	iosDev, isIOS := platDev.(*devices.IOSDevice)
+	bundleID := c.PostForm("app")
+	if isIOS && bundleID == "" {
+		api.BadRequest(c, "Missing `app` bundle identifier, only app containers are accessible on iOS")
 		return
 	}
 
@@ -721,8 +783,18 @@ func PushFileToSharedStorage(c *gin.Context) {
 		return
 	}
 
+	if isIOS {
+		if err := iosDev.PushIOSAppFile(bundleID, tempPath, destPath); err != nil {
+			platDev.GetLogger().LogError("app_files", fmt.Sprintf("Failed to push file `%s` to `%s` of app `%s` - %s", file.Filename, destPath, bundleID, err))
+			api.InternalError(c, fmt.Sprintf("Failed to push file `%s` to `%s` of app `%s` - %s", file.Filename, destPath, bundleID, err))
+			return
+		}
+		api.OKMessage(c, fmt.Sprintf("File `%s` successfully pushed to `%s` of app `%s`", file.Filename, destPath, bundleID))
+		return
+	}
+
 	// Push the file via adb to from the temporary folder to the target shared storage path
-	adbCmd := exec.Command("adb", "-s", platDev.GetUDID(), "push", tempPath, destPath)
+	adbCmd := exec.Command(config.Local.Tools.ADB, "-s", platDev.GetUDID(), "push", tempPath, destPath)
 	_, err = adbCmd.CombinedOutput()
 	if err != nil {
 		api.InternalError(c, fmt.Sprintf("Failed to push file `%s` to `%s` - %s", file.Filename, destPath, err))
@@ -746,8 +818,18 @@ func DeleteFileFromSharedStorage(c *gin.Context) {
 		return
 	}
 
-	if platDev.GetOS() == "ios" {
-		api.BadRequest(c, "Functionality not supported for iOS devices")
+	if iosDev, ok := platDev.(*devices.IOSDevice); ok {
+		bundleID := c.PostForm("app")
+		if bundleID == "" {
+			api.BadRequest(c, "Missing `app` bundle identifier, only app containers are accessible on iOS")
+			return
+		}
+		if err := iosDev.DeleteIOSAppFile(bundleID, filePath); err != nil {
+			platDev.GetLogger().LogError("app_files", fmt.Sprintf("Failed to delete `%s` of app `%s` - %s", filePath, bundleID, err))
+			api.InternalError(c, fmt.Sprintf("Failed to delete file on path `%s` of app `%s`", filePath, bundleID))
+			return
+		}
+		api.OKMessage(c, "Successfully deleted file")
 		return
 	}
 
@@ -787,13 +869,21 @@ func PullFileFromSharedStorage(c *gin.Context) {
 		return
 	}
 
-	if platDev.GetOS() == "ios" {
-		api.BadRequest(c, "Functionality not supported for iOS devices")
-		return
+	var tempFilePath string
+	var err error
+	if iosDev, ok := platDev.(*devices.IOSDevice); ok {
+		bundleID := c.PostForm("app")
+		if bundleID == "" {
+			api.BadRequest(c, "Missing `app` bundle identifier, only app containers are accessible on iOS")
+			return
+		}
+		tempFilePath, err = iosDev.PullIOSAppFile(bundleID, filePath, fileName)
+		if err != nil {
+			platDev.GetLogger().LogError("app_files", fmt.Sprintf("Failed to pull `%s` of app `%s` - %s", filePath, bundleID, err))
+		}
+	} else {
+		tempFilePath, err = devices.PullAndroidSharedStorageFile(platDev.GetDBDevice(), filePath, fileName)
 	}
-
-	device := platDev.GetDBDevice()
-	tempFilePath, err := devices.PullAndroidSharedStorageFile(device, filePath, fileName)
 	defer os.Remove(tempFilePath)
 	if err != nil {
 		api.InternalError(c, fmt.Sprintf("Failed to pull file from path `%s` to a temporary directory", filePath))
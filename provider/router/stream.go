@@ -13,7 +13,10 @@ import (
 	"GADS/provider/logger"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"mime"
 	"mime/multipart"
@@ -22,6 +25,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"GADS/provider/devices"
 
@@ -52,8 +56,7 @@ func AndroidStreamProxy(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	u := url.URL{Scheme: "ws", Host: "localhost:" + rcDev.GetStreamPort(), Path: ""}
-	destConn, _, _, err := ws.DefaultDialer.Dial(context.Background(), u.String())
+	destConn, destReader, err := dialDeviceStream(context.Background(), rcDev.GetStreamPort())
 	if err != nil {
 		logger.ProviderLogger.LogError("AndroidStreamProxy", fmt.Sprintf("Failed connecting to device `%s` stream port - %s", udid, err))
 		return
@@ -63,7 +66,7 @@ func AndroidStreamProxy(c *gin.Context) {
 	// Read messages(jpegs) from the device streaming websocket server
 	// And send them to the provider websocket client
 	for {
-		data, code, err := wsutil.ReadServerData(destConn)
+		data, code, err := wsutil.ReadServerData(destReader)
 		if err != nil {
 			logger.ProviderLogger.LogError("AndroidStreamProxy", fmt.Sprintf("Failed reading data from device `%s` ws conn - %s", udid, err))
 			return
@@ -96,8 +99,7 @@ func AndroidStreamMJPEG(c *gin.Context) {
 		return
 	}
 
-	u := url.URL{Scheme: "ws", Host: "localhost:" + rcDev.GetStreamPort(), Path: ""}
-	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), u.String())
+	conn, reader, err := dialDeviceStream(context.Background(), rcDev.GetStreamPort())
 	if err != nil {
 		logger.ProviderLogger.LogError("AndroidStreamProxy", fmt.Sprintf("Failed connecting to device `%s` stream port - %s", udid, err))
 		return
@@ -107,7 +109,7 @@ func AndroidStreamMJPEG(c *gin.Context) {
 	// Read messages(jpegs) from the device streaming websocket server
 	// And send them to the provider websocket client
 	for {
-		data, _, err := wsutil.ReadServerData(conn)
+		data, _, err := wsutil.ReadServerData(reader)
 		if err != nil {
 			logger.ProviderLogger.LogError("AndroidStreamProxy", fmt.Sprintf("Failed reading data from device `%s` ws conn - %s", udid, err))
 			return
@@ -130,6 +132,23 @@ func AndroidStreamMJPEG(c *gin.Context) {
 	}
 }
 
+// dialDeviceStream connects to the JPEG websocket stream of the device.
+// Frames sent right after the handshake can be buffered with its response, so they are read through the returned reader.
+func dialDeviceStream(ctx context.Context, streamPort string) (net.Conn, io.ReadWriter, error) {
+	u := url.URL{Scheme: "ws", Host: "localhost:" + streamPort, Path: ""}
+	conn, br, _, err := ws.DefaultDialer.Dial(ctx, u.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	if br == nil {
+		return conn, conn, nil
+	}
+	return conn, struct {
+		io.Reader
+		io.Writer
+	}{br, conn}, nil
+}
+
 func findJPEGMarkers(data []byte) (int, int) {
 	start := bytes.Index(data, []byte{0xFF, 0xD8})
 	end := bytes.Index(data, []byte{0xFF, 0xD9})
@@ -461,3 +480,99 @@ func IosStreamProxyWDA(c *gin.Context) {
 		}
 	}
 }
+
+// tvStreamInterval is how often the TV stream captures the screen, the TVs cannot capture faster than about once a second
+const tvStreamInterval = time.Second
+
+// TVStreamMJPEG streams TV screenshots as MJPEG, the TVs have no video stream of their screen
+func TVStreamMJPEG(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, deviceFound := devices.DevManager.Get(udid)
+	if !deviceFound {
+		logger.ProviderLogger.LogError("TVStreamMJPEG", fmt.Sprintf("Device with UDID `%s` not found", udid))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	tvDev, isTVDevice := platDev.(devices.TVRemote)
+	if !isTVDevice {
+		logger.ProviderLogger.LogError("TVStreamMJPEG", fmt.Sprintf("Device `%s` is not a TV", udid))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	// TVs that can keep their connection open for the stream are not connected to again for every frame
+	capture := tvDev.Screenshot
+	if capturer, ok := platDev.(devices.TVScreenCapturer); ok {
+		screenCapture, err := capturer.OpenScreenCapture()
+		if err != nil {
+			logger.ProviderLogger.LogError("TVStreamMJPEG", fmt.Sprintf("Failed to connect to device `%s` for the screen capture - %s", udid, err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		defer screenCapture.Close()
+		capture = screenCapture.Screenshot
+	}
+
+	// Check the TV can capture its screen before starting the stream
+	frame, err := tvStreamFrame(capture)
+	if err != nil {
+		logger.ProviderLogger.LogError("TVStreamMJPEG", fmt.Sprintf("Failed to capture the screen of device `%s` - %s", udid, err))
+		if errors.Is(err, devices.ErrUnsupportedOperation) {
+			c.AbortWithStatus(http.StatusNotImplemented)
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", "multipart/x-mixed-replace; boundary=frame")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(tvStreamInterval)
+	defer ticker.Stop()
+	for {
+		if frame != nil {
+			if _, err := c.Writer.Write([]byte("\r\n--frame\r\nContent-Type: image/jpeg\r\n\r\n")); err != nil {
+				return
+			}
+			if _, err := c.Writer.Write(frame); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		frame, err = tvStreamFrame(capture)
+		if err != nil {
+			// Keep the stream open, the TV can fail to capture while it switches apps
+			logger.ProviderLogger.LogDebug("TVStreamMJPEG", fmt.Sprintf("Failed to capture the screen of device `%s` - %s", udid, err))
+			frame = nil
+		}
+	}
+}
+
+// tvStreamFrame captures the TV screen as JPEG
+func tvStreamFrame(capture func() ([]byte, error)) ([]byte, error) {
+	screenshot, err := capture()
+	if err != nil {
+		return nil, err
+	}
+	if http.DetectContentType(screenshot) == "image/jpeg" {
+		return screenshot, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the screenshot - %w", err)
+	}
+	var frame bytes.Buffer
+	if err := jpeg.Encode(&frame, img, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("failed to encode the screenshot - %w", err)
+	}
+	return frame.Bytes(), nil
+}
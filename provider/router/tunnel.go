@@ -0,0 +1,109 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"GADS/provider/devices"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+)
+
+// TunnelProxy relays a TCP connection to a device port or to a provider port of the device over the WebSocket.
+// After the upgrade the connection carries the raw TCP stream, the same as the ADB tunnel.
+func TunnelProxy(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	target := c.Query("target")
+	port, err := strconv.Atoi(c.Query("port"))
+	if err != nil {
+		platDev.GetLogger().LogError("tunnel", fmt.Sprintf("Invalid tunnel port `%s`", c.Query("port")))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	var targetConn net.Conn
+	switch target {
+	case "device":
+		targetConn, err = devices.DialDevicePort(platDev, port)
+	case "provider":
+		targetConn, err = devices.DialProviderPort(platDev, port)
+	default:
+		platDev.GetLogger().LogError("tunnel", fmt.Sprintf("Invalid tunnel target `%s`", target))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		platDev.GetLogger().LogError("tunnel", fmt.Sprintf("Failed to connect tunnel to %s port %d - %s", target, port, err))
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	wsConn, _, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	if err != nil {
+		targetConn.Close()
+		return
+	}
+
+	platDev.GetLogger().LogInfo("tunnel", fmt.Sprintf("Tunnel to %s port %d established", target, port))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(targetConn, wsConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(wsConn, targetConn)
+		done <- struct{}{}
+	}()
+
+	<-done
+	wsConn.Close()
+	targetConn.Close()
+	<-done
+
+	platDev.GetLogger().LogInfo("tunnel", fmt.Sprintf("Tunnel to %s port %d closed", target, port))
+}
+
+// UsbmuxProxy serves a usbmuxd connection restricted to the iOS device over the WebSocket.
+// After the upgrade the connection carries the raw usbmuxd protocol stream.
+func UsbmuxProxy(c *gin.Context) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	iosDev, ok := platDev.(*devices.IOSDevice)
+	if !ok {
+		platDev.GetLogger().LogError("usbmux", "usbmux tunnel is only available for iOS devices")
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	wsConn, _, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	if err != nil {
+		return
+	}
+
+	if err := iosDev.ServeUsbmux(wsConn); err != nil {
+		iosDev.GetLogger().LogWarn("usbmux", fmt.Sprintf("usbmux tunnel connection failed - %s", err))
+	}
+}
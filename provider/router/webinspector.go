@@ -0,0 +1,108 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package router
+
+import (
+	"fmt"
+	"strconv"
+
+	"GADS/common/api"
+	"GADS/provider/devices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+func getWebInspectorDevice(c *gin.Context) (*devices.IOSDevice, bool) {
+	udid := c.Param("udid")
+	platDev, ok := devices.DevManager.Get(udid)
+	if !ok {
+		api.NotFound(c, fmt.Sprintf("Device with UDID %s not found", udid))
+		return nil, false
+	}
+	iosDev, ok := platDev.(*devices.IOSDevice)
+	if !ok {
+		api.BadRequest(c, "Web Inspector is only available for iOS devices")
+		return nil, false
+	}
+	return iosDev, true
+}
+
+// DeviceWebInspectorPages lists the inspectable Safari tabs, WKWebViews and JSContexts on the device
+func DeviceWebInspectorPages(c *gin.Context) {
+	iosDev, ok := getWebInspectorDevice(c)
+	if !ok {
+		return
+	}
+
+	pages, err := iosDev.GetWebInspectorPages()
+	if err != nil {
+		iosDev.GetLogger().LogError("webinspector", fmt.Sprintf("Failed to list inspectable pages - %s", err))
+		api.InternalError(c, fmt.Sprintf("Failed to list inspectable pages - %s", err))
+		return
+	}
+
+	api.OK(c, "", pages)
+}
+
+// DeviceWebInspectorSocket bridges a WebSocket to the page, each text message is a WebKit Inspector protocol message
+func DeviceWebInspectorSocket(c *gin.Context) {
+	iosDev, ok := getWebInspectorDevice(c)
+	if !ok {
+		return
+	}
+	pageID, err := strconv.Atoi(c.Param("page"))
+	if err != nil {
+		api.BadRequest(c, "Invalid page ID")
+		return
+	}
+	appID := c.Param("app")
+
+	session, err := iosDev.OpenWebInspectorSession(appID, pageID)
+	if err != nil {
+		iosDev.GetLogger().LogError("webinspector", fmt.Sprintf("Failed to attach to page %d of `%s` - %s", pageID, appID, err))
+		api.InternalError(c, fmt.Sprintf("Failed to attach to page - %s", err))
+		return
+	}
+	defer session.Close()
+
+	conn, _, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	iosDev.GetLogger().LogInfo("webinspector", fmt.Sprintf("Attached Web Inspector to page %d of `%s`", pageID, appID))
+
+	go func() {
+		defer session.Close()
+		for {
+			data, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+			if err := session.Send(data); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data := <-session.Messages():
+			if err := wsutil.WriteServerText(conn, data); err != nil {
+				return
+			}
+		case <-session.Done():
+			iosDev.GetLogger().LogInfo("webinspector", fmt.Sprintf("Detached Web Inspector from page %d of `%s`", pageID, appID))
+			return
+		}
+	}
+}
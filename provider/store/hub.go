@@ -0,0 +1,206 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"GADS/common/mockdevice"
+	"GADS/common/models"
+)
+
+// HubStore reads and writes the provider data through the hub API, so the provider does not need MongoDB access.
+// Requests are authenticated with the provider token and fail over between the hub addresses.
+type HubStore struct {
+	hubs   []string
+	token  string
+	client *http.Client
+	// mockDevices is the `--mock-devices` count reported to the hub so it accepts the mock devices the provider registers
+	mockDevices int
+}
+
+func NewHubStore(hubs []string, token string, mockDevices int) *HubStore {
+	return &HubStore{
+		hubs:        hubs,
+		token:       token,
+		mockDevices: mockDevices,
+		client: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// HubStatusError is returned when the hub responds with an unexpected status
+type HubStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HubStatusError) Error() string {
+	return fmt.Sprintf("hub responded with status %d - %s", e.StatusCode, e.Message)
+}
+
+// request sends the request to the first hub that responds
+func (s *HubStore) request(method, path string, body any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed marshaling request body - %w", err)
+		}
+	}
+
+	var lastErr error
+	for _, hub := range s.hubs {
+		req, err := http.NewRequest(method, hub+"/provider-api"+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		if s.mockDevices > 0 {
+			req.Header.Set(mockdevice.CountHeader, strconv.Itoa(s.mockDevices))
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			var apiResp models.APIResponse[any]
+			json.NewDecoder(resp.Body).Decode(&apiResp)
+			return nil, &HubStatusError{StatusCode: resp.StatusCode, Message: apiResp.Message}
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("no hub is reachable - %w", lastErr)
+}
+
+// call sends the request and decodes the result of the hub response into `result` if it is not nil
+func (s *HubStore) call(method, path string, body, result any) error {
+	resp, err := s.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+	apiResp := models.APIResponse[any]{Result: result}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("failed decoding hub response - %w", err)
+	}
+	return nil
+}
+
+func (s *HubStore) GetProvider(providerNickname string) (models.Provider, error) {
+	var provider models.Provider
+	if err := s.call(http.MethodGet, "/config", nil, &provider); err != nil {
+		return provider, err
+	}
+	if provider.Nickname != providerNickname {
+		return models.Provider{}, fmt.Errorf("the provider token belongs to provider `%s`, not `%s`", provider.Nickname, providerNickname)
+	}
+	return provider, nil
+}
+
+func (s *HubStore) GetProviderDevices(providerNickname string) ([]models.DBDevice, error) {
+	var devices []models.DBDevice
+	err := s.call(http.MethodGet, "/devices", nil, &devices)
+	return devices, err
+}
+
+func (s *HubStore) AddOrUpdateDevice(device *models.DBDevice) error {
+	return s.call(http.MethodPut, "/devices", device, nil)
+}
+
+func (s *HubStore) GetDefaultWorkspace() (models.Workspace, error) {
+	var workspace models.Workspace
+	err := s.call(http.MethodGet, "/workspaces/default", nil, &workspace)
+	return workspace, err
+}
+
+func (s *HubStore) GetWorkspaceByID(workspaceId string) (models.Workspace, error) {
+	var workspace models.Workspace
+	err := s.call(http.MethodGet, "/workspaces/"+url.PathEscape(workspaceId), nil, &workspace)
+	return workspace, err
+}
+
+func (s *HubStore) GetTURNConfig() (models.TURNConfig, error) {
+	var turnConfig models.TURNConfig
+	err := s.call(http.MethodGet, "/turn-config", nil, &turnConfig)
+	return turnConfig, err
+}
+
+func (s *HubStore) GetGlobalStreamSettings() (models.StreamSettings, error) {
+	var settings models.StreamSettings
+	err := s.call(http.MethodGet, "/stream-settings", nil, &settings)
+	return settings, err
+}
+
+func (s *HubStore) GetDeviceStreamSettings(udid string) (models.DeviceStreamSettings, error) {
+	var settings models.DeviceStreamSettings
+	err := s.call(http.MethodGet, "/devices/"+url.PathEscape(udid)+"/stream-settings", nil, &settings)
+	return settings, err
+}
+
+func (s *HubStore) UpdateDeviceStreamSettings(udid string, settings models.DeviceStreamSettings) error {
+	return s.call(http.MethodPut, "/devices/"+url.PathEscape(udid)+"/stream-settings", settings, nil)
+}
+
+func (s *HubStore) DownloadFile(fileName, downloadPath string) error {
+	return s.download("/files?name="+url.QueryEscape(fileName), filepath.Join(downloadPath, fileName))
+}
+
+func (s *HubStore) DownloadFileByID(fileID, downloadPath, localName string) error {
+	return s.download("/files?id="+url.QueryEscape(fileID), filepath.Join(downloadPath, localName))
+}
+
+func (s *HubStore) AddAppiumLog(collectionName string, log models.AppiumPluginLog) error {
+	return s.call(http.MethodPost, "/devices/"+url.PathEscape(collectionName)+"/appium-logs", log, nil)
+}
+
+// download writes the file served by the hub to filePath, replacing any stale local copy
+func (s *HubStore) download(path, filePath string) error {
+	resp, err := s.request(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	tempPath := filePath + ".download"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file with path `%s` - %w", tempPath, err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to download file to `%s` - %w", tempPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, filePath)
+}
@@ -0,0 +1,41 @@
+/*
+ * This file is part of GADS.
+ *
+ * Copyright (c) 2022-2025 Nikola Shabanov
+ *
+ * This source code is licensed under the GNU Affero General Public License v3.0.
+ * You may obtain a copy of the license at https://www.gnu.org/licenses/agpl-3.0.html
+ */
+
+// Package store gives the provider access to the shared GADS data - the provider and device configuration,
+// settings and files. It is read either directly from MongoDB or through the hub API with a provider token.
+package store
+
+import (
+	"GADS/common/db"
+	"GADS/common/models"
+)
+
+// Store is implemented by *db.MongoStore and by HubStore
+type Store interface {
+	GetProvider(providerNickname string) (models.Provider, error)
+	GetProviderDevices(providerNickname string) ([]models.DBDevice, error)
+	AddOrUpdateDevice(device *models.DBDevice) error
+	GetDefaultWorkspace() (models.Workspace, error)
+	GetWorkspaceByID(workspaceId string) (models.Workspace, error)
+	GetTURNConfig() (models.TURNConfig, error)
+	GetGlobalStreamSettings() (models.StreamSettings, error)
+	GetDeviceStreamSettings(udid string) (models.DeviceStreamSettings, error)
+	UpdateDeviceStreamSettings(udid string, settings models.DeviceStreamSettings) error
+	DownloadFile(fileName, downloadPath string) error
+	DownloadFileByID(fileID, downloadPath, localName string) error
+	AddAppiumLog(collectionName string, log models.AppiumPluginLog) error
+}
+
+var (
+	_ Store = (*db.MongoStore)(nil)
+	_ Store = (*HubStore)(nil)
+)
+
+// GlobalStore is the store the provider was started with
+var GlobalStore Store